@@ -1,18 +1,52 @@
 package gocassa
 
 import (
+	"bytes"
+	"context"
+	"fmt"
+	"hash/fnv"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
 	"time"
+
+	"github.com/gocql/gocql"
 )
 
+// defaultMaxBuckets is the number of buckets a bucketWalkOp queries when
+// MaxBuckets isn't set
+const defaultMaxBuckets = 100
+
+// shardFieldName is the partition key field holding the shard of a row in
+// sharded recipes
+const shardFieldName = "shard"
+
+// shardOf determines the shard of a row from its id
+func shardOf(id interface{}, shards int) int {
+	h := fnv.New32a()
+	fmt.Fprint(h, id)
+	return int(h.Sum32() % uint32(shards))
+}
+
+// allShards returns the values of the shard field for the given number of
+// shards
+func allShards(shards int) []interface{} {
+	values := make([]interface{}, shards)
+	for i := range values {
+		values[i] = i
+	}
+	return values
+}
+
 func bucket(t time.Time, step time.Duration) time.Time {
-	return bucketIter{
-		v:    t,
-		step: step}.Bucket()
+	return durationBuckets(step).Bucket(t)
 }
 
 type bucketIter struct {
 	v         time.Time
-	step      time.Duration
+	bucketer  Bucketer
+	shards    int
 	field     string
 	invariant Filter
 }
@@ -22,32 +56,414 @@ func (b bucketIter) String() string {
 }
 
 func (b bucketIter) Bucket() time.Time {
-	step := b.step
-	if step < time.Second {
-		step = time.Second
-	}
-	secs := b.v.Unix()
-	return time.Unix((secs - secs%int64(step/time.Second)), 0)
+	return b.bucketer.Bucket(b.v)
 }
 
 func (b bucketIter) Next() Buckets {
 	return bucketIter{
-		v:         b.v.Add(b.step),
-		step:      b.step,
+		v:         b.bucketer.Next(b.Bucket()),
+		bucketer:  b.bucketer,
+		shards:    b.shards,
 		invariant: b.invariant,
 		field:     b.field}
 }
 
 func (b bucketIter) Prev() Buckets {
 	return bucketIter{
-		v:         b.v.Add(-b.step),
-		step:      b.step,
+		v:         b.bucketer.Prev(b.Bucket()),
+		bucketer:  b.bucketer,
+		shards:    b.shards,
 		invariant: b.invariant,
 		field:     b.field}
 }
 
 func (b bucketIter) Filter() Filter {
-	rels := b.invariant.Relations()
+	rels := append([]Relation{}, b.invariant.Relations()...)
 	rels = append(rels, Eq(b.field, b.Bucket()))
+	if b.shards > 0 {
+		rels = append(rels, In(shardFieldName, allShards(b.shards)...))
+	}
 	return b.invariant.Table().Where(rels...)
 }
+
+// bucketReader reads the rows of a single bucket. The rows of sharded recipes
+// are read with a query per shard and merged in time order, as a single query
+// would return them ordered by shard (and apply its limit accordingly). Rows
+// with the same time are merged in the order of their id column
+type bucketReader struct {
+	relations []Relation // applied on top of the relations of the bucket
+	shards    int
+	timeField string // the clustering column the rows are first ordered by
+	idField   string
+	rowTime   func(row map[string]interface{}) time.Time
+}
+
+func (r bucketReader) read(b Buckets, opts Options, descending bool, sliceType reflect.Type) (reflect.Value, error) {
+	f := b.Filter()
+	rels := []Relation{}
+	restricted := map[string]bool{}
+	for _, rel := range f.Relations() {
+		if rel.Field() != shardFieldName {
+			rels = append(rels, rel)
+			restricted[rel.Field()] = true
+		}
+	}
+	for _, rel := range r.relations {
+		if !restricted[rel.Field()] && rel.Field() != shardFieldName {
+			rels = append(rels, rel)
+		}
+	}
+
+	if r.shards == 0 {
+		page := reflect.New(sliceType)
+		err := f.Table().Where(rels...).Read(page.Interface()).WithOptions(opts).Run()
+		return page.Elem(), err
+	}
+
+	rows := reflect.MakeSlice(sliceType, 0, 0)
+	for shard := 0; shard < r.shards; shard++ {
+		page := reflect.New(sliceType)
+		shardRels := append(append([]Relation{}, rels...), Eq(shardFieldName, shard))
+		if err := f.Table().Where(shardRels...).Read(page.Interface()).WithOptions(opts).Run(); err != nil {
+			return rows, err
+		}
+		rows = reflect.AppendSlice(rows, page.Elem())
+	}
+
+	times := make([]time.Time, rows.Len())
+	ids := make([][]byte, rows.Len())
+	order := make([]int, rows.Len())
+	for i := range times {
+		row, ok := toMap(rows.Index(i).Interface())
+		if !ok {
+			return rows, fmt.Errorf("can't merge shards of rows of type %v", sliceType.Elem())
+		}
+		times[i] = r.rowTime(row)
+		ids[i] = clusteringBytes(row[r.idField])
+		order[i] = i
+	}
+	idDescending := r.idDescending(f.Table(), descending)
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := order[i], order[j]
+		if !times[a].Equal(times[b]) {
+			return times[a].After(times[b]) == descending
+		}
+		if idDescending {
+			return bytes.Compare(ids[a], ids[b]) > 0
+		}
+		return bytes.Compare(ids[a], ids[b]) < 0
+	})
+
+	merged := reflect.MakeSlice(sliceType, 0, rows.Len())
+	for _, i := range order {
+		merged = reflect.Append(merged, rows.Index(i))
+	}
+	if opts.Limit > 0 && merged.Len() > opts.Limit {
+		merged = merged.Slice(0, opts.Limit)
+	}
+	return merged, nil
+}
+
+// idDescending tells whether a query returning the rows in descending
+// (or ascending) time order returns the rows with the same time in descending
+// order of their id. That's the clustering order of the id column of the
+// table, reversed along with the time column when the query reverses it
+func (r bucketReader) idDescending(tbl Table, descending bool) bool {
+	t, ok := tbl.(optionsTable)
+	if !ok {
+		return descending
+	}
+	declared := map[string]ColumnDirection{}
+	for _, col := range t.tableOptions().ClusteringOrder {
+		declared[strings.ToLower(col.Column)] = col.Direction
+	}
+	timeDir, idDir := declared[strings.ToLower(r.timeField)], declared[strings.ToLower(r.idField)]
+	return (timeDir == idDir) == descending
+}
+
+// clusteringBytes returns the value marshalled as Cassandra compares it when
+// ordering the rows of a partition
+func clusteringBytes(v interface{}) []byte {
+	if v == nil {
+		return nil
+	}
+	b, err := gocql.Marshal(&gocqlTypeInfo{proto: 0x03, typ: cassaType(v)}, v)
+	if err != nil {
+		return nil
+	}
+	return b
+}
+
+// bucketWalkOp reads up to n rows by querying one bucket at a time, starting
+// with the bucket it's given and walking backwards (when the order is DESC) or
+// forwards, until n rows are read or MaxBuckets buckets have been queried
+type bucketWalkOp struct {
+	start     Buckets
+	timeField string
+	reader    bucketReader
+	order     ColumnDirection
+	n         int
+	result    interface{}
+	options   Options
+}
+
+func newBucketWalkOp(start Buckets, timeField string, reader bucketReader, order ColumnDirection, n int, result interface{}) Op {
+	return bucketWalkOp{
+		start:     start,
+		timeField: timeField,
+		reader:    reader,
+		order:     order,
+		n:         n,
+		result:    result,
+	}
+}
+
+func (o bucketWalkOp) readOptions(limit int) Options {
+	return o.options.Merge(Options{
+		Limit:           limit,
+		ClusteringOrder: []ClusteringOrderColumn{{Column: o.timeField, Direction: o.order}},
+	})
+}
+
+func (o bucketWalkOp) Run() error {
+	ptr := reflect.ValueOf(o.result)
+	if ptr.Kind() != reflect.Ptr || ptr.IsNil() || ptr.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("can only read into a pointer to a slice, not %T", o.result)
+	}
+	sliceType := ptr.Elem().Type()
+
+	opts := o.options
+	if t, ok := o.start.Filter().Table().(optionsTable); ok {
+		opts = t.tableOptions().Merge(o.options)
+	}
+	maxBuckets := opts.MaxBuckets
+	if maxBuckets == 0 {
+		maxBuckets = defaultMaxBuckets
+	}
+
+	rows := reflect.MakeSlice(sliceType, 0, o.n)
+	b := o.start
+	for i := 0; i < maxBuckets && rows.Len() < o.n; i++ {
+		page, err := o.reader.read(b, o.readOptions(o.n-rows.Len()), o.order == DESC, sliceType)
+		if err != nil {
+			return err
+		}
+		rows = reflect.AppendSlice(rows, page)
+
+		if o.order == DESC {
+			b = b.Prev()
+		} else {
+			b = b.Next()
+		}
+	}
+
+	ptr.Elem().Set(rows)
+	return nil
+}
+
+func (o bucketWalkOp) RunWithContext(ctx context.Context) error {
+	return o.WithOptions(Options{Context: ctx}).Run()
+}
+
+func (o bucketWalkOp) RunAtomically() error {
+	return o.Run()
+}
+
+func (o bucketWalkOp) RunLoggedBatchWithContext(ctx context.Context) error {
+	return o.RunWithContext(ctx)
+}
+
+func (o bucketWalkOp) RunAtomicallyWithContext(ctx context.Context) error {
+	return o.RunWithContext(ctx)
+}
+
+func (o bucketWalkOp) Add(additions ...Op) Op {
+	return multiOpOf(o.firstRead(), o).Add(additions...)
+}
+
+func (o bucketWalkOp) WithOptions(opts Options) Op {
+	o.options = o.options.Merge(opts)
+	return o
+}
+
+func (o bucketWalkOp) Options() Options {
+	return o.options
+}
+
+func (o bucketWalkOp) Preflight() error {
+	return nil
+}
+
+// firstRead returns the read of the first bucket (or the first shard of it)
+func (o bucketWalkOp) firstRead() Op {
+	f := o.start.Filter()
+	rels := append([]Relation{}, f.Relations()...)
+	rels = append(rels, o.reader.relations...)
+	return f.Table().
+		Where(rels...).
+		Read(o.result).
+		WithOptions(o.readOptions(o.n))
+}
+
+// GenerateStatement generates the statement reading the first bucket
+func (o bucketWalkOp) GenerateStatement() Statement {
+	return o.firstRead().GenerateStatement()
+}
+
+func (o bucketWalkOp) QueryExecutor() QueryExecutor {
+	return o.firstRead().QueryExecutor()
+}
+
+// multiOpOf returns a multi op holding op, of the kind the table op reads
+// with runs, so that it can be combined with other ops of the table
+func multiOpOf(read, op Op) Op {
+	if _, ok := read.(mockOp); ok {
+		return mockMultiOp{op}
+	}
+	return multiOp{op}
+}
+
+// optionsTable is implemented by tables which can report their own options
+type optionsTable interface {
+	tableOptions() Options
+}
+
+// bucketListOp lists the rows of the buckets from start up to end. By default
+// it does so with a single query restricting the bucket field with IN, but
+// when BucketConcurrency is set, or the recipe is sharded, it queries each
+// bucket separately and merges the results
+type bucketListOp struct {
+	filter    Filter // filter for a single IN query covering all buckets
+	start     Buckets
+	end       time.Time
+	timeField string
+	reader    bucketReader
+	result    interface{}
+	options   Options
+}
+
+func newBucketListOp(filter Filter, start Buckets, end time.Time, timeField string, reader bucketReader, result interface{}) Op {
+	reader.relations = filter.Relations()
+	return bucketListOp{
+		filter:    filter,
+		start:     start,
+		end:       end,
+		timeField: timeField,
+		reader:    reader,
+		result:    result,
+	}
+}
+
+func (o bucketListOp) inOp() Op {
+	return o.filter.Read(o.result).WithOptions(o.options)
+}
+
+func (o bucketListOp) Run() error {
+	opts := o.options
+	if t, ok := o.filter.Table().(optionsTable); ok {
+		opts = t.tableOptions().Merge(o.options)
+	}
+	concurrency := opts.BucketConcurrency
+	if concurrency <= 0 {
+		if o.reader.shards == 0 {
+			return o.inOp().Run()
+		}
+		concurrency = 1
+	}
+
+	ptr := reflect.ValueOf(o.result)
+	if ptr.Kind() != reflect.Ptr || ptr.IsNil() || ptr.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("can only read into a pointer to a slice, not %T", o.result)
+	}
+	sliceType := ptr.Elem().Type()
+
+	buckets := []Buckets{}
+	for b := o.start; b.Bucket().Before(o.end); b = b.Next() {
+		buckets = append(buckets, b)
+	}
+	// Buckets are in ascending order, the rows of a table with a descending
+	// clustering order need to be merged starting with the latest bucket
+	descending := false
+	for _, col := range opts.ClusteringOrder {
+		if strings.EqualFold(col.Column, o.timeField) && col.Direction == DESC {
+			descending = true
+			for i, j := 0, len(buckets)-1; i < j; i, j = i+1, j-1 {
+				buckets[i], buckets[j] = buckets[j], buckets[i]
+			}
+		}
+	}
+
+	readOpts := o.options.Merge(Options{Limit: opts.Limit})
+	pages := make([]reflect.Value, len(buckets))
+	errs := make([]error, len(buckets))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, b := range buckets {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, b Buckets) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			pages[i], errs[i] = o.reader.read(b, readOpts, descending, sliceType)
+		}(i, b)
+	}
+	wg.Wait()
+
+	rows := reflect.MakeSlice(sliceType, 0, 0)
+	for i, page := range pages {
+		if errs[i] != nil {
+			return errs[i]
+		}
+		rows = reflect.AppendSlice(rows, page)
+	}
+	if opts.Limit > 0 && rows.Len() > opts.Limit {
+		rows = rows.Slice(0, opts.Limit)
+	}
+
+	ptr.Elem().Set(rows)
+	return nil
+}
+
+func (o bucketListOp) RunWithContext(ctx context.Context) error {
+	return o.WithOptions(Options{Context: ctx}).Run()
+}
+
+func (o bucketListOp) RunAtomically() error {
+	return o.Run()
+}
+
+func (o bucketListOp) RunLoggedBatchWithContext(ctx context.Context) error {
+	return o.RunWithContext(ctx)
+}
+
+func (o bucketListOp) RunAtomicallyWithContext(ctx context.Context) error {
+	return o.RunWithContext(ctx)
+}
+
+func (o bucketListOp) Add(additions ...Op) Op {
+	return multiOpOf(o.inOp(), o).Add(additions...)
+}
+
+func (o bucketListOp) WithOptions(opts Options) Op {
+	o.options = o.options.Merge(opts)
+	return o
+}
+
+func (o bucketListOp) Options() Options {
+	return o.options
+}
+
+func (o bucketListOp) Preflight() error {
+	return nil
+}
+
+// GenerateStatement generates the single IN query covering all buckets
+func (o bucketListOp) GenerateStatement() Statement {
+	return o.inOp().GenerateStatement()
+}
+
+func (o bucketListOp) QueryExecutor() QueryExecutor {
+	return o.inOp().QueryExecutor()
+}
@@ -0,0 +1,201 @@
+package gocassa
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Bucketer determines which bucket (and so which partition) a point in time
+// belongs to in the time series and flake series recipes. Buckets are
+// identified by the time they start at.
+type Bucketer interface {
+	// Bucket returns the start of the bucket t belongs to
+	Bucket(t time.Time) time.Time
+	// Next returns the start of the bucket following the one starting at bucket
+	Next(bucket time.Time) time.Time
+	// Prev returns the start of the bucket preceding the one starting at bucket
+	Prev(bucket time.Time) time.Time
+	// String describes the bucketing scheme. It is used as part of the table
+	// name, so it may only contain letters, digits and underscores
+	String() string
+}
+
+type fixedBuckets struct {
+	size time.Duration
+}
+
+// FixedBuckets returns a Bucketer with buckets of a fixed duration, aligned to
+// the Unix epoch. The size is rounded down to the millisecond, the precision
+// of Cassandra timestamps, and must be at least a millisecond
+func FixedBuckets(size time.Duration) Bucketer {
+	size = size.Truncate(time.Millisecond)
+	if size <= 0 {
+		panic(fmt.Sprintf("bucket size must be at least a millisecond, got %v", size))
+	}
+	return fixedBuckets{size: size}
+}
+
+func (b fixedBuckets) Bucket(t time.Time) time.Time {
+	step := int64(b.size / time.Millisecond)
+	ms := t.UnixNano() / int64(time.Millisecond)
+	if t.UnixNano() < 0 && t.UnixNano()%int64(time.Millisecond) != 0 {
+		ms--
+	}
+	offset := ms % step
+	if offset < 0 {
+		offset += step
+	}
+	return time.Unix(0, (ms-offset)*int64(time.Millisecond))
+}
+
+func (b fixedBuckets) Next(bucket time.Time) time.Time {
+	return b.Bucket(bucket).Add(b.size)
+}
+
+func (b fixedBuckets) Prev(bucket time.Time) time.Time {
+	return b.Bucket(bucket).Add(-b.size)
+}
+
+func (b fixedBuckets) String() string {
+	return b.size.String()
+}
+
+// CalendarUnit is the length of the buckets of CalendarBuckets
+type CalendarUnit int
+
+const (
+	// CalendarDay buckets start at midnight
+	CalendarDay CalendarUnit = iota
+	// CalendarWeek buckets start at midnight on Monday, as ISO weeks do
+	CalendarWeek
+	// CalendarMonth buckets start at midnight on the first day of the month
+	CalendarMonth
+)
+
+func (u CalendarUnit) String() string {
+	switch u {
+	case CalendarDay:
+		return "day"
+	case CalendarWeek:
+		return "week"
+	case CalendarMonth:
+		return "month"
+	default:
+		return ""
+	}
+}
+
+type calendarBuckets struct {
+	unit CalendarUnit
+	loc  *time.Location
+}
+
+// CalendarBuckets returns a Bucketer with buckets lining up with days, weeks
+// or months in the given location. If loc is nil, UTC is used
+func CalendarBuckets(unit CalendarUnit, loc *time.Location) Bucketer {
+	if loc == nil {
+		loc = time.UTC
+	}
+	return calendarBuckets{unit: unit, loc: loc}
+}
+
+func (b calendarBuckets) Bucket(t time.Time) time.Time {
+	t = t.In(b.loc)
+	switch b.unit {
+	case CalendarWeek:
+		daysSinceMonday := (int(t.Weekday()) + 6) % 7
+		return time.Date(t.Year(), t.Month(), t.Day()-daysSinceMonday, 0, 0, 0, 0, b.loc)
+	case CalendarMonth:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, b.loc)
+	default:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, b.loc)
+	}
+}
+
+func (b calendarBuckets) step(bucket time.Time, n int) time.Time {
+	// Dates are normalised by time.Date, so stepping by calendar units
+	// rather than durations keeps buckets aligned across DST changes
+	bucket = b.Bucket(bucket)
+	switch b.unit {
+	case CalendarWeek:
+		return time.Date(bucket.Year(), bucket.Month(), bucket.Day()+7*n, 0, 0, 0, 0, b.loc)
+	case CalendarMonth:
+		return time.Date(bucket.Year(), bucket.Month()+time.Month(n), 1, 0, 0, 0, 0, b.loc)
+	default:
+		return time.Date(bucket.Year(), bucket.Month(), bucket.Day()+n, 0, 0, 0, 0, b.loc)
+	}
+}
+
+func (b calendarBuckets) Next(bucket time.Time) time.Time {
+	return b.step(bucket, 1)
+}
+
+func (b calendarBuckets) Prev(bucket time.Time) time.Time {
+	return b.step(bucket, -1)
+}
+
+func (b calendarBuckets) String() string {
+	loc := strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, b.loc.String())
+	return fmt.Sprintf("%s_%s", b.unit, loc)
+}
+
+// BucketFuncs is a Bucketer made up of functions, for custom bucketing
+// schemes. Name is used as the String of the Bucketer
+type BucketFuncs struct {
+	Name       string
+	BucketFunc func(t time.Time) time.Time
+	NextFunc   func(bucket time.Time) time.Time
+	PrevFunc   func(bucket time.Time) time.Time
+}
+
+func (b BucketFuncs) Bucket(t time.Time) time.Time {
+	return b.BucketFunc(t)
+}
+
+func (b BucketFuncs) Next(bucket time.Time) time.Time {
+	return b.NextFunc(bucket)
+}
+
+func (b BucketFuncs) Prev(bucket time.Time) time.Time {
+	return b.PrevFunc(bucket)
+}
+
+func (b BucketFuncs) String() string {
+	return b.Name
+}
+
+// durationBuckets is the Bucketer of recipes created with a bucket size.
+// Buckets are whole seconds aligned to the Unix epoch, with sizes under a
+// second treated as a second, and the size is named as time.Duration formats
+// it, so that the tables and bucket values of existing recipes are kept
+type durationBuckets time.Duration
+
+func (b durationBuckets) step() time.Duration {
+	if step := time.Duration(b).Truncate(time.Second); step > 0 {
+		return step
+	}
+	return time.Second
+}
+
+func (b durationBuckets) Bucket(t time.Time) time.Time {
+	secs := t.Unix()
+	return time.Unix(secs-secs%int64(b.step()/time.Second), 0)
+}
+
+func (b durationBuckets) Next(bucket time.Time) time.Time {
+	return b.Bucket(bucket).Add(b.step())
+}
+
+func (b durationBuckets) Prev(bucket time.Time) time.Time {
+	return b.Bucket(bucket).Add(-b.step())
+}
+
+func (b durationBuckets) String() string {
+	return time.Duration(b).String()
+}
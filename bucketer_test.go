@@ -0,0 +1,95 @@
+package gocassa
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFixedBuckets(t *testing.T) {
+	b := FixedBuckets(time.Minute)
+	tm := time.Date(2015, 4, 1, 15, 41, 25, 500, time.UTC)
+	assert.True(t, time.Date(2015, 4, 1, 15, 41, 0, 0, time.UTC).Equal(b.Bucket(tm)))
+	assert.True(t, time.Date(2015, 4, 1, 15, 42, 0, 0, time.UTC).Equal(b.Next(b.Bucket(tm))))
+	assert.True(t, time.Date(2015, 4, 1, 15, 40, 0, 0, time.UTC).Equal(b.Prev(b.Bucket(tm))))
+	assert.Equal(t, "1m0s", b.String())
+
+	// Buckets under a second are no longer clamped
+	b = FixedBuckets(250 * time.Millisecond)
+	assert.True(t, time.Date(2015, 4, 1, 15, 41, 25, int(250*time.Millisecond), time.UTC).
+		Equal(b.Bucket(tm.Add(300*time.Millisecond))))
+
+	assert.Panics(t, func() { FixedBuckets(time.Microsecond) })
+
+	// Recipes created with a bucket size keep their buckets and table names
+	b = durationBuckets(500 * time.Millisecond)
+	assert.True(t, time.Date(2015, 4, 1, 15, 41, 25, 0, time.UTC).Equal(b.Bucket(tm.Add(300*time.Millisecond))))
+	assert.True(t, time.Date(2015, 4, 1, 15, 41, 26, 0, time.UTC).Equal(b.Next(b.Bucket(tm))))
+	assert.Equal(t, "500ms", b.String())
+	b = durationBuckets(time.Hour)
+	assert.True(t, time.Date(2015, 4, 1, 15, 0, 0, 0, time.UTC).Equal(b.Bucket(tm)))
+	assert.Equal(t, "1h0m0s", b.String())
+
+	ks := NewMockKeySpace()
+	assert.Equal(t, "points_timeSeries_Time_Id_500ms",
+		ks.TimeSeriesTable("points", "Time", "Id", 500*time.Millisecond, point{}).Name())
+}
+
+func TestCalendarBuckets(t *testing.T) {
+	loc := time.FixedZone("UTC+2", 2*60*60)
+	// 23:30 UTC on Sunday 5th April is already Monday 6th in UTC+2
+	tm := time.Date(2015, 4, 5, 23, 30, 0, 0, time.UTC)
+
+	day := CalendarBuckets(CalendarDay, loc)
+	assert.True(t, time.Date(2015, 4, 6, 0, 0, 0, 0, loc).Equal(day.Bucket(tm)))
+	assert.True(t, time.Date(2015, 4, 7, 0, 0, 0, 0, loc).Equal(day.Next(day.Bucket(tm))))
+	assert.True(t, time.Date(2015, 4, 5, 0, 0, 0, 0, loc).Equal(day.Prev(day.Bucket(tm))))
+	assert.Equal(t, "day_UTC_2", day.String())
+
+	week := CalendarBuckets(CalendarWeek, nil)
+	assert.True(t, time.Date(2015, 3, 30, 0, 0, 0, 0, time.UTC).Equal(week.Bucket(tm)))
+	assert.True(t, time.Date(2015, 4, 6, 0, 0, 0, 0, time.UTC).Equal(week.Next(week.Bucket(tm))))
+	assert.Equal(t, "week_UTC", week.String())
+
+	month := CalendarBuckets(CalendarMonth, loc)
+	assert.True(t, time.Date(2015, 4, 1, 0, 0, 0, 0, loc).Equal(month.Bucket(tm)))
+	assert.True(t, time.Date(2015, 5, 1, 0, 0, 0, 0, loc).Equal(month.Next(month.Bucket(tm))))
+	assert.True(t, time.Date(2015, 3, 1, 0, 0, 0, 0, loc).Equal(month.Prev(month.Bucket(tm))))
+	assert.True(t, time.Date(2014, 12, 1, 0, 0, 0, 0, loc).Equal(month.Prev(time.Date(2015, 1, 1, 0, 0, 0, 0, loc))))
+}
+
+func TestCalendarBucketsAcrossDST(t *testing.T) {
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		t.Skip("no time zone database available")
+	}
+
+	// Clocks go forward on the 29th of March 2015, so that day is 23 hours long
+	day := CalendarBuckets(CalendarDay, london)
+	b := day.Bucket(time.Date(2015, 3, 29, 12, 0, 0, 0, london))
+	assert.True(t, time.Date(2015, 3, 30, 0, 0, 0, 0, london).Equal(day.Next(b)))
+	assert.Equal(t, 23*time.Hour, day.Next(b).Sub(b))
+}
+
+func TestBucketsIterFollowsBucketer(t *testing.T) {
+	ks := NewMockKeySpace()
+	tbl := ks.TimeSeriesTableWithBucketer("points", "Time", "Id", CalendarBuckets(CalendarMonth, time.UTC), point{})
+	for i, month := range []time.Month{time.January, time.February, time.March} {
+		p := point{Time: time.Date(2015, month, 15, 0, 0, 0, 0, time.UTC), Id: i}
+		assert.NoError(t, tbl.Set(p).Run())
+	}
+
+	b := tbl.Buckets(time.Date(2015, 2, 20, 0, 0, 0, 0, time.UTC))
+	assert.True(t, time.Date(2015, 2, 1, 0, 0, 0, 0, time.UTC).Equal(b.Bucket()))
+	assert.True(t, time.Date(2015, 3, 1, 0, 0, 0, 0, time.UTC).Equal(b.Next().Bucket()))
+	assert.True(t, time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC).Equal(b.Prev().Bucket()))
+
+	var ps []point
+	assert.NoError(t, tbl.List(time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2015, 3, 1, 0, 0, 0, 0, time.UTC), &ps).Run())
+	assert.Len(t, ps, 2)
+
+	assert.NoError(t, b.Filter().Read(&ps).Run())
+	assert.Len(t, ps, 1)
+	assert.Equal(t, 1, ps[0].Id)
+}
@@ -0,0 +1,132 @@
+package gocassa
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells the time. Anything depending on the current time takes a Clock,
+// so that time can be controlled in tests
+type Clock interface {
+	Now() time.Time
+}
+
+// ClockFunc is a Clock made up of a function
+type ClockFunc func() time.Time
+
+func (f ClockFunc) Now() time.Time {
+	return f()
+}
+
+// TimerClock is a Clock which can also tell when time has passed on it.
+// Follow waits between polls through the Clock option when it's a TimerClock
+type TimerClock interface {
+	Clock
+	// After returns a channel which receives the time once d has passed
+	After(d time.Duration) <-chan time.Time
+}
+
+// systemClock is used wherever a Clock isn't given
+var systemClock Clock = ClockFunc(time.Now)
+
+// clockAfter waits for d to pass on clock, or in real time if the clock
+// can't tell
+func clockAfter(clock Clock, d time.Duration) <-chan time.Time {
+	if t, ok := clock.(TimerClock); ok {
+		return t.After(d)
+	}
+	return time.After(d)
+}
+
+// ManualClock is a Clock which only moves when it's told to, for tests. It's
+// a TimerClock: timers fire when the clock is moved past them
+type ManualClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []manualTimer
+	added  *sync.Cond // signalled when a timer is added
+}
+
+type manualTimer struct {
+	at time.Time
+	c  chan time.Time
+}
+
+// NewManualClock returns a ManualClock set to now
+func NewManualClock(now time.Time) *ManualClock {
+	return &ManualClock{now: now}
+}
+
+func (c *ManualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Set sets the time of the clock, firing the timers it passes
+func (c *ManualClock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = now
+	c.fire()
+}
+
+// Add moves the clock on by d, firing the timers it passes
+func (c *ManualClock) Add(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	c.fire()
+}
+
+func (c *ManualClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+	c.timers = append(c.timers, manualTimer{at: c.now.Add(d), c: ch})
+	c.cond().Broadcast()
+	return ch
+}
+
+// BlockUntilTimers waits until at least n timers are waiting for the clock to
+// move, so that a test can move it once whatever it's testing is waiting
+func (c *ManualClock) BlockUntilTimers(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.timers) < n {
+		c.cond().Wait()
+	}
+}
+
+func (c *ManualClock) cond() *sync.Cond {
+	if c.added == nil {
+		c.added = sync.NewCond(&c.mu)
+	}
+	return c.added
+}
+
+// fire fires the timers which are due. It's called with the lock held
+func (c *ManualClock) fire() {
+	pending := c.timers[:0]
+	for _, t := range c.timers {
+		if t.at.After(c.now) {
+			pending = append(pending, t)
+		} else {
+			t.c <- c.now
+		}
+	}
+	c.timers = pending
+}
+
+// tableClock returns the clock set on a table with Options.Clock, or the
+// system clock if there isn't one
+func tableClock(tbl Table) Clock {
+	if t, ok := tbl.(optionsTable); ok && t.tableOptions().Clock != nil {
+		return t.tableOptions().Clock
+	}
+	return systemClock
+}
@@ -0,0 +1,1042 @@
+package gocassa
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/gocql/gocql"
+)
+
+// This file parses the subset of CQL which gocassa generates, along with the
+// queries gocql runs when connecting, so the mock keyspaces can run CQL
+
+// cqlSyntaxError is returned for queries which can't be parsed. Its code is
+// the one Cassandra returns for syntax errors
+type cqlSyntaxError struct {
+	message string
+}
+
+func (e cqlSyntaxError) Code() int {
+	return 0x2000
+}
+
+func (e cqlSyntaxError) Message() string {
+	return e.message
+}
+
+func (e cqlSyntaxError) Error() string {
+	return e.message
+}
+
+type cqlTokenKind int
+
+const (
+	cqlEOF cqlTokenKind = iota
+	cqlIdent
+	cqlQuotedIdent
+	cqlString
+	cqlNumber
+	cqlPunct
+)
+
+type cqlToken struct {
+	kind cqlTokenKind
+	text string
+}
+
+func (t cqlToken) String() string {
+	switch t.kind {
+	case cqlEOF:
+		return "end of input"
+	case cqlString:
+		return "'" + t.text + "'"
+	case cqlQuotedIdent:
+		return `"` + t.text + `"`
+	default:
+		return t.text
+	}
+}
+
+func tokenizeCQL(query string) ([]cqlToken, error) {
+	var tokens []cqlToken
+	rs := []rune(query)
+	for i := 0; i < len(rs); {
+		c := rs[i]
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '-' && i+1 < len(rs) && rs[i+1] == '-':
+			for i < len(rs) && rs[i] != '\n' {
+				i++
+			}
+		case unicode.IsLetter(c) || c == '_':
+			start := i
+			for i < len(rs) && (unicode.IsLetter(rs[i]) || unicode.IsDigit(rs[i]) || rs[i] == '_') {
+				i++
+			}
+			tokens = append(tokens, cqlToken{cqlIdent, string(rs[start:i])})
+		case unicode.IsDigit(c):
+			start := i
+			for i < len(rs) && (unicode.IsDigit(rs[i]) || rs[i] == '.' || rs[i] == 'e' || rs[i] == 'E' ||
+				((rs[i] == '-' || rs[i] == '+') && (rs[i-1] == 'e' || rs[i-1] == 'E'))) {
+				i++
+			}
+			tokens = append(tokens, cqlToken{cqlNumber, string(rs[start:i])})
+		case c == '\'' || c == '"':
+			kind := cqlString
+			if c == '"' {
+				kind = cqlQuotedIdent
+			}
+			var sb strings.Builder
+			i++
+			for {
+				if i >= len(rs) {
+					return nil, cqlSyntaxError{fmt.Sprintf("unterminated %c in %q", c, query)}
+				}
+				if rs[i] == c {
+					if i+1 < len(rs) && rs[i+1] == c {
+						sb.WriteRune(c)
+						i += 2
+						continue
+					}
+					i++
+					break
+				}
+				sb.WriteRune(rs[i])
+				i++
+			}
+			tokens = append(tokens, cqlToken{kind, sb.String()})
+		case strings.ContainsRune("<>!", c) && i+1 < len(rs) && rs[i+1] == '=':
+			tokens = append(tokens, cqlToken{cqlPunct, string(rs[i : i+2])})
+			i += 2
+		case strings.ContainsRune("(),.;?*=<>[]{}:+-", c):
+			tokens = append(tokens, cqlToken{cqlPunct, string(c)})
+			i++
+		default:
+			return nil, cqlSyntaxError{fmt.Sprintf("unexpected character %q in %q", c, query)}
+		}
+	}
+	return append(tokens, cqlToken{kind: cqlEOF}), nil
+}
+
+// cqlTerm is a value in a statement, either a bind marker or a literal
+type cqlTerm struct {
+	// bind is the index of the bind marker, or -1 for a literal
+	bind  int
+	value interface{}
+	// list holds the terms of a parenthesised list, as used by IN
+	list []cqlTerm
+}
+
+type cqlRelation struct {
+	column string
+	cmp    Comparator
+	term   cqlTerm
+}
+
+type cqlSelect struct {
+	keyspace, table string
+	json            bool
+	columns         []string // nil selects every column
+	where           []cqlRelation
+	order           []ClusteringOrderColumn
+	limit           *cqlTerm
+	allowFiltering  bool
+}
+
+type cqlInsert struct {
+	keyspace, table string
+	columns         []string
+	values          []cqlTerm
+	json            *cqlTerm
+	jsonDefault     JSONDefault
+	ttl             *cqlTerm
+}
+
+type cqlAssignmentOp int
+
+const (
+	cqlAssign        cqlAssignmentOp = iota // c = ?
+	cqlAssignAdd                            // c = c + ?
+	cqlAssignPrepend                        // c = ? + c
+	cqlAssignRemove                         // c = c - ?
+	cqlAssignElement                        // c[?] = ?
+)
+
+type cqlAssignment struct {
+	column string
+	op     cqlAssignmentOp
+	index  cqlTerm
+	term   cqlTerm
+}
+
+type cqlUpdate struct {
+	keyspace, table string
+	ttl             *cqlTerm
+	set             []cqlAssignment
+	where           []cqlRelation
+}
+
+type cqlDeletion struct {
+	column  string
+	element *cqlTerm
+}
+
+type cqlDelete struct {
+	keyspace, table string
+	columns         []cqlDeletion
+	where           []cqlRelation
+}
+
+type cqlColumnDef struct {
+	name string
+	typ  gocql.TypeInfo
+}
+
+type cqlCreateTable struct {
+	keyspace, table string
+	ifNotExists     bool
+	columns         []cqlColumnDef
+	keys            Keys
+	order           []ClusteringOrderColumn
+}
+
+type cqlCreateKeyspace struct {
+	keyspace    string
+	ifNotExists bool
+}
+
+type cqlDropTable struct {
+	keyspace, table string
+	ifExists        bool
+}
+
+type cqlDropKeyspace struct {
+	keyspace string
+	ifExists bool
+}
+
+type cqlTruncate struct {
+	keyspace, table string
+}
+
+type cqlUse struct {
+	keyspace string
+}
+
+type cqlParser struct {
+	query  string
+	tokens []cqlToken
+	pos    int
+	binds  int
+}
+
+// parseCQL parses a single statement, returning one of the cql* statement
+// types and the number of bind markers in it
+func parseCQL(query string) (interface{}, int, error) {
+	tokens, err := tokenizeCQL(query)
+	if err != nil {
+		return nil, 0, err
+	}
+	p := &cqlParser{query: query, tokens: tokens}
+	stmt, err := p.statement()
+	if err != nil {
+		return nil, 0, err
+	}
+	p.acceptPunct(";")
+	if p.peek().kind != cqlEOF {
+		return nil, 0, p.errorf("unexpected %s", p.peek())
+	}
+	return stmt, p.binds, nil
+}
+
+func (p *cqlParser) errorf(format string, args ...interface{}) error {
+	return cqlSyntaxError{fmt.Sprintf("line 1: %s in %q", fmt.Sprintf(format, args...), p.query)}
+}
+
+func (p *cqlParser) peek() cqlToken {
+	return p.tokens[p.pos]
+}
+
+func (p *cqlParser) next() cqlToken {
+	t := p.tokens[p.pos]
+	if t.kind != cqlEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *cqlParser) isKeyword(t cqlToken, keyword string) bool {
+	return t.kind == cqlIdent && strings.EqualFold(t.text, keyword)
+}
+
+// accept consumes the keywords if they come next
+func (p *cqlParser) accept(keywords ...string) bool {
+	for i, keyword := range keywords {
+		if p.pos+i >= len(p.tokens) || !p.isKeyword(p.tokens[p.pos+i], keyword) {
+			return false
+		}
+	}
+	p.pos += len(keywords)
+	return true
+}
+
+func (p *cqlParser) expect(keywords ...string) error {
+	for _, keyword := range keywords {
+		if !p.accept(keyword) {
+			return p.errorf("expected %s but found %s", keyword, p.peek())
+		}
+	}
+	return nil
+}
+
+func (p *cqlParser) acceptPunct(punct string) bool {
+	if t := p.peek(); t.kind == cqlPunct && t.text == punct {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *cqlParser) expectPunct(punct string) error {
+	if !p.acceptPunct(punct) {
+		return p.errorf("expected %s but found %s", punct, p.peek())
+	}
+	return nil
+}
+
+// identifier returns the next identifier. Unquoted identifiers are case
+// insensitive, so they're lowercased
+func (p *cqlParser) identifier() (string, error) {
+	t := p.next()
+	switch t.kind {
+	case cqlIdent:
+		return strings.ToLower(t.text), nil
+	case cqlQuotedIdent:
+		return t.text, nil
+	}
+	return "", p.errorf("expected an identifier but found %s", t)
+}
+
+// tableName returns the keyspace and table of an optionally qualified name
+func (p *cqlParser) tableName() (string, string, error) {
+	name, err := p.identifier()
+	if err != nil {
+		return "", "", err
+	}
+	if !p.acceptPunct(".") {
+		return "", name, nil
+	}
+	table, err := p.identifier()
+	return name, table, err
+}
+
+func (p *cqlParser) statement() (interface{}, error) {
+	switch {
+	case p.accept("SELECT"):
+		return p.selectStatement()
+	case p.accept("INSERT"):
+		return p.insertStatement()
+	case p.accept("UPDATE"):
+		return p.updateStatement()
+	case p.accept("DELETE"):
+		return p.deleteStatement()
+	case p.accept("CREATE", "TABLE"), p.accept("CREATE", "COLUMNFAMILY"):
+		return p.createTableStatement()
+	case p.accept("CREATE", "KEYSPACE"):
+		return p.createKeyspaceStatement()
+	case p.accept("DROP", "TABLE"), p.accept("DROP", "COLUMNFAMILY"):
+		stmt := &cqlDropTable{ifExists: p.accept("IF", "EXISTS")}
+		var err error
+		stmt.keyspace, stmt.table, err = p.tableName()
+		return stmt, err
+	case p.accept("DROP", "KEYSPACE"):
+		stmt := &cqlDropKeyspace{ifExists: p.accept("IF", "EXISTS")}
+		var err error
+		stmt.keyspace, err = p.identifier()
+		return stmt, err
+	case p.accept("TRUNCATE"):
+		p.accept("TABLE")
+		stmt := &cqlTruncate{}
+		var err error
+		stmt.keyspace, stmt.table, err = p.tableName()
+		return stmt, err
+	case p.accept("USE"):
+		keyspace, err := p.identifier()
+		return &cqlUse{keyspace: keyspace}, err
+	}
+	return nil, p.errorf("unsupported statement starting with %s", p.peek())
+}
+
+func (p *cqlParser) term() (cqlTerm, error) {
+	t := p.next()
+	switch {
+	case t.kind == cqlPunct && t.text == "?":
+		p.binds++
+		return cqlTerm{bind: p.binds - 1}, nil
+	case t.kind == cqlPunct && t.text == "(":
+		list := cqlTerm{bind: -1, list: []cqlTerm{}}
+		if p.acceptPunct(")") {
+			return list, nil
+		}
+		for {
+			term, err := p.term()
+			if err != nil {
+				return cqlTerm{}, err
+			}
+			list.list = append(list.list, term)
+			if p.acceptPunct(")") {
+				return list, nil
+			}
+			if err := p.expectPunct(","); err != nil {
+				return cqlTerm{}, err
+			}
+		}
+	case t.kind == cqlPunct && (t.text == "{" || t.text == "["):
+		closing := "}"
+		if t.text == "[" {
+			closing = "]"
+		}
+		value, err := p.collectionLiteral(closing)
+		return cqlTerm{bind: -1, value: value}, err
+	case t.kind == cqlPunct && t.text == "-" && p.peek().kind == cqlNumber:
+		term, err := p.number(p.next())
+		if err != nil {
+			return cqlTerm{}, err
+		}
+		switch v := term.value.(type) {
+		case int64:
+			term.value = -v
+		case float64:
+			term.value = -v
+		}
+		return term, nil
+	case t.kind == cqlNumber:
+		return p.number(t)
+	case t.kind == cqlString:
+		return cqlTerm{bind: -1, value: t.text}, nil
+	case p.isKeyword(t, "true"), p.isKeyword(t, "false"):
+		return cqlTerm{bind: -1, value: strings.EqualFold(t.text, "true")}, nil
+	case p.isKeyword(t, "null"):
+		return cqlTerm{bind: -1}, nil
+	}
+	return cqlTerm{}, p.errorf("expected a value but found %s", t)
+}
+
+func (p *cqlParser) number(t cqlToken) (cqlTerm, error) {
+	if i, err := strconv.ParseInt(t.text, 10, 64); err == nil {
+		return cqlTerm{bind: -1, value: i}, nil
+	}
+	f, err := strconv.ParseFloat(t.text, 64)
+	if err != nil {
+		return cqlTerm{}, p.errorf("invalid number %s", t.text)
+	}
+	return cqlTerm{bind: -1, value: f}, nil
+}
+
+// collectionLiteral parses a list, set or map literal after its opening
+// bracket or brace. Lists and sets are returned as []interface{}, and maps as
+// map[interface{}]interface{}
+func (p *cqlParser) collectionLiteral(closing string) (interface{}, error) {
+	var (
+		list []interface{}
+		m    map[interface{}]interface{}
+	)
+	if p.acceptPunct(closing) {
+		if closing == "}" {
+			return map[interface{}]interface{}{}, nil
+		}
+		return []interface{}{}, nil
+	}
+	for {
+		t := p.peek()
+		element, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		if element.bind >= 0 || element.list != nil {
+			return nil, p.errorf("unexpected %s in collection literal", t)
+		}
+		if closing == "}" && (m != nil || list == nil && p.peek().text == ":") {
+			if err := p.expectPunct(":"); err != nil {
+				return nil, err
+			}
+			value, err := p.term()
+			if err != nil {
+				return nil, err
+			}
+			if m == nil {
+				m = map[interface{}]interface{}{}
+			}
+			m[element.value] = value.value
+		} else {
+			list = append(list, element.value)
+		}
+		if p.acceptPunct(closing) {
+			if m != nil {
+				return m, nil
+			}
+			return list, nil
+		}
+		if err := p.expectPunct(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *cqlParser) where() ([]cqlRelation, error) {
+	if !p.accept("WHERE") {
+		return nil, nil
+	}
+	var relations []cqlRelation
+	for {
+		column, err := p.identifier()
+		if err != nil {
+			return nil, err
+		}
+		r := cqlRelation{column: column}
+		t := p.next()
+		switch {
+		case p.isKeyword(t, "IN"):
+			r.cmp = CmpIn
+		case t.kind == cqlPunct && t.text == "=":
+			r.cmp = CmpEquality
+		case t.kind == cqlPunct && t.text == ">":
+			r.cmp = CmpGreaterThan
+		case t.kind == cqlPunct && t.text == ">=":
+			r.cmp = CmpGreaterThanOrEquals
+		case t.kind == cqlPunct && t.text == "<":
+			r.cmp = CmpLesserThan
+		case t.kind == cqlPunct && t.text == "<=":
+			r.cmp = CmpLesserThanOrEquals
+		default:
+			return nil, p.errorf("unsupported operator %s", t)
+		}
+		if r.term, err = p.term(); err != nil {
+			return nil, err
+		}
+		if r.term.list != nil && r.cmp != CmpIn {
+			return nil, p.errorf("unexpected list of values for %s", column)
+		}
+		relations = append(relations, r)
+		if !p.accept("AND") {
+			return relations, nil
+		}
+	}
+}
+
+// using parses the USING clause of a write, returning its TTL
+func (p *cqlParser) using() (*cqlTerm, error) {
+	if !p.accept("USING") {
+		return nil, nil
+	}
+	var ttl *cqlTerm
+	for {
+		switch {
+		case p.accept("TTL"):
+			term, err := p.term()
+			if err != nil {
+				return nil, err
+			}
+			ttl = &term
+		case p.accept("TIMESTAMP"):
+			if _, err := p.term(); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, p.errorf("expected TTL or TIMESTAMP but found %s", p.peek())
+		}
+		if !p.accept("AND") {
+			return ttl, nil
+		}
+	}
+}
+
+func (p *cqlParser) selectStatement() (interface{}, error) {
+	stmt := &cqlSelect{json: p.accept("JSON")}
+	if !p.acceptPunct("*") {
+		for {
+			column, err := p.identifier()
+			if err != nil {
+				return nil, err
+			}
+			stmt.columns = append(stmt.columns, column)
+			if !p.acceptPunct(",") {
+				break
+			}
+		}
+	}
+	var err error
+	if err = p.expect("FROM"); err != nil {
+		return nil, err
+	}
+	if stmt.keyspace, stmt.table, err = p.tableName(); err != nil {
+		return nil, err
+	}
+	if stmt.where, err = p.where(); err != nil {
+		return nil, err
+	}
+	if p.accept("ORDER", "BY") {
+		for {
+			column, err := p.identifier()
+			if err != nil {
+				return nil, err
+			}
+			order := ClusteringOrderColumn{Column: column, Direction: ASC}
+			if p.accept("DESC") {
+				order.Direction = DESC
+			} else {
+				p.accept("ASC")
+			}
+			stmt.order = append(stmt.order, order)
+			if !p.acceptPunct(",") {
+				break
+			}
+		}
+	}
+	if p.accept("LIMIT") {
+		limit, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		stmt.limit = &limit
+	}
+	stmt.allowFiltering = p.accept("ALLOW", "FILTERING")
+	return stmt, nil
+}
+
+func (p *cqlParser) insertStatement() (interface{}, error) {
+	stmt := &cqlInsert{}
+	var err error
+	if err = p.expect("INTO"); err != nil {
+		return nil, err
+	}
+	if stmt.keyspace, stmt.table, err = p.tableName(); err != nil {
+		return nil, err
+	}
+	if p.accept("JSON") {
+		document, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		stmt.json = &document
+		if p.accept("DEFAULT") {
+			switch {
+			case p.accept("UNSET"):
+				stmt.jsonDefault = JSONDefaultUnset
+			case p.accept("NULL"):
+			default:
+				return nil, p.errorf("expected NULL or UNSET but found %s", p.peek())
+			}
+		}
+	} else {
+		if err := p.expectPunct("("); err != nil {
+			return nil, err
+		}
+		for {
+			column, err := p.identifier()
+			if err != nil {
+				return nil, err
+			}
+			stmt.columns = append(stmt.columns, column)
+			if p.acceptPunct(")") {
+				break
+			}
+			if err := p.expectPunct(","); err != nil {
+				return nil, err
+			}
+		}
+		if err := p.expect("VALUES"); err != nil {
+			return nil, err
+		}
+		values, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		if values.list == nil || len(values.list) != len(stmt.columns) {
+			return nil, p.errorf("expected %d values", len(stmt.columns))
+		}
+		stmt.values = values.list
+	}
+	if p.accept("IF", "NOT", "EXISTS") {
+		return nil, p.errorf("lightweight transactions aren't supported")
+	}
+	stmt.ttl, err = p.using()
+	return stmt, err
+}
+
+func (p *cqlParser) updateStatement() (interface{}, error) {
+	stmt := &cqlUpdate{}
+	var err error
+	if stmt.keyspace, stmt.table, err = p.tableName(); err != nil {
+		return nil, err
+	}
+	if stmt.ttl, err = p.using(); err != nil {
+		return nil, err
+	}
+	if err := p.expect("SET"); err != nil {
+		return nil, err
+	}
+	for {
+		a, err := p.assignment()
+		if err != nil {
+			return nil, err
+		}
+		stmt.set = append(stmt.set, a)
+		if !p.acceptPunct(",") {
+			break
+		}
+	}
+	if stmt.where, err = p.where(); err != nil {
+		return nil, err
+	}
+	if p.accept("IF") {
+		return nil, p.errorf("lightweight transactions aren't supported")
+	}
+	return stmt, nil
+}
+
+func (p *cqlParser) assignment() (cqlAssignment, error) {
+	column, err := p.identifier()
+	if err != nil {
+		return cqlAssignment{}, err
+	}
+	a := cqlAssignment{column: column}
+	if p.acceptPunct("[") {
+		a.op = cqlAssignElement
+		if a.index, err = p.term(); err != nil {
+			return a, err
+		}
+		if err := p.expectPunct("]"); err != nil {
+			return a, err
+		}
+		if err := p.expectPunct("="); err != nil {
+			return a, err
+		}
+		a.term, err = p.term()
+		return a, err
+	}
+	if err := p.expectPunct("="); err != nil {
+		return a, err
+	}
+
+	// c = c + ? and c = c - ?
+	if t := p.peek(); (t.kind == cqlIdent || t.kind == cqlQuotedIdent) && !p.isKeyword(t, "true") &&
+		!p.isKeyword(t, "false") && !p.isKeyword(t, "null") {
+		other, err := p.identifier()
+		if err != nil {
+			return a, err
+		}
+		if other != column {
+			return a, p.errorf("only expressions of the form %s = %s + ? are supported", column, column)
+		}
+		switch {
+		case p.acceptPunct("+"):
+			a.op = cqlAssignAdd
+		case p.acceptPunct("-"):
+			a.op = cqlAssignRemove
+		default:
+			return a, p.errorf("expected + or - but found %s", p.peek())
+		}
+		a.term, err = p.term()
+		return a, err
+	}
+
+	if a.term, err = p.term(); err != nil {
+		return a, err
+	}
+	// c = ? + c
+	if p.acceptPunct("+") {
+		other, err := p.identifier()
+		if err != nil {
+			return a, err
+		}
+		if other != column {
+			return a, p.errorf("only expressions of the form %s = ? + %s are supported", column, column)
+		}
+		a.op = cqlAssignPrepend
+	}
+	return a, nil
+}
+
+func (p *cqlParser) deleteStatement() (interface{}, error) {
+	stmt := &cqlDelete{}
+	for !p.accept("FROM") {
+		column, err := p.identifier()
+		if err != nil {
+			return nil, err
+		}
+		d := cqlDeletion{column: column}
+		if p.acceptPunct("[") {
+			element, err := p.term()
+			if err != nil {
+				return nil, err
+			}
+			d.element = &element
+			if err := p.expectPunct("]"); err != nil {
+				return nil, err
+			}
+		}
+		stmt.columns = append(stmt.columns, d)
+		if !p.acceptPunct(",") {
+			if err := p.expect("FROM"); err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+	var err error
+	if stmt.keyspace, stmt.table, err = p.tableName(); err != nil {
+		return nil, err
+	}
+	if _, err := p.using(); err != nil {
+		return nil, err
+	}
+	if stmt.where, err = p.where(); err != nil {
+		return nil, err
+	}
+	if p.accept("IF") {
+		return nil, p.errorf("lightweight transactions aren't supported")
+	}
+	return stmt, nil
+}
+
+func (p *cqlParser) identifierList() ([]string, error) {
+	if err := p.expectPunct("("); err != nil {
+		return nil, err
+	}
+	var names []string
+	for !p.acceptPunct(")") {
+		if len(names) > 0 {
+			if err := p.expectPunct(","); err != nil {
+				return nil, err
+			}
+		}
+		name, err := p.identifier()
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+func (p *cqlParser) createTableStatement() (interface{}, error) {
+	stmt := &cqlCreateTable{ifNotExists: p.accept("IF", "NOT", "EXISTS")}
+	var err error
+	if stmt.keyspace, stmt.table, err = p.tableName(); err != nil {
+		return nil, err
+	}
+	if err := p.expectPunct("("); err != nil {
+		return nil, err
+	}
+	for {
+		if p.accept("PRIMARY", "KEY") {
+			if err := p.expectPunct("("); err != nil {
+				return nil, err
+			}
+			if p.peek().kind == cqlPunct && p.peek().text == "(" {
+				if stmt.keys.PartitionKeys, err = p.identifierList(); err != nil {
+					return nil, err
+				}
+			} else {
+				name, err := p.identifier()
+				if err != nil {
+					return nil, err
+				}
+				stmt.keys.PartitionKeys = []string{name}
+			}
+			for !p.acceptPunct(")") {
+				if err := p.expectPunct(","); err != nil {
+					return nil, err
+				}
+				name, err := p.identifier()
+				if err != nil {
+					return nil, err
+				}
+				stmt.keys.ClusteringColumns = append(stmt.keys.ClusteringColumns, name)
+			}
+		} else {
+			name, err := p.identifier()
+			if err != nil {
+				return nil, err
+			}
+			typ, err := p.cqlType()
+			if err != nil {
+				return nil, err
+			}
+			stmt.columns = append(stmt.columns, cqlColumnDef{name: name, typ: typ})
+			p.accept("STATIC")
+			if p.accept("PRIMARY", "KEY") {
+				stmt.keys.PartitionKeys = []string{name}
+			}
+		}
+		if p.acceptPunct(")") {
+			break
+		}
+		if err := p.expectPunct(","); err != nil {
+			return nil, err
+		}
+	}
+	if len(stmt.keys.PartitionKeys) == 0 {
+		return nil, p.errorf("no PRIMARY KEY specified for table %s", stmt.table)
+	}
+
+	if p.accept("WITH") {
+		for {
+			switch {
+			case p.accept("CLUSTERING", "ORDER", "BY"):
+				if err := p.expectPunct("("); err != nil {
+					return nil, err
+				}
+				for !p.acceptPunct(")") {
+					if len(stmt.order) > 0 {
+						if err := p.expectPunct(","); err != nil {
+							return nil, err
+						}
+					}
+					name, err := p.identifier()
+					if err != nil {
+						return nil, err
+					}
+					order := ClusteringOrderColumn{Column: name, Direction: ASC}
+					if p.accept("DESC") {
+						order.Direction = DESC
+					} else if err := p.expect("ASC"); err != nil {
+						return nil, err
+					}
+					stmt.order = append(stmt.order, order)
+				}
+			case p.accept("COMPACT", "STORAGE"):
+			default:
+				if _, err := p.identifier(); err != nil {
+					return nil, err
+				}
+				if err := p.expectPunct("="); err != nil {
+					return nil, err
+				}
+				if _, err := p.term(); err != nil {
+					return nil, err
+				}
+			}
+			if !p.accept("AND") {
+				break
+			}
+		}
+	}
+	return stmt, nil
+}
+
+func (p *cqlParser) createKeyspaceStatement() (interface{}, error) {
+	stmt := &cqlCreateKeyspace{ifNotExists: p.accept("IF", "NOT", "EXISTS")}
+	var err error
+	if stmt.keyspace, err = p.identifier(); err != nil {
+		return nil, err
+	}
+	if err := p.expect("WITH"); err != nil {
+		return nil, err
+	}
+	for {
+		if _, err := p.identifier(); err != nil {
+			return nil, err
+		}
+		if err := p.expectPunct("="); err != nil {
+			return nil, err
+		}
+		if _, err := p.term(); err != nil {
+			return nil, err
+		}
+		if !p.accept("AND") {
+			return stmt, nil
+		}
+	}
+}
+
+var cqlNativeTypes = map[string]gocql.Type{
+	"ascii":     gocql.TypeAscii,
+	"bigint":    gocql.TypeBigInt,
+	"blob":      gocql.TypeBlob,
+	"boolean":   gocql.TypeBoolean,
+	"counter":   gocql.TypeCounter,
+	"date":      gocql.TypeDate,
+	"decimal":   gocql.TypeDecimal,
+	"double":    gocql.TypeDouble,
+	"float":     gocql.TypeFloat,
+	"inet":      gocql.TypeInet,
+	"int":       gocql.TypeInt,
+	"smallint":  gocql.TypeSmallInt,
+	"text":      gocql.TypeVarchar,
+	"time":      gocql.TypeTime,
+	"timestamp": gocql.TypeTimestamp,
+	"timeuuid":  gocql.TypeTimeUUID,
+	"tinyint":   gocql.TypeTinyInt,
+	"uuid":      gocql.TypeUUID,
+	"varchar":   gocql.TypeVarchar,
+	"varint":    gocql.TypeVarint,
+}
+
+// cqlType parses a column type, as written in a CREATE TABLE statement
+func (p *cqlParser) cqlType() (gocql.TypeInfo, error) {
+	name, err := p.identifier()
+	if err != nil {
+		return nil, err
+	}
+	if typ, ok := cqlNativeTypes[name]; ok {
+		return gocql.NewNativeType(cqlProtoVersion, typ, ""), nil
+	}
+
+	var collection gocql.CollectionType
+	switch name {
+	case "frozen":
+		if err := p.expectPunct("<"); err != nil {
+			return nil, err
+		}
+		typ, err := p.cqlType()
+		if err != nil {
+			return nil, err
+		}
+		return typ, p.expectPunct(">")
+	case "list":
+		collection.NativeType = gocql.NewNativeType(cqlProtoVersion, gocql.TypeList, "")
+	case "set":
+		collection.NativeType = gocql.NewNativeType(cqlProtoVersion, gocql.TypeSet, "")
+	case "map":
+		collection.NativeType = gocql.NewNativeType(cqlProtoVersion, gocql.TypeMap, "")
+	default:
+		return nil, p.errorf("unsupported type %s", name)
+	}
+	if err := p.expectPunct("<"); err != nil {
+		return nil, err
+	}
+	if name == "map" {
+		if collection.Key, err = p.cqlType(); err != nil {
+			return nil, err
+		}
+		if err := p.expectPunct(","); err != nil {
+			return nil, err
+		}
+	}
+	if collection.Elem, err = p.cqlType(); err != nil {
+		return nil, err
+	}
+	return collection, p.expectPunct(">")
+}
+
+// parseCQLType parses a type such as "map<varchar, int>"
+func parseCQLType(s string) (gocql.TypeInfo, error) {
+	tokens, err := tokenizeCQL(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &cqlParser{query: s, tokens: tokens}
+	typ, err := p.cqlType()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != cqlEOF {
+		return nil, p.errorf("unexpected %s", p.peek())
+	}
+	return typ, nil
+}
@@ -0,0 +1,461 @@
+package gocassa
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// EntityView describes a view of the rows of an EntityTable: a copy of every
+// row, keyed so that rows can be listed in another way
+type EntityView struct {
+	fieldToIndexBy string
+	timeField      string
+	bucketer       Bucketer
+}
+
+// MultimapView is a view listing rows by the value of a field, as a
+// MultimapTable does
+func MultimapView(fieldToIndexBy string) EntityView {
+	return EntityView{fieldToIndexBy: fieldToIndexBy}
+}
+
+// TimeSeriesView is a view listing rows by the time in a field, as a
+// TimeSeriesTable does
+func TimeSeriesView(timeField string, bucketer Bucketer) EntityView {
+	return EntityView{timeField: timeField, bucketer: bucketer}
+}
+
+// entityView is a table holding a view of the rows of an EntityTable
+type entityView interface {
+	// keyFields returns the fields of a row which determine where the view
+	// keeps it
+	keyFields() []string
+	// checkKeys returns an error if the row can't be kept in the view
+	checkKeys(row map[string]interface{}) error
+	set(row map[string]interface{}) Op
+	update(row, m map[string]interface{}) Op
+	delete(row map[string]interface{}) Op
+	withOptions(o Options) entityView
+	TableChanger
+}
+
+type multimapView struct{ *multimapT }
+
+func (v multimapView) keyFields() []string {
+	return []string{v.fieldToIndexBy, v.idField}
+}
+
+func (v multimapView) checkKeys(row map[string]interface{}) error {
+	return checkKeyFields(row, v.keyFields())
+}
+
+func (v multimapView) set(row map[string]interface{}) Op {
+	return v.Set(row)
+}
+
+func (v multimapView) update(row, m map[string]interface{}) Op {
+	return v.Update(row[v.fieldToIndexBy], row[v.idField], m)
+}
+
+func (v multimapView) delete(row map[string]interface{}) Op {
+	return v.Delete(row[v.fieldToIndexBy], row[v.idField])
+}
+
+func (v multimapView) withOptions(o Options) entityView {
+	return multimapView{v.WithOptions(o).(*multimapT)}
+}
+
+type timeSeriesView struct{ *timeSeriesT }
+
+func (v timeSeriesView) keyFields() []string {
+	return []string{v.timeField, v.idField}
+}
+
+func (v timeSeriesView) checkKeys(row map[string]interface{}) error {
+	if err := checkKeyFields(row, v.keyFields()); err != nil {
+		return err
+	}
+	if _, ok := row[v.timeField].(time.Time); !ok {
+		return fmt.Errorf("the time field (%s) of a view is a %T, not a time.Time", v.timeField, row[v.timeField])
+	}
+	return nil
+}
+
+func (v timeSeriesView) set(row map[string]interface{}) Op {
+	return v.Set(row)
+}
+
+func (v timeSeriesView) update(row, m map[string]interface{}) Op {
+	t, _ := row[v.timeField].(time.Time)
+	return v.Update(t, row[v.idField], m)
+}
+
+func (v timeSeriesView) delete(row map[string]interface{}) Op {
+	t, _ := row[v.timeField].(time.Time)
+	return v.Delete(t, row[v.idField])
+}
+
+func (v timeSeriesView) withOptions(o Options) entityView {
+	return timeSeriesView{v.WithOptions(o).(*timeSeriesT)}
+}
+
+// checkKeyFields returns an error if one of the fields is missing from the row
+func checkKeyFields(row map[string]interface{}, fields []string) error {
+	for _, f := range fields {
+		if row[f] == nil {
+			return fmt.Errorf("the key field (%s) of a view is missing", f)
+		}
+	}
+	return nil
+}
+
+type entityT struct {
+	primary *mapT
+	idField string
+	rowType reflect.Type
+	views   []entityView
+}
+
+func (e *entityT) tables() []TableChanger {
+	tables := []TableChanger{e.primary}
+	for _, v := range e.views {
+		tables = append(tables, v)
+	}
+	return tables
+}
+
+func (e *entityT) Create() error {
+	for _, t := range e.tables() {
+		if err := t.Create(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *entityT) CreateIfNotExist() error {
+	for _, t := range e.tables() {
+		if err := t.CreateIfNotExist(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *entityT) Recreate() error {
+	for _, t := range e.tables() {
+		if err := t.Recreate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *entityT) Map() MapTable {
+	return e.primary
+}
+
+func (e *entityT) Multimap(fieldToIndexBy string) MultimapTable {
+	for _, v := range e.views {
+		if v, ok := v.(multimapView); ok && v.fieldToIndexBy == fieldToIndexBy {
+			return v.multimapT
+		}
+	}
+	return nil
+}
+
+func (e *entityT) TimeSeries(timeField string) TimeSeriesTable {
+	for _, v := range e.views {
+		if v, ok := v.(timeSeriesView); ok && v.timeField == timeField {
+			return v.timeSeriesT
+		}
+	}
+	return nil
+}
+
+func (e *entityT) Read(id, pointer interface{}) Op {
+	return e.primary.Read(id, pointer)
+}
+
+// readRow reads the current row with the given id, returning false if there
+// isn't one
+func (e *entityT) readRow(ctx context.Context, id interface{}) (map[string]interface{}, bool, error) {
+	ptr := reflect.New(e.rowType)
+	err := e.primary.Read(id, ptr.Interface()).RunWithContext(ctx)
+	if _, ok := err.(RowNotFoundError); ok {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+	row, _ := toMap(ptr.Elem().Interface())
+	return row, true, nil
+}
+
+func (e *entityT) Set(v interface{}) Op {
+	row, ok := toSetMap(v)
+	if !ok {
+		panic("Can't set: not able to convert")
+	}
+	// The views are keyed by fields which may be left out of the row when
+	// empty, so they're written with all the fields
+	full, _ := toMap(v)
+	for _, view := range e.views {
+		if err := view.checkKeys(full); err != nil {
+			return errOp{err: err}
+		}
+	}
+	return newEntityOp(e.queryExecutor(), func(ctx context.Context) (Op, error) {
+		old, found, err := e.readRow(ctx, row[e.idField])
+		if err != nil {
+			return nil, err
+		}
+		// Fields left out of the row keep the values stored
+		viewRow := copyMap(full)
+		if found {
+			for k, v := range old {
+				if _, ok := row[k]; !ok {
+					viewRow[k] = v
+				}
+			}
+		}
+		op := e.primary.Set(copyMap(row))
+		for _, view := range e.views {
+			if found && movedInView(view, old, viewRow) {
+				op = op.Add(view.delete(old))
+			}
+			op = op.Add(view.set(copyMap(viewRow)))
+		}
+		return op, nil
+	})
+}
+
+func (e *entityT) Update(id interface{}, m map[string]interface{}) Op {
+	if v, ok := m[e.idField]; ok && !sameValue(id, v) {
+		return errOp{err: fmt.Errorf("can't change the id (%s) of a row", e.idField)}
+	}
+	return newEntityOp(e.queryExecutor(), func(ctx context.Context) (Op, error) {
+		old, found, err := e.readRow(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			return nil, RowNotFoundError{}
+		}
+
+		row := copyMap(old)
+		hasModifiers := false
+		for k, v := range m {
+			if _, ok := v.(Modifier); ok {
+				hasModifiers = true
+			}
+			row[k] = v
+		}
+
+		op := e.primary.Update(id, withoutFields(m, e.idField))
+		for _, view := range e.views {
+			if !movedInView(view, old, row) {
+				op = op.Add(view.update(old, withoutFields(m, view.keyFields()...)))
+				continue
+			}
+			if hasModifiers {
+				return nil, fmt.Errorf("can't apply modifiers while changing the key fields %v of a view", view.keyFields())
+			}
+			if err := view.checkKeys(row); err != nil {
+				return nil, err
+			}
+			op = op.Add(view.delete(old), view.set(row))
+		}
+		return op, nil
+	})
+}
+
+func (e *entityT) Delete(id interface{}) Op {
+	return newEntityOp(e.queryExecutor(), func(ctx context.Context) (Op, error) {
+		old, found, err := e.readRow(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		op := e.primary.Delete(id)
+		if found {
+			for _, view := range e.views {
+				op = op.Add(view.delete(old))
+			}
+		}
+		return op, nil
+	})
+}
+
+// queryExecutor returns the QueryExecutor the writes to the tables run with,
+// which is nil for mock tables
+func (e *entityT) queryExecutor() QueryExecutor {
+	switch tbl := e.primary.Table().(type) {
+	case t:
+		return tbl.keySpace.qe
+	case *t:
+		return tbl.keySpace.qe
+	}
+	return nil
+}
+
+func (e *entityT) WithOptions(o Options) EntityTable {
+	// Every table has a name of its own
+	o.TableName = ""
+	views := make([]entityView, len(e.views))
+	for i, v := range e.views {
+		views[i] = v.withOptions(o)
+	}
+	return &entityT{
+		primary: e.primary.WithOptions(o).(*mapT),
+		idField: e.idField,
+		rowType: e.rowType,
+		views:   views,
+	}
+}
+
+// movedInView reports whether a view keeps the new version of a row under a
+// different key to the old one
+func movedInView(view entityView, old, row map[string]interface{}) bool {
+	for _, f := range view.keyFields() {
+		if !sameValue(old[f], row[f]) {
+			return true
+		}
+	}
+	return false
+}
+
+// sameValue reports whether b holds the same value as a, converting it to the
+// type of a if needed
+func sameValue(a, b interface{}) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	av, bv := reflect.ValueOf(a), reflect.ValueOf(b)
+	if av.Type() != bv.Type() && isNumber(av.Kind()) && isNumber(bv.Kind()) {
+		bv = bv.Convert(av.Type())
+	}
+	if at, ok := av.Interface().(time.Time); ok {
+		bt, ok := bv.Interface().(time.Time)
+		return ok && at.Equal(bt)
+	}
+	return reflect.DeepEqual(av.Interface(), bv.Interface())
+}
+
+func isNumber(k reflect.Kind) bool {
+	return k >= reflect.Int && k <= reflect.Float64
+}
+
+func copyMap(m map[string]interface{}) map[string]interface{} {
+	c := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+func withoutFields(m map[string]interface{}, fields ...string) map[string]interface{} {
+	c := copyMap(m)
+	for _, f := range fields {
+		for k := range c {
+			if strings.EqualFold(k, f) {
+				delete(c, k)
+			}
+		}
+	}
+	return c
+}
+
+// entityOp builds the writes to the tables of an EntityTable when it's run,
+// as they depend on the row already stored
+type entityOp struct {
+	qe      QueryExecutor
+	build   func(ctx context.Context) (Op, error)
+	options Options
+}
+
+func newEntityOp(qe QueryExecutor, build func(ctx context.Context) (Op, error)) Op {
+	return entityOp{qe: qe, build: build}
+}
+
+// buildOp reads the stored row and builds the writes to the tables
+func (o entityOp) buildOp() (Op, error) {
+	ctx := o.options.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	op, err := o.build(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return op.WithOptions(o.options), nil
+}
+
+func (o entityOp) Run() error {
+	op, err := o.buildOp()
+	if err != nil {
+		return err
+	}
+	return op.Run()
+}
+
+func (o entityOp) RunWithContext(ctx context.Context) error {
+	return o.WithOptions(Options{Context: ctx}).Run()
+}
+
+func (o entityOp) RunAtomically() error {
+	op, err := o.buildOp()
+	if err != nil {
+		return err
+	}
+	return op.RunAtomically()
+}
+
+func (o entityOp) RunLoggedBatchWithContext(ctx context.Context) error {
+	return o.WithOptions(Options{Context: ctx}).RunAtomically()
+}
+
+func (o entityOp) RunAtomicallyWithContext(ctx context.Context) error {
+	return o.RunLoggedBatchWithContext(ctx)
+}
+
+// Add adds ops to the ones built, so they run in the same logged batch
+func (o entityOp) Add(additions ...Op) Op {
+	build := o.build
+	o.build = func(ctx context.Context) (Op, error) {
+		op, err := build(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return op.Add(additions...), nil
+	}
+	return o
+}
+
+func (o entityOp) WithOptions(opts Options) Op {
+	o.options = o.options.Merge(opts)
+	return o
+}
+
+func (o entityOp) Options() Options {
+	return o.options
+}
+
+func (o entityOp) Preflight() error {
+	return nil
+}
+
+// GenerateStatement isn't supported, as the writes to the tables depend on the
+// row stored and are only built when the op runs: it returns a no-op
+// statement. The writes of an entityOp added to a logged batch are built with
+// the other ops of the batch when it runs
+func (o entityOp) GenerateStatement() Statement {
+	return noOpStatement{}
+}
+
+// QueryExecutor returns the QueryExecutor of the primary table, which the
+// writes to all the tables run with
+func (o entityOp) QueryExecutor() QueryExecutor {
+	return o.qe
+}
@@ -1,5 +1,7 @@
 package gocassa
 
+import "encoding/json"
+
 type filter struct {
 	t  t
 	rs []Relation
@@ -21,6 +23,12 @@ func (f filter) Delete() Op {
 	return newWriteOp(f.t.keySpace.qe, f, deleteOpType, nil)
 }
 
+func (f filter) DeleteColumns(columns ...Selection) Op {
+	op := newWriteOp(f.t.keySpace.qe, f, deleteOpType, nil)
+	op.columns = columns
+	return op
+}
+
 //
 // Reads
 //
@@ -40,3 +48,21 @@ func (f filter) ReadOne(pointer interface{}) Op {
 		opType: singleReadOpType,
 		result: pointer}
 }
+
+func (f filter) ReadJSON(pointerToASlice *[]json.RawMessage) Op {
+	return &singleOp{
+		qe:     f.t.keySpace.qe,
+		f:      f,
+		opType: readOpType,
+		result: pointerToASlice,
+		json:   true}
+}
+
+func (f filter) ReadOneJSON(pointer *json.RawMessage) Op {
+	return &singleOp{
+		qe:     f.t.keySpace.qe,
+		f:      f,
+		opType: singleReadOpType,
+		result: pointer,
+		json:   true}
+}
@@ -1,8 +1,11 @@
 package gocassa
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"reflect"
 	"strings"
 	"time"
 
@@ -16,9 +19,11 @@ import (
 const flakeTimestampFieldName = "flake_created"
 
 type flakeSeriesT struct {
-	t          Table
-	idField    string
-	bucketSize time.Duration
+	t        Table
+	idField  string
+	bucketer Bucketer
+	ids      IDTimeExtractor
+	shards   int // number of shards each bucket is split into, if sharded
 }
 
 func (o *flakeSeriesT) Table() Table                        { return o.t }
@@ -32,7 +37,7 @@ func (o *flakeSeriesT) CreateIfNotExistStatement() (Statement, error) {
 }
 
 func (o *flakeSeriesT) Set(v interface{}) Op {
-	m, ok := toMap(v)
+	m, ok := toSetMap(v)
 	if !ok {
 		panic("Can't set: not able to convert")
 	}
@@ -41,80 +46,171 @@ func (o *flakeSeriesT) Set(v interface{}) Op {
 		panic(fmt.Sprintf("Id field (%s) is not present or is not a string", o.idField))
 	}
 
-	timestamp, err := flakeToTime(id)
+	timestamp, err := o.ids.Time(id)
 	if err != nil {
 		return errOp{err: err}
 	}
 
 	m[flakeTimestampFieldName] = timestamp
-	m[bucketFieldName] = bucket(timestamp, o.bucketSize)
+	m[bucketFieldName] = o.bucketer.Bucket(timestamp)
+	if o.shards > 0 {
+		m[shardFieldName] = shardOf(id, o.shards)
+	}
 
 	return o.Table().Set(m)
 }
 
 func (o *flakeSeriesT) Update(id string, m map[string]interface{}) Op {
-	timestamp, err := flakeToTime(id)
+	f, err := o.rowFilter(id)
 	if err != nil {
 		return errOp{err: err}
 	}
-	bucket := bucket(timestamp, o.bucketSize)
-
-	return o.Table().
-		Where(Eq(bucketFieldName, bucket),
-			Eq(flakeTimestampFieldName, timestamp),
-			Eq(o.idField, id)).
-		Update(m)
+	return f.Update(m)
 }
 
 func (o *flakeSeriesT) Delete(id string) Op {
-	timestamp, err := flakeToTime(id)
+	f, err := o.rowFilter(id)
 	if err != nil {
 		return errOp{err: err}
 	}
-	bucket := bucket(timestamp, o.bucketSize)
-
-	return o.Table().
-		Where(Eq(bucketFieldName, bucket),
-			Eq(flakeTimestampFieldName, timestamp),
-			Eq(o.idField, id)).
-		Delete()
+	return f.Delete()
 }
 
 func (o *flakeSeriesT) Read(id string, pointer interface{}) Op {
-	timestamp, err := flakeToTime(id)
+	f, err := o.rowFilter(id)
 	if err != nil {
 		return errOp{err: err}
 	}
-	bucket := bucket(timestamp, o.bucketSize)
-	return o.Table().
-		Where(Eq(bucketFieldName, bucket),
-			Eq(flakeTimestampFieldName, timestamp),
-			Eq(o.idField, id)).
-		ReadOne(pointer)
+	return f.ReadOne(pointer)
+}
+
+func (o *flakeSeriesT) SetJSON(document []byte, def JSONDefault) Op {
+	d, err := decodeJSONDocument(document)
+	if err != nil {
+		return errOp{err: err}
+	}
+	id, err := d.string(o.idField)
+	if err != nil {
+		return errOp{err: err}
+	}
+	timestamp, err := o.ids.Time(id)
+	if err != nil {
+		return errOp{err: err}
+	}
+
+	fields := map[string]interface{}{
+		flakeTimestampFieldName: timestamp,
+		bucketFieldName:         o.bucketer.Bucket(timestamp),
+	}
+	if o.shards > 0 {
+		fields[shardFieldName] = shardOf(id, o.shards)
+	}
+	for field, value := range fields {
+		if err := d.set(field, value); err != nil {
+			return errOp{err: err}
+		}
+	}
+	if document, err = d.encode(); err != nil {
+		return errOp{err: err}
+	}
+	return o.Table().SetJSON(document, def)
+}
+
+func (o *flakeSeriesT) ReadJSON(id string, pointer *json.RawMessage) Op {
+	f, err := o.rowFilter(id)
+	if err != nil {
+		return errOp{err: err}
+	}
+	return f.ReadOneJSON(pointer)
+}
+
+func (o *flakeSeriesT) rowFilter(id string) (Filter, error) {
+	timestamp, err := o.ids.Time(id)
+	if err != nil {
+		return nil, err
+	}
+	rels := []Relation{
+		Eq(bucketFieldName, o.bucketer.Bucket(timestamp)),
+		Eq(flakeTimestampFieldName, timestamp),
+		Eq(o.idField, id),
+	}
+	if o.shards > 0 {
+		rels = append(rels, Eq(shardFieldName, shardOf(id, o.shards)))
+	}
+	return o.Table().Where(rels...), nil
 }
 
 func (o *flakeSeriesT) List(startTime, endTime time.Time, pointerToASlice interface{}) Op {
+	return newBucketListOp(o.listFilter(startTime, endTime), o.Buckets(startTime), endTime, flakeTimestampFieldName, o.reader(), pointerToASlice)
+}
+
+func (o *flakeSeriesT) ListJSON(startTime, endTime time.Time, pointerToASlice *[]json.RawMessage) Op {
+	return o.listFilter(startTime, endTime).
+		ReadJSON(pointerToASlice)
+}
+
+func (o *flakeSeriesT) DeleteRange(startTime, endTime time.Time) Op {
+	return o.listFilter(startTime, endTime).
+		Delete()
+}
+
+func (o *flakeSeriesT) listFilter(startTime, endTime time.Time) Filter {
 	buckets := []interface{}{}
 	for bucket := o.Buckets(startTime); bucket.Bucket().Before(endTime); bucket = bucket.Next() {
 		buckets = append(buckets, bucket.Bucket())
 	}
 	return o.Table().
-		Where(In(bucketFieldName, buckets...),
+		Where(o.withShards(
+			In(bucketFieldName, buckets...),
 			GTE(flakeTimestampFieldName, startTime),
-			LT(flakeTimestampFieldName, endTime)).
-		Read(pointerToASlice)
+			LT(flakeTimestampFieldName, endTime))...)
+}
+
+// withShards adds a relation covering all shards when the table is sharded
+func (o *flakeSeriesT) withShards(rels ...Relation) []Relation {
+	if o.shards > 0 {
+		rels = append(rels, In(shardFieldName, allShards(o.shards)...))
+	}
+	return rels
+}
+
+func (o *flakeSeriesT) reader(rels ...Relation) bucketReader {
+	return bucketReader{
+		relations: rels,
+		shards:    o.shards,
+		timeField: flakeTimestampFieldName,
+		idField:   o.idField,
+		rowTime: func(row map[string]interface{}) time.Time {
+			id, _ := row[o.idField].(string)
+			t, _ := o.ids.Time(id)
+			return t
+		},
+	}
+}
+
+func (o *flakeSeriesT) Latest(n int, pointerToASlice interface{}) Op {
+	return o.ListBefore(tableClock(o.Table()).Now(), n, pointerToASlice)
+}
+
+func (o *flakeSeriesT) ListBefore(t time.Time, n int, pointerToASlice interface{}) Op {
+	return newBucketWalkOp(o.Buckets(t), flakeTimestampFieldName, o.reader(LT(flakeTimestampFieldName, t)), DESC, n, pointerToASlice)
+}
+
+func (o *flakeSeriesT) ListAfter(t time.Time, n int, pointerToASlice interface{}) Op {
+	return newBucketWalkOp(o.Buckets(t), flakeTimestampFieldName, o.reader(GT(flakeTimestampFieldName, t)), ASC, n, pointerToASlice)
 }
 
 func (o *flakeSeriesT) Buckets(start time.Time) Buckets {
 	return bucketIter{
 		v:         start,
-		step:      o.bucketSize,
+		bucketer:  o.bucketer,
+		shards:    o.shards,
 		field:     bucketFieldName,
 		invariant: o.Table().Where()}
 }
 
 func (o *flakeSeriesT) ListSince(id string, window time.Duration, pointerToASlice interface{}) Op {
-	startTime, err := flakeToTime(id)
+	startTime, err := o.ids.Time(id)
 	if err != nil {
 		return errOp{err: err}
 	}
@@ -122,7 +218,7 @@ func (o *flakeSeriesT) ListSince(id string, window time.Duration, pointerToASlic
 	var endTime time.Time
 	if window == 0 {
 		// no window set - so go up until 5 mins in the future
-		endTime = time.Now().Add(5 * time.Minute)
+		endTime = tableClock(o.Table()).Now().Add(5 * time.Minute)
 	} else {
 		endTime = startTime.Add(window)
 	}
@@ -132,19 +228,52 @@ func (o *flakeSeriesT) ListSince(id string, window time.Duration, pointerToASlic
 		buckets = append(buckets, bucket.Bucket())
 	}
 
-	return o.Table().
-		Where(In(bucketFieldName, buckets),
+	f := o.Table().
+		Where(o.withShards(
+			In(bucketFieldName, buckets...),
 			GTE(flakeTimestampFieldName, startTime),
-			LT(flakeTimestampFieldName, endTime),
-			GT(o.idField, id)).
-		Read(pointerToASlice)
+			LT(flakeTimestampFieldName, endTime))...)
+	list := newBucketListOp(f, o.Buckets(startTime), endTime, flakeTimestampFieldName, o.reader(), pointerToASlice)
+	return newSinceOp(list, o.Table(), o.ids, o.idField, id, pointerToASlice)
+}
+
+func (o *flakeSeriesT) Follow(ctx context.Context, fromID string, handler FollowHandler) error {
+	cp := Checkpoint{}
+	if fromID != "" {
+		t, err := o.ids.Time(fromID)
+		if err != nil {
+			return err
+		}
+		cp = Checkpoint{Time: t, IDs: []string{fromID}}
+	}
+	return follower{
+		table:     o.Table(),
+		buckets:   o.Buckets,
+		timeField: flakeTimestampFieldName,
+		reader:    o.reader(),
+		rowID: func(row map[string]interface{}) string {
+			id, _ := row[o.idField].(string)
+			return id
+		},
+		after: func(id string, cp Checkpoint) (bool, error) {
+			for _, seen := range cp.IDs {
+				cmp, err := o.ids.Compare(id, seen)
+				if err != nil || cmp <= 0 {
+					return false, err
+				}
+			}
+			return true, nil
+		},
+	}.follow(ctx, cp, handler)
 }
 
 func (o *flakeSeriesT) WithOptions(opt Options) FlakeSeriesTable {
 	return &flakeSeriesT{
-		t:          o.Table().WithOptions(opt),
-		idField:    o.idField,
-		bucketSize: o.bucketSize}
+		t:        o.Table().WithOptions(opt),
+		idField:  o.idField,
+		bucketer: o.bucketer,
+		ids:      o.ids,
+		shards:   o.shards}
 }
 
 func flakeToTime(id string) (time.Time, error) {
@@ -161,3 +290,126 @@ func flakeToTime(id string) (time.Time, error) {
 
 	return timestamp, nil
 }
+
+// sinceOp runs a listing and drops the rows whose id isn't after id. The
+// listing can't do that itself, as a clustering column following one
+// restricted by a range can't be restricted, and the text ordering of ids
+// doesn't always match the order they were generated in. Rows which aren't
+// after id all share its timestamp, so they come first (or last, in
+// descending order), and the listing is rerun with a higher limit until
+// enough rows are left
+type sinceOp struct {
+	list    Op
+	table   Table
+	ids     IDTimeExtractor
+	idField string
+	id      string
+	result  interface{}
+}
+
+func newSinceOp(list Op, table Table, ids IDTimeExtractor, idField, id string, result interface{}) Op {
+	return sinceOp{
+		list:    list,
+		table:   table,
+		ids:     ids,
+		idField: idField,
+		id:      id,
+		result:  result,
+	}
+}
+
+func (o sinceOp) Run() error {
+	ptr := reflect.ValueOf(o.result)
+	if ptr.Kind() != reflect.Ptr || ptr.IsNil() || ptr.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("can only read into a pointer to a slice, not %T", o.result)
+	}
+
+	opts := o.list.Options()
+	if t, ok := o.table.(optionsTable); ok {
+		opts = t.tableOptions().Merge(opts)
+	}
+	limit := opts.Limit
+
+	dropped := 0
+	for {
+		list := o.list
+		if limit > 0 {
+			list = list.WithOptions(Options{Limit: limit + dropped})
+		}
+		if err := list.Run(); err != nil {
+			return err
+		}
+
+		rows := ptr.Elem()
+		kept := reflect.MakeSlice(rows.Type(), 0, rows.Len())
+		for i := 0; i < rows.Len(); i++ {
+			m, ok := toMap(rows.Index(i).Interface())
+			if !ok {
+				return fmt.Errorf("can't read the id of rows of type %v", rows.Type().Elem())
+			}
+			id, _ := m[o.idField].(string)
+			cmp, err := o.ids.Compare(id, o.id)
+			if err != nil {
+				return err
+			}
+			if cmp > 0 {
+				kept = reflect.Append(kept, rows.Index(i))
+			}
+		}
+
+		// Done when nothing was cut off by the limit, or the extra rows
+		// requested made up for all the dropped ones
+		read, nowDropped := rows.Len(), rows.Len()-kept.Len()
+		if limit == 0 || read < limit+dropped || nowDropped == dropped {
+			if limit > 0 && kept.Len() > limit {
+				kept = kept.Slice(0, limit)
+			}
+			ptr.Elem().Set(kept)
+			return nil
+		}
+		dropped = nowDropped
+	}
+}
+
+func (o sinceOp) RunWithContext(ctx context.Context) error {
+	return o.WithOptions(Options{Context: ctx}).Run()
+}
+
+func (o sinceOp) RunAtomically() error {
+	return o.Run()
+}
+
+func (o sinceOp) RunLoggedBatchWithContext(ctx context.Context) error {
+	return o.RunWithContext(ctx)
+}
+
+func (o sinceOp) RunAtomicallyWithContext(ctx context.Context) error {
+	return o.RunWithContext(ctx)
+}
+
+func (o sinceOp) Add(additions ...Op) Op {
+	return multiOp{o}.Add(additions...)
+}
+
+func (o sinceOp) WithOptions(opts Options) Op {
+	o.list = o.list.WithOptions(opts)
+	return o
+}
+
+func (o sinceOp) Options() Options {
+	return o.list.Options()
+}
+
+func (o sinceOp) Preflight() error {
+	return o.list.Preflight()
+}
+
+// GenerateStatement generates the statement of the listing, which doesn't
+// restrict the id
+func (o sinceOp) GenerateStatement() Statement {
+	return o.list.GenerateStatement()
+}
+
+func (o sinceOp) QueryExecutor() QueryExecutor {
+	return o.list.QueryExecutor()
+}
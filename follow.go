@@ -0,0 +1,276 @@
+package gocassa
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultPollInterval is how long Follow waits between polls when
+	// PollInterval isn't set
+	defaultPollInterval = time.Second
+	// defaultMaxPollInterval is the longest Follow waits between polls when
+	// MaxPollInterval isn't set
+	defaultMaxPollInterval = 30 * time.Second
+	// defaultFollowPageSize is the number of rows Follow reads at once when
+	// Limit isn't set
+	defaultFollowPageSize = 100
+)
+
+// FollowHandler handles a row read by Follow. The row holds a value of the
+// type the table was created with. If the handler returns an error, Follow
+// stops and returns it
+type FollowHandler func(ctx context.Context, row interface{}) error
+
+// Checkpoint is how far Follow has got: the time of the last row it handled,
+// and the ids of the rows it handled with that time (formatted with fmt.Sprint
+// for time series)
+type Checkpoint struct {
+	Time time.Time
+	IDs  []string
+}
+
+// CheckpointStore keeps the checkpoint of a follower, so Follow can carry on
+// where it left off
+type CheckpointStore interface {
+	// Load returns the checkpoint saved last, and false if there isn't one
+	Load(ctx context.Context) (Checkpoint, bool, error)
+	// Save saves a checkpoint, replacing the previous one
+	Save(ctx context.Context, cp Checkpoint) error
+}
+
+// MemoryCheckpoints is a CheckpointStore keeping the checkpoint in memory.
+// The zero value is ready to use
+type MemoryCheckpoints struct {
+	mu sync.Mutex
+	cp *Checkpoint
+}
+
+func (m *MemoryCheckpoints) Load(ctx context.Context) (Checkpoint, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.cp == nil {
+		return Checkpoint{}, false, nil
+	}
+	return *m.cp, true, nil
+}
+
+func (m *MemoryCheckpoints) Save(ctx context.Context, cp Checkpoint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cp = &cp
+	return nil
+}
+
+type checkpointRow struct {
+	Follower string
+	Time     time.Time
+	IDs      []string
+}
+
+// CheckpointTable returns a MapTable for keeping the checkpoints of followers
+// in, with a row per follower. See MapTableCheckpoints
+func CheckpointTable(ks KeySpace, name string) MapTable {
+	return ks.MapTable(name, "Follower", checkpointRow{})
+}
+
+type mapTableCheckpoints struct {
+	tbl      MapTable
+	follower string
+}
+
+// MapTableCheckpoints returns a CheckpointStore keeping the checkpoint of the
+// named follower in a table returned by CheckpointTable
+func MapTableCheckpoints(tbl MapTable, follower string) CheckpointStore {
+	return mapTableCheckpoints{tbl: tbl, follower: follower}
+}
+
+func (m mapTableCheckpoints) Load(ctx context.Context) (Checkpoint, bool, error) {
+	row := checkpointRow{}
+	err := m.tbl.Read(m.follower, &row).RunWithContext(ctx)
+	if _, ok := err.(RowNotFoundError); ok {
+		return Checkpoint{}, false, nil
+	} else if err != nil {
+		return Checkpoint{}, false, err
+	}
+	return Checkpoint{Time: row.Time, IDs: row.IDs}, true, nil
+}
+
+func (m mapTableCheckpoints) Save(ctx context.Context, cp Checkpoint) error {
+	return m.tbl.Set(checkpointRow{
+		Follower: m.follower,
+		Time:     cp.Time,
+		IDs:      cp.IDs,
+	}).RunWithContext(ctx)
+}
+
+// rowDefinitionTable is implemented by tables which can report the row
+// definition they were created with
+type rowDefinitionTable interface {
+	rowDefinition() interface{}
+}
+
+// follower polls the buckets of a recipe, one at a time, for rows after its
+// checkpoint
+type follower struct {
+	table     Table
+	buckets   func(start time.Time) Buckets
+	timeField string
+	reader    bucketReader
+	rowID     func(row map[string]interface{}) string
+	// after reports whether the row with the given id, which has the time of
+	// the checkpoint, comes after it
+	after func(id string, cp Checkpoint) (bool, error)
+}
+
+func (f follower) follow(ctx context.Context, cp Checkpoint, handler FollowHandler) error {
+	opts := Options{}
+	if t, ok := f.table.(optionsTable); ok {
+		opts = t.tableOptions()
+	}
+	clock := opts.Clock
+	if clock == nil {
+		clock = systemClock
+	}
+	minInterval, maxInterval := opts.PollInterval, opts.MaxPollInterval
+	if minInterval <= 0 {
+		minInterval = defaultPollInterval
+	}
+	if maxInterval < minInterval {
+		maxInterval = defaultMaxPollInterval
+		if maxInterval < minInterval {
+			maxInterval = minInterval
+		}
+	}
+	pageSize := opts.Limit
+	if pageSize <= 0 {
+		pageSize = defaultFollowPageSize
+	}
+
+	t, ok := f.table.(rowDefinitionTable)
+	if !ok {
+		return fmt.Errorf("can't follow table %s", f.table.Name())
+	}
+	rowType := reflect.TypeOf(t.rowDefinition())
+	if rowType.Kind() == reflect.Ptr {
+		rowType = rowType.Elem()
+	}
+	sliceType := reflect.SliceOf(rowType)
+
+	if opts.Checkpoints != nil {
+		saved, ok, err := opts.Checkpoints.Load(ctx)
+		if err != nil {
+			return err
+		}
+		if ok {
+			cp = saved
+		}
+	}
+	if cp.Time.IsZero() {
+		cp.Time = clock.Now()
+	}
+
+	interval := minInterval
+	b := f.buckets(cp.Time)
+	for {
+		rows, more, err := f.page(ctx, b, cp, pageSize, sliceType)
+		if err != nil {
+			return err
+		}
+		for i := 0; i < rows.Len(); i++ {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			row := rows.Index(i).Interface()
+			if err := handler(ctx, row); err != nil {
+				return err
+			}
+			m, _ := toMap(row)
+			rowTime, id := f.reader.rowTime(m), f.rowID(m)
+			if rowTime.Equal(cp.Time) {
+				cp.IDs = append(cp.IDs, id)
+			} else {
+				cp = Checkpoint{Time: rowTime, IDs: []string{id}}
+			}
+			if opts.Checkpoints != nil {
+				if err := opts.Checkpoints.Save(ctx, cp); err != nil {
+					return err
+				}
+			}
+		}
+
+		// Buckets are read until they're exhausted, and left once the time
+		// they cover has passed
+		if more {
+			continue
+		}
+		if next := b.Next(); !next.Bucket().After(clock.Now()) {
+			b = next
+			continue
+		}
+
+		if rows.Len() > 0 {
+			interval = minInterval
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-clockAfter(clock, interval):
+		}
+		if rows.Len() == 0 {
+			if interval *= 2; interval > maxInterval {
+				interval = maxInterval
+			}
+		}
+	}
+}
+
+// page reads up to n rows of a bucket which come after the checkpoint, and
+// whether there may be more. Rows which aren't after the checkpoint all have
+// its time, so they come first and the limit is raised to make up for them
+func (f follower) page(ctx context.Context, b Buckets, cp Checkpoint, n int, sliceType reflect.Type) (reflect.Value, bool, error) {
+	reader := f.reader
+	reader.relations = []Relation{GTE(f.timeField, cp.Time)}
+
+	dropped := 0
+	for {
+		opts := Options{
+			Context:         ctx,
+			Limit:           n + dropped,
+			ClusteringOrder: []ClusteringOrderColumn{{Column: f.timeField, Direction: ASC}},
+		}
+		rows, err := reader.read(b, opts, false, sliceType)
+		if err != nil {
+			return rows, false, err
+		}
+
+		kept := reflect.MakeSlice(sliceType, 0, rows.Len())
+		for i := 0; i < rows.Len(); i++ {
+			m, ok := toMap(rows.Index(i).Interface())
+			if !ok {
+				return kept, false, fmt.Errorf("can't follow rows of type %v", sliceType.Elem())
+			}
+			after := f.reader.rowTime(m).After(cp.Time)
+			if !after {
+				if after, err = f.after(f.rowID(m), cp); err != nil {
+					return kept, false, err
+				}
+			}
+			if after {
+				kept = reflect.Append(kept, rows.Index(i))
+			}
+		}
+
+		full := rows.Len() == n+dropped
+		if !full || rows.Len()-kept.Len() == dropped {
+			if kept.Len() > n {
+				kept = kept.Slice(0, n)
+			}
+			return kept, full, nil
+		}
+		dropped = rows.Len() - kept.Len()
+	}
+}
@@ -8,4 +8,5 @@ require (
 	github.com/mattheath/base62 v0.0.0-20150408093626-b80cdc656a7a
 	github.com/mattheath/kala v0.0.0-20171219141654-d6276794bf0e
 	github.com/stretchr/testify v1.6.1
+	gopkg.in/yaml.v3 v3.0.1
 )
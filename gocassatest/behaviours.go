@@ -0,0 +1,151 @@
+package gocassatest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stut/gocassa"
+)
+
+func testOrdering(t *testing.T, s *suite) {
+	tbl := s.ks.Table("events_desc", event{}, gocassa.Keys{
+		PartitionKeys:     []string{"Account"},
+		ClusteringColumns: []string{"At", "Id"},
+	}).WithOptions(gocassa.Options{ClusteringOrder: []gocassa.ClusteringOrderColumn{
+		{Column: "At", Direction: gocassa.DESC},
+		{Column: "Id", Direction: gocassa.DESC},
+	}})
+	s.recreate(tbl.(gocassa.TableChanger))
+	for i, id := range []string{"b", "a", "c", "d"} {
+		s.run(tbl.Set(event{Account: "a", Id: id, At: start.Add(time.Duration(i/2) * time.Minute)}))
+	}
+
+	var events []event
+	s.run(tbl.Where(gocassa.Eq("Account", "a")).Read(&events))
+	assert.Equal(t, []string{"d", "c", "b", "a"}, ids(events))
+
+	// Reading in the reverse of the clustering order of the table
+	asc := gocassa.Options{ClusteringOrder: []gocassa.ClusteringOrderColumn{
+		{Column: "At", Direction: gocassa.ASC},
+		{Column: "Id", Direction: gocassa.ASC},
+	}}
+	s.run(tbl.Where(gocassa.Eq("Account", "a")).Read(&events).WithOptions(asc))
+	assert.Equal(t, []string{"a", "b", "c", "d"}, ids(events))
+	s.run(tbl.Where(gocassa.Eq("Account", "a"), gocassa.Eq("At", start)).Read(&events))
+	assert.Equal(t, []string{"b", "a"}, ids(events))
+	s.run(tbl.Where(gocassa.Eq("Account", "a"), gocassa.GT("At", start)).Read(&events).WithOptions(asc))
+	assert.Equal(t, []string{"c", "d"}, ids(events))
+}
+
+func testLimit(t *testing.T, s *suite) {
+	tbl := s.ks.MultimapTable("limited", "Account", "Id", event{})
+	s.recreate(tbl)
+	for _, id := range []string{"3", "1", "4", "2"} {
+		s.run(tbl.Set(event{Account: "a", Id: id}))
+	}
+
+	var events []event
+	s.run(tbl.Table().Where(gocassa.Eq("Account", "a")).Read(&events).WithOptions(gocassa.Options{Limit: 3}))
+	assert.Equal(t, []string{"1", "2", "3"}, ids(events))
+	s.run(tbl.List("a", "2", 1, &events))
+	assert.Equal(t, []string{"2"}, ids(events))
+	s.run(tbl.ListRange("a", gocassa.ClusteringRange{Direction: gocassa.DESC}, 2, &events))
+	assert.Equal(t, []string{"4", "3"}, ids(events))
+}
+
+func testSelect(t *testing.T, s *suite) {
+	tbl := s.ks.MapTable("selected", "Id", event{})
+	s.recreate(tbl)
+	s.run(tbl.Set(event{Id: "1", Account: "a", Kind: "login", At: start}))
+
+	var e event
+	s.run(tbl.Read("1", &e).WithOptions(gocassa.Options{Select: []string{"Id", "Kind"}}))
+	assert.Equal(t, event{Id: "1", Kind: "login"}, e)
+}
+
+func testTTL(t *testing.T, s *suite) {
+	tbl := s.ks.MapTable("expiring", "Id", event{})
+	s.recreate(tbl)
+	ttl := gocassa.Options{TTL: time.Second}
+	s.run(tbl.Set(event{Id: "1", Kind: "a"}).WithOptions(ttl))
+	s.run(tbl.Set(event{Id: "2", Kind: "b"}))
+	s.run(tbl.Update("2", map[string]interface{}{"Account": "x"}).WithOptions(ttl))
+
+	var e event
+	s.run(tbl.Read("1", &e))
+	s.run(tbl.Read("2", &e))
+	assert.Equal(t, event{Id: "2", Kind: "b", Account: "x"}, e)
+
+	s.wait(2 * time.Second)
+	assert.IsType(t, gocassa.RowNotFoundError{}, tbl.Read("1", &e).Run())
+	// Only the cells written with the TTL expire
+	s.run(tbl.Read("2", &e))
+	assert.Equal(t, event{Id: "2", Kind: "b"}, e)
+}
+
+// document is the row of the modifier tests
+type document struct {
+	Id    string
+	Tags  []string
+	Attrs map[string]string
+}
+
+func testModifiers(t *testing.T, s *suite) {
+	tbl := s.ks.MapTable("documents", "Id", document{})
+	s.recreate(tbl)
+	s.run(tbl.Set(document{Id: "1", Tags: []string{"b"}, Attrs: map[string]string{"a": "1"}}))
+
+	update := func(field string, m gocassa.Modifier) document {
+		t.Helper()
+		s.run(tbl.Update("1", map[string]interface{}{field: m}))
+		var d document
+		s.run(tbl.Read("1", &d))
+		return d
+	}
+	assert.Equal(t, []string{"b", "c"}, update("Tags", gocassa.ListAppend("c")).Tags)
+	assert.Equal(t, []string{"a", "b", "c"}, update("Tags", gocassa.ListPrepend("a")).Tags)
+	assert.Equal(t, []string{"a", "x", "c"}, update("Tags", gocassa.ListSetAtIndex(1, "x")).Tags)
+	assert.Equal(t, []string{"a", "c"}, update("Tags", gocassa.ListRemove("x")).Tags)
+
+	assert.Equal(t, map[string]string{"a": "1", "b": "2"}, update("Attrs", gocassa.MapSetField("b", "2")).Attrs)
+	assert.Equal(t, map[string]string{"a": "3", "b": "2", "c": "4"},
+		update("Attrs", gocassa.MapSetFields(map[string]interface{}{"a": "3", "c": "4"})).Attrs)
+	assert.Equal(t, map[string]string{"b": "2"}, update("Attrs", gocassa.MapRemoveKeys("a", "c")).Attrs)
+}
+
+// counters is the row of the counter tests
+type counters struct {
+	Id   string
+	Hits gocassa.Counter
+}
+
+func testCounters(t *testing.T, s *suite) {
+	tbl := s.ks.MapTable("counters", "Id", counters{})
+	s.recreate(tbl)
+	s.run(tbl.Update("1", map[string]interface{}{"Hits": gocassa.CounterIncrement(3)}))
+	s.run(tbl.Update("1", map[string]interface{}{"Hits": gocassa.CounterIncrement(-1)}))
+
+	var c counters
+	s.run(tbl.Read("1", &c))
+	assert.Equal(t, counters{Id: "1", Hits: 2}, c)
+}
+
+func testBatch(t *testing.T, s *suite) {
+	tbl := s.ks.MapTable("batched", "Id", event{})
+	s.recreate(tbl)
+	s.run(tbl.Set(event{Id: "3"}))
+
+	// The statements of a logged batch are written with the same timestamp,
+	// so each writes a different row
+	op := gocassa.Noop().
+		Add(tbl.Set(event{Id: "1", Kind: "a"})).
+		Add(tbl.Update("2", map[string]interface{}{"Kind": "b"}), tbl.Delete("3"))
+	if err := op.RunAtomically(); err != nil {
+		t.Fatal(err)
+	}
+
+	var events []event
+	s.run(tbl.MultiRead([]interface{}{"1", "2", "3"}, &events))
+	assert.ElementsMatch(t, []event{{Id: "1", Kind: "a"}, {Id: "2", Kind: "b"}}, events)
+}
@@ -0,0 +1,264 @@
+package gocassatest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stut/gocassa"
+)
+
+// event is the row most of the tests write
+type event struct {
+	Account string
+	Region  string
+	Id      string
+	At      time.Time
+	Kind    string
+}
+
+// start is the time the rows of the time series tests are written from. It
+// has millisecond precision, as timestamps do in Cassandra
+var start = time.Date(2021, 3, 4, 10, 0, 0, 0, time.UTC)
+
+func ids(events []event) []string {
+	ret := []string{}
+	for _, e := range events {
+		ret = append(ret, e.Id)
+	}
+	return ret
+}
+
+func testTable(t *testing.T, s *suite) {
+	tbl := s.ks.Table("events", event{}, gocassa.Keys{
+		PartitionKeys:     []string{"Account"},
+		ClusteringColumns: []string{"Id"},
+	})
+	s.recreate(tbl)
+	for _, e := range []event{{Account: "a", Id: "1"}, {Account: "a", Id: "3"}, {Account: "a", Id: "2"}, {Account: "b", Id: "1"}} {
+		s.run(tbl.Set(e))
+	}
+
+	var events []event
+	s.run(tbl.Where(gocassa.Eq("Account", "a")).Read(&events))
+	assert.Equal(t, []string{"1", "2", "3"}, ids(events))
+	s.run(tbl.Where(gocassa.Eq("Account", "a"), gocassa.GT("Id", "1")).Read(&events))
+	assert.Equal(t, []string{"2", "3"}, ids(events))
+	s.run(tbl.Where(gocassa.In("Account", "a", "b"), gocassa.Eq("Id", "1")).Read(&events))
+	assert.Len(t, events, 2)
+
+	var e event
+	s.run(tbl.Where(gocassa.Eq("Account", "a"), gocassa.Eq("Id", "2")).Update(map[string]interface{}{"Kind": "login"}))
+	s.run(tbl.Where(gocassa.Eq("Account", "a"), gocassa.Eq("Id", "2")).ReadOne(&e))
+	assert.Equal(t, event{Account: "a", Id: "2", Kind: "login"}, e)
+
+	// Updates write rows which don't exist
+	s.run(tbl.Where(gocassa.Eq("Account", "c"), gocassa.Eq("Id", "1")).Update(map[string]interface{}{"Kind": "new"}))
+	s.run(tbl.Where(gocassa.Eq("Account", "c"), gocassa.Eq("Id", "1")).ReadOne(&e))
+	assert.Equal(t, event{Account: "c", Id: "1", Kind: "new"}, e)
+
+	s.run(tbl.Where(gocassa.Eq("Account", "a"), gocassa.Eq("Id", "3")).Delete())
+	err := tbl.Where(gocassa.Eq("Account", "a"), gocassa.Eq("Id", "3")).ReadOne(&e).Run()
+	assert.IsType(t, gocassa.RowNotFoundError{}, err)
+	s.run(tbl.Where(gocassa.Eq("Account", "a")).Delete())
+	s.run(tbl.Where(gocassa.Eq("Account", "a")).Read(&events))
+	assert.Empty(t, events)
+}
+
+func testMapTable(t *testing.T, s *suite) {
+	tbl := s.ks.MapTable("accounts", "Id", event{})
+	s.recreate(tbl)
+	s.run(tbl.Set(event{Id: "1", Kind: "a"}))
+	s.run(tbl.Set(event{Id: "2", Kind: "b"}))
+
+	var e event
+	s.run(tbl.Read("1", &e))
+	assert.Equal(t, event{Id: "1", Kind: "a"}, e)
+	assert.IsType(t, gocassa.RowNotFoundError{}, tbl.Read("3", &e).Run())
+
+	s.run(tbl.Update("1", map[string]interface{}{"Kind": "c", "At": start}))
+	s.run(tbl.Read("1", &e))
+	assert.Equal(t, event{Id: "1", Kind: "c", At: start}, e)
+
+	var events []event
+	s.run(tbl.MultiRead([]interface{}{"1", "2", "3"}, &events))
+	assert.ElementsMatch(t, []string{"1", "2"}, ids(events))
+
+	// Set replaces the whole row
+	s.run(tbl.Set(event{Id: "1"}))
+	s.run(tbl.Read("1", &e))
+	assert.Equal(t, event{Id: "1"}, e)
+
+	s.run(tbl.Delete("1"))
+	assert.IsType(t, gocassa.RowNotFoundError{}, tbl.Read("1", &e).Run())
+}
+
+func testMultimapTable(t *testing.T, s *suite) {
+	tbl := s.ks.MultimapTable("by_account", "Account", "Id", event{})
+	s.recreate(tbl)
+	for _, e := range []event{{Account: "a", Id: "3"}, {Account: "a", Id: "1"}, {Account: "a", Id: "2"}, {Account: "b", Id: "1"}} {
+		s.run(tbl.Set(e))
+	}
+
+	var events []event
+	s.run(tbl.List("a", nil, 0, &events))
+	assert.Equal(t, []string{"1", "2", "3"}, ids(events))
+	s.run(tbl.List("a", "2", 0, &events))
+	assert.Equal(t, []string{"2", "3"}, ids(events))
+	s.run(tbl.List("a", nil, 2, &events))
+	assert.Equal(t, []string{"1", "2"}, ids(events))
+	s.run(tbl.ListRange("a", gocassa.ClusteringRange{From: "1", To: "3", ToInclusive: true, Direction: gocassa.DESC}, 0, &events))
+	assert.Equal(t, []string{"3", "2"}, ids(events))
+
+	var e event
+	s.run(tbl.Update("a", "2", map[string]interface{}{"Kind": "x"}))
+	s.run(tbl.Read("a", "2", &e))
+	assert.Equal(t, event{Account: "a", Id: "2", Kind: "x"}, e)
+	assert.IsType(t, gocassa.RowNotFoundError{}, tbl.Read("a", "4", &e).Run())
+
+	s.run(tbl.Delete("a", "1"))
+	s.run(tbl.DeleteRange("a", "2", "3"))
+	s.run(tbl.List("a", nil, 0, &events))
+	assert.Equal(t, []string{"3"}, ids(events))
+	s.run(tbl.DeleteAll("b"))
+	s.run(tbl.List("b", nil, 0, &events))
+	assert.Empty(t, events)
+}
+
+func testMultimapMkTable(t *testing.T, s *suite) {
+	tbl := s.ks.MultimapMultiKeyTable("by_account_region", []string{"Account", "Region"}, []string{"Id"}, event{})
+	s.recreate(tbl)
+	for _, e := range []event{{Account: "a", Region: "eu", Id: "2"}, {Account: "a", Region: "eu", Id: "1"}, {Account: "a", Region: "us", Id: "1"}} {
+		s.run(tbl.Set(e))
+	}
+	eu := map[string]interface{}{"Account": "a", "Region": "eu"}
+
+	var events []event
+	s.run(tbl.List(eu, nil, 0, &events))
+	assert.Equal(t, []string{"1", "2"}, ids(events))
+	s.run(tbl.List(eu, map[string]interface{}{"Id": "2"}, 0, &events))
+	assert.Equal(t, []string{"2"}, ids(events))
+
+	var e event
+	s.run(tbl.Update(eu, map[string]interface{}{"Id": "1"}, map[string]interface{}{"Kind": "x"}))
+	s.run(tbl.Read(eu, map[string]interface{}{"Id": "1"}, &e))
+	assert.Equal(t, event{Account: "a", Region: "eu", Id: "1", Kind: "x"}, e)
+
+	s.run(tbl.Delete(eu, map[string]interface{}{"Id": "1"}))
+	s.run(tbl.List(eu, nil, 0, &events))
+	assert.Equal(t, []string{"2"}, ids(events))
+	s.run(tbl.DeleteAll(eu))
+	s.run(tbl.List(eu, nil, 0, &events))
+	assert.Empty(t, events)
+	s.run(tbl.List(map[string]interface{}{"Account": "a", "Region": "us"}, nil, 0, &events))
+	assert.Equal(t, []string{"1"}, ids(events))
+}
+
+func testTimeSeriesTable(t *testing.T, s *suite) {
+	tbl := s.ks.TimeSeriesTable("timeline", "At", "Id", time.Hour, event{})
+	s.recreate(tbl)
+	for i, d := range []time.Duration{130 * time.Minute, 10 * time.Minute, 70 * time.Minute, 20 * time.Minute} {
+		s.run(tbl.Set(event{Id: string(rune('a' + i)), At: start.Add(d)}))
+	}
+
+	var events []event
+	s.run(tbl.List(start, start.Add(2*time.Hour), &events))
+	assert.Equal(t, []string{"b", "d", "c"}, ids(events))
+	s.run(tbl.List(start.Add(20*time.Minute), start.Add(3*time.Hour), &events))
+	assert.Equal(t, []string{"d", "c", "a"}, ids(events))
+
+	var e event
+	s.run(tbl.Update(start.Add(10*time.Minute), "b", map[string]interface{}{"Kind": "x"}))
+	s.run(tbl.Read(start.Add(10*time.Minute), "b", &e))
+	assert.Equal(t, event{Id: "b", At: start.Add(10 * time.Minute), Kind: "x"}, e)
+
+	s.run(tbl.Delete(start.Add(10*time.Minute), "b"))
+	assert.IsType(t, gocassa.RowNotFoundError{}, tbl.Read(start.Add(10*time.Minute), "b", &e).Run())
+	s.run(tbl.DeleteRange(start, start.Add(90*time.Minute)))
+	s.run(tbl.List(start, start.Add(3*time.Hour), &events))
+	assert.Equal(t, []string{"a"}, ids(events))
+}
+
+func testMultiTimeSeriesTable(t *testing.T, s *suite) {
+	tbl := s.ks.MultiTimeSeriesTable("account_timeline", "Account", "At", "Id", time.Hour, event{})
+	s.recreate(tbl)
+	s.run(tbl.Set(event{Account: "a", Id: "1", At: start.Add(70 * time.Minute)}))
+	s.run(tbl.Set(event{Account: "a", Id: "2", At: start.Add(10 * time.Minute)}))
+	s.run(tbl.Set(event{Account: "b", Id: "3", At: start.Add(10 * time.Minute)}))
+
+	var events []event
+	s.run(tbl.List("a", start, start.Add(2*time.Hour), &events))
+	assert.Equal(t, []string{"2", "1"}, ids(events))
+
+	var e event
+	s.run(tbl.Update("a", start.Add(70*time.Minute), "1", map[string]interface{}{"Kind": "x"}))
+	s.run(tbl.Read("a", start.Add(70*time.Minute), "1", &e))
+	assert.Equal(t, "x", e.Kind)
+
+	s.run(tbl.Delete("a", start.Add(10*time.Minute), "2"))
+	s.run(tbl.List("a", start, start.Add(2*time.Hour), &events))
+	assert.Equal(t, []string{"1"}, ids(events))
+	s.run(tbl.List("b", start, start.Add(2*time.Hour), &events))
+	assert.Equal(t, []string{"3"}, ids(events))
+}
+
+// flake is the row of the flake series tests, whose ids are time UUIDs
+type flake struct {
+	Account string
+	Id      string
+	Kind    string
+}
+
+func flakeID(d time.Duration) string {
+	return gocql.UUIDFromTime(start.Add(d)).String()
+}
+
+func testFlakeSeriesTable(t *testing.T, s *suite) {
+	tbl := s.ks.FlakeSeriesTableWithIDs("flakes", "Id", gocassa.FixedBuckets(time.Hour), gocassa.TimeUUIDs(), flake{})
+	s.recreate(tbl)
+	first, second, third := flakeID(70*time.Minute), flakeID(10*time.Minute), flakeID(20*time.Minute)
+	for _, id := range []string{first, second, third} {
+		s.run(tbl.Set(flake{Id: id}))
+	}
+
+	var flakes []flake
+	s.run(tbl.List(start, start.Add(2*time.Hour), &flakes))
+	if assert.Len(t, flakes, 3) {
+		assert.Equal(t, []string{second, third, first}, []string{flakes[0].Id, flakes[1].Id, flakes[2].Id})
+	}
+
+	var f flake
+	s.run(tbl.Update(second, map[string]interface{}{"Kind": "x"}))
+	s.run(tbl.Read(second, &f))
+	assert.Equal(t, flake{Id: second, Kind: "x"}, f)
+
+	s.run(tbl.Delete(second))
+	assert.IsType(t, gocassa.RowNotFoundError{}, tbl.Read(second, &f).Run())
+	s.run(tbl.List(start, start.Add(2*time.Hour), &flakes))
+	assert.Len(t, flakes, 2)
+}
+
+func testMultiFlakeSeriesTable(t *testing.T, s *suite) {
+	tbl := s.ks.MultiFlakeSeriesTableWithIDs("account_flakes", "Account", "Id", gocassa.FixedBuckets(time.Hour), gocassa.TimeUUIDs(), flake{})
+	s.recreate(tbl)
+	first, second := flakeID(70*time.Minute), flakeID(10*time.Minute)
+	s.run(tbl.Set(flake{Account: "a", Id: first}))
+	s.run(tbl.Set(flake{Account: "a", Id: second}))
+	s.run(tbl.Set(flake{Account: "b", Id: flakeID(20 * time.Minute)}))
+
+	var flakes []flake
+	s.run(tbl.List("a", start, start.Add(2*time.Hour), &flakes))
+	if assert.Len(t, flakes, 2) {
+		assert.Equal(t, []string{second, first}, []string{flakes[0].Id, flakes[1].Id})
+	}
+
+	var f flake
+	s.run(tbl.Update("a", first, map[string]interface{}{"Kind": "x"}))
+	s.run(tbl.Read("a", first, &f))
+	assert.Equal(t, flake{Account: "a", Id: first, Kind: "x"}, f)
+
+	s.run(tbl.Delete("a", first))
+	s.run(tbl.List("a", start, start.Add(2*time.Hour), &flakes))
+	assert.Len(t, flakes, 1)
+}
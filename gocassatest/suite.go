@@ -0,0 +1,110 @@
+// Package gocassatest is a behaviour suite for gocassa keyspaces. It runs the
+// same writes and reads on every recipe against a KeySpace and checks what
+// comes back, so that the mock keyspace, the in-memory query executor and
+// real clusters can be checked to behave the same way:
+//
+//	func TestConformance(t *testing.T) {
+//		gocassatest.Run(t, gocassatest.Backend{
+//			KeySpace: func(t *testing.T) gocassa.KeySpace {
+//				return gocassa.NewMockKeySpace()
+//			},
+//		})
+//	}
+package gocassatest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stut/gocassa"
+)
+
+// Backend is what the suite runs against
+type Backend struct {
+	// KeySpace returns the keyspace a test runs in. Tests recreate the tables
+	// they use, so it may return the same keyspace every time
+	KeySpace func(t *testing.T) gocassa.KeySpace
+	// Sleep lets d pass on the clock the tables of the keyspace tell the time
+	// by, for rows written with a TTL to expire. It defaults to time.Sleep
+	Sleep func(d time.Duration)
+	// Skip holds the names of the tests not to run, such as "TTL" for
+	// backends which are too slow to wait for rows to expire
+	Skip []string
+}
+
+// test is a test of the suite. Its name is the name of its subtest
+type test struct {
+	name string
+	run  func(t *testing.T, s *suite)
+}
+
+var tests = []test{
+	{"Table", testTable},
+	{"MapTable", testMapTable},
+	{"MultimapTable", testMultimapTable},
+	{"MultimapMkTable", testMultimapMkTable},
+	{"TimeSeriesTable", testTimeSeriesTable},
+	{"MultiTimeSeriesTable", testMultiTimeSeriesTable},
+	{"FlakeSeriesTable", testFlakeSeriesTable},
+	{"MultiFlakeSeriesTable", testMultiFlakeSeriesTable},
+	{"Ordering", testOrdering},
+	{"Limit", testLimit},
+	{"Select", testSelect},
+	{"TTL", testTTL},
+	{"Modifiers", testModifiers},
+	{"Counters", testCounters},
+	{"Batch", testBatch},
+}
+
+// Run runs the suite against the backend, each test as a subtest of t
+func Run(t *testing.T, b Backend) {
+	if b.KeySpace == nil {
+		t.Fatal("gocassatest: Backend.KeySpace is nil")
+	}
+	skip := map[string]bool{}
+	for _, name := range b.Skip {
+		skip[name] = true
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			if skip[tt.name] {
+				t.Skip("skipped by the backend")
+			}
+			tt.run(t, &suite{t: t, ks: b.KeySpace(t), sleep: b.Sleep})
+		})
+	}
+}
+
+// suite is what a test runs with
+type suite struct {
+	t     *testing.T
+	ks    gocassa.KeySpace
+	sleep func(time.Duration)
+}
+
+// recreate recreates a table, so that it's empty and has the schema of the
+// test
+func (s *suite) recreate(tbl gocassa.TableChanger) {
+	s.t.Helper()
+	if err := tbl.Recreate(); err != nil {
+		s.t.Fatalf("could not recreate table: %v", err)
+	}
+}
+
+// run runs op, failing the test if it returns an error
+func (s *suite) run(op gocassa.Op) {
+	s.t.Helper()
+	if err := op.Run(); err != nil {
+		s.t.Fatal(err)
+	}
+}
+
+// wait lets d pass
+func (s *suite) wait(d time.Duration) {
+	if s.sleep != nil {
+		s.sleep(d)
+		return
+	}
+	time.Sleep(d)
+}
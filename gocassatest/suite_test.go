@@ -0,0 +1,78 @@
+package gocassatest
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/stut/gocassa"
+)
+
+func TestMockKeySpace(t *testing.T) {
+	clock := gocassa.NewManualClock(time.Now())
+	Run(t, Backend{
+		KeySpace: func(t *testing.T) gocassa.KeySpace {
+			return gocassa.NewMockKeySpace(gocassa.MockClock(clock), gocassa.StrictMock())
+		},
+		Sleep: clock.Add,
+	})
+}
+
+// TestMockReplicas runs the suite against a mock keyspace with lagging
+// replicas, which ops at ALL see consistently
+func TestMockReplicas(t *testing.T) {
+	clock := gocassa.NewManualClock(time.Now())
+	Run(t, Backend{
+		KeySpace: func(t *testing.T) gocassa.KeySpace {
+			return gocassa.NewMockKeySpace(gocassa.MockClock(clock), gocassa.MockReplicas(gocassa.MockReplication{
+				Lag:         gocassa.FixedLatency(time.Minute),
+				Consistency: gocql.All,
+			}))
+		},
+		Sleep: clock.Add,
+	})
+}
+
+func TestInMemoryQueryExecutor(t *testing.T) {
+	clock := gocassa.NewManualClock(time.Now())
+	qe := gocassa.NewInMemoryQueryExecutor(gocassa.MockClock(clock))
+	Run(t, Backend{
+		KeySpace: func(t *testing.T) gocassa.KeySpace {
+			return gocassa.NewConnection(qe).KeySpace("test")
+		},
+		Sleep: clock.Add,
+	})
+}
+
+// TestCluster runs the suite against the Cassandra cluster given by
+// GOCASSA_TEST_HOSTS, if it's set
+func TestCluster(t *testing.T) {
+	hosts := os.Getenv("GOCASSA_TEST_HOSTS")
+	if hosts == "" {
+		t.Skip("GOCASSA_TEST_HOSTS isn't set")
+	}
+	cluster := gocql.NewCluster(strings.Split(hosts, ",")...)
+	cluster.Consistency = gocql.One
+	cluster.Timeout = 10 * time.Second
+	sess, err := cluster.CreateSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.Close()
+
+	conn := gocassa.NewConnection(gocassa.GoCQLSessionToQueryExecutor(sess))
+	const name = "gocassatest"
+	if err := conn.DropKeySpace(name); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.CreateKeySpace(name); err != nil {
+		t.Fatal(err)
+	}
+	Run(t, Backend{
+		KeySpace: func(t *testing.T) gocassa.KeySpace {
+			return conn.KeySpace(name)
+		},
+	})
+}
@@ -22,13 +22,24 @@ func (cb goCQLBackend) QueryWithOptions(opts Options, stmt Statement, scanner Sc
 	}
 
 	iter := qu.Iter()
-	if _, err := scanner.ScanIter(iter.Scanner()); err != nil {
+	if _, err := scanner.ScanIter(iterScanner{Scanner: iter.Scanner(), iter: iter}); err != nil {
 		return err
 	}
 
 	return iter.Close()
 }
 
+// iterScanner is the Scanner of an Iter, which also tells the columns of the
+// rows it scans
+type iterScanner struct {
+	gocql.Scanner
+	iter *gocql.Iter
+}
+
+func (s iterScanner) Columns() []gocql.ColumnInfo {
+	return s.iter.Columns()
+}
+
 func (cb goCQLBackend) Execute(stmt Statement) error {
 	return cb.ExecuteWithOptions(Options{}, stmt)
 }
@@ -0,0 +1,212 @@
+package gocassa
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+// WriteType is the kind of write a WriteEvent describes
+type WriteType int
+
+const (
+	SetWrite    WriteType = iota // a row was set, by Set or SetJSON
+	UpdateWrite                  // fields of rows were updated
+	DeleteWrite                  // rows, or columns of rows, were deleted
+)
+
+func (w WriteType) String() string {
+	switch w {
+	case SetWrite:
+		return "set"
+	case UpdateWrite:
+		return "update"
+	case DeleteWrite:
+		return "delete"
+	}
+	return fmt.Sprintf("WriteType(%d)", int(w))
+}
+
+// WriteEvent describes a successful write to a table
+type WriteEvent struct {
+	Keyspace string
+	Table    string
+	Type     WriteType
+	// Keys holds the values of the primary key fields of the rows written.
+	// Fields restricted with anything but Eq are left out, see Relations
+	Keys map[string]interface{}
+	// Relations are the relations updates and deletes were restricted by
+	Relations []Relation
+	// Values holds the fields written: the whole row for sets and the fields
+	// given (possibly Modifiers) for updates. It's nil for deletes
+	Values map[string]interface{}
+	// Columns holds the columns deleted by DeleteColumns
+	Columns []Selection
+	// Options are the options the write ran with
+	Options Options
+}
+
+// WriteHook is called with the event of each successful write. An error
+// returned by a hook is returned by Run, although the write has happened
+type WriteHook func(ctx context.Context, event WriteEvent) error
+
+// hookRegistry holds the write hooks and outbox of a keyspace
+type hookRegistry struct {
+	mu     sync.RWMutex
+	hooks  []WriteHook
+	outbox TimeSeriesTable
+}
+
+func (r *hookRegistry) add(hook WriteHook) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.hooks = append(r.hooks, hook)
+}
+
+func (r *hookRegistry) setOutbox(outbox TimeSeriesTable) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.outbox = outbox
+}
+
+// forWrite returns the hooks to call after a write to a table with the given
+// options, and the outbox to write its event to (nil if there isn't one).
+// Writes to the outbox itself aren't written to it again
+func (r *hookRegistry) forWrite(table string, opts Options) ([]WriteHook, TimeSeriesTable) {
+	r.mu.RLock()
+	hooks := append([]WriteHook{}, r.hooks...)
+	outbox := r.outbox
+	r.mu.RUnlock()
+
+	hooks = append(hooks, opts.WriteHooks...)
+	if opts.Outbox != nil {
+		outbox = opts.Outbox
+	}
+	if outbox != nil && outbox.Name() == table {
+		outbox = nil
+	}
+	return hooks, outbox
+}
+
+func runWriteHooks(hooks []WriteHook, e WriteEvent) error {
+	ctx := e.Options.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	for _, hook := range hooks {
+		if err := hook(ctx, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// keysFromRelations returns the values of the key fields restricted with Eq
+func keysFromRelations(keys Keys, rs []Relation) map[string]interface{} {
+	m := map[string]interface{}{}
+	for _, k := range append(keys.PartitionKeys, keys.ClusteringColumns...) {
+		for _, r := range rs {
+			if r.Comparator() == CmpEquality && strings.EqualFold(r.Field(), k) {
+				m[k] = r.Terms()[0]
+			}
+		}
+	}
+	return m
+}
+
+// keysFromValues returns the values of the key fields of a row
+func keysFromValues(keys Keys, row map[string]interface{}) map[string]interface{} {
+	m := map[string]interface{}{}
+	for _, k := range append(keys.PartitionKeys, keys.ClusteringColumns...) {
+		for f, v := range row {
+			if strings.EqualFold(f, k) {
+				m[k] = v
+			}
+		}
+	}
+	return m
+}
+
+// OutboxEvent is a row of an outbox table, recording a write. Keys and Values
+// are JSON objects; Modifiers are written as {"modifier": op, "args": [...]}
+type OutboxEvent struct {
+	Id       string // A timeuuid
+	Created  time.Time
+	Keyspace string
+	Table    string
+	Type     string
+	Keys     string
+	Values   string
+	Columns  []string
+}
+
+// OutboxTable returns a TimeSeriesTable for keeping OutboxEvents in. Set it as
+// the outbox with KeySpace.SetOutbox, or Options.Outbox, and read it back with
+// List or Follow
+func OutboxTable(ks KeySpace, name string, bucketer Bucketer) TimeSeriesTable {
+	return ks.TimeSeriesTableWithBucketer(name, "Created", "Id", bucketer, OutboxEvent{})
+}
+
+func newOutboxEvent(e WriteEvent) (OutboxEvent, error) {
+	clock := e.Options.Clock
+	if clock == nil {
+		clock = systemClock
+	}
+	now := clock.Now()
+
+	keys, err := json.Marshal(outboxValues(e.Keys))
+	if err != nil {
+		return OutboxEvent{}, err
+	}
+	values := []byte("null")
+	if e.Values != nil {
+		if values, err = json.Marshal(outboxValues(e.Values)); err != nil {
+			return OutboxEvent{}, err
+		}
+	}
+	var columns []string
+	for _, c := range e.Columns {
+		if elem, ok := c.Element(); ok {
+			columns = append(columns, fmt.Sprintf("%s[%v]", c.Column(), elem))
+		} else {
+			columns = append(columns, c.Column())
+		}
+	}
+
+	return OutboxEvent{
+		Id:       gocql.UUIDFromTime(now).String(),
+		Created:  now,
+		Keyspace: e.Keyspace,
+		Table:    e.Table,
+		Type:     e.Type.String(),
+		Keys:     string(keys),
+		Values:   string(values),
+		Columns:  columns,
+	}, nil
+}
+
+func outboxValues(m map[string]interface{}) map[string]interface{} {
+	ret := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		if mod, ok := v.(Modifier); ok {
+			v = map[string]interface{}{"modifier": mod.Operation(), "args": mod.Args()}
+		}
+		ret[k] = v
+	}
+	return ret
+}
+
+// outboxWriter is implemented by ops which write an event to an outbox and
+// call write hooks, so batches can include the outbox writes of their ops
+type outboxWriter interface {
+	// outboxStatement returns the statement writing the event of the op to the
+	// outbox, and false if there's nothing to write
+	outboxStatement() (Statement, bool, error)
+	// runWriteHooks calls the write hooks once the op has succeeded
+	runWriteHooks() error
+}
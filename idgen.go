@@ -0,0 +1,73 @@
+package gocassa
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/mattheath/base62"
+	"github.com/mattheath/kala/bigflake"
+	"github.com/mattheath/kala/util"
+)
+
+// maxWorkerID is the largest worker id which fits in a bigflake
+const maxWorkerID = 1<<48 - 1
+
+// maxSequence is the number of ids a worker can generate in a millisecond
+const maxSequence = 1<<16 - 1
+
+// IDGenerator generates ids for the flake series recipes: a prefix, an
+// underscore and a base62 encoded bigflake (see BigflakeIDs). The ids of a
+// generator are strictly increasing, even if its clock goes backwards or more
+// ids are generated in a millisecond than a bigflake has room for. In those
+// cases the ids carry on from the last millisecond used, so their time can be
+// slightly ahead of the clock
+type IDGenerator struct {
+	mu       sync.Mutex
+	prefix   string
+	workerID int64
+	clock    Clock
+	lastMs   int64
+	sequence int64
+}
+
+// NewIDGenerator returns an IDGenerator prefixing its ids with prefix. Every
+// process generating ids at the same time needs its own worker id, which can
+// be up to 48 bits. If clock is nil the system clock is used
+func NewIDGenerator(prefix string, workerID uint64, clock Clock) (*IDGenerator, error) {
+	if workerID > maxWorkerID {
+		return nil, fmt.Errorf("worker id %d does not fit in 48 bits", workerID)
+	}
+	if clock == nil {
+		clock = systemClock
+	}
+	return &IDGenerator{
+		prefix:   prefix,
+		workerID: int64(workerID),
+		clock:    clock,
+	}, nil
+}
+
+// NewID generates an id
+func (g *IDGenerator) NewID() (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ms := util.TimeToMsInt64(g.clock.Now())
+	if ms < 0 {
+		return "", errors.New("can't generate ids for times before the Unix epoch")
+	}
+	switch {
+	case ms > g.lastMs:
+		g.lastMs = ms
+		g.sequence = 0
+	case g.sequence < maxSequence:
+		g.sequence++
+	default:
+		g.lastMs++
+		g.sequence = 0
+	}
+
+	id := bigflake.MintId(g.lastMs, g.workerID, g.sequence)
+	return g.prefix + "_" + base62.EncodeBigInt(id), nil
+}
@@ -0,0 +1,97 @@
+package gocassa
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIDGenerator(t *testing.T) {
+	now := time.Date(2015, 4, 1, 15, 41, 0, 0, time.UTC)
+	clock := NewManualClock(now)
+	gen, err := NewIDGenerator("event", 7, clock)
+	require.NoError(t, err)
+
+	first, err := gen.NewID()
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(first, "event_"))
+	tm, err := BigflakeIDs().Time(first)
+	require.NoError(t, err)
+	assert.True(t, now.Equal(tm))
+
+	// Ids keep increasing while the clock stands still or goes backwards
+	ids := []string{first}
+	for i := 0; i < 3; i++ {
+		id, err := gen.NewID()
+		require.NoError(t, err)
+		ids = append(ids, id)
+	}
+	clock.Add(-time.Second)
+	id, err := gen.NewID()
+	require.NoError(t, err)
+	ids = append(ids, id)
+	clock.Add(time.Minute)
+	id, err = gen.NewID()
+	require.NoError(t, err)
+	ids = append(ids, id)
+
+	for i := 1; i < len(ids); i++ {
+		cmp, err := BigflakeIDs().Compare(ids[i], ids[i-1])
+		require.NoError(t, err)
+		assert.True(t, cmp > 0, "%s should be after %s", ids[i], ids[i-1])
+	}
+	tm, err = BigflakeIDs().Time(ids[len(ids)-1])
+	require.NoError(t, err)
+	assert.True(t, now.Add(59*time.Second).Equal(tm))
+
+	_, err = NewIDGenerator("event", 1<<48, nil)
+	assert.Error(t, err)
+}
+
+func TestIDGeneratorSequenceOverflow(t *testing.T) {
+	now := time.Date(2015, 4, 1, 15, 41, 0, 0, time.UTC)
+	gen, err := NewIDGenerator("event", 1, NewManualClock(now))
+	require.NoError(t, err)
+
+	var id string
+	for i := 0; i <= maxSequence+1; i++ {
+		id, err = gen.NewID()
+		require.NoError(t, err)
+	}
+	// The ids carry on into the next millisecond
+	tm, err := BigflakeIDs().Time(id)
+	require.NoError(t, err)
+	assert.True(t, now.Add(time.Millisecond).Equal(tm))
+}
+
+func TestIDGeneratorWithMockKeySpace(t *testing.T) {
+	type event struct {
+		Id   string
+		Body string
+	}
+	now := time.Date(2015, 4, 1, 15, 41, 0, 0, time.UTC)
+	clock := NewManualClock(now)
+	gen, err := NewIDGenerator("event", 1, clock)
+	require.NoError(t, err)
+	tbl := NewMockKeySpace().FlakeSeriesTable("events", "Id", time.Minute, event{})
+
+	since, err := gen.NewID()
+	require.NoError(t, err)
+	require.NoError(t, tbl.Set(event{Id: since, Body: "first"}).Run())
+	expected := []event{}
+	for _, d := range []time.Duration{0, time.Second, time.Minute} {
+		clock.Add(d)
+		id, err := gen.NewID()
+		require.NoError(t, err)
+		e := event{Id: id, Body: d.String()}
+		require.NoError(t, tbl.Set(e).Run())
+		expected = append(expected, e)
+	}
+
+	var events []event
+	require.NoError(t, tbl.ListSince(since, 2*time.Minute, &events).Run())
+	assert.Equal(t, expected, events)
+}
@@ -0,0 +1,251 @@
+package gocassa
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/mattheath/base62"
+)
+
+// IDTimeExtractor decodes the ids of the flake series recipes, which have the
+// time they were generated at embedded in them
+type IDTimeExtractor interface {
+	// Time returns the time id was generated at
+	Time(id string) (time.Time, error)
+	// Compare returns a negative number when a sorts before b, a positive one
+	// when it sorts after b and zero when they are equal
+	Compare(a, b string) (int, error)
+}
+
+type bigflakeIDs struct{}
+
+// BigflakeIDs decodes ids made up of a prefix, an underscore and a base62
+// encoded bigflake (see github.com/mattheath/kala). This is the scheme used by
+// the flake series recipes unless another one is given
+func BigflakeIDs() IDTimeExtractor {
+	return bigflakeIDs{}
+}
+
+func (bigflakeIDs) Time(id string) (time.Time, error) {
+	return flakeToTime(id)
+}
+
+func (bigflakeIDs) Compare(a, b string) (int, error) {
+	x, err := bigflakeInt(a)
+	if err != nil {
+		return 0, err
+	}
+	y, err := bigflakeInt(b)
+	if err != nil {
+		return 0, err
+	}
+	return x.Cmp(y), nil
+}
+
+func bigflakeInt(id string) (*big.Int, error) {
+	i := strings.LastIndex(id, "_")
+	if i < 0 {
+		return nil, errors.New("Invalid flake id")
+	}
+	return base62.DecodeToBigInt(id[i+1:]), nil
+}
+
+const crockfordAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+type ulidIDs struct{}
+
+// ULIDs decodes ULIDs (see https://github.com/ulid/spec), in their canonical
+// 26 character Crockford base32 form
+func ULIDs() IDTimeExtractor {
+	return ulidIDs{}
+}
+
+func (ulidIDs) parse(id string) (string, error) {
+	id = strings.ToUpper(id)
+	if len(id) != 26 || id[0] > '7' {
+		return "", fmt.Errorf("Invalid ULID %q", id)
+	}
+	for _, c := range id {
+		if !strings.ContainsRune(crockfordAlphabet, c) {
+			return "", fmt.Errorf("Invalid ULID %q", id)
+		}
+	}
+	return id, nil
+}
+
+func (u ulidIDs) Time(id string) (time.Time, error) {
+	id, err := u.parse(id)
+	if err != nil {
+		return time.Time{}, err
+	}
+	var ms int64
+	for _, c := range id[:10] {
+		ms = ms<<5 | int64(strings.IndexRune(crockfordAlphabet, c))
+	}
+	return time.Unix(0, ms*int64(time.Millisecond)), nil
+}
+
+func (u ulidIDs) Compare(a, b string) (int, error) {
+	a, err := u.parse(a)
+	if err != nil {
+		return 0, err
+	}
+	b, err = u.parse(b)
+	if err != nil {
+		return 0, err
+	}
+	return strings.Compare(a, b), nil
+}
+
+// ksuidEpoch is the start of KSUID time, in seconds since the Unix epoch
+const ksuidEpoch = 1400000000
+
+const base62Alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+type ksuidIDs struct{}
+
+// KSUIDs decodes KSUIDs (see https://github.com/segmentio/ksuid), in their
+// 27 character base62 form
+func KSUIDs() IDTimeExtractor {
+	return ksuidIDs{}
+}
+
+func (ksuidIDs) parse(id string) ([]byte, error) {
+	if len(id) != 27 {
+		return nil, fmt.Errorf("Invalid KSUID %q", id)
+	}
+	n := new(big.Int)
+	for _, c := range id {
+		i := strings.IndexRune(base62Alphabet, c)
+		if i < 0 {
+			return nil, fmt.Errorf("Invalid KSUID %q", id)
+		}
+		n.Mul(n, big.NewInt(62))
+		n.Add(n, big.NewInt(int64(i)))
+	}
+	if n.BitLen() > 160 {
+		return nil, fmt.Errorf("Invalid KSUID %q", id)
+	}
+	b := make([]byte, 20)
+	return n.FillBytes(b), nil
+}
+
+func (k ksuidIDs) Time(id string) (time.Time, error) {
+	b, err := k.parse(id)
+	if err != nil {
+		return time.Time{}, err
+	}
+	secs := int64(b[0])<<24 | int64(b[1])<<16 | int64(b[2])<<8 | int64(b[3])
+	return time.Unix(ksuidEpoch+secs, 0), nil
+}
+
+func (k ksuidIDs) Compare(a, b string) (int, error) {
+	x, err := k.parse(a)
+	if err != nil {
+		return 0, err
+	}
+	y, err := k.parse(b)
+	if err != nil {
+		return 0, err
+	}
+	return bytes.Compare(x, y), nil
+}
+
+type timeUUIDIDs struct{}
+
+// TimeUUIDs decodes version 1 (time based) UUIDs, such as those generated by
+// gocql.TimeUUID
+func TimeUUIDs() IDTimeExtractor {
+	return timeUUIDIDs{}
+}
+
+func (timeUUIDIDs) parse(id string) (gocql.UUID, error) {
+	u, err := gocql.ParseUUID(id)
+	if err != nil {
+		return u, err
+	}
+	if u.Version() != 1 {
+		return u, fmt.Errorf("Invalid timeuuid %q: version %d", id, u.Version())
+	}
+	return u, nil
+}
+
+func (t timeUUIDIDs) Time(id string) (time.Time, error) {
+	u, err := t.parse(id)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return u.Time(), nil
+}
+
+// Compare orders timeuuids by their time first, as Cassandra does
+func (t timeUUIDIDs) Compare(a, b string) (int, error) {
+	x, err := t.parse(a)
+	if err != nil {
+		return 0, err
+	}
+	y, err := t.parse(b)
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case x.Timestamp() < y.Timestamp():
+		return -1, nil
+	case x.Timestamp() > y.Timestamp():
+		return 1, nil
+	}
+	return bytes.Compare(x[8:], y[8:]), nil
+}
+
+// TwitterSnowflakeEpoch is the epoch of the Snowflake ids generated by Twitter
+var TwitterSnowflakeEpoch = time.Unix(0, 1288834974657*int64(time.Millisecond))
+
+type snowflakeIDs struct {
+	epoch time.Time
+}
+
+// SnowflakeIDs decodes Snowflake ids in decimal form. All but the lowest 22
+// bits of an id are the number of milliseconds since epoch
+func SnowflakeIDs(epoch time.Time) IDTimeExtractor {
+	return snowflakeIDs{epoch: epoch}
+}
+
+func (snowflakeIDs) parse(id string) (uint64, error) {
+	n, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid snowflake id %q", id)
+	}
+	return n, nil
+}
+
+func (s snowflakeIDs) Time(id string) (time.Time, error) {
+	n, err := s.parse(id)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return s.epoch.Add(time.Duration(n>>22) * time.Millisecond), nil
+}
+
+func (s snowflakeIDs) Compare(a, b string) (int, error) {
+	x, err := s.parse(a)
+	if err != nil {
+		return 0, err
+	}
+	y, err := s.parse(b)
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case x < y:
+		return -1, nil
+	case x > y:
+		return 1, nil
+	}
+	return 0, nil
+}
@@ -0,0 +1,94 @@
+package gocassa
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestULIDs(t *testing.T) {
+	ids := ULIDs()
+	tm, err := ids.Time("01ARZ3NDEKTSV4RRFFQ69G5FAV")
+	assert.NoError(t, err)
+	assert.True(t, time.Unix(0, 1469922850259*int64(time.Millisecond)).Equal(tm))
+
+	cmp, err := ids.Compare("01arz3ndektsv4rrffq69g5fav", "01ARZ3NDEKTSV4RRFFQ69G5FAW")
+	assert.NoError(t, err)
+	assert.True(t, cmp < 0)
+
+	_, err = ids.Time("81ARZ3NDEKTSV4RRFFQ69G5FAV")
+	assert.Error(t, err)
+	_, err = ids.Time("01ARZ3NDEKTSV4RRFFQ69G5FA")
+	assert.Error(t, err)
+}
+
+func TestKSUIDs(t *testing.T) {
+	ids := KSUIDs()
+	tm, err := ids.Time("0ujtsYcgvSTl8PAuAdqWYSMnLOv")
+	assert.NoError(t, err)
+	assert.True(t, time.Unix(1507608047, 0).Equal(tm))
+
+	cmp, err := ids.Compare("0ujtsYcgvSTl8PAuAdqWYSMnLOv", "0ujtsYcgvSTl8PAuAdqWYSMnLOv")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, cmp)
+
+	_, err = ids.Time("0ujtsYcgvSTl8PAuAdqWYSMnLO_")
+	assert.Error(t, err)
+}
+
+func TestTimeUUIDs(t *testing.T) {
+	ids := TimeUUIDs()
+	tm := time.Date(2015, 4, 1, 15, 41, 0, 0, time.UTC)
+	earlier := gocql.UUIDFromTime(tm).String()
+	later := gocql.UUIDFromTime(tm.Add(5 * time.Minute)).String()
+
+	decoded, err := ids.Time(earlier)
+	assert.NoError(t, err)
+	assert.True(t, tm.Equal(decoded))
+
+	// The text of a timeuuid starts with the lowest bits of its time
+	assert.True(t, later < earlier)
+	cmp, err := ids.Compare(earlier, later)
+	assert.NoError(t, err)
+	assert.True(t, cmp < 0)
+
+	random, err := gocql.RandomUUID()
+	assert.NoError(t, err)
+	_, err = ids.Time(random.String())
+	assert.Error(t, err)
+}
+
+func TestSnowflakeIDs(t *testing.T) {
+	ids := SnowflakeIDs(TwitterSnowflakeEpoch)
+	tm := time.Date(2015, 4, 1, 15, 41, 0, 0, time.UTC)
+	ms := uint64(tm.Sub(TwitterSnowflakeEpoch) / time.Millisecond)
+	id := strconv.FormatUint(ms<<22|42, 10)
+
+	decoded, err := ids.Time(id)
+	assert.NoError(t, err)
+	assert.True(t, tm.Equal(decoded))
+
+	cmp, err := ids.Compare("999", "1000")
+	assert.NoError(t, err)
+	assert.True(t, cmp < 0)
+
+	_, err = ids.Time("id_1")
+	assert.Error(t, err)
+}
+
+func TestBigflakeIDs(t *testing.T) {
+	ids := BigflakeIDs()
+	earlier := timeToFlake(t, "2006 Jan 2 15:03:59")
+	later := timeToFlake(t, "2006 Jan 2 15:04:00")
+
+	decoded, err := ids.Time(earlier)
+	assert.NoError(t, err)
+	assert.True(t, parse("2006 Jan 2 15:03:59").Equal(decoded))
+
+	cmp, err := ids.Compare(later, earlier)
+	assert.NoError(t, err)
+	assert.True(t, cmp > 0)
+}
@@ -2,6 +2,7 @@ package gocassa
 
 import (
 	"context"
+	"encoding/json"
 	"time"
 )
 
@@ -57,10 +58,45 @@ type KeySpace interface {
 	*/
 	FlakeSeriesTable(prefixForTableName, flakeIDField string, bucketSize time.Duration, rowDefinition interface{}) FlakeSeriesTable
 	MultiFlakeSeriesTable(prefixForTableName, partitionKey, flakeIDField string, bucketSize time.Duration, rowDefinition interface{}) MultiFlakeSeriesTable
+	/*
+		The WithBucketer variants of the time series and flake series recipes take a Bucketer instead of a bucket
+		size, for buckets which line up with calendar days, weeks or months or follow a custom scheme.
+		See FixedBuckets, CalendarBuckets and BucketFuncs.
+	*/
+	TimeSeriesTableWithBucketer(prefixForTableName, timeField, clusteringKey string, bucketer Bucketer, rowDefinition interface{}) TimeSeriesTable
+	MultiTimeSeriesTableWithBucketer(prefixForTableName, partitionKey, timeField, clusteringKey string, bucketer Bucketer, rowDefinition interface{}) MultiTimeSeriesTable
+	MultiKeyTimeSeriesTableWithBucketer(prefixForTableName string, partitionKeys []string, timeField string, clusteringKeys []string, bucketer Bucketer, rowDefinition interface{}) MultiKeyTimeSeriesTable
+	FlakeSeriesTableWithBucketer(prefixForTableName, flakeIDField string, bucketer Bucketer, rowDefinition interface{}) FlakeSeriesTable
+	MultiFlakeSeriesTableWithBucketer(prefixForTableName, partitionKey, flakeIDField string, bucketer Bucketer, rowDefinition interface{}) MultiFlakeSeriesTable
+	/*
+		The WithIDs variants of the flake series recipes decode the time from ids using the given IDTimeExtractor,
+		rather than expecting base62 encoded bigflakes. See BigflakeIDs, ULIDs, KSUIDs, TimeUUIDs and SnowflakeIDs.
+	*/
+	FlakeSeriesTableWithIDs(prefixForTableName, flakeIDField string, bucketer Bucketer, ids IDTimeExtractor, rowDefinition interface{}) FlakeSeriesTable
+	MultiFlakeSeriesTableWithIDs(prefixForTableName, partitionKey, flakeIDField string, bucketer Bucketer, ids IDTimeExtractor, rowDefinition interface{}) MultiFlakeSeriesTable
+	/*
+		ShardedTimeSeriesTable and ShardedFlakeSeriesTable spread the rows of each bucket over a number of
+		partitions, determined by a hash of the id, to avoid hot partitions when writing.
+		Listing queries every shard of a bucket separately and merges the results in time order.
+		ShardedFlakeSeriesTableWithIDs decodes the time from ids using the given IDTimeExtractor.
+	*/
+	ShardedTimeSeriesTable(prefixForTableName, timeField, clusteringKey string, shards int, bucketer Bucketer, rowDefinition interface{}) TimeSeriesTable
+	ShardedFlakeSeriesTable(prefixForTableName, flakeIDField string, shards int, bucketer Bucketer, rowDefinition interface{}) FlakeSeriesTable
+	ShardedFlakeSeriesTableWithIDs(prefixForTableName, flakeIDField string, shards int, bucketer Bucketer, ids IDTimeExtractor, rowDefinition interface{}) FlakeSeriesTable
+	/*
+		EntityTable keeps rows in a MapTable by idField, along with a table for each of the views, so rows can also
+		be listed by the value of a field or by time. The views are kept in sync on every write.
+	*/
+	EntityTable(prefixForTableName, idField string, rowDefinition interface{}, views ...EntityView) EntityTable
 	Table(prefixForTableName string, rowDefinition interface{}, keys Keys) Table
 	// DebugMode enables/disables debug mode depending on the value of the input boolean.
 	// When DebugMode is enabled, all built CQL statements are printe to stdout.
 	DebugMode(bool)
+	// AddWriteHook adds a hook called after each successful write to the tables of the keyspace
+	AddWriteHook(hook WriteHook)
+	// SetOutbox makes writes to the tables of the keyspace also write an OutboxEvent to outbox, in the same
+	// logged batch. See OutboxTable
+	SetOutbox(outbox TimeSeriesTable)
 	// Name returns the keyspace name as in C*
 	Name() string
 	// Tables returns the name of all configured column families in this keyspace
@@ -80,8 +116,17 @@ type MapTable interface {
 	Set(rowStruct interface{}) Op
 	Update(partitionKey interface{}, valuesToUpdate map[string]interface{}) Op
 	Delete(partitionKey interface{}) Op
+	// DeleteColumns deletes only the selected columns, map entries or list elements of a row
+	DeleteColumns(partitionKey interface{}, columns ...Selection) Op
 	Read(partitionKey, pointer interface{}) Op
 	MultiRead(partitionKeys []interface{}, pointerToASlice interface{}) Op
+	// SetJSON inserts a row from a JSON document keyed by column name. def determines whether columns
+	// missing from the document are set to null or left untouched
+	SetJSON(document []byte, def JSONDefault) Op
+	// ReadJSON reads a single row as a JSON document keyed by column name
+	ReadJSON(partitionKey interface{}, pointer *json.RawMessage) Op
+	// MultiReadJSON reads the rows matching the keys provided as JSON documents
+	MultiReadJSON(partitionKeys []interface{}, pointerToASlice *[]json.RawMessage) Op
 	WithOptions(Options) MapTable
 	Table() Table
 	TableChanger
@@ -97,11 +142,26 @@ type MultimapTable interface {
 	Set(rowStruct interface{}) Op
 	Update(value, id interface{}, valuesToUpdate map[string]interface{}) Op
 	Delete(value, id interface{}) Op
+	// DeleteColumns deletes only the selected columns, map entries or list elements of a row
+	DeleteColumns(value, id interface{}, columns ...Selection) Op
 	DeleteAll(value interface{}) Op
+	// DeleteRange deletes the rows of a partition with clustering keys from startId (inclusive) up to
+	// endId (exclusive). A nil startId or endId leaves that end of the range open
+	DeleteRange(value, startId, endId interface{}) Op
 	// List populates the provided pointer to a slice with the results matching the keys provided.
 	// To disable the limit, set limit to 0
 	List(partitionKey, clusteringKey interface{}, limit int, pointerToASlice interface{}) Op
+	// ListRange populates the provided pointer to a slice with the rows of a partition within the clustering
+	// range, in the range's direction. To disable the limit, set limit to 0
+	ListRange(partitionKey interface{}, r ClusteringRange, limit int, pointerToASlice interface{}) Op
 	Read(partitionKey, clusteringKey, pointer interface{}) Op
+	// SetJSON inserts a row from a JSON document keyed by column name. def determines whether columns
+	// missing from the document are set to null or left untouched
+	SetJSON(document []byte, def JSONDefault) Op
+	// ListJSON is like List but populates the provided slice with JSON documents keyed by column name
+	ListJSON(partitionKey, clusteringKey interface{}, limit int, pointerToASlice *[]json.RawMessage) Op
+	// ReadJSON reads a single row as a JSON document keyed by column name
+	ReadJSON(partitionKey, clusteringKey interface{}, pointer *json.RawMessage) Op
 	WithOptions(Options) MultimapTable
 	Table() Table
 	TableChanger
@@ -113,12 +173,26 @@ type MultimapMkTable interface {
 	Set(rowStruct interface{}) Op
 	Update(v, id map[string]interface{}, valuesToUpdate map[string]interface{}) Op
 	Delete(v, id map[string]interface{}) Op
+	// DeleteColumns deletes only the selected columns, map entries or list elements of a row
+	DeleteColumns(v, id map[string]interface{}, columns ...Selection) Op
 	DeleteAll(v map[string]interface{}) Op
 	// List populates the provided pointer to a slice with the results matching the keys provided.
 	// To disable the limit, set limit to 0
 	List(v, startId map[string]interface{}, limit int, pointerToASlice interface{}) Op
+	// ListRange populates the provided pointer to a slice with the rows of a partition within the clustering
+	// range, in the range's direction. The bounds of the range must be maps of id fields to values. The range
+	// applies to the last id field the bounds hold, and they must hold the same values of the id fields before it.
+	// To disable the limit, set limit to 0
+	ListRange(v map[string]interface{}, r ClusteringRange, limit int, pointerToASlice interface{}) Op
 	Read(v, id map[string]interface{}, pointer interface{}) Op
 	MultiRead(v, id map[string]interface{}, pointerToASlice interface{}) Op
+	// SetJSON inserts a row from a JSON document keyed by column name. def determines whether columns
+	// missing from the document are set to null or left untouched
+	SetJSON(document []byte, def JSONDefault) Op
+	// ListJSON is like List but populates the provided slice with JSON documents keyed by column name
+	ListJSON(v, startId map[string]interface{}, limit int, pointerToASlice *[]json.RawMessage) Op
+	// ReadJSON reads a single row as a JSON document keyed by column name
+	ReadJSON(v, id map[string]interface{}, pointer *json.RawMessage) Op
 	WithOptions(Options) MultimapMkTable
 	Table() Table
 	TableChanger
@@ -138,7 +212,31 @@ type TimeSeriesTable interface {
 	Delete(timeStamp time.Time, id interface{}) Op
 	Read(timeStamp time.Time, id, pointer interface{}) Op
 	List(start, end time.Time, pointerToASlice interface{}) Op
+	// DeleteRange deletes the rows List would return for the same time range
+	DeleteRange(start, end time.Time) Op
+	// Latest populates the provided pointer to a slice with the n most recent rows, newest first.
+	// Buckets are queried one at a time, going back no more than MaxBuckets buckets
+	Latest(n int, pointerToASlice interface{}) Op
+	// ListBefore populates the provided pointer to a slice with up to n rows from before t, newest first.
+	// Buckets are queried one at a time, going back no more than MaxBuckets buckets
+	ListBefore(t time.Time, n int, pointerToASlice interface{}) Op
+	// ListAfter populates the provided pointer to a slice with up to n rows from after t, oldest first.
+	// Buckets are queried one at a time, going forward no more than MaxBuckets buckets
+	ListAfter(t time.Time, n int, pointerToASlice interface{}) Op
 	Buckets(start time.Time) Buckets
+	// SetJSON inserts a row from a JSON document keyed by column name, which must hold the time and id
+	// columns. def determines whether columns missing from the document are set to null or left untouched
+	SetJSON(document []byte, def JSONDefault) Op
+	// ReadJSON reads a single row as a JSON document keyed by column name
+	ReadJSON(timeStamp time.Time, id interface{}, pointer *json.RawMessage) Op
+	// ListJSON is like List but populates the provided slice with JSON documents keyed by column name.
+	// It reads every bucket with a single query, so BucketConcurrency doesn't apply to it
+	ListJSON(start, end time.Time, pointerToASlice *[]json.RawMessage) Op
+	// Follow calls handler with every row from from onwards, oldest first, polling for new rows until ctx is
+	// done or handler returns an error. A zero from starts from the current time. Follow moves on to the next
+	// bucket once the time of the current one has passed, so rows written to a bucket after that are missed.
+	// See the PollInterval, MaxPollInterval, Checkpoints and Limit (rows read at once) options
+	Follow(ctx context.Context, from time.Time, handler FollowHandler) error
 	WithOptions(Options) TimeSeriesTable
 	Table() Table
 	TableChanger
@@ -158,7 +256,26 @@ type MultiTimeSeriesTable interface {
 	Delete(v interface{}, timeStamp time.Time, id interface{}) Op
 	Read(v interface{}, timeStamp time.Time, id, pointer interface{}) Op
 	List(v interface{}, start, end time.Time, pointerToASlice interface{}) Op
+	// DeleteRange deletes the rows List would return for the same time range
+	DeleteRange(v interface{}, start, end time.Time) Op
+	// Latest populates the provided pointer to a slice with the n most recent rows, newest first.
+	// Buckets are queried one at a time, going back no more than MaxBuckets buckets
+	Latest(v interface{}, n int, pointerToASlice interface{}) Op
+	// ListBefore populates the provided pointer to a slice with up to n rows from before t, newest first.
+	// Buckets are queried one at a time, going back no more than MaxBuckets buckets
+	ListBefore(v interface{}, t time.Time, n int, pointerToASlice interface{}) Op
+	// ListAfter populates the provided pointer to a slice with up to n rows from after t, oldest first.
+	// Buckets are queried one at a time, going forward no more than MaxBuckets buckets
+	ListAfter(v interface{}, t time.Time, n int, pointerToASlice interface{}) Op
 	Buckets(v interface{}, start time.Time) Buckets
+	// SetJSON inserts a row from a JSON document keyed by column name, which must hold the index, time and
+	// id columns. def determines whether columns missing from the document are set to null or left untouched
+	SetJSON(document []byte, def JSONDefault) Op
+	// ReadJSON reads a single row as a JSON document keyed by column name
+	ReadJSON(v interface{}, timeStamp time.Time, id interface{}, pointer *json.RawMessage) Op
+	// ListJSON is like List but populates the provided slice with JSON documents keyed by column name.
+	// It reads every bucket with a single query, so BucketConcurrency doesn't apply to it
+	ListJSON(v interface{}, start, end time.Time, pointerToASlice *[]json.RawMessage) Op
 	WithOptions(Options) MultiTimeSeriesTable
 	Table() Table
 	TableChanger
@@ -175,12 +292,58 @@ type MultiKeyTimeSeriesTable interface {
 	Delete(v map[string]interface{}, timeStamp time.Time, id map[string]interface{}) Op
 	Read(v map[string]interface{}, timeStamp time.Time, id map[string]interface{}, pointer interface{}) Op
 	List(v map[string]interface{}, start, end time.Time, pointerToASlice interface{}) Op
+	// DeleteRange deletes the rows List would return for the same time range
+	DeleteRange(v map[string]interface{}, start, end time.Time) Op
+	// Latest populates the provided pointer to a slice with the n most recent rows, newest first.
+	// Buckets are queried one at a time, going back no more than MaxBuckets buckets
+	Latest(v map[string]interface{}, n int, pointerToASlice interface{}) Op
+	// ListBefore populates the provided pointer to a slice with up to n rows from before t, newest first.
+	// Buckets are queried one at a time, going back no more than MaxBuckets buckets
+	ListBefore(v map[string]interface{}, t time.Time, n int, pointerToASlice interface{}) Op
+	// ListAfter populates the provided pointer to a slice with up to n rows from after t, oldest first.
+	// Buckets are queried one at a time, going forward no more than MaxBuckets buckets
+	ListAfter(v map[string]interface{}, t time.Time, n int, pointerToASlice interface{}) Op
 	Buckets(v map[string]interface{}, start time.Time) Buckets
+	// SetJSON inserts a row from a JSON document keyed by column name, which must hold the index, time and
+	// id columns. def determines whether columns missing from the document are set to null or left untouched
+	SetJSON(document []byte, def JSONDefault) Op
+	// ReadJSON reads a single row as a JSON document keyed by column name
+	ReadJSON(v map[string]interface{}, timeStamp time.Time, id map[string]interface{}, pointer *json.RawMessage) Op
+	// ListJSON is like List but populates the provided slice with JSON documents keyed by column name.
+	// It reads every bucket with a single query, so BucketConcurrency doesn't apply to it
+	ListJSON(v map[string]interface{}, start, end time.Time, pointerToASlice *[]json.RawMessage) Op
 	WithOptions(Options) MultiKeyTimeSeriesTable
 	Table() Table
 	TableChanger
 }
 
+// EntityTable writes every row to a MapTable and to the tables of its views, with a single Op. As writes need to
+// know where the views keep the current version of a row, they read it first when the Op is run: a write which
+// changes the value of a field a view is keyed by moves the row in that view. The Op can be run as a logged batch,
+// and ops added to it are run in the same batch. Writes to the same row at the same time can leave stale rows in
+// the views
+type EntityTable interface {
+	// Set writes the row to every table, replacing the current version of it
+	Set(rowStruct interface{}) Op
+	// Update updates the fields of the row in every table. It returns a RowNotFoundError if there's no such row
+	Update(id interface{}, valuesToUpdate map[string]interface{}) Op
+	// Delete deletes the row from every table
+	Delete(id interface{}) Op
+	Read(id, pointer interface{}) Op
+	// Map returns the table keeping rows by id
+	Map() MapTable
+	// Multimap returns the table of the MultimapView of the field, or nil if there isn't one
+	Multimap(fieldToIndexBy string) MultimapTable
+	// TimeSeries returns the table of the TimeSeriesView of the field, or nil if there isn't one
+	TimeSeries(timeField string) TimeSeriesTable
+	// WithOptions applies options to every table. The TableName option is ignored
+	WithOptions(Options) EntityTable
+	// Create, CreateIfNotExist and Recreate act on every table
+	Create() error
+	CreateIfNotExist() error
+	Recreate() error
+}
+
 type FlakeSeriesTable interface {
 	// Set Inserts, or Replaces your row with the supplied struct. Be aware that what is not in your struct
 	// will be deleted. To only overwrite some of the fields, Update()
@@ -189,10 +352,35 @@ type FlakeSeriesTable interface {
 	Delete(id string) Op
 	Read(id string, pointer interface{}) Op
 	List(start, end time.Time, pointerToASlice interface{}) Op
+	// DeleteRange deletes the rows List would return for the same time range
+	DeleteRange(start, end time.Time) Op
+	// Latest populates the provided pointer to a slice with the n most recent rows, newest first.
+	// Buckets are queried one at a time, going back no more than MaxBuckets buckets
+	Latest(n int, pointerToASlice interface{}) Op
+	// ListBefore populates the provided pointer to a slice with up to n rows from before t, newest first.
+	// Buckets are queried one at a time, going back no more than MaxBuckets buckets
+	ListBefore(t time.Time, n int, pointerToASlice interface{}) Op
+	// ListAfter populates the provided pointer to a slice with up to n rows from after t, oldest first.
+	// Buckets are queried one at a time, going forward no more than MaxBuckets buckets
+	ListAfter(t time.Time, n int, pointerToASlice interface{}) Op
 	Buckets(start time.Time) Buckets
 	// ListSince queries the flakeSeries for the items after the specified ID but within the time window,
-	// if the time window is zero then it lists up until 5 minutes in the future
+	// if the time window is zero then it lists up until 5 minutes in the future. IDs are compared using the
+	// IDTimeExtractor of the table
 	ListSince(id string, window time.Duration, pointerToASlice interface{}) Op
+	// SetJSON inserts a row from a JSON document keyed by column name, which must hold the id column.
+	// def determines whether columns missing from the document are set to null or left untouched
+	SetJSON(document []byte, def JSONDefault) Op
+	// ReadJSON reads a single row as a JSON document keyed by column name
+	ReadJSON(id string, pointer *json.RawMessage) Op
+	// ListJSON is like List but populates the provided slice with JSON documents keyed by column name.
+	// It reads every bucket with a single query, so BucketConcurrency doesn't apply to it
+	ListJSON(start, end time.Time, pointerToASlice *[]json.RawMessage) Op
+	// Follow calls handler with every row after fromID, oldest first, polling for new rows until ctx is done or
+	// handler returns an error. An empty fromID starts from the current time. Follow moves on to the next
+	// bucket once the time of the current one has passed, so rows written to a bucket after that are missed.
+	// See the PollInterval, MaxPollInterval, Checkpoints and Limit (rows read at once) options
+	Follow(ctx context.Context, fromID string, handler FollowHandler) error
 	WithOptions(Options) FlakeSeriesTable
 	Table() Table
 	TableChanger
@@ -206,10 +394,30 @@ type MultiFlakeSeriesTable interface {
 	Delete(v interface{}, id string) Op
 	Read(v interface{}, id string, pointer interface{}) Op
 	List(v interface{}, start, end time.Time, pointerToASlice interface{}) Op
+	// DeleteRange deletes the rows List would return for the same time range
+	DeleteRange(v interface{}, start, end time.Time) Op
+	// Latest populates the provided pointer to a slice with the n most recent rows, newest first.
+	// Buckets are queried one at a time, going back no more than MaxBuckets buckets
+	Latest(v interface{}, n int, pointerToASlice interface{}) Op
+	// ListBefore populates the provided pointer to a slice with up to n rows from before t, newest first.
+	// Buckets are queried one at a time, going back no more than MaxBuckets buckets
+	ListBefore(v interface{}, t time.Time, n int, pointerToASlice interface{}) Op
+	// ListAfter populates the provided pointer to a slice with up to n rows from after t, oldest first.
+	// Buckets are queried one at a time, going forward no more than MaxBuckets buckets
+	ListAfter(v interface{}, t time.Time, n int, pointerToASlice interface{}) Op
 	Buckets(v interface{}, start time.Time) Buckets
 	// ListSince queries the flakeSeries for the items after the specified ID but within the time window,
-	// if the time window is zero then it lists up until 5 minutes in the future
+	// if the time window is zero then it lists up until 5 minutes in the future. IDs are compared using the
+	// IDTimeExtractor of the table
 	ListSince(v interface{}, id string, window time.Duration, pointerToASlice interface{}) Op
+	// SetJSON inserts a row from a JSON document keyed by column name, which must hold the index and id
+	// columns. def determines whether columns missing from the document are set to null or left untouched
+	SetJSON(document []byte, def JSONDefault) Op
+	// ReadJSON reads a single row as a JSON document keyed by column name
+	ReadJSON(v interface{}, id string, pointer *json.RawMessage) Op
+	// ListJSON is like List but populates the provided slice with JSON documents keyed by column name.
+	// It reads every bucket with a single query, so BucketConcurrency doesn't apply to it
+	ListJSON(v interface{}, start, end time.Time, pointerToASlice *[]json.RawMessage) Op
 	WithOptions(Options) MultiFlakeSeriesTable
 	Table() Table
 	TableChanger
@@ -226,10 +434,17 @@ type Filter interface {
 	Update(valuesToUpdate map[string]interface{}) Op // Probably this is danger zone (can't be implemented efficiently) on a selectuinb with more than 1 document
 	// Delete all rows matching the filter.
 	Delete() Op
+	// DeleteColumns deletes only the selected columns, map entries or list elements (see Column,
+	// MapKey and ListIndex) of the rows matching the filter, rather than whole rows.
+	DeleteColumns(columns ...Selection) Op
 	// Reads all results. Make sure you pass in a pointer to a slice.
 	Read(pointerToASlice interface{}) Op
 	// ReadOne reads a single result. Make sure you pass in a pointer.
 	ReadOne(pointer interface{}) Op
+	// ReadJSON reads all results as JSON documents keyed by column name (SELECT JSON).
+	ReadJSON(pointerToASlice *[]json.RawMessage) Op
+	// ReadOneJSON reads a single result as a JSON document keyed by column name (SELECT JSON).
+	ReadOneJSON(pointer *json.RawMessage) Op
 	// Table on which this filter operates.
 	Table() Table
 	// Relations which make up this filter. These should not be modified.
@@ -313,6 +528,9 @@ type Table interface {
 	// Set Inserts, or Replaces your row with the supplied struct. Be aware that what is not in your struct
 	// will be deleted. To only overwrite some of the fields, use Query.Update.
 	Set(rowStruct interface{}) Op
+	// SetJSON inserts a row from a JSON document keyed by column name (INSERT ... JSON). def determines
+	// whether columns missing from the document are set to null or left untouched.
+	SetJSON(document []byte, def JSONDefault) Op
 	// Where accepts a bunch of realtions and returns a filter. See the documentation for Relation and Filter to understand what that means.
 	Where(relations ...Relation) Filter // Because we provide selections
 	// Name returns the underlying table name, as stored in C*
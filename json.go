@@ -0,0 +1,162 @@
+package gocassa
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// jsonTimeFormat is how Cassandra formats timestamps in the documents SELECT
+// JSON returns
+const jsonTimeFormat = "2006-01-02 15:04:05.000Z"
+
+// jsonTimeFormats are the formats of timestamps INSERT ... JSON accepts
+var jsonTimeFormats = []string{
+	jsonTimeFormat,
+	"2006-01-02 15:04:05.000Z0700",
+	"2006-01-02 15:04:05Z0700",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05.000Z0700",
+	time.RFC3339Nano,
+	"2006-01-02",
+}
+
+// parseJSONTime parses a timestamp of a JSON document, which is either a
+// string or a number of milliseconds since the epoch
+func parseJSONTime(value json.RawMessage) (time.Time, error) {
+	var s string
+	if err := json.Unmarshal(value, &s); err != nil {
+		ms, err := strconv.ParseInt(string(bytes.TrimSpace(value)), 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("Unable to make a timestamp from %s", value)
+		}
+		return time.Unix(0, ms*int64(time.Millisecond)).UTC(), nil
+	}
+	for _, format := range jsonTimeFormats {
+		if t, err := time.Parse(format, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("Unable to coerce '%s' to a formatted date", s)
+}
+
+// jsonValue returns a value as Cassandra writes it in a JSON document:
+// timestamps in jsonTimeFormat and blobs as hex strings, including in
+// collections
+func jsonValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case time.Time:
+		return v.UTC().Format(jsonTimeFormat)
+	case []byte:
+		return "0x" + hex.EncodeToString(v)
+	case json.Marshaler:
+		return v
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		values := make([]interface{}, rv.Len())
+		for i := range values {
+			values[i] = jsonValue(rv.Index(i).Interface())
+		}
+		return values
+	case reflect.Map:
+		values := make(map[string]interface{}, rv.Len())
+		for _, k := range rv.MapKeys() {
+			key := fmt.Sprint(jsonValue(k.Interface()))
+			values[key] = jsonValue(rv.MapIndex(k).Interface())
+		}
+		return values
+	}
+	return v
+}
+
+// jsonDocument is a JSON document written with SetJSON, decoded so that the
+// recipes can read the columns they key rows by, and add the columns they
+// derive from them
+type jsonDocument map[string]json.RawMessage
+
+func decodeJSONDocument(document []byte) (jsonDocument, error) {
+	var d jsonDocument
+	if err := json.Unmarshal(document, &d); err != nil {
+		return nil, fmt.Errorf("Could not decode JSON string as a map: %v", err)
+	}
+	return d, nil
+}
+
+// key returns the key of a column in the document. Like Cassandra, it
+// matches unquoted keys case insensitively
+func (d jsonDocument) key(column string) (string, bool) {
+	for k := range d {
+		if unquoted, err := strconv.Unquote(k); err == nil && unquoted == column {
+			return k, true
+		}
+		if strings.EqualFold(k, column) {
+			return k, true
+		}
+	}
+	return "", false
+}
+
+// value returns the value of a column in the document. Numbers are returned
+// as json.Number, so they're formatted as they were written
+func (d jsonDocument) value(column string) (interface{}, error) {
+	k, ok := d.key(column)
+	if !ok {
+		return nil, fmt.Errorf("JSON document is missing column %s", column)
+	}
+	dec := json.NewDecoder(bytes.NewReader(d[k]))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, fmt.Errorf("Error decoding JSON value for %s: %v", column, err)
+	}
+	return v, nil
+}
+
+// time returns the value of a timestamp column in the document
+func (d jsonDocument) time(column string) (time.Time, error) {
+	k, ok := d.key(column)
+	if !ok {
+		return time.Time{}, fmt.Errorf("JSON document is missing column %s", column)
+	}
+	return parseJSONTime(d[k])
+}
+
+// string returns the value of a text column in the document
+func (d jsonDocument) string(column string) (string, error) {
+	v, err := d.value(column)
+	if err != nil {
+		return "", err
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("JSON value for %s is not a string", column)
+	}
+	return s, nil
+}
+
+// set sets a column of the document, replacing any value it already has
+func (d jsonDocument) set(column string, v interface{}) error {
+	if k, ok := d.key(column); ok {
+		delete(d, k)
+	}
+	value, err := json.Marshal(jsonValue(v))
+	if err != nil {
+		return err
+	}
+	d[strings.ToLower(column)] = value
+	return nil
+}
+
+func (d jsonDocument) encode() ([]byte, error) {
+	return json.Marshal(map[string]json.RawMessage(d))
+}
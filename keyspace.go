@@ -2,6 +2,7 @@ package gocassa
 
 import (
 	"fmt"
+	"reflect"
 	"strings"
 	"time"
 )
@@ -15,6 +16,7 @@ type k struct {
 	name         string
 	debugMode    bool
 	tableFactory tableFactory
+	hooks        hookRegistry
 }
 
 // Connect to a certain keyspace directly. Same as using Connect().KeySpace(keySpaceName)
@@ -30,6 +32,14 @@ func (k *k) DebugMode(b bool) {
 	k.debugMode = b
 }
 
+func (k *k) AddWriteHook(hook WriteHook) {
+	k.hooks.add(hook)
+}
+
+func (k *k) SetOutbox(outbox TimeSeriesTable) {
+	k.hooks.setOutbox(outbox)
+}
+
 func (k *k) Table(name string, entity interface{}, keys Keys) Table {
 	n := name + "__" + strings.Join(keys.PartitionKeys, "_") + "__" + strings.Join(keys.ClusteringColumns, "_")
 	m, ok := toMap(entity)
@@ -102,41 +112,75 @@ func (k *k) MultimapMultiKeyTable(name string, fieldToIndexBy, id []string, row
 }
 
 func (k *k) TimeSeriesTable(name, timeField, idField string, bucketSize time.Duration, row interface{}) TimeSeriesTable {
+	return k.TimeSeriesTableWithBucketer(name, timeField, idField, durationBuckets(bucketSize), row)
+}
+
+func (k *k) TimeSeriesTableWithBucketer(name, timeField, idField string, bucketer Bucketer, row interface{}) TimeSeriesTable {
 	m, ok := toMap(row)
 	if !ok {
 		panic("Unrecognized row type")
 	}
 	m[bucketFieldName] = time.Now()
 	return &timeSeriesT{
-		t: k.NewTable(fmt.Sprintf("%s_timeSeries_%s_%s_%s", name, timeField, idField, bucketSize), row, m, Keys{
+		t: k.NewTable(fmt.Sprintf("%s_timeSeries_%s_%s_%s", name, timeField, idField, bucketer), row, m, Keys{
 			PartitionKeys:     []string{bucketFieldName},
 			ClusteringColumns: []string{timeField, idField},
 		}),
-		timeField:  timeField,
-		idField:    idField,
-		bucketSize: bucketSize,
+		timeField: timeField,
+		idField:   idField,
+		bucketer:  bucketer,
+	}
+}
+
+func (k *k) ShardedTimeSeriesTable(name, timeField, idField string, shards int, bucketer Bucketer, row interface{}) TimeSeriesTable {
+	if shards < 1 {
+		panic("A sharded table needs at least one shard")
+	}
+	m, ok := toMap(row)
+	if !ok {
+		panic("Unrecognized row type")
+	}
+	m[bucketFieldName] = time.Now()
+	m[shardFieldName] = 0
+	return &timeSeriesT{
+		t: k.NewTable(fmt.Sprintf("%s_shardedTimeSeries_%s_%s_%s_%d", name, timeField, idField, bucketer, shards), row, m, Keys{
+			PartitionKeys:     []string{bucketFieldName, shardFieldName},
+			ClusteringColumns: []string{timeField, idField},
+		}),
+		timeField: timeField,
+		idField:   idField,
+		bucketer:  bucketer,
+		shards:    shards,
 	}
 }
 
 func (k *k) MultiTimeSeriesTable(name, indexField, timeField, idField string, bucketSize time.Duration, row interface{}) MultiTimeSeriesTable {
+	return k.MultiTimeSeriesTableWithBucketer(name, indexField, timeField, idField, durationBuckets(bucketSize), row)
+}
+
+func (k *k) MultiTimeSeriesTableWithBucketer(name, indexField, timeField, idField string, bucketer Bucketer, row interface{}) MultiTimeSeriesTable {
 	m, ok := toMap(row)
 	if !ok {
 		panic("Unrecognized row type")
 	}
 	m[bucketFieldName] = time.Now()
 	return &multiTimeSeriesT{
-		t: k.NewTable(fmt.Sprintf("%s_multiTimeSeries_%s_%s_%s_%s", name, indexField, timeField, idField, bucketSize.String()), row, m, Keys{
+		t: k.NewTable(fmt.Sprintf("%s_multiTimeSeries_%s_%s_%s_%s", name, indexField, timeField, idField, bucketer.String()), row, m, Keys{
 			PartitionKeys:     []string{indexField, bucketFieldName},
 			ClusteringColumns: []string{timeField, idField},
 		}),
 		indexField: indexField,
 		timeField:  timeField,
 		idField:    idField,
-		bucketSize: bucketSize,
+		bucketer:   bucketer,
 	}
 }
 
 func (k *k) MultiKeyTimeSeriesTable(name string, indexFields []string, timeField string, idFields []string, bucketSize time.Duration, row interface{}) MultiKeyTimeSeriesTable {
+	return k.MultiKeyTimeSeriesTableWithBucketer(name, indexFields, timeField, idFields, durationBuckets(bucketSize), row)
+}
+
+func (k *k) MultiKeyTimeSeriesTableWithBucketer(name string, indexFields []string, timeField string, idFields []string, bucketer Bucketer, row interface{}) MultiKeyTimeSeriesTable {
 	m, ok := toMap(row)
 	if !ok {
 		panic("Unrecognized row type")
@@ -149,18 +193,26 @@ func (k *k) MultiKeyTimeSeriesTable(name string, indexFields []string, timeField
 
 	m[bucketFieldName] = time.Now()
 	return &multiKeyTimeSeriesT{
-		t: k.NewTable(fmt.Sprintf("%s_multiKeyTimeSeries_%s_%s", name, timeField, bucketSize.String()), row, m, Keys{
+		t: k.NewTable(fmt.Sprintf("%s_multiKeyTimeSeries_%s_%s", name, timeField, bucketer.String()), row, m, Keys{
 			PartitionKeys:     partitionKeys,
 			ClusteringColumns: clusteringColumns,
 		}),
 		indexFields: indexFields,
 		timeField:   timeField,
 		idFields:    idFields,
-		bucketSize:  bucketSize,
+		bucketer:    bucketer,
 	}
 }
 
 func (k *k) FlakeSeriesTable(name, idField string, bucketSize time.Duration, row interface{}) FlakeSeriesTable {
+	return k.FlakeSeriesTableWithBucketer(name, idField, durationBuckets(bucketSize), row)
+}
+
+func (k *k) FlakeSeriesTableWithBucketer(name, idField string, bucketer Bucketer, row interface{}) FlakeSeriesTable {
+	return k.FlakeSeriesTableWithIDs(name, idField, bucketer, BigflakeIDs(), row)
+}
+
+func (k *k) FlakeSeriesTableWithIDs(name, idField string, bucketer Bucketer, ids IDTimeExtractor, row interface{}) FlakeSeriesTable {
 	m, ok := toMap(row)
 	if !ok {
 		panic("Unrecognized row type")
@@ -168,16 +220,52 @@ func (k *k) FlakeSeriesTable(name, idField string, bucketSize time.Duration, row
 	m[flakeTimestampFieldName] = time.Now()
 	m[bucketFieldName] = time.Now()
 	return &flakeSeriesT{
-		t: k.NewTable(fmt.Sprintf("%s_flakeSeries_%s_%s", name, idField, bucketSize.String()), row, m, Keys{
+		t: k.NewTable(fmt.Sprintf("%s_flakeSeries_%s_%s", name, idField, bucketer.String()), row, m, Keys{
 			PartitionKeys:     []string{bucketFieldName},
 			ClusteringColumns: []string{flakeTimestampFieldName, idField},
 		}),
-		idField:    idField,
-		bucketSize: bucketSize,
+		idField:  idField,
+		bucketer: bucketer,
+		ids:      ids,
+	}
+}
+
+func (k *k) ShardedFlakeSeriesTable(name, idField string, shards int, bucketer Bucketer, row interface{}) FlakeSeriesTable {
+	return k.ShardedFlakeSeriesTableWithIDs(name, idField, shards, bucketer, BigflakeIDs(), row)
+}
+
+func (k *k) ShardedFlakeSeriesTableWithIDs(name, idField string, shards int, bucketer Bucketer, ids IDTimeExtractor, row interface{}) FlakeSeriesTable {
+	if shards < 1 {
+		panic("A sharded table needs at least one shard")
+	}
+	m, ok := toMap(row)
+	if !ok {
+		panic("Unrecognized row type")
+	}
+	m[flakeTimestampFieldName] = time.Now()
+	m[bucketFieldName] = time.Now()
+	m[shardFieldName] = 0
+	return &flakeSeriesT{
+		t: k.NewTable(fmt.Sprintf("%s_shardedFlakeSeries_%s_%s_%d", name, idField, bucketer.String(), shards), row, m, Keys{
+			PartitionKeys:     []string{bucketFieldName, shardFieldName},
+			ClusteringColumns: []string{flakeTimestampFieldName, idField},
+		}),
+		idField:  idField,
+		bucketer: bucketer,
+		ids:      ids,
+		shards:   shards,
 	}
 }
 
 func (k *k) MultiFlakeSeriesTable(name, indexField, idField string, bucketSize time.Duration, row interface{}) MultiFlakeSeriesTable {
+	return k.MultiFlakeSeriesTableWithBucketer(name, indexField, idField, durationBuckets(bucketSize), row)
+}
+
+func (k *k) MultiFlakeSeriesTableWithBucketer(name, indexField, idField string, bucketer Bucketer, row interface{}) MultiFlakeSeriesTable {
+	return k.MultiFlakeSeriesTableWithIDs(name, indexField, idField, bucketer, BigflakeIDs(), row)
+}
+
+func (k *k) MultiFlakeSeriesTableWithIDs(name, indexField, idField string, bucketer Bucketer, ids IDTimeExtractor, row interface{}) MultiFlakeSeriesTable {
 	m, ok := toMap(row)
 	if !ok {
 		panic("Unrecognized row type")
@@ -185,16 +273,39 @@ func (k *k) MultiFlakeSeriesTable(name, indexField, idField string, bucketSize t
 	m[flakeTimestampFieldName] = time.Now()
 	m[bucketFieldName] = time.Now()
 	return &multiFlakeSeriesT{
-		t: k.NewTable(fmt.Sprintf("%s_multiflakeSeries_%s_%s_%s", name, indexField, idField, bucketSize.String()), row, m, Keys{
+		t: k.NewTable(fmt.Sprintf("%s_multiflakeSeries_%s_%s_%s", name, indexField, idField, bucketer.String()), row, m, Keys{
 			PartitionKeys:     []string{indexField, bucketFieldName},
 			ClusteringColumns: []string{flakeTimestampFieldName, idField},
 		}),
 		idField:    idField,
-		bucketSize: bucketSize,
+		bucketer:   bucketer,
+		ids:        ids,
 		indexField: indexField,
 	}
 }
 
+func (k *k) EntityTable(name, idField string, row interface{}, views ...EntityView) EntityTable {
+	rowType := reflect.TypeOf(row)
+	if rowType.Kind() == reflect.Ptr {
+		rowType = rowType.Elem()
+	}
+	e := &entityT{
+		primary: k.MapTable(name, idField, row).(*mapT),
+		idField: idField,
+		rowType: rowType,
+	}
+	for _, v := range views {
+		if v.timeField != "" {
+			tbl := k.TimeSeriesTableWithBucketer(name, v.timeField, idField, v.bucketer, row)
+			e.views = append(e.views, timeSeriesView{tbl.(*timeSeriesT)})
+		} else {
+			tbl := k.MultimapTable(name, v.fieldToIndexBy, idField, row)
+			e.views = append(e.views, multimapView{tbl.(*multimapT)})
+		}
+	}
+	return e
+}
+
 type tableInfoMarshal struct {
 	TableName string `cql:"table_name"`
 }
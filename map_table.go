@@ -1,5 +1,7 @@
 package gocassa
 
+import "encoding/json"
+
 type mapT struct {
 	t       Table
 	idField string
@@ -32,6 +34,12 @@ func (m *mapT) Delete(id interface{}) Op {
 		Delete()
 }
 
+func (m *mapT) DeleteColumns(id interface{}, columns ...Selection) Op {
+	return m.Table().
+		Where(Eq(m.idField, id)).
+		DeleteColumns(columns...)
+}
+
 func (m *mapT) Read(id, pointer interface{}) Op {
 	return m.Table().
 		Where(Eq(m.idField, id)).
@@ -44,6 +52,23 @@ func (m *mapT) MultiRead(ids []interface{}, pointerToASlice interface{}) Op {
 		Read(pointerToASlice)
 }
 
+func (m *mapT) SetJSON(document []byte, def JSONDefault) Op {
+	return m.Table().
+		SetJSON(document, def)
+}
+
+func (m *mapT) ReadJSON(id interface{}, pointer *json.RawMessage) Op {
+	return m.Table().
+		Where(Eq(m.idField, id)).
+		ReadOneJSON(pointer)
+}
+
+func (m *mapT) MultiReadJSON(ids []interface{}, pointerToASlice *[]json.RawMessage) Op {
+	return m.Table().
+		Where(In(m.idField, ids...)).
+		ReadJSON(pointerToASlice)
+}
+
 func (m *mapT) WithOptions(o Options) MapTable {
 	return &mapT{
 		t:       m.Table().WithOptions(o),
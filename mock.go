@@ -2,11 +2,14 @@ package gocassa
 
 import (
 	"bytes"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"reflect"
 	"strings"
 	"sync"
+	"time"
 
 	"context"
 
@@ -17,12 +20,44 @@ import (
 // MockKeySpace implements the KeySpace interface and constructs in-memory tables.
 type mockKeySpace struct {
 	k
+	store  mockStore
+	clock  Clock
+	strict bool
+	faults *FaultInjector
+	// replication simulates the replicas of the tables, if it's set
+	replication *mockReplication
+}
+
+// MockOption configures a keyspace returned by NewMockKeySpace
+type MockOption func(ks *mockKeySpace)
+
+// MockClock makes the tables of a mock keyspace tell the time by clock,
+// unless they're given another one with Options.Clock. Rows and cells written
+// with a TTL expire once it has passed on the clock
+func MockClock(clock Clock) MockOption {
+	return func(ks *mockKeySpace) {
+		ks.clock = clock
+	}
+}
+
+// StrictMock makes the tables of a mock keyspace reject the queries Cassandra
+// would reject given their keys, such as reads restricting a clustering column
+// without restricting the ones before it, or updates missing part of the
+// primary key, with an InvalidRequestError
+func StrictMock() MockOption {
+	return func(ks *mockKeySpace) {
+		ks.strict = true
+	}
 }
 
 type mockOp struct {
 	options      Options
 	funcs        []func(mockOp) error
 	preflightErr error
+	// target is what the op runs, for ops of tables injecting faults
+	target *mockTarget
+	// batch is the logged batch the op is run in, if any
+	batch *mockBatch
 }
 
 func newOp(f func(mockOp) error) mockOp {
@@ -36,13 +71,27 @@ func (m mockOp) Add(ops ...Op) Op {
 }
 
 func (m mockOp) Run() error {
+	return m.run(0, 1)
+}
+
+// run runs the op as the idx-th of count ops run together, after injecting
+// the faults of its table
+func (m mockOp) run(idx, count int) error {
+	var injected error
+	if m.target != nil && m.target.table.faults != nil {
+		var run bool
+		run, injected = m.target.table.faults.inject(m.target.statement(m.options, idx, count))
+		if !run {
+			return injected
+		}
+	}
 	for _, f := range m.funcs {
 		err := f(m)
 		if err != nil {
 			return err
 		}
 	}
-	return nil
+	return injected
 }
 
 func (m mockOp) RunWithContext(ctx context.Context) error {
@@ -57,15 +106,17 @@ func (m mockOp) WithOptions(opt Options) Op {
 	return mockOp{
 		options: m.options.Merge(opt),
 		funcs:   m.funcs,
+		target:  m.target,
 	}
 }
 
 func (m mockOp) RunAtomically() error {
+	m.batch = newMockBatch()
 	return m.Run()
 }
 
 func (m mockOp) RunLoggedBatchWithContext(ctx context.Context) error {
-	return m.WithOptions(Options{Context: ctx}).Run()
+	return m.WithOptions(Options{Context: ctx}).RunAtomically()
 }
 
 func (m mockOp) RunAtomicallyWithContext(ctx context.Context) error {
@@ -87,15 +138,33 @@ func (m mockOp) Preflight() error {
 type mockMultiOp []Op
 
 func (mo mockMultiOp) Run() error {
+	return mo.run(nil)
+}
+
+// run runs the ops one after the other, in the logged batch if it's set
+func (mo mockMultiOp) run(batch *mockBatch) error {
 	if err := mo.Preflight(); err != nil {
 		return err
 	}
 	for i, op := range mo {
 		errorInjector := getErrorInjector(op.Options().Context)
-		if errToReturn := errorInjector.shouldReturnErr(op, i, len(mo)); errToReturn != nil {
+		if errToReturn := errorInjector.ShouldReturnErr(op, i, len(mo)); errToReturn != nil {
 			return errToReturn
 		}
-		if err := op.Run(); err != nil {
+		var err error
+		switch op := op.(type) {
+		case mockOp:
+			op.batch = batch
+			err = op.run(i, len(mo))
+		case entityOp:
+			var built Op
+			if built, err = op.buildOp(); err == nil {
+				err = mockMultiOp{}.Add(built).(mockMultiOp).run(batch)
+			}
+		default:
+			err = op.Run()
+		}
+		if err != nil {
 			return err
 		}
 	}
@@ -107,11 +176,11 @@ func (mo mockMultiOp) RunWithContext(ctx context.Context) error {
 }
 
 func (mo mockMultiOp) RunAtomically() error {
-	return mo.Run()
+	return mo.run(newMockBatch())
 }
 
 func (mo mockMultiOp) RunLoggedBatchWithContext(ctx context.Context) error {
-	return mo.WithOptions(Options{Context: ctx}).Run()
+	return mo.WithOptions(Options{Context: ctx}).RunAtomically()
 }
 
 func (mo mockMultiOp) RunAtomicallyWithContext(ctx context.Context) error {
@@ -135,6 +204,12 @@ func (mo mockMultiOp) Add(inOps ...Op) Op {
 			ops = append(ops, op...)
 		case mockOp:
 			ops = append(ops, op)
+		case bucketListOp, bucketWalkOp:
+			// Recipes read buckets by running mock ops themselves
+			ops = append(ops, op)
+		case entityOp:
+			// The writes are built when run, in the batch of the others
+			ops = append(ops, op)
 		case multiOp:
 			if len(op) == 0 {
 				continue
@@ -182,6 +257,14 @@ func (ks *mockKeySpace) NewTable(name string, entity interface{}, fieldSource ma
 		fieldSource: fieldSource,
 		rows:        map[rowKey]*btree.BTree{},
 		mtx:         &sync.RWMutex{},
+		hooks:       &ks.hooks,
+		options:     Options{Clock: ks.clock},
+		strict:      ks.strict,
+		faults:      ks.faults,
+		created:     newCreatedOrders(),
+	}
+	if ks.replication != nil {
+		mt.replicas = newTableReplicas(ks.replication)
 	}
 
 	fields := []string{}
@@ -189,12 +272,16 @@ func (ks *mockKeySpace) NewTable(name string, entity interface{}, fieldSource ma
 		fields = append(fields, k)
 	}
 	mt.fields = fields
+	ks.register(mt)
 
 	return mt
 }
 
-func NewMockKeySpace() KeySpace {
+func NewMockKeySpace(options ...MockOption) KeySpace {
 	ks := &mockKeySpace{}
+	for _, o := range options {
+		o(ks)
+	}
 	ks.tableFactory = ks
 	return ks
 }
@@ -213,12 +300,93 @@ type MockTable struct {
 	fields      []string
 	keys        Keys
 	options     Options
+	hooks       *hookRegistry
+	// strict makes the table reject queries Cassandra would reject
+	strict bool
+	faults *FaultInjector
+	// replicas simulates the replicas of the table, if it's set
+	replicas *tableReplicas
+	created  *createdOrders
 }
 
 type rowKey string
 type superColumn struct {
 	Key     key
 	Columns map[string]interface{}
+	// Expiries holds the time at which each cell written with a TTL expires
+	Expiries map[string]time.Time
+	// Sets write a row marker, which keeps the row alive (until
+	// MarkerExpiry, if it was written with a TTL) even if all of its cells
+	// are null or have expired
+	Marker       bool
+	MarkerExpiry time.Time
+}
+
+func keyFieldSet(keys Keys) map[string]bool {
+	fields := map[string]bool{}
+	for _, k := range append(keys.PartitionKeys, keys.ClusteringColumns...) {
+		fields[k] = true
+	}
+	return fields
+}
+
+func expired(expiry, now time.Time) bool {
+	return !expiry.IsZero() && !now.Before(expiry)
+}
+
+// live returns the cells of the row which haven't expired, and whether the
+// row is still alive
+func (c *superColumn) live(keys Keys, now time.Time) (map[string]interface{}, bool) {
+	if len(c.Expiries) == 0 && c.MarkerExpiry.IsZero() {
+		return c.Columns, true
+	}
+
+	keyFields := keyFieldSet(keys)
+	alive := c.Marker && !expired(c.MarkerExpiry, now)
+	columns := make(map[string]interface{}, len(c.Columns))
+	for k, v := range c.Columns {
+		if expired(c.Expiries[k], now) {
+			continue
+		}
+		columns[k] = v
+		if !keyFields[k] {
+			alive = true
+		}
+	}
+	return columns, alive
+}
+
+// purge removes the cells and row marker which have expired, so that writes
+// don't apply modifiers to expired cells
+func (c *superColumn) purge(now time.Time) {
+	for k, expiry := range c.Expiries {
+		if expired(expiry, now) {
+			delete(c.Columns, k)
+			delete(c.Expiries, k)
+		}
+	}
+	if expired(c.MarkerExpiry, now) {
+		c.Marker, c.MarkerExpiry = false, time.Time{}
+	}
+}
+
+// expire sets the expiry of the cells written, clearing that of cells written
+// without a TTL, as a newer write replaces the TTL of a cell
+func (c *superColumn) expire(keys Keys, fields map[string]interface{}, ttl time.Duration, now time.Time) {
+	keyFields := keyFieldSet(keys)
+	for k := range fields {
+		if keyFields[k] {
+			continue
+		}
+		if ttl > 0 {
+			if c.Expiries == nil {
+				c.Expiries = map[string]time.Time{}
+			}
+			c.Expiries[k] = now.Add(ttl)
+		} else {
+			delete(c.Expiries, k)
+		}
+	}
 }
 
 func (c *superColumn) Less(item btree.Item) bool {
@@ -351,6 +519,14 @@ func (t *MockTable) clusteringKeyFromColumnValues(values map[string]interface{},
 	return key, nil
 }
 
+func (t *MockTable) tableOptions() Options {
+	return t.options
+}
+
+func (t *MockTable) rowDefinition() interface{} {
+	return t.entity
+}
+
 func (t *MockTable) Name() string {
 	if len(t.options.TableName) > 0 {
 		return t.options.TableName
@@ -366,10 +542,11 @@ func (t *MockTable) getOrCreateRow(rowKey key) *btree.BTree {
 		row = btree.New(2)
 		t.rows[rowKey.RowKey()] = row
 	}
+	t.touch(rowKey.RowKey())
 	return row
 }
 
-func (t *MockTable) getOrCreateColumnGroup(rowKey, superColumnKey key) map[string]interface{} {
+func (t *MockTable) getOrCreateSuperColumn(rowKey, superColumnKey key) *superColumn {
 	row := t.getOrCreateRow(rowKey)
 	scol := superColumnKey.ToSuperColumn()
 
@@ -384,47 +561,214 @@ func (t *MockTable) getOrCreateColumnGroup(rowKey, superColumnKey key) map[strin
 	}
 
 	if row.Has(scol) {
-		return row.Get(scol).(*superColumn).Columns
+		return row.Get(scol).(*superColumn)
 	}
 	row.ReplaceOrInsert(scol)
 	scol.Columns = map[string]interface{}{}
 
-	return scol.Columns
+	return scol
 }
 
 func (t *MockTable) SetWithOptions(i interface{}, options Options) Op {
-	return newOp(func(m mockOp) error {
+	target := &mockTarget{table: t, typ: MockInsert, keys: func() map[string]interface{} {
+		columns, _ := toSetMap(i)
+		return keysFromValues(t.keys, columns)
+	}}
+	return t.writeOp(target, func(m mockOp) (WriteEvent, error) {
 		t.Lock()
 		defer t.Unlock()
 
-		columns, ok := toMap(i)
+		columns, ok := toSetMap(i)
 		if !ok {
-			return errors.New("Can't create: value not understood")
+			return WriteEvent{}, errors.New("Can't create: value not understood")
+		}
+		opts := t.options.Merge(options).Merge(m.options)
+		columns = applyEmptyFieldMode(columns, t.keys, opts.EmptyFields)
+		if t.strict {
+			if err := t.schema().validateInsert(columns); err != nil {
+				return WriteEvent{}, err
+			}
 		}
 
-		rowKey, err := t.partitionKeyFromColumnValues(columns, t.keys.PartitionKeys)
-		if err != nil {
-			return err
+		if err := t.setColumns(columns, opts.TTL); err != nil {
+			return WriteEvent{}, err
 		}
+		return WriteEvent{Type: SetWrite, Keys: keysFromValues(t.keys, columns), Values: columns}, nil
+	})
+}
 
-		superColumnKey, err := t.clusteringKeyFromColumnValues(columns, t.keys.ClusteringColumns)
-		if err != nil {
+// writeOp returns an op running write, which returns the event of the write,
+// then writing the event to the outbox and calling the write hooks
+func (t *MockTable) writeOp(target *mockTarget, write func(m mockOp) (WriteEvent, error)) mockOp {
+	op := newOp(func(m mockOp) error {
+		var (
+			e   WriteEvent
+			err error
+		)
+		if t.replicas != nil {
+			// Writes which can't achieve their consistency level aren't made
+			if _, err := t.replicas.replication.required(t.options.Merge(m.options).Consistency); err != nil {
+				return err
+			}
+			t.replicas.writing.Lock()
+			if e, err = write(m); err == nil {
+				err = t.replicate(m)
+			}
+			t.replicas.writing.Unlock()
+		} else {
+			e, err = write(m)
+		}
+		if err != nil || t.hooks == nil {
 			return err
 		}
+		e.Keyspace, e.Table = t.ksName, t.Name()
+		e.Options = t.options.Merge(m.options)
 
-		superColumn := t.getOrCreateColumnGroup(rowKey, superColumnKey)
-
-		if err := assignRecords(columns, superColumn); err != nil {
-			return err
+		hooks, outbox := t.hooks.forWrite(e.Table, e.Options)
+		if outbox != nil {
+			row, err := newOutboxEvent(e)
+			if err != nil {
+				return err
+			}
+			if err := outbox.Set(row).WithOptions(Options{Context: e.Options.Context}).Run(); err != nil {
+				return err
+			}
 		}
-		return nil
+		return runWriteHooks(hooks, e)
 	})
+	op.target = target
+	return op
+}
+
+// now returns the time of the clock of the table, which rows written with a
+// TTL expire by
+func (t *MockTable) now() time.Time {
+	return tableClock(t).Now()
+}
+
+func (t *MockTable) setColumns(columns map[string]interface{}, ttl time.Duration) error {
+	rowKey, err := t.partitionKeyFromColumnValues(columns, t.keys.PartitionKeys)
+	if err != nil {
+		return err
+	}
+
+	superColumnKey, err := t.clusteringKeyFromColumnValues(columns, t.keys.ClusteringColumns)
+	if err != nil {
+		return err
+	}
+
+	now := t.now()
+	superColumn := t.getOrCreateSuperColumn(rowKey, superColumnKey)
+	superColumn.purge(now)
+
+	if err := assignRecords(columns, superColumn.Columns); err != nil {
+		return err
+	}
+	superColumn.expire(t.keys, columns, ttl, now)
+	superColumn.Marker, superColumn.MarkerExpiry = true, time.Time{}
+	if ttl > 0 {
+		superColumn.MarkerExpiry = now.Add(ttl)
+	}
+	return nil
 }
 
 func (t *MockTable) Set(i interface{}) Op {
 	return t.SetWithOptions(i, t.options)
 }
 
+func (t *MockTable) SetJSON(document []byte, def JSONDefault) Op {
+	target := &mockTarget{table: t, typ: MockInsert, keys: func() map[string]interface{} {
+		columns, _ := t.columnsFromJSON(document, def)
+		return keysFromValues(t.keys, columns)
+	}}
+	return t.writeOp(target, func(m mockOp) (WriteEvent, error) {
+		t.Lock()
+		defer t.Unlock()
+
+		columns, err := t.columnsFromJSON(document, def)
+		if err != nil {
+			return WriteEvent{}, err
+		}
+
+		if err := t.setColumns(columns, t.options.Merge(m.options).TTL); err != nil {
+			return WriteEvent{}, err
+		}
+		return WriteEvent{Type: SetWrite, Keys: keysFromValues(t.keys, columns), Values: columns}, nil
+	})
+}
+
+// columnsFromJSON decodes a JSON document keyed by column name into a map
+// keyed by field name, using the field source to determine the type of each
+// column. Columns missing from the document are set to their zero value
+// unless def is JSONDefaultUnset
+func (t *MockTable) columnsFromJSON(document []byte, def JSONDefault) (map[string]interface{}, error) {
+	values, err := decodeJSONDocument(document)
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make(map[string]interface{}, len(t.fields))
+	for _, field := range t.fields {
+		column, ok := values.key(field)
+		if !ok {
+			continue
+		}
+		value, err := decodeJSONValue(values[column], t.fieldSource[field])
+		if err != nil {
+			return nil, fmt.Errorf("Error decoding JSON value for %s: %v", column, err)
+		}
+		columns[field] = value
+		delete(values, column)
+	}
+	for column := range values {
+		return nil, fmt.Errorf("JSON values map contains unrecognized column: %s", column)
+	}
+
+	if def == JSONDefaultNull {
+		for _, field := range t.fields {
+			if _, ok := columns[field]; ok {
+				continue
+			}
+			if source := t.fieldSource[field]; source != nil {
+				columns[field] = reflect.Zero(reflect.TypeOf(source)).Interface()
+			} else {
+				columns[field] = nil
+			}
+		}
+	}
+
+	return columns, nil
+}
+
+// decodeJSONValue decodes the JSON value of a column into a value of the type
+// of source, accepting timestamps and blobs as Cassandra formats them
+func decodeJSONValue(value json.RawMessage, source interface{}) (interface{}, error) {
+	if source == nil {
+		var v interface{}
+		err := json.Unmarshal(value, &v)
+		return v, err
+	}
+	typ := reflect.TypeOf(source)
+	switch {
+	case typ == reflect.TypeOf(time.Time{}):
+		return parseJSONTime(value)
+	case typ.Kind() == reflect.Slice && typ.Elem().Kind() == reflect.Uint8:
+		var s string
+		if err := json.Unmarshal(value, &s); err == nil && strings.HasPrefix(s, "0x") {
+			b, err := hex.DecodeString(s[2:])
+			if err != nil {
+				return nil, err
+			}
+			return reflect.ValueOf(b).Convert(typ).Interface(), nil
+		}
+	}
+	target := reflect.New(typ)
+	if err := json.Unmarshal(value, target.Interface()); err != nil {
+		return nil, err
+	}
+	return target.Elem().Interface(), nil
+}
+
 func (t *MockTable) Where(relations ...Relation) Filter {
 	return &MockFilter{
 		table:     t,
@@ -433,23 +777,27 @@ func (t *MockTable) Where(relations ...Relation) Filter {
 }
 
 func (t *MockTable) Create() error {
-	return nil
+	_, err := t.CreateStatement()
+	return err
 }
 
 func (t *MockTable) CreateStatement() (Statement, error) {
+	t.created.record(t.Name(), t.options.ClusteringOrder)
 	return noOpStatement{}, nil
 }
 
 func (t *MockTable) CreateIfNotExist() error {
-	return nil
+	_, err := t.CreateIfNotExistStatement()
+	return err
 }
 
 func (t *MockTable) CreateIfNotExistStatement() (Statement, error) {
+	t.created.record(t.Name(), t.options.ClusteringOrder)
 	return noOpStatement{}, nil
 }
 
 func (t *MockTable) Recreate() error {
-	return nil
+	return t.Create()
 }
 
 func (t *MockTable) WithOptions(o Options) Table {
@@ -464,6 +812,11 @@ func (t *MockTable) WithOptions(o Options) Table {
 		fields:      t.fields,
 		options:     t.options.Merge(o),
 		mtx:         t.mtx,
+		hooks:       t.hooks,
+		strict:      t.strict,
+		faults:      t.faults,
+		replicas:    t.replicas,
+		created:     t.created,
 	}
 }
 
@@ -503,84 +856,111 @@ func (f *MockFilter) fieldRelationMap() map[string]Relation {
 
 func (f *MockFilter) fieldsFromRelations(fields []string) ([]key, error) {
 	fieldRelationMap := f.fieldRelationMap()
-	var rowKey key
-	var result []key
+	result := []key{key{}}
 
-	if len(fields) == 0 {
-		return []key{key{}}, nil
-	}
-
-	for i, keyName := range fields {
-		lastKey := i == len(fields)-1
+	for _, keyName := range fields {
 		relation, ok := fieldRelationMap[keyName]
 
 		if !ok {
 			return nil, fmt.Errorf("Missing mandatory PRIMARY KEY part `%s`", keyName)
 		}
 
-		if relation.Comparator() != CmpEquality && !(lastKey && relation.Comparator() == CmpIn) {
+		if relation.Comparator() != CmpEquality && relation.Comparator() != CmpIn {
 			return nil, fmt.Errorf("Invalid use of PK `%s`", keyName)
 		}
 
-		if !lastKey {
-			rowKey = rowKey.Append(keyName, relation.Terms()[0])
-		} else {
-			for _, term := range relation.Terms() {
-				result = append(result, rowKey.Append(relation.Field(), term))
+		// Like Cassandra, an IN on any part of the key selects the cartesian
+		// product of the values of every part
+		terms := relation.Terms()
+		if relation.Comparator() == CmpEquality {
+			terms = terms[:1]
+		}
+		var next []key
+		for _, k := range result {
+			for _, term := range terms {
+				next = append(next, k.Append(keyName, term))
 			}
 		}
+		result = next
 	}
 
 	return result, nil
 }
 
 func (f *MockFilter) UpdateWithOptions(m map[string]interface{}, options Options) Op {
-	return newOp(func(mock mockOp) error {
+	return f.table.writeOp(f.target(MockUpdate), func(mock mockOp) (WriteEvent, error) {
 		f.table.Lock()
 		defer f.table.Unlock()
 
+		if f.table.strict {
+			if err := f.table.schema().validateUpdate(f.relations, m); err != nil {
+				return WriteEvent{}, err
+			}
+		}
+
 		rowKeys, err := f.fieldsFromRelations(f.table.keys.PartitionKeys)
 		if err != nil {
-			return err
+			return WriteEvent{}, err
 		}
 
+		now, ttl := f.table.now(), f.table.options.Merge(options).Merge(mock.options).TTL
 		for _, rowKey := range rowKeys {
 			superColumnKeys, err := f.fieldsFromRelations(f.table.keys.ClusteringColumns)
 			if err != nil {
-				return err
+				return WriteEvent{}, err
 			}
 
 			for _, superColumnKey := range superColumnKeys {
-				superColumn := f.table.getOrCreateColumnGroup(rowKey, superColumnKey)
+				superColumn := f.table.getOrCreateSuperColumn(rowKey, superColumnKey)
+				superColumn.purge(now)
 
 				for _, key := range []key{rowKey, superColumnKey} {
 					for _, keyPart := range key {
-						superColumn[keyPart.Key] = keyPart.Value
+						superColumn.Columns[keyPart.Key] = keyPart.Value
 					}
 				}
 
-				if err := assignRecords(m, superColumn); err != nil {
-					return err
+				if err := assignRecords(m, superColumn.Columns); err != nil {
+					return WriteEvent{}, err
 				}
+				superColumn.expire(f.table.keys, m, ttl, now)
 			}
 		}
 
-		return nil
+		return f.event(WriteEvent{Type: UpdateWrite, Values: m}), nil
 	})
 }
 
+// target returns the target of an op of the filter, for injecting faults
+func (f *MockFilter) target(typ MockStatementType) *mockTarget {
+	return &mockTarget{table: f.table, typ: typ, relations: f.relations}
+}
+
+// event fills in the keys and relations of the event of a write
+func (f *MockFilter) event(e WriteEvent) WriteEvent {
+	e.Relations = f.relations
+	e.Keys = keysFromRelations(f.table.keys, f.relations)
+	return e
+}
+
 func (f *MockFilter) Update(m map[string]interface{}) Op {
 	return f.UpdateWithOptions(m, Options{})
 }
 
 func (f *MockFilter) Delete() Op {
-	return newOp(func(m mockOp) error {
+	return f.table.writeOp(f.target(MockDelete), func(m mockOp) (WriteEvent, error) {
 		f.table.Lock()
 		defer f.table.Unlock()
 
+		if f.table.strict {
+			if err := f.table.schema().validateDelete(f.relations, nil); err != nil {
+				return WriteEvent{}, err
+			}
+		}
+
 		rowKeys, err := f.fieldsFromRelations(f.table.keys.PartitionKeys)
 		if err != nil {
-			return err
+			return WriteEvent{}, err
 		}
 
 		f.table.mtx.Lock()
@@ -588,46 +968,306 @@ func (f *MockFilter) Delete() Op {
 		for _, rowKey := range rowKeys {
 			row := f.table.rows[rowKey.RowKey()]
 			if row == nil {
-				return nil
+				continue
 			}
+			f.table.touch(rowKey.RowKey())
 
+			// Collect the matching items first, the btree can't be modified
+			// while it is being iterated over
+			var deleted []btree.Item
 			row.Ascend(func(item btree.Item) bool {
 				columns := item.(*superColumn).Columns
 				if f.rowMatch(columns) {
-					row.Delete(item)
+					deleted = append(deleted, item)
 				}
 
 				return true
 			})
+			for _, item := range deleted {
+				row.Delete(item)
+			}
 		}
 
-		return nil
+		return f.event(WriteEvent{Type: DeleteWrite}), nil
 	})
 }
 
-func (q *MockFilter) Read(out interface{}) Op {
-	return newOp(func(m mockOp) error {
-		q.table.Lock()
-		defer q.table.Unlock()
+func (f *MockFilter) DeleteColumns(columns ...Selection) Op {
+	return f.table.writeOp(f.target(MockDelete), func(m mockOp) (WriteEvent, error) {
+		f.table.Lock()
+		defer f.table.Unlock()
 
-		var (
-			result []map[string]interface{}
-			err    error
-		)
+		if f.table.strict {
+			if err := f.table.schema().validateDelete(f.relations, columns); err != nil {
+				return WriteEvent{}, err
+			}
+		}
+
+		fields, err := f.table.schema().deletableFields(columns)
+		if err != nil {
+			return WriteEvent{}, err
+		}
+
+		rowKeys, err := f.fieldsFromRelations(f.table.keys.PartitionKeys)
+		if err != nil {
+			return WriteEvent{}, err
+		}
+		if _, err := f.fieldsFromRelations(f.table.keys.ClusteringColumns); err != nil {
+			return WriteEvent{}, err
+		}
+
+		f.table.mtx.Lock()
+		defer f.table.mtx.Unlock()
+		for _, rowKey := range rowKeys {
+			row := f.table.rows[rowKey.RowKey()]
+			if row == nil {
+				continue
+			}
+			f.table.touch(rowKey.RowKey())
+
+			row.Ascend(func(item btree.Item) bool {
+				record := item.(*superColumn).Columns
+				if f.rowMatch(record) {
+					err = deleteSelections(columns, fields, record)
+				}
+				return err == nil
+			})
+			if err != nil {
+				return WriteEvent{}, err
+			}
+		}
+
+		return f.event(WriteEvent{Type: DeleteWrite, Columns: columns}), nil
+	})
+}
+
+// schema returns what queries on the table are validated against
+func (t *MockTable) schema() tableSchema {
+	order, known := t.created.get(t.Name())
+	return tableSchema{table: t.Name(), keys: t.keys, fieldSource: t.fieldSource, order: order, orderKnown: known}
+}
+
+func deleteSelections(columns []Selection, fields []string, record map[string]interface{}) error {
+	for i, column := range columns {
+		field := fields[i]
+		element, ok := column.Element()
+		if !ok {
+			delete(record, field)
+			continue
+		}
+		if record[field] == nil {
+			continue
+		}
 
-		switch {
-		case len(q.Relations()) == 0:
-			result = q.readAllRows()
+		var err error
+		switch reflect.ValueOf(record[field]).Kind() {
+		case reflect.Map:
+			record[field], err = mapWithoutKeys(record[field], []interface{}{element})
+		case reflect.Slice:
+			record[field], err = sliceWithoutIndex(record[field], element)
 		default:
-			result, err = q.readSomeRows()
+			err = fmt.Errorf("Invalid deletion of an element of %s which is not a collection", column.Column())
 		}
 		if err != nil {
 			return err
 		}
+	}
+	return nil
+}
+
+// mapWithoutKeys returns a copy of the map with the given keys removed
+func mapWithoutKeys(m interface{}, keys []interface{}) (interface{}, error) {
+	rv := reflect.ValueOf(m)
+	if rv.Kind() != reflect.Map {
+		return nil, fmt.Errorf("Can't remove keys from a field that isn't a map: %T", m)
+	}
+
+	remove := make([]reflect.Value, 0, len(keys))
+	for _, k := range keys {
+		kv := reflect.ValueOf(k)
+		// Go happily converts integers to strings, C* doesn't
+		keyType := rv.Type().Key()
+		if !kv.Type().ConvertibleTo(keyType) || (keyType.Kind() == reflect.String) != (kv.Kind() == reflect.String) {
+			return nil, fmt.Errorf("Invalid map key %v for map of type %T", k, m)
+		}
+		remove = append(remove, kv.Convert(keyType))
+	}
+
+	result := reflect.MakeMap(rv.Type())
+	iter := rv.MapRange()
+	for iter.Next() {
+		result.SetMapIndex(iter.Key(), iter.Value())
+	}
+	for _, kv := range remove {
+		result.SetMapIndex(kv, reflect.Value{})
+	}
+	return result.Interface(), nil
+}
+
+// sliceWithoutValues returns a copy of the slice with all elements equal to
+// one of the given values removed
+func sliceWithoutValues(s interface{}, values []interface{}) (interface{}, error) {
+	rv := reflect.ValueOf(s)
+	if rv.Kind() != reflect.Slice {
+		return nil, fmt.Errorf("Can't remove elements from a field that isn't a list or set: %T", s)
+	}
+
+	result := reflect.MakeSlice(rv.Type(), 0, rv.Len())
+	for i := 0; i < rv.Len(); i++ {
+		if !anyEquals(rv.Index(i).Interface(), values) {
+			result = reflect.Append(result, rv.Index(i))
+		}
+	}
+	return result.Interface(), nil
+}
+
+// sliceWithValues returns a copy of the slice with the given values appended,
+// or prepended, to it. A nil slice is made of the type of the values
+func sliceWithValues(s interface{}, values []interface{}, prepend bool) (interface{}, error) {
+	var rv reflect.Value
+	switch {
+	case s != nil:
+		rv = reflect.ValueOf(s)
+		if rv.Kind() != reflect.Slice {
+			return nil, fmt.Errorf("Can't add elements to a field that isn't a list: %T", s)
+		}
+	case len(values) > 0:
+		rv = reflect.MakeSlice(reflect.SliceOf(reflect.TypeOf(values[0])), 0, len(values))
+	default:
+		return s, nil
+	}
+
+	added := reflect.MakeSlice(rv.Type(), 0, len(values))
+	for _, value := range values {
+		ev, err := sliceElement(rv.Type(), value)
+		if err != nil {
+			return nil, err
+		}
+		added = reflect.Append(added, ev)
+	}
+
+	result := reflect.MakeSlice(rv.Type(), 0, rv.Len()+len(values))
+	if prepend {
+		result = reflect.AppendSlice(reflect.AppendSlice(result, added), rv)
+	} else {
+		result = reflect.AppendSlice(reflect.AppendSlice(result, rv), added)
+	}
+	return result.Interface(), nil
+}
+
+// sliceWithValueAtIndex returns a copy of the slice with the element at the
+// given index replaced
+func sliceWithValueAtIndex(s interface{}, index interface{}, value interface{}) (interface{}, error) {
+	i, ok := index.(int)
+	if !ok {
+		return nil, fmt.Errorf("Invalid list index %v", index)
+	}
+	rv := reflect.ValueOf(s)
+	if s != nil && rv.Kind() != reflect.Slice {
+		return nil, fmt.Errorf("Can't set an element of a field that isn't a list: %T", s)
+	}
+	if s == nil || i < 0 || i >= rv.Len() {
+		size := 0
+		if s != nil {
+			size = rv.Len()
+		}
+		return nil, fmt.Errorf("List index %d out of bound, list has size %d", i, size)
+	}
+	ev, err := sliceElement(rv.Type(), value)
+	if err != nil {
+		return nil, err
+	}
+
+	result := reflect.MakeSlice(rv.Type(), rv.Len(), rv.Len())
+	reflect.Copy(result, rv)
+	result.Index(i).Set(ev)
+	return result.Interface(), nil
+}
+
+// sliceElement converts a value to the element type of a slice
+func sliceElement(t reflect.Type, value interface{}) (reflect.Value, error) {
+	if value == nil {
+		return reflect.Zero(t.Elem()), nil
+	}
+	ev := reflect.ValueOf(value)
+	if !ev.Type().ConvertibleTo(t.Elem()) || (t.Elem().Kind() == reflect.String) != (ev.Kind() == reflect.String) {
+		return reflect.Value{}, fmt.Errorf("Invalid value %v for list of type %v", value, t)
+	}
+	return ev.Convert(t.Elem()), nil
+}
+
+// sliceWithoutIndex returns a copy of the slice with the element at the
+// given index removed
+func sliceWithoutIndex(s interface{}, index interface{}) (interface{}, error) {
+	rv := reflect.ValueOf(s)
+	i, ok := index.(int)
+	if !ok {
+		return nil, fmt.Errorf("Invalid list index %v", index)
+	}
+	if i < 0 || i >= rv.Len() {
+		return nil, fmt.Errorf("Attempted to delete an element from a list which has only %d elements", rv.Len())
+	}
+
+	result := reflect.MakeSlice(rv.Type(), 0, rv.Len()-1)
+	result = reflect.AppendSlice(result, rv.Slice(0, i))
+	result = reflect.AppendSlice(result, rv.Slice(i+1, rv.Len()))
+	return result.Interface(), nil
+}
+
+func (q *MockFilter) Read(out interface{}) Op {
+	return q.read(out, false)
+}
+
+func (q *MockFilter) ReadJSON(out *[]json.RawMessage) Op {
+	return q.read(out, true)
+}
+
+// rows returns the rows matching the filter, in the order and up to the
+// limit given by the options. The table has to be locked while the rows are
+// in use, as they aren't copied
+func (q *MockFilter) rows(opt Options) ([]map[string]interface{}, error) {
+	if q.table.strict {
+		if err := q.table.schema().validateSelect(q.relations, opt); err != nil {
+			return nil, err
+		}
+	}
+
+	var (
+		result []map[string]interface{}
+		err    error
+	)
+	switch {
+	case len(q.Relations()) == 0:
+		result = q.readAllRows()
+	default:
+		result, err = q.readSomeRows(opt.ClusteringOrder)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if opt.Limit > 0 && opt.Limit < len(result) {
+		result = result[:opt.Limit]
+	}
+	return result, nil
+}
+
+func (q *MockFilter) read(out interface{}, asJSON bool) Op {
+	op := newOp(func(m mockOp) error {
+		q.table.Lock()
+		defer q.table.Unlock()
 
 		opt := q.table.options.Merge(m.options)
-		if opt.Limit > 0 && opt.Limit < len(result) {
-			result = result[:opt.Limit]
+		filter := q
+		if q.table.replicas != nil {
+			var err error
+			if filter, err = q.replicaFilter(opt.Consistency); err != nil {
+				return err
+			}
+		}
+		result, err := filter.rows(opt)
+		if err != nil {
+			return err
 		}
 
 		fieldNames := opt.Select
@@ -636,13 +1276,52 @@ func (q *MockFilter) Read(out interface{}) Op {
 		}
 
 		stmt := SelectStatement{keyspace: q.table.ksName, table: q.table.Name(), fields: fieldNames}
-		iter := newMockIterator(result, stmt.fields)
+		if asJSON {
+			if result, err = rowsToJSON(result, fieldNames); err != nil {
+				return err
+			}
+			stmt = stmt.WithJSON(true)
+			fieldNames = []string{JSONColumnName}
+		}
+
+		iter := newMockIterator(result, fieldNames)
 		_, err = NewScanner(stmt, out).ScanIter(iter)
 		return err
 	})
+	op.target = q.target(MockSelect)
+	return op
+}
+
+// rowsToJSON serialises each row into a JSON document keyed by the names of
+// its columns, which Cassandra lowercases as they're created unquoted, with
+// values formatted as a SELECT JSON query returns them
+func rowsToJSON(rows []map[string]interface{}, fieldNames []string) ([]map[string]interface{}, error) {
+	result := make([]map[string]interface{}, 0, len(rows))
+	for _, row := range rows {
+		doc := make(map[string]interface{}, len(fieldNames))
+		for _, fieldName := range fieldNames {
+			column := fieldName
+			if _, ok := row[fieldName]; !ok {
+				for k := range row {
+					if strings.EqualFold(k, fieldName) {
+						column = k
+						break
+					}
+				}
+			}
+			doc[strings.ToLower(column)] = jsonValue(row[column])
+		}
+
+		marshalled, err := json.Marshal(doc)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, map[string]interface{}{JSONColumnName: string(marshalled)})
+	}
+	return result, nil
 }
 
-func (q *MockFilter) readSomeRows() ([]map[string]interface{}, error) {
+func (q *MockFilter) readSomeRows(order []ClusteringOrderColumn) ([]map[string]interface{}, error) {
 	q.table.mtx.RLock()
 	defer q.table.mtx.RUnlock()
 
@@ -651,6 +1330,7 @@ func (q *MockFilter) readSomeRows() ([]map[string]interface{}, error) {
 		return nil, err
 	}
 
+	now := q.table.now()
 	var result []map[string]interface{}
 	for _, rowKey := range rowKeys {
 		row := q.table.rows[rowKey.RowKey()]
@@ -658,9 +1338,13 @@ func (q *MockFilter) readSomeRows() ([]map[string]interface{}, error) {
 			continue
 		}
 
-		row.Ascend(func(item btree.Item) bool {
-			columns := item.(*superColumn).Columns
-			if q.rowMatch(columns) {
+		iterate := row.Ascend
+		if reversedOrder(row, order) {
+			iterate = row.Descend
+		}
+		iterate(func(item btree.Item) bool {
+			columns, alive := item.(*superColumn).live(q.table.keys, now)
+			if alive && q.rowMatch(columns) {
 				result = append(result, columns)
 			}
 
@@ -671,14 +1355,32 @@ func (q *MockFilter) readSomeRows() ([]map[string]interface{}, error) {
 	return result, nil
 }
 
+// reversedOrder determines whether the rows of a partition have to be read
+// in the reverse of the order they are stored in to satisfy the requested
+// ordering of the first clustering column
+func reversedOrder(row *btree.BTree, order []ClusteringOrderColumn) bool {
+	min, ok := row.Min().(*superColumn)
+	if !ok || len(min.Key) == 0 {
+		return false
+	}
+	first := min.Key[0]
+	for _, o := range order {
+		if strings.EqualFold(o.Column, first.Key) {
+			return o.Direction != first.ClusteringOrder
+		}
+	}
+	return false
+}
+
 func (q *MockFilter) readAllRows() []map[string]interface{} {
 	q.table.mtx.RLock()
 	defer q.table.mtx.RUnlock()
+	now := q.table.now()
 	var result []map[string]interface{}
 	for _, row := range q.table.rows {
 		row.Ascend(func(item btree.Item) bool {
-			columns := item.(*superColumn).Columns
-			if q.rowMatch(columns) {
+			columns, alive := item.(*superColumn).live(q.table.keys, now)
+			if alive && q.rowMatch(columns) {
 				result = append(result, columns)
 			}
 
@@ -689,9 +1391,11 @@ func (q *MockFilter) readAllRows() []map[string]interface{} {
 }
 
 func (q *MockFilter) ReadOne(out interface{}) Op {
-	return newOp(func(m mockOp) error {
-		return q.Read(out).Run()
-	})
+	return q.read(out, false)
+}
+
+func (q *MockFilter) ReadOneJSON(out *json.RawMessage) Op {
+	return q.read(out, true)
 }
 
 // mockIterator takes in a slice of maps and implements a Scannable iterator
@@ -917,6 +1621,39 @@ func assignRecords(m map[string]interface{}, record map[string]interface{}) erro
 					targetMap.SetMapIndex(reflect.ValueOf(k), reflect.ValueOf(v))
 				}
 				record[k] = targetMap.Interface()
+			case ModifierMapRemoveKeys:
+				if record[k] == nil {
+					continue
+				}
+				result, err := mapWithoutKeys(record[k], v.args)
+				if err != nil {
+					return err
+				}
+				record[k] = result
+			case ModifierSetRemove, ModifierListRemove:
+				if record[k] == nil {
+					continue
+				}
+				result, err := sliceWithoutValues(record[k], v.args)
+				if err != nil {
+					return err
+				}
+				record[k] = result
+			case ModifierListAppend, ModifierListPrepend:
+				result, err := sliceWithValues(record[k], v.args, v.op == ModifierListPrepend)
+				if err != nil {
+					return err
+				}
+				record[k] = result
+			case ModifierListSetAtIndex:
+				if len(v.args) != 2 {
+					return fmt.Errorf("Argument for ListSetAtIndex is not a slice of 2 elements")
+				}
+				result, err := sliceWithValueAtIndex(record[k], v.args[0], v.args[1])
+				if err != nil {
+					return err
+				}
+				record[k] = result
 			case ModifierCounterIncrement:
 				oldV, _ := record[k].(int64)
 				delta := int64(v.args[0].(int))
@@ -926,6 +1663,10 @@ func assignRecords(m map[string]interface{}, record map[string]interface{}) erro
 				return fmt.Errorf("Modifer %v not supported by mock keyspace", v.op)
 			}
 		default:
+			if v == gocql.UnsetValue {
+				// Unset values leave the existing value untouched
+				continue
+			}
 			record[k] = v
 		}
 	}
@@ -937,8 +1678,12 @@ type mockContextKey string
 
 var errorInjectorContextKey mockContextKey = "error_injector_context_key"
 
+// ErrorInjector decides whether to fail an op run together with other ops on
+// a mock keyspace, see ErrorInjectorContext
 type ErrorInjector interface {
-	shouldReturnErr(op Op, opIdx int, opCount int) error
+	// ShouldReturnErr returns the error to fail op with before it's run, or
+	// nil to run it. op is the opIdx-th of opCount ops, 0 indexed
+	ShouldReturnErr(op Op, opIdx int, opCount int) error
 }
 
 func getErrorInjector(ctx context.Context) ErrorInjector {
@@ -969,7 +1714,7 @@ func ErrorInjectorContext(parent context.Context, strategy ErrorInjector) contex
 
 type neverFail struct{}
 
-func (n *neverFail) shouldReturnErr(Op, int, int) error { return nil }
+func (n *neverFail) ShouldReturnErr(Op, int, int) error { return nil }
 
 // FailOnNthOperation returns an ErrorInjector which injects the provided err on
 // the nth operation of a mockMultiOp. n is 0 indexed so an n value of 0 will
@@ -984,7 +1729,7 @@ type failOnNthOperation struct {
 	err error
 }
 
-func (f *failOnNthOperation) shouldReturnErr(op Op, opIdx, opCount int) error {
+func (f *failOnNthOperation) ShouldReturnErr(op Op, opIdx, opCount int) error {
 	if opIdx == f.n {
 		return f.err
 	}
@@ -1027,7 +1772,7 @@ func (f *FailOnEachOperationErrorInjector) ShouldContinue() bool {
 	return true
 }
 
-func (f *FailOnEachOperationErrorInjector) shouldReturnErr(op Op, opIdx, opCount int) error {
+func (f *FailOnEachOperationErrorInjector) ShouldReturnErr(op Op, opIdx, opCount int) error {
 	if opIdx == f.shouldFailOnOp {
 		f.shouldFailOnOp++
 		f.lastErrorInjectedAtIdx = opIdx
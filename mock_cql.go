@@ -0,0 +1,1291 @@
+package gocassa
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+// cqlProtoVersion is the version of the native protocol values are encoded in
+const cqlProtoVersion = 4
+
+// cqlReleaseVersion is the version of Cassandra the mock claims to be
+const cqlReleaseVersion = "3.11.9"
+
+// cqlAlreadyExistsError is returned when creating a keyspace or table which
+// already exists
+type cqlAlreadyExistsError struct {
+	keyspace, table string
+}
+
+func (e cqlAlreadyExistsError) Code() int {
+	return 0x2400
+}
+
+func (e cqlAlreadyExistsError) Message() string {
+	if e.table == "" {
+		return fmt.Sprintf("Cannot add existing keyspace %q", e.keyspace)
+	}
+	return fmt.Sprintf("Cannot add already existing table %q to keyspace %q", e.table, e.keyspace)
+}
+
+func (e cqlAlreadyExistsError) Error() string {
+	return e.Message()
+}
+
+// cqlColumn is a column of a table, or a value bound to a statement
+type cqlColumn struct {
+	name string
+	// field is the field of the mock rows holding the column
+	field  string
+	typ    gocql.TypeInfo
+	goType reflect.Type
+}
+
+// decode decodes a value of the column, as encoded by the native protocol,
+// into the Go type of the column
+func (c cqlColumn) decode(data []byte) (interface{}, error) {
+	v := reflect.New(c.goType)
+	if err := gocql.Unmarshal(c.typ, data, v.Interface()); err != nil {
+		return nil, invalidRequest("Invalid value for %s: %v", c.name, err)
+	}
+	return v.Elem().Interface(), nil
+}
+
+// encode encodes a value of the column as the native protocol does. A nil
+// value is encoded as null
+func (c cqlColumn) encode(v interface{}) ([]byte, error) {
+	if v == nil {
+		return nil, nil
+	}
+	b, err := gocql.Marshal(c.typ, v)
+	if err != nil {
+		return nil, fmt.Errorf("could not encode %s: %v", c.name, err)
+	}
+	return b, nil
+}
+
+// cqlTable is a mock table, or one of the system tables, as seen through CQL
+type cqlTable struct {
+	keyspace, name string
+	columns        []cqlColumn
+	// mock is nil for system tables
+	mock *MockTable
+	// rows returns the rows of a system table, keyed by column name
+	rows func() []map[string]interface{}
+}
+
+func (t *cqlTable) column(name string) (*cqlColumn, error) {
+	for i, c := range t.columns {
+		if c.name == name {
+			return &t.columns[i], nil
+		}
+	}
+	return nil, invalidRequest("Undefined column name %s in table %s.%s", name, t.keyspace, t.name)
+}
+
+// cqlPrepared is a parsed statement, along with the values bound to it and
+// the columns of the rows it returns
+type cqlPrepared struct {
+	query    string
+	keyspace string
+	stmt     interface{}
+	binds    []cqlColumn
+	// table is the table of the statement, if it has one
+	table   *cqlTable
+	results []cqlColumn
+}
+
+// checkBatchable returns an error if the statement can't be part of a batch
+func (p *cqlPrepared) checkBatchable() error {
+	switch p.stmt.(type) {
+	case *cqlInsert, *cqlUpdate, *cqlDelete:
+		return nil
+	}
+	return invalidRequest("Only INSERT, UPDATE and DELETE statements are allowed in BATCH, not %q", p.query)
+}
+
+// cqlResult is the result of a statement
+type cqlResult struct {
+	columns []cqlColumn
+	rows    [][]interface{}
+	// keyspace is set by USE statements
+	keyspace string
+}
+
+// cqlEngine runs CQL statements against mock keyspaces
+type cqlEngine struct {
+	mu        sync.RWMutex
+	keyspaces map[string]*mockKeySpace
+	// types holds the column types of the tables created by CQL, keyed by
+	// keyspace and table
+	types  map[[2]string]map[string]gocql.TypeInfo
+	hostID gocql.UUID
+	// options configure the keyspaces created through CQL
+	options []MockOption
+	// implicitKeyspaces makes keyspaces exist as soon as they're referred to
+	implicitKeyspaces bool
+}
+
+func newCQLEngine(options ...MockOption) *cqlEngine {
+	return &cqlEngine{
+		keyspaces: map[string]*mockKeySpace{},
+		types:     map[[2]string]map[string]gocql.TypeInfo{},
+		hostID:    gocql.TimeUUID(),
+		options:   options,
+	}
+}
+
+// newKeySpace returns a new mock keyspace with the options of the engine
+func (e *cqlEngine) newKeySpace(name string) *mockKeySpace {
+	ks := NewMockKeySpace(e.options...).(*mockKeySpace)
+	ks.SetKeysSpaceName(name)
+	return ks
+}
+
+func (e *cqlEngine) addKeySpace(name string, ks *mockKeySpace) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if ks.Name() == "" {
+		ks.SetKeysSpaceName(name)
+	}
+	e.keyspaces[name] = ks
+}
+
+// keyspace returns the named keyspace. Unquoted names are lowercased by the
+// parser, so they match keyspaces regardless of case
+func (e *cqlEngine) keyspace(name string) (*mockKeySpace, string, error) {
+	e.mu.RLock()
+	ks, found := e.findKeySpace(name)
+	e.mu.RUnlock()
+	if ks != nil {
+		return ks, found, nil
+	}
+	if name == "" {
+		return nil, "", invalidRequest("No keyspace has been specified. USE a keyspace, or explicitly specify keyspace.tablename")
+	}
+	if !e.implicitKeyspaces || strings.HasPrefix(name, "system") {
+		return nil, "", invalidRequest("Keyspace %s does not exist", name)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if ks, found := e.findKeySpace(name); ks != nil {
+		return ks, found, nil
+	}
+	ks = e.newKeySpace(name)
+	e.keyspaces[name] = ks
+	return ks, name, nil
+}
+
+func (e *cqlEngine) findKeySpace(name string) (*mockKeySpace, string) {
+	if ks, ok := e.keyspaces[name]; ok {
+		return ks, name
+	}
+	for n, ks := range e.keyspaces {
+		if strings.EqualFold(n, name) {
+			return ks, n
+		}
+	}
+	return nil, ""
+}
+
+// mockTable returns the table of a mock keyspace with the given name,
+// ignoring case as gocassa doesn't quote its table names
+func (ks *mockKeySpace) mockTable(name string) *MockTable {
+	ks.store.mu.Lock()
+	defer ks.store.mu.Unlock()
+	if t, ok := ks.store.tables[name]; ok {
+		return t
+	}
+	for n, t := range ks.store.tables {
+		if strings.EqualFold(n, name) {
+			return t
+		}
+	}
+	return nil
+}
+
+// table returns the table a statement refers to
+func (e *cqlEngine) table(sessionKeyspace, keyspace, name string) (*cqlTable, error) {
+	if keyspace == "" {
+		keyspace = sessionKeyspace
+	}
+	if t := e.systemTable(keyspace, name); t != nil {
+		return t, nil
+	}
+	ks, keyspace, err := e.keyspace(keyspace)
+	if err != nil {
+		return nil, err
+	}
+	mt := ks.mockTable(name)
+	if mt == nil {
+		return nil, invalidRequest("unconfigured table %s", name)
+	}
+
+	e.mu.RLock()
+	declared := e.types[[2]string{keyspace, mt.tableName}]
+	e.mu.RUnlock()
+
+	t := &cqlTable{keyspace: keyspace, name: mt.tableName, mock: mt}
+	for _, field := range mt.fields {
+		c := cqlColumn{name: field, field: field, typ: declared[field]}
+		if declared == nil {
+			// gocassa doesn't quote column names, so they're lowercased
+			c.name = strings.ToLower(field)
+		}
+		if source := mt.fieldSource[field]; source != nil {
+			c.goType = reflect.TypeOf(source)
+		}
+		if c.typ == nil {
+			if c.goType == nil {
+				continue
+			}
+			// Fields of types gocassa can't create columns for aren't
+			// visible through CQL
+			s, err := stringTypeOf(mt.fieldSource[field])
+			if err != nil {
+				continue
+			}
+			if c.typ, err = parseCQLType(s); err != nil {
+				continue
+			}
+		}
+		if c.goType == nil {
+			c.goType = cqlGoType(c.typ)
+		}
+		t.columns = append(t.columns, c)
+	}
+	sortCQLColumns(t.columns, mt.keys)
+	return t, nil
+}
+
+// sortCQLColumns sorts the columns of a table in the order SELECT * returns
+// them: the partition keys, the clustering columns, then the other columns
+// by name
+func sortCQLColumns(columns []cqlColumn, keys Keys) {
+	rank := map[string]int{}
+	for i, k := range append(append([]string{}, keys.PartitionKeys...), keys.ClusteringColumns...) {
+		rank[k] = i - len(keys.PartitionKeys) - len(keys.ClusteringColumns)
+	}
+	sort.SliceStable(columns, func(i, j int) bool {
+		ri, rj := rank[columns[i].field], rank[columns[j].field]
+		if ri != rj {
+			return ri < rj
+		}
+		return columns[i].name < columns[j].name
+	})
+}
+
+// cqlGoType returns the type values of a column are decoded into
+func cqlGoType(typ gocql.TypeInfo) reflect.Type {
+	switch typ.Type() {
+	case gocql.TypeList, gocql.TypeSet:
+		return reflect.SliceOf(cqlGoType(typ.(gocql.CollectionType).Elem))
+	case gocql.TypeMap:
+		c := typ.(gocql.CollectionType)
+		return reflect.MapOf(cqlGoType(c.Key), cqlGoType(c.Elem))
+	case gocql.TypeVarint, gocql.TypeCounter:
+		return reflect.TypeOf(int64(0))
+	case gocql.TypeAscii, gocql.TypeVarchar, gocql.TypeInet:
+		return reflect.TypeOf("")
+	}
+	return reflect.TypeOf(typ.New()).Elem()
+}
+
+// cqlTypeString returns the CQL name of a type
+func cqlTypeString(typ gocql.TypeInfo) string {
+	switch typ.Type() {
+	case gocql.TypeList, gocql.TypeSet:
+		return fmt.Sprintf("%s<%s>", typ.Type(), cqlTypeString(typ.(gocql.CollectionType).Elem))
+	case gocql.TypeMap:
+		c := typ.(gocql.CollectionType)
+		return fmt.Sprintf("map<%s, %s>", cqlTypeString(c.Key), cqlTypeString(c.Elem))
+	case gocql.TypeVarchar:
+		return "text"
+	}
+	return typ.Type().String()
+}
+
+func cqlNativeType(typ gocql.Type) gocql.TypeInfo {
+	return gocql.NewNativeType(cqlProtoVersion, typ, "")
+}
+
+func cqlCollectionType(typ gocql.Type, key, elem gocql.TypeInfo) gocql.TypeInfo {
+	return gocql.CollectionType{NativeType: gocql.NewNativeType(cqlProtoVersion, typ, ""), Key: key, Elem: elem}
+}
+
+func cqlSystemColumn(name string, typ gocql.TypeInfo) cqlColumn {
+	return cqlColumn{name: name, field: name, typ: typ, goType: cqlGoType(typ)}
+}
+
+// systemTable returns the system tables gocql and other drivers query when
+// connecting, or nil if the name isn't one of them
+func (e *cqlEngine) systemTable(keyspace, name string) *cqlTable {
+	varchar, uuid := cqlNativeType(gocql.TypeVarchar), cqlNativeType(gocql.TypeUUID)
+	tokens := cqlCollectionType(gocql.TypeSet, nil, varchar)
+	t := &cqlTable{keyspace: keyspace, name: name}
+	switch keyspace + "." + name {
+	case "system.local":
+		for _, c := range []string{"key", "bootstrapped", "cluster_name", "cql_version", "data_center", "partitioner", "rack", "release_version"} {
+			t.columns = append(t.columns, cqlSystemColumn(c, varchar))
+		}
+		t.columns = append(t.columns,
+			cqlSystemColumn("host_id", uuid), cqlSystemColumn("schema_version", uuid), cqlSystemColumn("tokens", tokens))
+		t.rows = func() []map[string]interface{} {
+			return []map[string]interface{}{{
+				"key":             "local",
+				"bootstrapped":    "COMPLETED",
+				"cluster_name":    "gocassa",
+				"cql_version":     "3.4.4",
+				"data_center":     "datacenter1",
+				"partitioner":     "org.apache.cassandra.dht.Murmur3Partitioner",
+				"rack":            "rack1",
+				"release_version": cqlReleaseVersion,
+				"host_id":         e.hostID,
+				"schema_version":  e.hostID,
+				"tokens":          []string{"0"},
+			}}
+		}
+	case "system.peers":
+		for _, c := range []string{"peer", "data_center", "rack", "release_version", "rpc_address"} {
+			t.columns = append(t.columns, cqlSystemColumn(c, varchar))
+		}
+		t.columns = append(t.columns,
+			cqlSystemColumn("host_id", uuid), cqlSystemColumn("schema_version", uuid), cqlSystemColumn("tokens", tokens))
+		t.rows = func() []map[string]interface{} { return nil }
+	case "system_schema.keyspaces":
+		t.columns = []cqlColumn{
+			cqlSystemColumn("keyspace_name", varchar),
+			cqlSystemColumn("durable_writes", cqlNativeType(gocql.TypeBoolean)),
+			cqlSystemColumn("replication", cqlCollectionType(gocql.TypeMap, varchar, varchar)),
+		}
+		t.rows = func() []map[string]interface{} {
+			var rows []map[string]interface{}
+			for _, name := range e.keyspaceNames() {
+				rows = append(rows, map[string]interface{}{
+					"keyspace_name":  name,
+					"durable_writes": true,
+					"replication": map[string]string{
+						"class":              "org.apache.cassandra.locator.SimpleStrategy",
+						"replication_factor": "1",
+					},
+				})
+			}
+			return rows
+		}
+	case "system_schema.tables":
+		t.columns = []cqlColumn{cqlSystemColumn("keyspace_name", varchar), cqlSystemColumn("table_name", varchar)}
+		t.rows = func() []map[string]interface{} {
+			var rows []map[string]interface{}
+			for _, table := range e.tables() {
+				rows = append(rows, map[string]interface{}{"keyspace_name": table.keyspace, "table_name": table.name})
+			}
+			return rows
+		}
+	case "system_schema.columns":
+		t.columns = []cqlColumn{
+			cqlSystemColumn("keyspace_name", varchar),
+			cqlSystemColumn("table_name", varchar),
+			cqlSystemColumn("column_name", varchar),
+			cqlSystemColumn("clustering_order", varchar),
+			cqlSystemColumn("kind", varchar),
+			cqlSystemColumn("position", cqlNativeType(gocql.TypeInt)),
+			cqlSystemColumn("type", varchar),
+		}
+		t.rows = func() []map[string]interface{} {
+			var rows []map[string]interface{}
+			for _, table := range e.tables() {
+				for _, c := range table.columns {
+					rows = append(rows, schemaColumnRow(table, c))
+				}
+			}
+			return rows
+		}
+	default:
+		return nil
+	}
+	return t
+}
+
+// schemaColumnRow returns the row of system_schema.columns for a column
+func schemaColumnRow(t *cqlTable, c cqlColumn) map[string]interface{} {
+	row := map[string]interface{}{
+		"keyspace_name":    t.keyspace,
+		"table_name":       strings.ToLower(t.name),
+		"column_name":      c.name,
+		"clustering_order": "none",
+		"kind":             "regular",
+		"position":         -1,
+		"type":             cqlTypeString(c.typ),
+	}
+	keys := t.mock.keys
+	for i, k := range keys.PartitionKeys {
+		if k == c.field {
+			row["kind"], row["position"] = "partition_key", i
+		}
+	}
+	for i, k := range keys.ClusteringColumns {
+		if k == c.field {
+			row["kind"], row["position"], row["clustering_order"] = "clustering", i, "asc"
+			for _, o := range t.mock.options.ClusteringOrder {
+				if o.Column == k && o.Direction == DESC {
+					row["clustering_order"] = "desc"
+				}
+			}
+		}
+	}
+	return row
+}
+
+func (e *cqlEngine) keyspaceNames() []string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	names := []string{"system", "system_schema"}
+	for name := range e.keyspaces {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// tables returns every table of the mock keyspaces
+func (e *cqlEngine) tables() []*cqlTable {
+	var tables []*cqlTable
+	for _, keyspace := range e.keyspaceNames() {
+		ks, _, err := e.keyspace(keyspace)
+		if err != nil {
+			continue
+		}
+		ks.store.mu.Lock()
+		names := make([]string, 0, len(ks.store.tables))
+		for name := range ks.store.tables {
+			names = append(names, name)
+		}
+		ks.store.mu.Unlock()
+		sort.Strings(names)
+
+		for _, name := range names {
+			if t, err := e.table(keyspace, keyspace, name); err == nil {
+				t.name = strings.ToLower(t.name)
+				tables = append(tables, t)
+			}
+		}
+	}
+	return tables
+}
+
+// prepare parses a statement, resolving the table it refers to and the types
+// of the values bound to it. Unqualified tables refer to the keyspace of the
+// session
+func (e *cqlEngine) prepare(keyspace, query string) (*cqlPrepared, error) {
+	stmt, binds, err := parseCQL(query)
+	if err != nil {
+		return nil, err
+	}
+	p := &cqlPrepared{query: query, keyspace: keyspace, stmt: stmt, binds: make([]cqlColumn, binds)}
+	bindAll := func(columns map[int]cqlColumn) {
+		for i, c := range columns {
+			p.binds[i] = c
+		}
+	}
+
+	switch stmt := stmt.(type) {
+	case *cqlSelect:
+		if p.table, err = e.table(keyspace, stmt.keyspace, stmt.table); err != nil {
+			return nil, err
+		}
+		binds, err := p.table.relationBinds(stmt.where)
+		if err != nil {
+			return nil, err
+		}
+		if stmt.limit != nil && stmt.limit.bind >= 0 {
+			binds[stmt.limit.bind] = cqlSystemColumn("[limit]", cqlNativeType(gocql.TypeInt))
+		}
+		bindAll(binds)
+
+		switch {
+		case stmt.json:
+			p.results = []cqlColumn{cqlSystemColumn(JSONColumnName, cqlNativeType(gocql.TypeVarchar))}
+		case stmt.columns == nil:
+			p.results = p.table.columns
+		default:
+			for _, name := range stmt.columns {
+				c, err := p.table.column(name)
+				if err != nil {
+					return nil, err
+				}
+				p.results = append(p.results, *c)
+			}
+		}
+	case *cqlInsert:
+		if p.table, err = e.mockTable(keyspace, stmt.keyspace, stmt.table); err != nil {
+			return nil, err
+		}
+		binds := map[int]cqlColumn{}
+		for i, name := range stmt.columns {
+			c, err := p.table.column(name)
+			if err != nil {
+				return nil, err
+			}
+			if stmt.values[i].bind >= 0 {
+				binds[stmt.values[i].bind] = *c
+			}
+		}
+		if stmt.json != nil && stmt.json.bind >= 0 {
+			binds[stmt.json.bind] = cqlSystemColumn("[json]", cqlNativeType(gocql.TypeVarchar))
+		}
+		bindTTL(binds, stmt.ttl)
+		bindAll(binds)
+	case *cqlUpdate:
+		if p.table, err = e.mockTable(keyspace, stmt.keyspace, stmt.table); err != nil {
+			return nil, err
+		}
+		binds, err := p.table.relationBinds(stmt.where)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range stmt.set {
+			c, err := p.table.column(a.column)
+			if err != nil {
+				return nil, err
+			}
+			valueType, indexType, err := assignmentTypes(c, a.op)
+			if err != nil {
+				return nil, err
+			}
+			if a.term.bind >= 0 {
+				binds[a.term.bind] = cqlColumn{name: c.name, field: c.field, typ: valueType, goType: cqlGoType(valueType)}
+				if valueType == c.typ {
+					binds[a.term.bind] = *c
+				}
+			}
+			if a.op == cqlAssignElement && a.index.bind >= 0 {
+				binds[a.index.bind] = cqlSystemColumn("key("+c.name+")", indexType)
+			}
+		}
+		bindTTL(binds, stmt.ttl)
+		bindAll(binds)
+	case *cqlDelete:
+		if p.table, err = e.mockTable(keyspace, stmt.keyspace, stmt.table); err != nil {
+			return nil, err
+		}
+		binds, err := p.table.relationBinds(stmt.where)
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range stmt.columns {
+			c, err := p.table.column(d.column)
+			if err != nil {
+				return nil, err
+			}
+			if d.element == nil || d.element.bind < 0 {
+				continue
+			}
+			_, indexType, err := assignmentTypes(c, cqlAssignElement)
+			if err != nil {
+				return nil, err
+			}
+			binds[d.element.bind] = cqlSystemColumn("key("+c.name+")", indexType)
+		}
+		bindAll(binds)
+	default:
+		if binds > 0 {
+			return nil, invalidRequest("Bind variables aren't supported in %s", strings.Fields(query)[0])
+		}
+	}
+
+	for i, b := range p.binds {
+		if b.typ == nil {
+			return nil, invalidRequest("Unsupported use of bind variable %d in %q", i, query)
+		}
+	}
+	return p, nil
+}
+
+// mockTable returns the table a statement writing to a table refers to, which
+// can't be a system table
+func (e *cqlEngine) mockTable(sessionKeyspace, keyspace, name string) (*cqlTable, error) {
+	t, err := e.table(sessionKeyspace, keyspace, name)
+	if err != nil {
+		return nil, err
+	}
+	if t.mock == nil {
+		return nil, cqlUnauthorizedError{fmt.Sprintf("%s keyspace is not user-modifiable.", t.keyspace)}
+	}
+	return t, nil
+}
+
+// cqlUnauthorizedError is returned by writes to the system tables
+type cqlUnauthorizedError struct {
+	message string
+}
+
+func (e cqlUnauthorizedError) Code() int {
+	return 0x2100
+}
+
+func (e cqlUnauthorizedError) Message() string {
+	return e.message
+}
+
+func (e cqlUnauthorizedError) Error() string {
+	return e.message
+}
+
+func bindTTL(binds map[int]cqlColumn, ttl *cqlTerm) {
+	if ttl != nil && ttl.bind >= 0 {
+		binds[ttl.bind] = cqlSystemColumn("[ttl]", cqlNativeType(gocql.TypeInt))
+	}
+}
+
+// relationBinds returns the types of the values bound to the relations of
+// a WHERE clause
+func (t *cqlTable) relationBinds(relations []cqlRelation) (map[int]cqlColumn, error) {
+	binds := map[int]cqlColumn{}
+	for _, r := range relations {
+		c, err := t.column(r.column)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case r.term.bind >= 0 && r.cmp == CmpIn:
+			typ := cqlCollectionType(gocql.TypeList, nil, c.typ)
+			binds[r.term.bind] = cqlColumn{name: "in(" + c.name + ")", field: c.field, typ: typ, goType: reflect.SliceOf(c.goType)}
+		case r.term.bind >= 0:
+			binds[r.term.bind] = *c
+		}
+		for _, term := range r.term.list {
+			if term.bind >= 0 {
+				binds[term.bind] = *c
+			}
+		}
+	}
+	return binds, nil
+}
+
+// assignmentTypes returns the types of the value, and of the index or key
+// for element assignments, bound to an assignment to a column
+func assignmentTypes(c *cqlColumn, op cqlAssignmentOp) (gocql.TypeInfo, gocql.TypeInfo, error) {
+	collection, _ := c.typ.(gocql.CollectionType)
+	switch op {
+	case cqlAssign:
+		return c.typ, nil, nil
+	case cqlAssignAdd, cqlAssignRemove:
+		switch c.typ.Type() {
+		case gocql.TypeCounter:
+			return cqlNativeType(gocql.TypeBigInt), nil, nil
+		case gocql.TypeMap:
+			if op == cqlAssignRemove {
+				return cqlCollectionType(gocql.TypeSet, nil, collection.Key), nil, nil
+			}
+			return c.typ, nil, nil
+		case gocql.TypeList, gocql.TypeSet:
+			return c.typ, nil, nil
+		}
+	case cqlAssignPrepend:
+		if c.typ.Type() == gocql.TypeList {
+			return c.typ, nil, nil
+		}
+	case cqlAssignElement:
+		switch c.typ.Type() {
+		case gocql.TypeList:
+			return collection.Elem, cqlNativeType(gocql.TypeInt), nil
+		case gocql.TypeMap:
+			return collection.Elem, collection.Key, nil
+		}
+		return nil, nil, invalidRequest("Invalid operation for non collection column %s", c.name)
+	}
+	return nil, nil, invalidRequest("Invalid operation for column %s of type %s", c.name, cqlTypeString(c.typ))
+}
+
+// cqlBinding resolves the terms of a statement to the values bound to it
+type cqlBinding struct {
+	values []interface{}
+}
+
+func (b cqlBinding) value(term cqlTerm, goType reflect.Type) (interface{}, error) {
+	if term.bind >= 0 {
+		if term.bind >= len(b.values) {
+			return nil, invalidRequest("There were %d markers(?) in CQL but %d bound variables", term.bind+1, len(b.values))
+		}
+		return b.values[term.bind], nil
+	}
+	if term.list != nil || term.value == nil {
+		return term.value, nil
+	}
+	return cqlLiteral(term.value, goType)
+}
+
+// cqlLiteral converts a literal to the type of the column it's compared with
+// or assigned to
+func cqlLiteral(value interface{}, goType reflect.Type) (interface{}, error) {
+	if goType == nil {
+		return value, nil
+	}
+	switch goType {
+	case reflect.TypeOf(gocql.UUID{}):
+		if s, ok := value.(string); ok {
+			return gocql.ParseUUID(s)
+		}
+	case reflect.TypeOf(time.Time{}):
+		switch v := value.(type) {
+		case string:
+			return time.Parse(time.RFC3339Nano, v)
+		case int64:
+			return time.Unix(0, v*int64(time.Millisecond)).UTC(), nil
+		}
+	}
+	switch v := value.(type) {
+	case []interface{}:
+		if goType.Kind() != reflect.Slice {
+			break
+		}
+		result := reflect.MakeSlice(goType, 0, len(v))
+		for _, element := range v {
+			ev, err := cqlLiteral(element, goType.Elem())
+			if err != nil {
+				return nil, err
+			}
+			result = reflect.Append(result, reflect.ValueOf(ev))
+		}
+		return result.Interface(), nil
+	case map[interface{}]interface{}:
+		if goType.Kind() != reflect.Map {
+			break
+		}
+		result := reflect.MakeMapWithSize(goType, len(v))
+		for k, element := range v {
+			kv, err := cqlLiteral(k, goType.Key())
+			if err != nil {
+				return nil, err
+			}
+			ev, err := cqlLiteral(element, goType.Elem())
+			if err != nil {
+				return nil, err
+			}
+			result.SetMapIndex(reflect.ValueOf(kv), reflect.ValueOf(ev))
+		}
+		return result.Interface(), nil
+	}
+	rv := reflect.ValueOf(value)
+	if (rv.Kind() == reflect.String) != (goType.Kind() == reflect.String) || !rv.Type().ConvertibleTo(goType) {
+		return nil, invalidRequest("Invalid literal %v for a value of type %v", value, goType)
+	}
+	return rv.Convert(goType).Interface(), nil
+}
+
+// relations converts the relations of a WHERE clause to gocassa relations on
+// the fields of the table
+func (t *cqlTable) relations(b cqlBinding, where []cqlRelation) ([]Relation, error) {
+	var relations []Relation
+	for _, r := range where {
+		c, err := t.column(r.column)
+		if err != nil {
+			return nil, err
+		}
+		if r.cmp == CmpIn {
+			var terms []interface{}
+			if r.term.list != nil {
+				for _, term := range r.term.list {
+					v, err := b.value(term, c.goType)
+					if err != nil {
+						return nil, err
+					}
+					terms = append(terms, v)
+				}
+			} else {
+				v, err := b.value(r.term, nil)
+				if err != nil {
+					return nil, err
+				}
+				if v != nil {
+					rv := reflect.ValueOf(v)
+					for i := 0; i < rv.Len(); i++ {
+						terms = append(terms, rv.Index(i).Interface())
+					}
+				}
+			}
+			relations = append(relations, In(c.field, terms...))
+			continue
+		}
+
+		v, err := b.value(r.term, c.goType)
+		if err != nil {
+			return nil, err
+		}
+		if v == nil || v == gocql.UnsetValue {
+			return nil, invalidRequest("Invalid null value in condition for column %s", c.name)
+		}
+		relations = append(relations, Relation{cmp: r.cmp, field: c.field, terms: []interface{}{v}})
+	}
+	return relations, nil
+}
+
+// execute runs a prepared statement with the given values, which have to be
+// of the Go types of its bind columns
+func (e *cqlEngine) execute(p *cqlPrepared, values []interface{}) (*cqlResult, error) {
+	if len(values) != len(p.binds) {
+		return nil, invalidRequest("There were %d markers(?) in CQL but %d bound variables", len(p.binds), len(values))
+	}
+	b := cqlBinding{values: values}
+
+	switch stmt := p.stmt.(type) {
+	case *cqlSelect:
+		return e.selectRows(p, stmt, b)
+	case *cqlInsert:
+		return &cqlResult{}, e.insert(p.table, stmt, b)
+	case *cqlUpdate:
+		return &cqlResult{}, e.update(p.table, stmt, b)
+	case *cqlDelete:
+		return &cqlResult{}, e.delete(p.table, stmt, b)
+	case *cqlCreateTable:
+		return &cqlResult{}, e.createTable(p.keyspace, stmt)
+	case *cqlCreateKeyspace:
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		if ks, _ := e.findKeySpace(stmt.keyspace); ks != nil {
+			if stmt.ifNotExists || e.implicitKeyspaces {
+				return &cqlResult{}, nil
+			}
+			return nil, cqlAlreadyExistsError{keyspace: stmt.keyspace}
+		}
+		e.keyspaces[stmt.keyspace] = e.newKeySpace(stmt.keyspace)
+		return &cqlResult{}, nil
+	case *cqlDropTable:
+		return &cqlResult{}, e.dropTable(p.keyspace, stmt)
+	case *cqlDropKeyspace:
+		_, name, err := e.keyspace(stmt.keyspace)
+		if err != nil {
+			if stmt.ifExists {
+				return &cqlResult{}, nil
+			}
+			return nil, err
+		}
+		e.mu.Lock()
+		delete(e.keyspaces, name)
+		e.mu.Unlock()
+		return &cqlResult{}, nil
+	case *cqlTruncate:
+		t, err := e.mockTable(p.keyspace, stmt.keyspace, stmt.table)
+		if err != nil {
+			return nil, err
+		}
+		t.mock.truncate()
+		return &cqlResult{}, nil
+	case *cqlUse:
+		_, name, err := e.keyspace(stmt.keyspace)
+		if err != nil {
+			return nil, err
+		}
+		return &cqlResult{keyspace: name}, nil
+	}
+	return nil, invalidRequest("Unsupported statement %q", p.query)
+}
+
+func (e *cqlEngine) selectRows(p *cqlPrepared, stmt *cqlSelect, b cqlBinding) (*cqlResult, error) {
+	t := p.table
+	relations, err := t.relations(b, stmt.where)
+	if err != nil {
+		return nil, err
+	}
+	opts := Options{AllowFiltering: stmt.allowFiltering}
+	if stmt.limit != nil {
+		limit, err := b.value(*stmt.limit, reflect.TypeOf(0))
+		if err != nil {
+			return nil, err
+		}
+		if l, _ := limit.(int); l <= 0 {
+			return nil, invalidRequest("LIMIT must be strictly positive")
+		}
+		opts.Limit = limit.(int)
+	}
+	for _, o := range stmt.order {
+		c, err := t.column(o.Column)
+		if err != nil {
+			return nil, err
+		}
+		opts.ClusteringOrder = append(opts.ClusteringOrder, ClusteringOrderColumn{Column: c.field, Direction: o.Direction})
+	}
+	fields := make([]string, len(p.results))
+	for i, c := range p.results {
+		fields[i] = c.field
+	}
+	if !stmt.json && stmt.columns != nil {
+		opts.Select = fields
+	}
+
+	var rows []map[string]interface{}
+	if t.mock == nil {
+		for _, row := range t.rows() {
+			if (&MockFilter{relations: relations}).rowMatch(row) {
+				rows = append(rows, row)
+			}
+		}
+		if opts.Limit > 0 && opts.Limit < len(rows) {
+			rows = rows[:opts.Limit]
+		}
+	} else {
+		if err := t.mock.schema().validateSelect(relations, opts); err != nil {
+			return nil, err
+		}
+		filter := &MockFilter{table: t.mock, relations: relations}
+		t.mock.Lock()
+		rows, err = filter.rows(t.mock.options.Merge(opts))
+		if err == nil {
+			copied := make([]map[string]interface{}, len(rows))
+			for i, row := range rows {
+				copied[i] = deepCopy(row).(map[string]interface{})
+			}
+			rows = copied
+		}
+		t.mock.Unlock()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	result := &cqlResult{columns: p.results}
+	if stmt.json {
+		fields = fields[:0]
+		for _, c := range t.columns {
+			if stmt.columns == nil {
+				fields = append(fields, c.field)
+			}
+		}
+		for _, name := range stmt.columns {
+			c, err := t.column(name)
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, c.field)
+		}
+		if rows, err = rowsToJSON(rows, fields); err != nil {
+			return nil, err
+		}
+		fields = []string{JSONColumnName}
+	}
+	for _, row := range rows {
+		values := make([]interface{}, len(fields))
+		for i, f := range fields {
+			values[i] = row[f]
+		}
+		result.rows = append(result.rows, values)
+	}
+	return result, nil
+}
+
+// writeOptions returns the options of a write with the given TTL
+func writeOptions(b cqlBinding, ttl *cqlTerm) (Options, error) {
+	if ttl == nil {
+		return Options{}, nil
+	}
+	v, err := b.value(*ttl, reflect.TypeOf(0))
+	if err != nil {
+		return Options{}, err
+	}
+	seconds, _ := v.(int)
+	if seconds < 0 {
+		return Options{}, invalidRequest("A TTL must be greater or equal to 0, but was %d", seconds)
+	}
+	return Options{TTL: time.Duration(seconds) * time.Second}, nil
+}
+
+func (e *cqlEngine) insert(t *cqlTable, stmt *cqlInsert, b cqlBinding) error {
+	opts, err := writeOptions(b, stmt.ttl)
+	if err != nil {
+		return err
+	}
+
+	if stmt.json != nil {
+		document, err := b.value(*stmt.json, reflect.TypeOf(""))
+		if err != nil {
+			return err
+		}
+		s, ok := document.(string)
+		if !ok {
+			return invalidRequest("Got null for INSERT JSON values")
+		}
+		return t.mock.SetJSON([]byte(s), stmt.jsonDefault).WithOptions(opts).Run()
+	}
+
+	m := map[string]interface{}{}
+	for i, name := range stmt.columns {
+		c, err := t.column(name)
+		if err != nil {
+			return err
+		}
+		if m[c.field], err = b.value(stmt.values[i], c.goType); err != nil {
+			return err
+		}
+	}
+	if err := t.mock.schema().validateInsert(m); err != nil {
+		return err
+	}
+	return t.mock.SetWithOptions(m, opts).Run()
+}
+
+func (e *cqlEngine) update(t *cqlTable, stmt *cqlUpdate, b cqlBinding) error {
+	opts, err := writeOptions(b, stmt.ttl)
+	if err != nil {
+		return err
+	}
+	relations, err := t.relations(b, stmt.where)
+	if err != nil {
+		return err
+	}
+
+	m := map[string]interface{}{}
+	elements := map[string]map[interface{}]interface{}{}
+	for _, a := range stmt.set {
+		c, err := t.column(a.column)
+		if err != nil {
+			return err
+		}
+		if a.op == cqlAssignElement && c.typ.Type() == gocql.TypeMap {
+			k, err := b.value(a.index, c.goType.Key())
+			if err != nil {
+				return err
+			}
+			v, err := b.value(a.term, c.goType.Elem())
+			if err != nil {
+				return err
+			}
+			if elements[c.field] == nil {
+				elements[c.field] = map[interface{}]interface{}{}
+			}
+			elements[c.field][k] = v
+			continue
+		}
+		if _, ok := m[c.field]; ok {
+			return invalidRequest("Multiple incompatible setting of column %s", c.name)
+		}
+		if m[c.field], err = assignmentValue(c, a, b); err != nil {
+			return err
+		}
+	}
+	for field, kvs := range elements {
+		if _, ok := m[field]; ok {
+			return invalidRequest("Multiple incompatible setting of column %s", strings.ToLower(field))
+		}
+		m[field] = mapSetModifier(kvs)
+	}
+
+	if err := t.mock.schema().validateUpdate(relations, m); err != nil {
+		return err
+	}
+	filter := &MockFilter{table: t.mock, relations: relations}
+	return filter.UpdateWithOptions(m, opts).Run()
+}
+
+// mapSetModifier returns the modifier setting the given entries of a map
+func mapSetModifier(kvs map[interface{}]interface{}) Modifier {
+	if len(kvs) == 1 {
+		for k, v := range kvs {
+			return MapSetField(k, v)
+		}
+	}
+	fields := make(map[string]interface{}, len(kvs))
+	for k, v := range kvs {
+		fields[fmt.Sprint(k)] = v
+	}
+	return MapSetFields(fields)
+}
+
+// sliceValues returns the elements of a list or set value
+func sliceValues(v interface{}) []interface{} {
+	if v == nil {
+		return nil
+	}
+	rv := reflect.ValueOf(v)
+	values := make([]interface{}, rv.Len())
+	for i := range values {
+		values[i] = rv.Index(i).Interface()
+	}
+	return values
+}
+
+// assignmentValue returns the value, or modifier, an assignment sets a
+// column to
+func assignmentValue(c *cqlColumn, a cqlAssignment, b cqlBinding) (interface{}, error) {
+	valueType, _, err := assignmentTypes(c, a.op)
+	if err != nil {
+		return nil, err
+	}
+	goType := c.goType
+	if valueType != c.typ {
+		goType = cqlGoType(valueType)
+	}
+	v, err := b.value(a.term, goType)
+	if err != nil || a.op == cqlAssign {
+		return v, err
+	}
+
+	typ := c.typ.Type()
+	switch {
+	case typ == gocql.TypeCounter:
+		n, _ := v.(int64)
+		if a.op == cqlAssignRemove {
+			n = -n
+		}
+		return CounterIncrement(int(n)), nil
+	case a.op == cqlAssignElement:
+		index, err := b.value(a.index, reflect.TypeOf(0))
+		if err != nil {
+			return nil, err
+		}
+		i, _ := index.(int)
+		return ListSetAtIndex(i, v), nil
+	case typ == gocql.TypeMap && a.op == cqlAssignAdd:
+		kvs := map[interface{}]interface{}{}
+		if v != nil {
+			iter := reflect.ValueOf(v).MapRange()
+			for iter.Next() {
+				kvs[iter.Key().Interface()] = iter.Value().Interface()
+			}
+		}
+		return mapSetModifier(kvs), nil
+	case typ == gocql.TypeMap:
+		return MapRemoveKeys(sliceValues(v)...), nil
+	case a.op == cqlAssignRemove && typ == gocql.TypeSet:
+		return SetRemove(sliceValues(v)...), nil
+	case a.op == cqlAssignRemove:
+		return Modifier{op: ModifierListRemove, args: sliceValues(v)}, nil
+	case typ == gocql.TypeSet:
+		return nil, invalidRequest("Adding to sets isn't supported by the mock keyspace, column %s", c.name)
+	case a.op == cqlAssignPrepend:
+		return Modifier{op: ModifierListPrepend, args: sliceValues(v)}, nil
+	}
+	return Modifier{op: ModifierListAppend, args: sliceValues(v)}, nil
+}
+
+func (e *cqlEngine) delete(t *cqlTable, stmt *cqlDelete, b cqlBinding) error {
+	relations, err := t.relations(b, stmt.where)
+	if err != nil {
+		return err
+	}
+
+	var columns []Selection
+	for _, d := range stmt.columns {
+		c, err := t.column(d.column)
+		if err != nil {
+			return err
+		}
+		if d.element == nil {
+			columns = append(columns, Column(c.field))
+			continue
+		}
+		if _, _, err := assignmentTypes(c, cqlAssignElement); err != nil {
+			return err
+		}
+		if c.typ.Type() == gocql.TypeList {
+			index, err := b.value(*d.element, reflect.TypeOf(0))
+			if err != nil {
+				return err
+			}
+			i, _ := index.(int)
+			columns = append(columns, ListIndex(c.field, i))
+			continue
+		}
+		k, err := b.value(*d.element, c.goType.Key())
+		if err != nil {
+			return err
+		}
+		columns = append(columns, MapKey(c.field, k))
+	}
+
+	if err := t.mock.schema().validateDelete(relations, columns); err != nil {
+		return err
+	}
+	filter := t.mock.Where(relations...)
+	if len(columns) == 0 {
+		return filter.Delete().Run()
+	}
+	return filter.DeleteColumns(columns...).Run()
+}
+
+func (e *cqlEngine) createTable(sessionKeyspace string, stmt *cqlCreateTable) error {
+	name := stmt.keyspace
+	if name == "" {
+		name = sessionKeyspace
+	}
+	ks, name, err := e.keyspace(name)
+	if err != nil {
+		return err
+	}
+	if ks.mockTable(stmt.table) != nil {
+		if stmt.ifNotExists {
+			return nil
+		}
+		return cqlAlreadyExistsError{keyspace: name, table: stmt.table}
+	}
+
+	fieldSource := make(map[string]interface{}, len(stmt.columns))
+	types := make(map[string]gocql.TypeInfo, len(stmt.columns))
+	for _, c := range stmt.columns {
+		if _, ok := fieldSource[c.name]; ok {
+			return invalidRequest("Multiple definition of identifier %s", c.name)
+		}
+		fieldSource[c.name] = reflect.Zero(cqlGoType(c.typ)).Interface()
+		types[c.name] = c.typ
+	}
+	for _, k := range append(append([]string{}, stmt.keys.PartitionKeys...), stmt.keys.ClusteringColumns...) {
+		if _, ok := fieldSource[k]; !ok {
+			return invalidRequest("Unknown definition %s referenced in PRIMARY KEY", k)
+		}
+	}
+	for _, o := range stmt.order {
+		if !isKeyField(Keys{ClusteringColumns: stmt.keys.ClusteringColumns}, o.Column) {
+			return invalidRequest("Only clustering key columns can be defined in CLUSTERING ORDER directive")
+		}
+	}
+
+	e.mu.Lock()
+	e.types[[2]string{name, stmt.table}] = types
+	e.mu.Unlock()
+	mt := ks.NewTable(stmt.table, fieldSource, fieldSource, stmt.keys).(*MockTable)
+	mt.options.ClusteringOrder = stmt.order
+	mt.created.record(mt.Name(), stmt.order)
+	return nil
+}
+
+func (e *cqlEngine) dropTable(sessionKeyspace string, stmt *cqlDropTable) error {
+	t, err := e.mockTable(sessionKeyspace, stmt.keyspace, stmt.table)
+	if err != nil {
+		if stmt.ifExists {
+			return nil
+		}
+		return err
+	}
+	ks, name, err := e.keyspace(t.keyspace)
+	if err != nil {
+		return err
+	}
+
+	t.mock.truncate()
+	ks.store.mu.Lock()
+	delete(ks.store.tables, t.mock.tableName)
+	ks.store.mu.Unlock()
+	e.mu.Lock()
+	delete(e.types, [2]string{name, t.mock.tableName})
+	e.mu.Unlock()
+	return nil
+}
+
+// truncate removes every row of the table, including from the copies made
+// by WithOptions which share them
+func (t *MockTable) truncate() {
+	t.Lock()
+	defer t.Unlock()
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	for k := range t.rows {
+		delete(t.rows, k)
+	}
+	t.resetReplicas()
+}
@@ -0,0 +1,188 @@
+package gocassa
+
+import (
+	"fmt"
+
+	"github.com/gocql/gocql"
+)
+
+// InMemoryQueryExecutor is a QueryExecutor which parses the CQL generated by
+// gocassa and runs it against in-memory mock tables. Unlike a MockKeySpace,
+// which implements the tables directly, everything goes through the same
+// statements as with Cassandra, so custom statements, logged batches and
+// keyspace metadata such as Tables behave the way they do against a cluster.
+//
+// As with Cassandra, tables have to be created, with CreateIfNotExist or a
+// CREATE TABLE statement, before they're used. Keyspaces are created as soon
+// as they're referred to. Values are encoded and decoded with gocql, so
+// they're stored and scanned as the CQL types of their columns.
+type InMemoryQueryExecutor struct {
+	engine *cqlEngine
+}
+
+// NewInMemoryQueryExecutor returns an empty InMemoryQueryExecutor. The
+// options configure the mock keyspaces holding its tables
+func NewInMemoryQueryExecutor(options ...MockOption) *InMemoryQueryExecutor {
+	engine := newCQLEngine(options...)
+	engine.implicitKeyspaces = true
+	return &InMemoryQueryExecutor{engine: engine}
+}
+
+// KeySpace returns the mock keyspace holding the tables of the named
+// keyspace, creating it if it doesn't exist. It implements MockStore, so it
+// can be used to snapshot or dump the tables
+func (qe *InMemoryQueryExecutor) KeySpace(name string) KeySpace {
+	ks, _, err := qe.engine.keyspace(name)
+	if err != nil {
+		return nil
+	}
+	return ks
+}
+
+func (qe *InMemoryQueryExecutor) Query(stmt Statement, scanner Scanner) error {
+	return qe.QueryWithOptions(Options{}, stmt, scanner)
+}
+
+func (qe *InMemoryQueryExecutor) QueryWithOptions(opts Options, stmt Statement, scanner Scanner) error {
+	p, values, err := qe.prepare(opts, stmt)
+	if err != nil {
+		return err
+	}
+	result, err := qe.engine.execute(p, values)
+	if err != nil {
+		return err
+	}
+	_, err = scanner.ScanIter(&cqlIterator{result: result, row: -1})
+	return err
+}
+
+func (qe *InMemoryQueryExecutor) Execute(stmt Statement) error {
+	return qe.ExecuteWithOptions(Options{}, stmt)
+}
+
+func (qe *InMemoryQueryExecutor) ExecuteWithOptions(opts Options, stmt Statement) error {
+	p, values, err := qe.prepare(opts, stmt)
+	if err != nil {
+		return err
+	}
+	_, err = qe.engine.execute(p, values)
+	return err
+}
+
+func (qe *InMemoryQueryExecutor) ExecuteAtomically(stmts []Statement) error {
+	return qe.ExecuteAtomicallyWithOptions(Options{}, stmts)
+}
+
+// ExecuteAtomicallyWithOptions runs the statements as a logged batch. None of
+// them is run if any of them is invalid but, as with Cassandra, reads may
+// see some of the statements applied before the others
+func (qe *InMemoryQueryExecutor) ExecuteAtomicallyWithOptions(opts Options, stmts []Statement) error {
+	prepared := make([]*cqlPrepared, len(stmts))
+	values := make([][]interface{}, len(stmts))
+	for i, stmt := range stmts {
+		var err error
+		if prepared[i], values[i], err = qe.prepare(opts, stmt); err != nil {
+			return err
+		}
+		if err := prepared[i].checkBatchable(); err != nil {
+			return err
+		}
+	}
+	for i, p := range prepared {
+		if _, err := qe.engine.execute(p, values[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// prepare parses a statement, and converts its values to the Go types of its
+// bind markers as they'd be once sent to Cassandra
+func (qe *InMemoryQueryExecutor) prepare(opts Options, stmt Statement) (*cqlPrepared, []interface{}, error) {
+	if opts.Context != nil {
+		if err := opts.Context.Err(); err != nil {
+			return nil, nil, err
+		}
+	}
+	p, err := qe.engine.prepare("", stmt.Query())
+	if err != nil {
+		return nil, nil, err
+	}
+	values := stmt.Values()
+	if len(values) != len(p.binds) {
+		return nil, nil, invalidRequest("There were %d markers(?) in CQL but %d bound variables", len(p.binds), len(values))
+	}
+	bound := make([]interface{}, len(values))
+	for i, v := range values {
+		if v == gocql.UnsetValue {
+			bound[i] = v
+			continue
+		}
+		data, err := p.binds[i].encode(v)
+		if err != nil {
+			return nil, nil, err
+		}
+		if data == nil {
+			continue
+		}
+		if bound[i], err = p.binds[i].decode(data); err != nil {
+			return nil, nil, err
+		}
+	}
+	return p, bound, nil
+}
+
+// cqlIterator scans the rows of a result as a gocql iterator does, decoding
+// the columns into whichever types they're scanned into
+type cqlIterator struct {
+	result *cqlResult
+	row    int
+	err    error
+}
+
+func (iter *cqlIterator) Next() bool {
+	if iter.err != nil || iter.row+1 >= len(iter.result.rows) {
+		return false
+	}
+	iter.row++
+	return true
+}
+
+func (iter *cqlIterator) Scan(dest ...interface{}) error {
+	if iter.row < 0 {
+		return fmt.Errorf("called Scan without calling Next")
+	}
+	columns := iter.result.columns
+	if len(dest) != len(columns) {
+		iter.err = fmt.Errorf("got %d pointers for unmarshalling %d columns", len(dest), len(columns))
+		return iter.err
+	}
+	for i, c := range columns {
+		if _, ok := dest[i].(*IgnoreFieldType); ok {
+			continue
+		}
+		data, err := c.encode(iter.result.rows[iter.row][i])
+		if err == nil {
+			err = gocql.Unmarshal(c.typ, data, dest[i])
+		}
+		if err != nil {
+			iter.err = fmt.Errorf("could not scan %s: %v", c.name, err)
+			return iter.err
+		}
+	}
+	return nil
+}
+
+// Columns returns the names and types of the columns of the rows, as
+// gocql.Iter does
+func (iter *cqlIterator) Columns() []gocql.ColumnInfo {
+	columns := make([]gocql.ColumnInfo, len(iter.result.columns))
+	for i, c := range iter.result.columns {
+		columns[i] = gocql.ColumnInfo{Name: c.name, TypeInfo: c.typ}
+	}
+	return columns
+}
+
+func (iter *cqlIterator) Err() error {
+	return iter.err
+}
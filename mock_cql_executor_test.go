@@ -0,0 +1,136 @@
+package gocassa
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInMemoryQueryExecutor(t *testing.T) {
+	qe := NewInMemoryQueryExecutor()
+	ks := NewConnection(qe).KeySpace("test")
+	tbl := ks.MapTable("customer", "Id", Customer{})
+
+	var c Customer
+	err := tbl.Read("1", &c).Run()
+	assert.Error(t, err)
+	assert.True(t, strings.Contains(err.Error(), "unconfigured table customer_map_id"))
+	assert.NoError(t, tbl.(TableChanger).CreateIfNotExist())
+
+	assert.NoError(t, tbl.Set(Customer{Id: "1", Name: "Joe"}).Run())
+	assert.NoError(t, tbl.Read("1", &c).Run())
+	assert.Equal(t, Customer{Id: "1", Name: "Joe"}, c)
+	assert.IsType(t, RowNotFoundError{}, tbl.Read("3", &c).Run())
+
+	assert.NoError(t, tbl.Set(Customer{Id: "2", Name: "Jane"}).Add(tbl.Update("1", map[string]interface{}{"Name": "Jim"})).RunAtomically())
+	var cs []Customer
+	assert.NoError(t, tbl.MultiRead([]interface{}{"1", "2"}, &cs).Run())
+	assert.ElementsMatch(t, []Customer{{Id: "1", Name: "Jim"}, {Id: "2", Name: "Jane"}}, cs)
+
+	var doc json.RawMessage
+	assert.NoError(t, tbl.ReadJSON("2", &doc).Run())
+	assert.JSONEq(t, `{"id": "2", "name": "Jane"}`, string(doc))
+
+	assert.NoError(t, tbl.Delete("1").Run())
+	assert.IsType(t, RowNotFoundError{}, tbl.Read("1", &c).Run())
+
+	tables, err := ks.Tables()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"customer_map_id"}, tables)
+
+	// The rows are held by a mock keyspace
+	var buf strings.Builder
+	assert.NoError(t, qe.KeySpace("test").(MockStore).Dump(&buf, JSONDump))
+	assert.JSONEq(t, `{"customer_map_id": [{"id": "2", "name": "Jane"}]}`, buf.String())
+}
+
+func TestInMemoryQueryExecutorEntityBatch(t *testing.T) {
+	type order struct {
+		Id    string
+		Owner string
+		Total int
+	}
+	ks := NewConnection(NewInMemoryQueryExecutor()).KeySpace("test")
+	customers := ks.MapTable("customer", "Id", Customer{})
+	orders := ks.EntityTable("orders", "Id", order{}, MultimapView("Owner"))
+	assert.NoError(t, customers.(TableChanger).CreateIfNotExist())
+	assert.NoError(t, orders.CreateIfNotExist())
+
+	o := order{Id: "o1", Owner: "joe", Total: 10}
+	assert.NoError(t, customers.Set(Customer{Id: "1", Name: "Joe"}).Add(orders.Set(o)).RunAtomically())
+	var read order
+	assert.NoError(t, orders.Read("o1", &read).Run())
+	assert.Equal(t, o, read)
+	var byOwner []order
+	assert.NoError(t, orders.Multimap("Owner").List("joe", nil, 0, &byOwner).Run())
+	assert.Equal(t, []order{o}, byOwner)
+
+	// The writes of an entity op are only built when it runs, moving the row
+	// in the view when its owner changes
+	o.Owner = "jim"
+	assert.Equal(t, noOpStatement{}, orders.Set(o).GenerateStatement())
+	assert.NoError(t, orders.Update("o1", map[string]interface{}{"Owner": "jim"}).
+		Add(customers.Delete("1")).RunAtomically())
+	assert.NoError(t, orders.Multimap("Owner").List("jim", nil, 0, &byOwner).Run())
+	assert.Equal(t, []order{o}, byOwner)
+	var c Customer
+	assert.IsType(t, RowNotFoundError{}, customers.Read("1", &c).Run())
+}
+
+func TestInMemoryQueryExecutorStatements(t *testing.T) {
+	qe := NewInMemoryQueryExecutor()
+	tbl := NewConnection(qe).KeySpace("test").Table("events", serverEvent{}, Keys{
+		PartitionKeys:     []string{"Account"},
+		ClusteringColumns: []string{"At"},
+	}).WithOptions(Options{ClusteringOrder: []ClusteringOrderColumn{{Column: "At", Direction: DESC}}})
+	assert.NoError(t, tbl.(TableChanger).Create())
+
+	start := time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		e := serverEvent{Account: "a", At: start.Add(time.Duration(i) * time.Hour), Kind: "login", Tags: []string{"b"}}
+		assert.NoError(t, tbl.Set(e).Run())
+	}
+	key := []Relation{Eq("Account", "a"), Eq("At", start)}
+	assert.NoError(t, tbl.Where(key...).Update(map[string]interface{}{
+		"Tags":  ListAppend("c"),
+		"Attrs": MapSetFields(map[string]interface{}{"ip": "::1"}),
+		"Count": CounterIncrement(2),
+	}).Run())
+
+	var e serverEvent
+	assert.NoError(t, tbl.Where(key...).ReadOne(&e).Run())
+	assert.Equal(t, []string{"b", "c"}, e.Tags)
+	assert.Equal(t, map[string]string{"ip": "::1"}, e.Attrs)
+	assert.Equal(t, Counter(2), e.Count)
+
+	var events []serverEvent
+	assert.NoError(t, tbl.Where(Eq("Account", "a")).Read(&events).WithOptions(Options{Limit: 2}).Run())
+	if assert.Len(t, events, 2) {
+		assert.True(t, events[0].At.Equal(start.Add(2*time.Hour)))
+	}
+
+	// Cassandra rejects queries restricting a clustering column only
+	err := tbl.Where(Eq("At", start)).Read(&events).Run()
+	assert.Error(t, err)
+	assert.True(t, strings.Contains(err.Error(), "ALLOW FILTERING"))
+
+	// Custom statements go through the same tables
+	assert.NoError(t, qe.Execute(cqlStatement{
+		query:  "UPDATE test.events__account__at SET kind = ? WHERE account = ? AND at = ?",
+		values: []interface{}{"logout", "a", start},
+	}))
+	assert.NoError(t, tbl.Where(key...).ReadOne(&e).Run())
+	assert.Equal(t, "logout", e.Kind)
+
+	// None of the statements of a batch are run if one of them is invalid
+	err = qe.ExecuteAtomically([]Statement{
+		cqlStatement{query: "DELETE FROM test.events__account__at WHERE account = ?", values: []interface{}{"a"}},
+		cqlStatement{query: "UPDATE test.missing SET kind = ? WHERE account = ?", values: []interface{}{"x", "a"}},
+	})
+	assert.Error(t, err)
+	assert.NoError(t, tbl.Where(Eq("Account", "a")).Read(&events).Run())
+	assert.Len(t, events, 3)
+}
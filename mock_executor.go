@@ -0,0 +1,419 @@
+package gocassa
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+// AnyValue matches any value bound to a statement, for values such as
+// timestamps which aren't known in advance
+var AnyValue interface{} = anyValue{}
+
+type anyValue struct{}
+
+func (anyValue) String() string {
+	return "<any>"
+}
+
+// TestingT is the part of testing.TB a MockQueryExecutor reports to
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+	Cleanup(func())
+}
+
+// ExpectedStatement describes a statement expected by a MockQueryExecutor
+type ExpectedStatement struct {
+	// Query is the CQL of the statement, or a regular expression matching it
+	// if Regexp is set
+	Query  string
+	Regexp bool
+	// Values are the values bound to the statement. They aren't checked if nil
+	Values []interface{}
+}
+
+func (s ExpectedStatement) String() string {
+	if s.Values == nil {
+		return fmt.Sprintf("%q", s.Query)
+	}
+	return fmt.Sprintf("%q with values %v", s.Query, s.Values)
+}
+
+// match returns why stmt doesn't match the expected statement, or nil if it
+// does
+func (s ExpectedStatement) match(stmt Statement) error {
+	query := stmt.Query()
+	if s.Regexp {
+		if !regexp.MustCompile(s.Query).MatchString(query) {
+			return fmt.Errorf("query %q doesn't match %q", query, s.Query)
+		}
+	} else if query != s.Query {
+		return fmt.Errorf("query %q isn't %q", query, s.Query)
+	}
+	if s.Values == nil {
+		return nil
+	}
+	values := stmt.Values()
+	if len(values) != len(s.Values) {
+		return fmt.Errorf("query %q has values %v, not %v", query, values, s.Values)
+	}
+	for i, v := range s.Values {
+		if !valuesEqual(v, values[i]) {
+			return fmt.Errorf("query %q has values %v, not %v", query, values, s.Values)
+		}
+	}
+	return nil
+}
+
+func valuesEqual(expected, actual interface{}) bool {
+	if expected == AnyValue {
+		return true
+	}
+	if et, ok := expected.(time.Time); ok {
+		at, ok := actual.(time.Time)
+		return ok && et.Equal(at)
+	}
+	return reflect.DeepEqual(expected, actual)
+}
+
+type expectationType int
+
+const (
+	queryExpectation expectationType = iota
+	executeExpectation
+	batchExpectation
+)
+
+func (t expectationType) String() string {
+	switch t {
+	case queryExpectation:
+		return "query"
+	case executeExpectation:
+		return "execute"
+	default:
+		return "batch"
+	}
+}
+
+// Expectation is a call expected by a MockQueryExecutor. Its methods set what
+// else the call should match and what it returns
+type Expectation struct {
+	typ         expectationType
+	statements  []ExpectedStatement
+	consistency *gocql.Consistency
+	rows        []map[string]interface{}
+	err         error
+	met         bool
+}
+
+// WithValues sets the values expected to be bound to the statement. The
+// values of statements in a batch are set by their ExpectedStatement instead
+func (e *Expectation) WithValues(values ...interface{}) *Expectation {
+	if e.typ == batchExpectation {
+		panic("WithValues: set the Values of the ExpectedStatements of a batch")
+	}
+	if values == nil {
+		values = []interface{}{}
+	}
+	e.statements[0].Values = values
+	return e
+}
+
+// WithConsistency sets the consistency the call is expected to be made at
+func (e *Expectation) WithConsistency(c gocql.Consistency) *Expectation {
+	e.consistency = &c
+	return e
+}
+
+// WillReturnRows sets the rows a query returns, which are scanned into the
+// result of the query as they would be if Cassandra returned them. Each row
+// is either a struct or a map of column names to values
+func (e *Expectation) WillReturnRows(rows ...interface{}) *Expectation {
+	for _, row := range rows {
+		m, ok := toMap(row)
+		if !ok {
+			panic(fmt.Sprintf("WillReturnRows: Incompatible type %T", row))
+		}
+		e.rows = append(e.rows, m)
+	}
+	return e
+}
+
+// WillReturnError sets the error the call returns
+func (e *Expectation) WillReturnError(err error) *Expectation {
+	e.err = err
+	return e
+}
+
+func (e *Expectation) String() string {
+	s := make([]string, len(e.statements))
+	for i, stmt := range e.statements {
+		s[i] = stmt.String()
+	}
+	str := fmt.Sprintf("%s %s", e.typ, strings.Join(s, ", "))
+	if e.consistency != nil {
+		str += fmt.Sprintf(" at %s", *e.consistency)
+	}
+	return str
+}
+
+var errWrongCall = errors.New("wrong call")
+
+// match returns why a call doesn't match the expectation, or nil if it does
+func (e *Expectation) match(typ expectationType, opts Options, stmts []Statement) error {
+	if typ != e.typ {
+		return errWrongCall
+	}
+	if len(stmts) != len(e.statements) {
+		return fmt.Errorf("batch has %d statements, not %d", len(stmts), len(e.statements))
+	}
+	for i, s := range e.statements {
+		if err := s.match(stmts[i]); err != nil {
+			return err
+		}
+	}
+	if e.consistency != nil {
+		if opts.Consistency == nil {
+			return fmt.Errorf("no consistency was set, expected %s", *e.consistency)
+		}
+		if *opts.Consistency != *e.consistency {
+			return fmt.Errorf("consistency %s isn't %s", *opts.Consistency, *e.consistency)
+		}
+	}
+	return nil
+}
+
+// MockQueryExecutor is a QueryExecutor which checks the statements it's given
+// against a list of expectations, for testing the CQL ops produce. Use it
+// with NewConnection:
+//
+//	qe := gocassa.NewMockQueryExecutor(t)
+//	ks := gocassa.NewConnection(qe).KeySpace("test")
+//	qe.ExpectQuery("SELECT id, name FROM test.sale WHERE id = ?").
+//		WithValues("sale-1").
+//		WillReturnRows(Sale{Id: "sale-1", Name: "Joe"})
+//
+// By default calls must be made in the order they're expected in
+type MockQueryExecutor struct {
+	mu           sync.Mutex
+	expectations []*Expectation
+	unexpected   []string
+	statements   []Statement
+	anyOrder     bool
+}
+
+// NewMockQueryExecutor returns a MockQueryExecutor. If t isn't nil, the test
+// fails once it's finished if any expectations weren't met or any calls
+// weren't expected
+func NewMockQueryExecutor(t TestingT) *MockQueryExecutor {
+	qe := &MockQueryExecutor{}
+	if t != nil {
+		t.Cleanup(func() {
+			t.Helper()
+			if err := qe.ExpectationsWereMet(); err != nil {
+				t.Errorf("%v", err)
+			}
+		})
+	}
+	return qe
+}
+
+// MatchInAnyOrder lets calls be made in any order, such as when ops run
+// queries concurrently
+func (qe *MockQueryExecutor) MatchInAnyOrder() {
+	qe.mu.Lock()
+	defer qe.mu.Unlock()
+	qe.anyOrder = true
+}
+
+func (qe *MockQueryExecutor) expect(typ expectationType, stmts ...ExpectedStatement) *Expectation {
+	e := &Expectation{typ: typ, statements: stmts}
+	qe.mu.Lock()
+	defer qe.mu.Unlock()
+	qe.expectations = append(qe.expectations, e)
+	return e
+}
+
+// ExpectQuery expects a query with exactly the given CQL
+func (qe *MockQueryExecutor) ExpectQuery(query string) *Expectation {
+	return qe.expect(queryExpectation, ExpectedStatement{Query: query})
+}
+
+// ExpectQueryRegexp expects a query with CQL matching the regular expression
+func (qe *MockQueryExecutor) ExpectQueryRegexp(pattern string) *Expectation {
+	regexp.MustCompile(pattern)
+	return qe.expect(queryExpectation, ExpectedStatement{Query: pattern, Regexp: true})
+}
+
+// ExpectExecute expects a statement with exactly the given CQL to be executed
+func (qe *MockQueryExecutor) ExpectExecute(query string) *Expectation {
+	return qe.expect(executeExpectation, ExpectedStatement{Query: query})
+}
+
+// ExpectExecuteRegexp expects a statement with CQL matching the regular
+// expression to be executed
+func (qe *MockQueryExecutor) ExpectExecuteRegexp(pattern string) *Expectation {
+	regexp.MustCompile(pattern)
+	return qe.expect(executeExpectation, ExpectedStatement{Query: pattern, Regexp: true})
+}
+
+// ExpectBatch expects the statements to be executed in a logged batch
+func (qe *MockQueryExecutor) ExpectBatch(stmts ...ExpectedStatement) *Expectation {
+	for _, s := range stmts {
+		if s.Regexp {
+			regexp.MustCompile(s.Query)
+		}
+	}
+	return qe.expect(batchExpectation, stmts...)
+}
+
+// Statements returns every statement the executor was given, in order
+func (qe *MockQueryExecutor) Statements() []Statement {
+	qe.mu.Lock()
+	defer qe.mu.Unlock()
+	return append([]Statement{}, qe.statements...)
+}
+
+// ExpectationsWereMet returns an error describing the expectations which
+// weren't met and the calls which weren't expected, if there were any
+func (qe *MockQueryExecutor) ExpectationsWereMet() error {
+	qe.mu.Lock()
+	defer qe.mu.Unlock()
+	var problems []string
+	for _, e := range qe.expectations {
+		if !e.met {
+			problems = append(problems, "expected "+e.String())
+		}
+	}
+	problems = append(problems, qe.unexpected...)
+	if len(problems) == 0 {
+		return nil
+	}
+	return errors.New("gocassa: " + strings.Join(problems, "; "))
+}
+
+// call finds the expectation matching a call, recording it as unexpected if
+// there isn't one
+func (qe *MockQueryExecutor) call(typ expectationType, opts Options, stmts []Statement) (*Expectation, error) {
+	qe.mu.Lock()
+	defer qe.mu.Unlock()
+	qe.statements = append(qe.statements, stmts...)
+
+	var (
+		mismatch error
+		expected *Expectation
+	)
+	for _, e := range qe.expectations {
+		if e.met {
+			continue
+		}
+		err := e.match(typ, opts, stmts)
+		if err == nil {
+			e.met = true
+			return e, nil
+		}
+		if mismatch == nil {
+			mismatch = err
+			expected = e
+		}
+		if !qe.anyOrder {
+			break
+		}
+	}
+
+	queries := make([]string, len(stmts))
+	for i, stmt := range stmts {
+		queries[i] = fmt.Sprintf("%q with values %v", stmt.Query(), stmt.Values())
+	}
+	msg := fmt.Sprintf("unexpected %s %s", typ, strings.Join(queries, ", "))
+	if expected != nil && !qe.anyOrder {
+		if mismatch != errWrongCall {
+			msg = fmt.Sprintf("unexpected %s: %v", typ, mismatch)
+		}
+		msg += fmt.Sprintf(", expected %s", expected)
+	}
+	qe.unexpected = append(qe.unexpected, msg)
+	return nil, errors.New("gocassa: " + msg)
+}
+
+func (qe *MockQueryExecutor) QueryWithOptions(opts Options, stmt Statement, scanner Scanner) error {
+	e, err := qe.call(queryExpectation, opts, []Statement{stmt})
+	if err != nil {
+		return err
+	}
+	if e.err != nil {
+		return e.err
+	}
+
+	rows := e.rows
+	var fields []string
+	if sel, ok := stmt.(SelectStatement); ok {
+		fields = sel.Fields()
+		if sel.JSON() {
+			if rows, err = rowsToJSON(rows, fields); err != nil {
+				return err
+			}
+			fields = []string{JSONColumnName}
+		}
+	} else {
+		fields = rowFields(rows)
+	}
+	iter := newMockIterator(rows, fields)
+	if _, err := scanner.ScanIter(iter); err != nil {
+		return err
+	}
+	return iter.Err()
+}
+
+func (qe *MockQueryExecutor) Query(stmt Statement, scanner Scanner) error {
+	return qe.QueryWithOptions(Options{}, stmt, scanner)
+}
+
+func (qe *MockQueryExecutor) ExecuteWithOptions(opts Options, stmt Statement) error {
+	e, err := qe.call(executeExpectation, opts, []Statement{stmt})
+	if err != nil {
+		return err
+	}
+	return e.err
+}
+
+func (qe *MockQueryExecutor) Execute(stmt Statement) error {
+	return qe.ExecuteWithOptions(Options{}, stmt)
+}
+
+func (qe *MockQueryExecutor) ExecuteAtomically(stmts []Statement) error {
+	return qe.ExecuteAtomicallyWithOptions(Options{}, stmts)
+}
+
+func (qe *MockQueryExecutor) ExecuteAtomicallyWithOptions(opts Options, stmts []Statement) error {
+	e, err := qe.call(batchExpectation, opts, stmts)
+	if err != nil {
+		return err
+	}
+	return e.err
+}
+
+// rowFields returns the columns of rows returned for a statement which isn't
+// a SelectStatement, in sorted order
+func rowFields(rows []map[string]interface{}) []string {
+	seen := map[string]bool{}
+	fields := []string{}
+	for _, row := range rows {
+		for k := range row {
+			if !seen[k] {
+				seen[k] = true
+				fields = append(fields, k)
+			}
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
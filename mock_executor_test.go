@@ -0,0 +1,78 @@
+package gocassa
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/gocql/gocql"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMockQueryExecutor(t *testing.T) {
+	qe := NewMockQueryExecutor(t)
+	ks := NewConnection(qe).KeySpace("test")
+	tbl := ks.MapTable("customer", "Id", Customer{})
+
+	qe.ExpectQuery("SELECT id, name FROM test.customer_map_Id WHERE id = ?").
+		WithValues("100").
+		WithConsistency(gocql.Quorum).
+		WillReturnRows(Customer{Id: "100", Name: "Joe"})
+	qe.ExpectExecuteRegexp(`^UPDATE test\.customer_map_Id SET Name = \? WHERE id = \?$`).
+		WithValues("Jim", "100")
+	qe.ExpectBatch(
+		ExpectedStatement{Query: "DELETE FROM test.customer_map_Id WHERE id = ?", Values: []interface{}{"100"}},
+		ExpectedStatement{Query: "DELETE FROM test.customer_map_Id WHERE id = ?", Values: []interface{}{AnyValue}},
+	)
+	qe.ExpectQuery("SELECT id, name FROM test.customer_map_Id WHERE id = ?").
+		WillReturnError(errors.New("timed out"))
+
+	var c Customer
+	consistency := gocql.Quorum
+	err := tbl.Read("100", &c).WithOptions(Options{Consistency: &consistency}).Run()
+	assert.NoError(t, err)
+	assert.Equal(t, Customer{Id: "100", Name: "Joe"}, c)
+	assert.NoError(t, tbl.Update("100", map[string]interface{}{"Name": "Jim"}).Run())
+	assert.NoError(t, tbl.Delete("100").Add(tbl.Delete("101")).RunAtomically())
+	assert.EqualError(t, tbl.Read("100", &c).Run(), "timed out")
+
+	assert.NoError(t, qe.ExpectationsWereMet())
+	assert.Len(t, qe.Statements(), 5)
+}
+
+func TestMockQueryExecutorUnmet(t *testing.T) {
+	qe := NewMockQueryExecutor(nil)
+	ks := NewConnection(qe).KeySpace("test")
+	tbl := ks.MapTable("customer", "Id", Customer{})
+
+	qe.ExpectQuery("SELECT id, name FROM test.customer_map_Id WHERE id = ?").WithValues("100")
+	qe.ExpectExecute("DELETE FROM test.customer_map_Id WHERE id = ?")
+
+	var c Customer
+	err := tbl.Read("101", &c).Run()
+	assert.Error(t, err)
+	assert.True(t, strings.Contains(err.Error(), "unexpected query"))
+
+	err = qe.ExpectationsWereMet()
+	assert.Error(t, err)
+	assert.True(t, strings.Contains(err.Error(), `expected execute "DELETE FROM test.customer_map_Id WHERE id = ?"`))
+	assert.True(t, strings.Contains(err.Error(), "[101], not [100]"))
+}
+
+func TestMockQueryExecutorAnyOrder(t *testing.T) {
+	qe := NewMockQueryExecutor(t)
+	qe.MatchInAnyOrder()
+	ks := NewConnection(qe).KeySpace("test")
+	tbl := ks.MapTable("customer", "Id", Customer{})
+
+	qe.ExpectQuery("SELECT id, name FROM test.customer_map_Id WHERE id = ?").
+		WithValues("101").
+		WillReturnRows(map[string]interface{}{"id": "101", "name": "Jim"})
+	qe.ExpectQuery("SELECT id, name FROM test.customer_map_Id WHERE id = ?").
+		WithValues("100")
+
+	var c Customer
+	assert.Equal(t, RowNotFoundError{}, tbl.Read("100", &c).Run())
+	assert.NoError(t, tbl.Read("101", &c).Run())
+	assert.Equal(t, "Jim", c.Name)
+}
@@ -0,0 +1,361 @@
+package gocassa
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+// MockStatementType is the kind of statement an op of a mock table runs
+type MockStatementType int
+
+const (
+	MockSelect MockStatementType = iota // rows are read
+	MockInsert                          // a row is set, by Set or SetJSON
+	MockUpdate                          // fields of rows are updated
+	MockDelete                          // rows, or columns of rows, are deleted
+)
+
+func (s MockStatementType) String() string {
+	switch s {
+	case MockSelect:
+		return "select"
+	case MockInsert:
+		return "insert"
+	case MockUpdate:
+		return "update"
+	case MockDelete:
+		return "delete"
+	}
+	return fmt.Sprintf("MockStatementType(%d)", int(s))
+}
+
+// MockStatement describes an op of a mock table which faults may be injected
+// into
+type MockStatement struct {
+	Keyspace string
+	Table    string
+	Type     MockStatementType
+	// Keys holds the values of the primary key fields of the rows, as given
+	// by the row set or by the Eq relations of the op
+	Keys map[string]interface{}
+	// Relations are the relations reads, updates and deletes are restricted by
+	Relations []Relation
+	// BatchIndex is the index of the op in the ops run together with it, of
+	// which there are BatchSize. Ops run on their own have a BatchSize of 1
+	BatchIndex int
+	BatchSize  int
+	// Options are the options the op runs with
+	Options Options
+}
+
+// FaultMatcher selects the statements a Fault is injected into
+type FaultMatcher func(stmt MockStatement) bool
+
+// OnTable matches the statements on any of the tables
+func OnTable(tables ...string) FaultMatcher {
+	return func(stmt MockStatement) bool {
+		for _, t := range tables {
+			if strings.EqualFold(t, stmt.Table) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// OnStatementType matches the statements of any of the types
+func OnStatementType(types ...MockStatementType) FaultMatcher {
+	return func(stmt MockStatement) bool {
+		for _, t := range types {
+			if t == stmt.Type {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// OnWrite matches inserts, updates and deletes
+func OnWrite() FaultMatcher {
+	return OnStatementType(MockInsert, MockUpdate, MockDelete)
+}
+
+// OnKey matches the statements whose value of the key field, formatted with
+// %v, matches the regular expression pattern. It panics if pattern doesn't
+// compile
+func OnKey(field, pattern string) FaultMatcher {
+	re := regexp.MustCompile(pattern)
+	return func(stmt MockStatement) bool {
+		for k, v := range stmt.Keys {
+			if strings.EqualFold(k, field) {
+				return re.MatchString(fmt.Sprint(v))
+			}
+		}
+		return false
+	}
+}
+
+// OnBatchIndex matches the nth op of the ops run together, 0 indexed. A
+// negative n counts from the last op, -1 being the last one
+func OnBatchIndex(n int) FaultMatcher {
+	return func(stmt MockStatement) bool {
+		if n < 0 {
+			return stmt.BatchIndex == stmt.BatchSize+n
+		}
+		return stmt.BatchIndex == n
+	}
+}
+
+// AllOf matches the statements matched by all of the matchers
+func AllOf(matchers ...FaultMatcher) FaultMatcher {
+	return func(stmt MockStatement) bool {
+		for _, m := range matchers {
+			if !m(stmt) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Latency returns how long a statement is delayed by
+type Latency func(r *rand.Rand) time.Duration
+
+// FixedLatency delays statements by d
+func FixedLatency(d time.Duration) Latency {
+	return func(*rand.Rand) time.Duration {
+		return d
+	}
+}
+
+// UniformLatency delays statements by a duration picked uniformly between
+// min and max
+func UniformLatency(min, max time.Duration) Latency {
+	return func(r *rand.Rand) time.Duration {
+		if max <= min {
+			return min
+		}
+		return min + time.Duration(r.Int63n(int64(max-min)))
+	}
+}
+
+// NormalLatency delays statements by a normally distributed duration, which
+// is never negative
+func NormalLatency(mean, stddev time.Duration) Latency {
+	return func(r *rand.Rand) time.Duration {
+		d := time.Duration(r.NormFloat64()*float64(stddev)) + mean
+		if d < 0 {
+			return 0
+		}
+		return d
+	}
+}
+
+// Fault is a delay or a failure injected into the statements of a mock
+// keyspace
+type Fault struct {
+	// Match selects the statements the fault is injected into. All of them
+	// are if it's nil
+	Match FaultMatcher
+	// Probability is the chance of the fault being injected into a matching
+	// statement, between 0 and 1. It's always injected if Probability is 0
+	Probability float64
+	// Latency delays the statement. The statement fails with the error of its
+	// context if the context is done before the delay is over
+	Latency Latency
+	// Err is returned instead of running the statement
+	Err error
+	// Timeout makes the statement fail as it does when Cassandra times out
+	// waiting for the replicas, with an error which unwraps to a
+	// *gocql.RequestErrReadTimeout or *gocql.RequestErrWriteTimeout
+	Timeout bool
+	// Applied runs the statement before returning Err, or timing out, as
+	// writes may be applied even though they failed
+	Applied bool
+}
+
+// FaultInjector injects faults into the statements of the mock keyspaces it's
+// given to with MockFaults. Faults can be added and removed while it's in use
+type FaultInjector struct {
+	mu       sync.Mutex
+	rand     *rand.Rand
+	faults   []Fault
+	injected int
+}
+
+// NewFaultInjector returns a FaultInjector injecting faults, using seed to
+// decide which statements probabilistic faults are injected into and how long
+// statements are delayed by
+func NewFaultInjector(seed int64, faults ...Fault) *FaultInjector {
+	return &FaultInjector{
+		rand:   rand.New(rand.NewSource(seed)),
+		faults: faults,
+	}
+}
+
+// MockFaults makes a mock keyspace inject the faults of injector into the ops
+// of its tables
+func MockFaults(injector *FaultInjector) MockOption {
+	return func(ks *mockKeySpace) {
+		ks.faults = injector
+	}
+}
+
+// Add adds faults to the faults injected
+func (f *FaultInjector) Add(faults ...Fault) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.faults = append(f.faults, faults...)
+}
+
+// Clear removes all the faults
+func (f *FaultInjector) Clear() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.faults = nil
+}
+
+// Injected returns the number of statements faults were injected into
+func (f *FaultInjector) Injected() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.injected
+}
+
+// faultsFor returns the latency of the faults injected into stmt, and the
+// fault failing it if there's one
+func (f *FaultInjector) faultsFor(stmt MockStatement) (time.Duration, *Fault) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var (
+		latency time.Duration
+		failure *Fault
+		matched bool
+	)
+	for _, fault := range f.faults {
+		if fault.Match != nil && !fault.Match(stmt) {
+			continue
+		}
+		if fault.Probability > 0 && f.rand.Float64() >= fault.Probability {
+			continue
+		}
+		matched = true
+		if fault.Latency != nil {
+			latency += fault.Latency(f.rand)
+		}
+		if failure == nil && (fault.Err != nil || fault.Timeout) {
+			fault := fault
+			failure = &fault
+		}
+	}
+	if matched {
+		f.injected++
+	}
+	return latency, failure
+}
+
+// inject delays the statement, then returns whether it should run and the
+// error it fails with
+func (f *FaultInjector) inject(stmt MockStatement) (bool, error) {
+	latency, fault := f.faultsFor(stmt)
+	if latency > 0 {
+		if err := sleepContext(stmt.Options.Context, latency); err != nil {
+			return false, err
+		}
+	}
+	switch {
+	case fault == nil:
+		return true, nil
+	case fault.Timeout:
+		return fault.Applied, newTimeoutError(stmt)
+	}
+	return fault.Applied, fault.Err
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if ctx == nil {
+		time.Sleep(d)
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// requestError is the error of a statement which timed out or was rejected.
+// It unwraps to the error gocql returns for it, which can't be constructed
+// with a message outside of gocql
+type requestError struct {
+	message string
+	err     error
+}
+
+func (e requestError) Error() string {
+	return e.message
+}
+
+func (e requestError) Unwrap() error {
+	return e.err
+}
+
+func newTimeoutError(stmt MockStatement) error {
+	consistency := gocql.One
+	if stmt.Options.Consistency != nil {
+		consistency = *stmt.Options.Consistency
+	}
+	if stmt.Type == MockSelect {
+		return requestError{
+			message: fmt.Sprintf("Operation timed out - received only 0 responses (consistency %v)", consistency),
+			err:     &gocql.RequestErrReadTimeout{Consistency: consistency, BlockFor: 1},
+		}
+	}
+	writeType := "SIMPLE"
+	if stmt.BatchSize > 1 {
+		writeType = "BATCH"
+	}
+	return requestError{
+		message: fmt.Sprintf("Operation timed out - received only 0 responses (consistency %v, write type %s)", consistency, writeType),
+		err:     &gocql.RequestErrWriteTimeout{Consistency: consistency, BlockFor: 1, WriteType: writeType},
+	}
+}
+
+// mockTarget describes what an op of a mock table runs, for injecting faults
+// into it
+type mockTarget struct {
+	table     *MockTable
+	typ       MockStatementType
+	relations []Relation
+	// keys returns the values of the primary key of the rows written by sets
+	keys func() map[string]interface{}
+}
+
+func (t *mockTarget) statement(opts Options, idx, count int) MockStatement {
+	stmt := MockStatement{
+		Keyspace:   t.table.ksName,
+		Table:      t.table.Name(),
+		Type:       t.typ,
+		Relations:  t.relations,
+		BatchIndex: idx,
+		BatchSize:  count,
+		Options:    t.table.options.Merge(opts),
+	}
+	if t.keys != nil {
+		stmt.Keys = t.keys()
+	} else {
+		stmt.Keys = keysFromRelations(t.table.keys, t.relations)
+	}
+	return stmt
+}
@@ -0,0 +1,116 @@
+package gocassa
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMockFaults(t *testing.T) {
+	errInjected := errors.New("injected")
+	faults := NewFaultInjector(1)
+	ks := NewMockKeySpace(MockFaults(faults))
+	customers := ks.MapTable("customer", "Id", Customer{})
+	other := ks.MapTable("other", "Id", Customer{})
+
+	t.Run("Table and statement type", func(t *testing.T) {
+		faults.Add(Fault{Match: AllOf(OnTable(customers.Name()), OnWrite()), Err: errInjected})
+		defer faults.Clear()
+
+		assert.Equal(t, errInjected, customers.Set(Customer{Id: "1"}).Run())
+		assert.Equal(t, errInjected, customers.WithOptions(Options{TTL: time.Hour}).Set(Customer{Id: "1"}).Run())
+		assert.NoError(t, other.Set(Customer{Id: "1"}).Run())
+		var c Customer
+		assert.IsType(t, RowNotFoundError{}, customers.Read("1", &c).Run())
+		assert.Equal(t, 2, faults.Injected())
+	})
+
+	t.Run("Key pattern", func(t *testing.T) {
+		faults.Add(Fault{Match: OnKey("Id", "^bad-"), Err: errInjected})
+		defer faults.Clear()
+
+		assert.NoError(t, customers.Set(Customer{Id: "good-1"}).Run())
+		assert.Equal(t, errInjected, customers.Set(Customer{Id: "bad-1"}).Run())
+		var c Customer
+		assert.Equal(t, errInjected, customers.Read("bad-1", &c).Run())
+		assert.NoError(t, customers.Read("good-1", &c).Run())
+	})
+
+	t.Run("Timeouts", func(t *testing.T) {
+		faults.Add(Fault{Match: OnWrite(), Timeout: true, Applied: true})
+		faults.Add(Fault{Match: OnStatementType(MockSelect), Timeout: true})
+		defer faults.Clear()
+
+		err := customers.Set(Customer{Id: "2", Name: "Jane"}).Run()
+		var writeTimeout *gocql.RequestErrWriteTimeout
+		if assert.True(t, errors.As(err, &writeTimeout)) {
+			assert.Equal(t, "SIMPLE", writeTimeout.WriteType)
+		}
+		var c Customer
+		err = customers.Read("2", &c).Run()
+		var readTimeout *gocql.RequestErrReadTimeout
+		assert.True(t, errors.As(err, &readTimeout))
+
+		// The write was applied although it timed out
+		faults.Clear()
+		assert.NoError(t, customers.Read("2", &c).Run())
+		assert.Equal(t, "Jane", c.Name)
+	})
+
+	t.Run("Partial batch", func(t *testing.T) {
+		faults.Add(Fault{Match: OnBatchIndex(1), Err: errInjected})
+		defer faults.Clear()
+
+		err := customers.Set(Customer{Id: "3"}).Add(customers.Set(Customer{Id: "4"}), customers.Set(Customer{Id: "5"})).RunAtomically()
+		assert.Equal(t, errInjected, err)
+		var cs []Customer
+		assert.NoError(t, customers.MultiRead([]interface{}{"3", "4", "5"}, &cs).Run())
+		assert.Equal(t, []Customer{{Id: "3"}}, cs)
+	})
+
+	t.Run("Probability", func(t *testing.T) {
+		faults.Add(Fault{Probability: 0.5, Err: errInjected})
+		defer faults.Clear()
+
+		before, failed := faults.Injected(), 0
+		var c Customer
+		for i := 0; i < 100; i++ {
+			if err := customers.Read("3", &c).Run(); err != nil {
+				failed++
+			}
+		}
+		assert.True(t, failed > 0 && failed < 100, "%d reads failed", failed)
+		assert.Equal(t, failed, faults.Injected()-before)
+	})
+
+	t.Run("Latency", func(t *testing.T) {
+		faults.Add(Fault{Latency: UniformLatency(10*time.Millisecond, 20*time.Millisecond)})
+		defer faults.Clear()
+
+		start := time.Now()
+		assert.NoError(t, customers.Set(Customer{Id: "6"}).Run())
+		assert.True(t, time.Since(start) >= 10*time.Millisecond)
+
+		faults.Add(Fault{Latency: FixedLatency(time.Minute)})
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		assert.Equal(t, context.DeadlineExceeded, customers.Set(Customer{Id: "7"}).RunWithContext(ctx))
+	})
+}
+
+type failTable string
+
+func (f failTable) ShouldReturnErr(op Op, _, _ int) error {
+	return fmt.Errorf("%s failed", f)
+}
+
+func TestCustomErrorInjector(t *testing.T) {
+	tbl := NewMockKeySpace().MapTable("customer", "Id", Customer{})
+	op := tbl.Set(Customer{Id: "1"}).Add(tbl.Set(Customer{Id: "2"}))
+	assert.EqualError(t, op.RunWithContext(ErrorInjectorContext(context.Background(), failTable("customer"))), "customer failed")
+}
@@ -0,0 +1,337 @@
+package gocassa
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/google/btree"
+)
+
+// MockReplication configures the replicas simulated by a mock keyspace given
+// MockReplicas. Each write is acknowledged by as many replicas as its
+// consistency level requires, which see it straight away, while the others
+// receive it after a lag or not at all. Reads ask as many replicas as their
+// consistency level requires and return the latest version of each partition
+// among them, so reads at ONE may be stale while writes and reads at QUORUM
+// see each other.
+//
+// Replicas receive whole partitions, as they are after each write, so a
+// replica which missed a write catches up on the next write it receives to
+// the same partition.
+type MockReplication struct {
+	// Replicas is the number of replicas of every partition. It defaults to 3
+	Replicas int
+	// Lag is how long the replicas which didn't acknowledge a write take to
+	// receive it. They receive it straight away if it's nil
+	Lag Latency
+	// Drop is the probability of a replica which didn't acknowledge a write
+	// never receiving it, between 0 and 1
+	Drop float64
+	// BatchDelay is how long the writes of a logged batch take to reach any
+	// replica, as they're written to the batch log first. They then reach all
+	// the replicas receiving them at once
+	BatchDelay Latency
+	// Consistency is the consistency level of the ops which don't set one with
+	// Options.Consistency. The zero value, ANY, is treated as ONE
+	Consistency gocql.Consistency
+	// Seed seeds the choice of replicas, and the lags and drops
+	Seed int64
+}
+
+// MockReplicas makes a mock keyspace simulate replicas which only become
+// consistent eventually, as configured by r
+func MockReplicas(r MockReplication) MockOption {
+	return func(ks *mockKeySpace) {
+		if r.Replicas <= 0 {
+			r.Replicas = 3
+		}
+		ks.replication = &mockReplication{
+			MockReplication: r,
+			rand:            rand.New(rand.NewSource(r.Seed)),
+		}
+	}
+}
+
+// mockReplication picks the replicas of the ops of a mock keyspace
+type mockReplication struct {
+	MockReplication
+	mu   sync.Mutex
+	rand *rand.Rand
+	// version orders the writes to the tables of the keyspace
+	version uint64
+}
+
+// mockDelivery is when a write reaches each replica
+type mockDelivery struct {
+	version uint64
+	at      []time.Time
+	dropped []bool
+}
+
+// required returns the number of replicas an op at the consistency level
+// waits for
+func (r *mockReplication) required(consistency *gocql.Consistency) (int, error) {
+	c := r.Consistency
+	if consistency != nil {
+		c = *consistency
+	}
+	n := r.Replicas
+	required := 1
+	switch c {
+	case gocql.Two:
+		required = 2
+	case gocql.Three:
+		required = 3
+	case gocql.Quorum, gocql.LocalQuorum, gocql.EachQuorum:
+		required = n/2 + 1
+	case gocql.All:
+		required = n
+	}
+	if required > n {
+		return 0, requestError{
+			message: fmt.Sprintf("Cannot achieve consistency level %v", c),
+			err:     &gocql.RequestErrUnavailable{Consistency: c, Required: required, Alive: n},
+		}
+	}
+	return required, nil
+}
+
+// deliver returns when a write made at now reaches each replica
+func (r *mockReplication) deliver(now time.Time, consistency *gocql.Consistency, batch bool) (mockDelivery, error) {
+	required, err := r.required(consistency)
+	if err != nil {
+		return mockDelivery{}, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.version++
+	d := mockDelivery{
+		version: r.version,
+		at:      make([]time.Time, r.Replicas),
+		dropped: make([]bool, r.Replicas),
+	}
+	if batch && r.BatchDelay != nil {
+		now = now.Add(r.BatchDelay(r.rand))
+	}
+	for i, replica := range r.rand.Perm(r.Replicas) {
+		d.at[replica] = now
+		if i < required {
+			continue
+		}
+		if r.Lag != nil {
+			d.at[replica] = now.Add(r.Lag(r.rand))
+		}
+		d.dropped[replica] = r.Drop > 0 && r.rand.Float64() < r.Drop
+	}
+	return d, nil
+}
+
+// contact returns the replicas a read at the consistency level asks
+func (r *mockReplication) contact(consistency *gocql.Consistency) ([]int, error) {
+	required, err := r.required(consistency)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rand.Perm(r.Replicas)[:required], nil
+}
+
+// mockBatch holds when the writes of a logged batch reach the replicas, so
+// that they all become visible at once
+type mockBatch struct {
+	deliveries map[*mockReplication]mockDelivery
+}
+
+func newMockBatch() *mockBatch {
+	return &mockBatch{deliveries: map[*mockReplication]mockDelivery{}}
+}
+
+func (b *mockBatch) deliver(r *mockReplication, now time.Time, consistency *gocql.Consistency) (mockDelivery, error) {
+	if d, ok := b.deliveries[r]; ok {
+		return d, nil
+	}
+	d, err := r.deliver(now, consistency, true)
+	if err != nil {
+		return mockDelivery{}, err
+	}
+	b.deliveries[r] = d
+	return d, nil
+}
+
+// tableReplicas holds the rows of the replicas of a mock table, and the
+// writes on their way to them. It's guarded by the lock of the table
+type tableReplicas struct {
+	replication *mockReplication
+	// writing serializes writes to the table, so that each write is sent to
+	// the replicas with the partitions it changed
+	writing  sync.Mutex
+	touched  map[rowKey]bool
+	replicas []replica
+	pending  []replicatedWrite
+}
+
+// replica is the rows of a table on one of its replicas, along with the
+// version of the write each partition was last received from
+type replica struct {
+	rows     map[rowKey]*btree.BTree
+	versions map[rowKey]uint64
+}
+
+// replicatedWrite is the partitions changed by a write, as they were after
+// it, on their way to the replicas
+type replicatedWrite struct {
+	delivery   mockDelivery
+	partitions map[rowKey]*btree.BTree
+	// received holds the replicas which received the write, or dropped it
+	received []bool
+}
+
+func newTableReplicas(r *mockReplication) *tableReplicas {
+	tr := &tableReplicas{replication: r, touched: map[rowKey]bool{}}
+	for i := 0; i < r.Replicas; i++ {
+		tr.replicas = append(tr.replicas, replica{rows: map[rowKey]*btree.BTree{}, versions: map[rowKey]uint64{}})
+	}
+	return tr
+}
+
+// touch records a partition changed by the write being made
+func (t *MockTable) touch(k rowKey) {
+	if t.replicas != nil {
+		t.replicas.touched[k] = true
+	}
+}
+
+// replicate sends the partitions changed by the last write to the replicas
+func (t *MockTable) replicate(m mockOp) error {
+	t.Lock()
+	defer t.Unlock()
+	tr := t.replicas
+	if len(tr.touched) == 0 {
+		return nil
+	}
+
+	opts := t.options.Merge(m.options)
+	var (
+		d   mockDelivery
+		err error
+	)
+	if m.batch != nil {
+		d, err = m.batch.deliver(tr.replication, t.now(), opts.Consistency)
+	} else {
+		d, err = tr.replication.deliver(t.now(), opts.Consistency, false)
+	}
+	if err != nil {
+		return err
+	}
+
+	t.mtx.RLock()
+	changed := make(map[rowKey]*btree.BTree, len(tr.touched))
+	for k := range tr.touched {
+		if row := t.rows[k]; row != nil {
+			changed[k] = row
+		}
+	}
+	t.mtx.RUnlock()
+	w := replicatedWrite{delivery: d, partitions: copyMockRows(changed), received: make([]bool, len(tr.replicas))}
+	for k := range tr.touched {
+		if _, ok := w.partitions[k]; !ok {
+			w.partitions[k] = nil
+		}
+	}
+	tr.touched = map[rowKey]bool{}
+	tr.pending = append(tr.pending, w)
+	tr.receive(t.now())
+	return nil
+}
+
+// receive delivers the writes which have reached the replicas by now
+func (tr *tableReplicas) receive(now time.Time) {
+	pending := tr.pending[:0]
+	for _, w := range tr.pending {
+		done := true
+		for i := range tr.replicas {
+			switch {
+			case w.received[i]:
+			case w.delivery.dropped[i]:
+				w.received[i] = true
+			case !w.delivery.at[i].After(now):
+				tr.replicas[i].apply(w)
+				w.received[i] = true
+			default:
+				done = false
+			}
+		}
+		if !done {
+			pending = append(pending, w)
+		}
+	}
+	tr.pending = pending
+}
+
+// apply replaces the partitions of the replica with those of the write,
+// unless it already has a later version of them
+func (r replica) apply(w replicatedWrite) {
+	for k, row := range w.partitions {
+		if r.versions[k] > w.delivery.version {
+			continue
+		}
+		r.versions[k] = w.delivery.version
+		if row == nil {
+			delete(r.rows, k)
+		} else {
+			r.rows[k] = row
+		}
+	}
+}
+
+// resetReplicas makes every replica hold the rows of the table, for changes
+// which aren't replicated, such as restoring a snapshot. The table has to be
+// locked
+func (t *MockTable) resetReplicas() {
+	tr := t.replicas
+	if tr == nil {
+		return
+	}
+	rows := copyMockRows(t.rows)
+	for i := range tr.replicas {
+		r := replica{rows: make(map[rowKey]*btree.BTree, len(rows)), versions: map[rowKey]uint64{}}
+		for k, row := range rows {
+			r.rows[k] = row
+		}
+		tr.replicas[i] = r
+	}
+	tr.touched = map[rowKey]bool{}
+	tr.pending = nil
+}
+
+// replicaFilter returns a filter reading the rows of the replicas a read at
+// the consistency level asks, taking the latest version of each partition
+// among them. The table has to be locked
+func (q *MockFilter) replicaFilter(consistency *gocql.Consistency) (*MockFilter, error) {
+	tr := q.table.replicas
+	contacted, err := tr.replication.contact(consistency)
+	if err != nil {
+		return nil, err
+	}
+	tr.receive(q.table.now())
+
+	rows := map[rowKey]*btree.BTree{}
+	versions := map[rowKey]uint64{}
+	for _, i := range contacted {
+		r := tr.replicas[i]
+		for k, row := range r.rows {
+			if v, ok := versions[k]; !ok || r.versions[k] > v {
+				rows[k], versions[k] = row, r.versions[k]
+			}
+		}
+	}
+
+	table := *q.table
+	table.rows, table.mtx = rows, &sync.RWMutex{}
+	return &MockFilter{table: &table, relations: q.relations}, nil
+}
@@ -0,0 +1,113 @@
+package gocassa
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMockReplicas(t *testing.T) {
+	one, quorum, all := gocql.One, gocql.Quorum, gocql.All
+	clock := NewManualClock(time.Now())
+	ks := NewMockKeySpace(MockClock(clock), MockReplicas(MockReplication{
+		Lag:        FixedLatency(time.Second),
+		BatchDelay: FixedLatency(time.Second),
+		Seed:       1,
+	}))
+	customers := ks.MapTable("customer", "Id", Customer{})
+
+	// names returns the names read from every replica a read at the consistency
+	// level may ask
+	names := func(id string, consistency *gocql.Consistency) map[string]bool {
+		t.Helper()
+		seen := map[string]bool{}
+		for i := 0; i < 50; i++ {
+			var c Customer
+			err := customers.Read(id, &c).WithOptions(Options{Consistency: consistency}).Run()
+			if _, ok := err.(RowNotFoundError); !ok && err != nil {
+				t.Fatal(err)
+			}
+			seen[c.Name] = true
+		}
+		return seen
+	}
+
+	t.Run("Lag", func(t *testing.T) {
+		assert.NoError(t, customers.Set(Customer{Id: "1", Name: "Joe"}).WithOptions(Options{Consistency: &one}).Run())
+		assert.Equal(t, map[string]bool{"": true, "Joe": true}, names("1", &one))
+		assert.Equal(t, map[string]bool{"Joe": true}, names("1", &all))
+
+		clock.Add(time.Second)
+		assert.Equal(t, map[string]bool{"Joe": true}, names("1", &one))
+	})
+
+	t.Run("Quorum", func(t *testing.T) {
+		assert.NoError(t, customers.Set(Customer{Id: "2", Name: "Jane"}).WithOptions(Options{Consistency: &quorum}).Run())
+		assert.Equal(t, map[string]bool{"Jane": true}, names("2", &quorum))
+		assert.Len(t, names("2", &one), 2)
+
+		// The consistency level of the table is kept when merged with the
+		// options of the op
+		quorumCustomers := customers.WithOptions(Options{Consistency: &quorum})
+		assert.NoError(t, quorumCustomers.Set(Customer{Id: "3", Name: "Jim"}).WithOptions(Options{TTL: time.Hour}).Run())
+		assert.Equal(t, map[string]bool{"Jim": true}, names("3", &quorum))
+	})
+
+	t.Run("Drops", func(t *testing.T) {
+		ks := NewMockKeySpace(MockClock(clock), MockReplicas(MockReplication{Drop: 1}))
+		customers := ks.MapTable("customer", "Id", Customer{})
+		assert.NoError(t, customers.Set(Customer{Id: "1", Name: "Joe"}).Run())
+		clock.Add(time.Hour)
+
+		var c Customer
+		assert.NoError(t, customers.Read("1", &c).WithOptions(Options{Consistency: &all}).Run())
+		assert.Equal(t, "Joe", c.Name)
+		missed := 0
+		for i := 0; i < 50; i++ {
+			if _, ok := customers.Read("1", &c).Run().(RowNotFoundError); ok {
+				missed++
+			}
+		}
+		assert.NotZero(t, missed)
+
+		// A replica which missed a write catches up on the next one it
+		// receives to the partition
+		assert.NoError(t, customers.Update("1", map[string]interface{}{"Name": "Joseph"}).WithOptions(Options{Consistency: &all}).Run())
+		for i := 0; i < 50; i++ {
+			assert.NoError(t, customers.Read("1", &c).Run())
+			assert.Equal(t, "Joseph", c.Name)
+		}
+	})
+
+	t.Run("Logged batch", func(t *testing.T) {
+		op := customers.Set(Customer{Id: "3", Name: "Jim"}).
+			Add(customers.Set(Customer{Id: "4", Name: "Jill"})).
+			WithOptions(Options{Consistency: &quorum})
+		assert.NoError(t, op.RunAtomically())
+
+		var cs []Customer
+		assert.NoError(t, customers.MultiRead([]interface{}{"3", "4"}, &cs).WithOptions(Options{Consistency: &all}).Run())
+		assert.Empty(t, cs)
+
+		clock.Add(time.Second)
+		assert.NoError(t, customers.MultiRead([]interface{}{"3", "4"}, &cs).WithOptions(Options{Consistency: &quorum}).Run())
+		assert.ElementsMatch(t, []Customer{{Id: "3", Name: "Jim"}, {Id: "4", Name: "Jill"}}, cs)
+	})
+
+	t.Run("Unavailable", func(t *testing.T) {
+		ks := NewMockKeySpace(MockReplicas(MockReplication{Replicas: 1}))
+		customers := ks.MapTable("customer", "Id", Customer{})
+		two := gocql.Two
+
+		err := customers.Set(Customer{Id: "1"}).WithOptions(Options{Consistency: &two}).Run()
+		var unavailable *gocql.RequestErrUnavailable
+		if assert.True(t, errors.As(err, &unavailable)) {
+			assert.Equal(t, 2, unavailable.Required)
+		}
+		var c Customer
+		assert.IsType(t, RowNotFoundError{}, customers.Read("1", &c).Run())
+	})
+}
@@ -0,0 +1,674 @@
+package gocassa
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+
+	"github.com/gocql/gocql"
+)
+
+// Opcodes of the native protocol
+const (
+	cqlOpError        = 0x00
+	cqlOpStartup      = 0x01
+	cqlOpReady        = 0x02
+	cqlOpOptions      = 0x05
+	cqlOpSupported    = 0x06
+	cqlOpQuery        = 0x07
+	cqlOpResult       = 0x08
+	cqlOpPrepare      = 0x09
+	cqlOpExecute      = 0x0A
+	cqlOpRegister     = 0x0B
+	cqlOpBatch        = 0x0D
+	cqlMaxFrameLength = 256 * 1024 * 1024
+)
+
+// Kinds of RESULT messages
+const (
+	cqlResultVoid        = 0x0001
+	cqlResultRows        = 0x0002
+	cqlResultSetKeyspace = 0x0003
+	cqlResultPrepared    = 0x0004
+)
+
+// cqlProtocolError is returned for malformed or unsupported messages
+type cqlProtocolError struct {
+	message string
+}
+
+func (e cqlProtocolError) Code() int {
+	return 0x000A
+}
+
+func (e cqlProtocolError) Message() string {
+	return e.message
+}
+
+func (e cqlProtocolError) Error() string {
+	return e.message
+}
+
+// cqlUnpreparedError is returned when executing a statement which wasn't
+// prepared, so the driver prepares it again
+type cqlUnpreparedError struct {
+	id []byte
+}
+
+func (e cqlUnpreparedError) Code() int {
+	return 0x2500
+}
+
+func (e cqlUnpreparedError) Message() string {
+	return fmt.Sprintf("Prepared query with ID %x not found", e.id)
+}
+
+func (e cqlUnpreparedError) Error() string {
+	return e.Message()
+}
+
+// MockServer serves mock keyspaces over version 4 of the CQL native protocol,
+// so gocql, and tools such as cqlsh, can read and write the same tables as the
+// code using the keyspaces directly. It understands the statements gocassa
+// generates, along with CREATE/DROP KEYSPACE/TABLE, TRUNCATE and USE, and the
+// system tables drivers query when connecting. Keyspaces created through CQL
+// are mock keyspaces too.
+//
+// Paging, lightweight transactions, authentication and compression aren't
+// supported, and batches aren't atomic
+type MockServer struct {
+	engine   *cqlEngine
+	listener net.Listener
+
+	mu       sync.Mutex
+	conns    map[net.Conn]bool
+	prepared map[string]*cqlPrepared
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+// NewMockServer starts a server listening on addr. An empty address listens
+// on a free port on the loopback interface
+func NewMockServer(addr string) (*MockServer, error) {
+	if addr == "" {
+		addr = "127.0.0.1:0"
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	s := &MockServer{
+		engine:   newCQLEngine(),
+		listener: l,
+		conns:    map[net.Conn]bool{},
+		prepared: map[string]*cqlPrepared{},
+	}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// AddKeySpace serves a keyspace returned by NewMockKeySpace under the given
+// name
+func (s *MockServer) AddKeySpace(name string, ks KeySpace) error {
+	mks, ok := ks.(*mockKeySpace)
+	if !ok {
+		return fmt.Errorf("gocassa: %T isn't a mock keyspace", ks)
+	}
+	s.engine.addKeySpace(name, mks)
+	return nil
+}
+
+// KeySpace returns the keyspace served under the given name, including those
+// created through CQL, or nil if there isn't one
+func (s *MockServer) KeySpace(name string) KeySpace {
+	ks, _, err := s.engine.keyspace(name)
+	if err != nil {
+		return nil
+	}
+	return ks
+}
+
+// Addr returns the address the server listens on
+func (s *MockServer) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Host returns the host the server listens on
+func (s *MockServer) Host() string {
+	host, _, _ := net.SplitHostPort(s.Addr())
+	return host
+}
+
+// Port returns the port the server listens on
+func (s *MockServer) Port() int {
+	_, port, _ := net.SplitHostPort(s.Addr())
+	p, _ := strconv.Atoi(port)
+	return p
+}
+
+// ClusterConfig returns the configuration of a gocql cluster connecting to
+// the server, using the given keyspace unless it's empty
+func (s *MockServer) ClusterConfig(keyspace string) *gocql.ClusterConfig {
+	cluster := gocql.NewCluster(s.Host())
+	cluster.Port = s.Port()
+	cluster.ProtoVersion = cqlProtoVersion
+	cluster.Keyspace = keyspace
+	cluster.Consistency = gocql.One
+	return cluster
+}
+
+// Close stops the server, closing the connections to it
+func (s *MockServer) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	err := s.listener.Close()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	return err
+}
+
+func (s *MockServer) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			conn.Close()
+			return
+		}
+		s.conns[conn] = true
+		s.wg.Add(1)
+		s.mu.Unlock()
+
+		go func() {
+			defer s.wg.Done()
+			s.serveConn(conn)
+			s.mu.Lock()
+			delete(s.conns, conn)
+			s.mu.Unlock()
+			conn.Close()
+		}()
+	}
+}
+
+// cqlFrame is a message of the native protocol
+type cqlFrame struct {
+	version byte
+	flags   byte
+	stream  int16
+	opcode  byte
+	body    []byte
+}
+
+func readCQLFrame(r io.Reader) (*cqlFrame, error) {
+	var header [9]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	f := &cqlFrame{
+		version: header[0],
+		flags:   header[1],
+		stream:  int16(binary.BigEndian.Uint16(header[2:4])),
+		opcode:  header[4],
+	}
+	length := binary.BigEndian.Uint32(header[5:9])
+	if length > cqlMaxFrameLength {
+		return nil, fmt.Errorf("frame of %d bytes is too large", length)
+	}
+	f.body = make([]byte, length)
+	if _, err := io.ReadFull(r, f.body); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func writeCQLFrame(w io.Writer, version byte, stream int16, opcode byte, body []byte) error {
+	header := [9]byte{0x80 | version, 0}
+	binary.BigEndian.PutUint16(header[2:4], uint16(stream))
+	header[4] = opcode
+	binary.BigEndian.PutUint32(header[5:9], uint32(len(body)))
+	_, err := w.Write(append(header[:], body...))
+	return err
+}
+
+// cqlConn is the state of a connection to the server
+type cqlConn struct {
+	server   *MockServer
+	keyspace string
+}
+
+func (s *MockServer) serveConn(conn net.Conn) {
+	c := &cqlConn{server: s}
+	for {
+		f, err := readCQLFrame(conn)
+		if err != nil {
+			return
+		}
+		version := f.version & 0x7F
+		var (
+			opcode byte
+			body   []byte
+		)
+		if version != cqlProtoVersion {
+			opcode, body = cqlOpError, encodeCQLError(cqlProtocolError{
+				fmt.Sprintf("Invalid or unsupported protocol version (%d); supported versions are (4/v4)", version),
+			})
+		} else {
+			opcode, body = c.handle(f)
+		}
+		if err := writeCQLFrame(conn, version, f.stream, opcode, body); err != nil {
+			return
+		}
+	}
+}
+
+// handle returns the response to a request
+func (c *cqlConn) handle(f *cqlFrame) (byte, []byte) {
+	r := &cqlReader{b: f.body}
+	w := &cqlWriter{}
+	var err error
+	switch f.opcode {
+	case cqlOpOptions:
+		w.stringMultimap(map[string][]string{"CQL_VERSION": {"3.4.4"}, "COMPRESSION": {}})
+		return cqlOpSupported, w.Bytes()
+	case cqlOpStartup:
+		options := r.stringMap()
+		if compression := options["COMPRESSION"]; compression != "" {
+			return cqlOpError, encodeCQLError(cqlProtocolError{fmt.Sprintf("Unsupported compression %s", compression)})
+		}
+		return cqlOpReady, nil
+	case cqlOpRegister:
+		return cqlOpReady, nil
+	case cqlOpQuery:
+		query := r.longString()
+		var p *cqlPrepared
+		if p, err = c.server.engine.prepare(c.keyspace, query); err == nil {
+			err = c.run(w, p, r)
+		}
+	case cqlOpPrepare:
+		err = c.prepare(w, r.longString())
+	case cqlOpExecute:
+		id := r.shortBytes()
+		var p *cqlPrepared
+		if p, err = c.server.lookup(id); err == nil {
+			err = c.run(w, p, r)
+		}
+	case cqlOpBatch:
+		err = c.batch(w, r)
+	default:
+		err = cqlProtocolError{fmt.Sprintf("Unsupported opcode 0x%02x", f.opcode)}
+	}
+	if err == nil && r.err != nil {
+		err = cqlProtocolError{r.err.Error()}
+	}
+	if err != nil {
+		return cqlOpError, encodeCQLError(err)
+	}
+	return cqlOpResult, w.Bytes()
+}
+
+func preparedID(keyspace, query string) []byte {
+	id := md5.Sum([]byte(keyspace + "\x00" + query))
+	return id[:]
+}
+
+func (s *MockServer) lookup(id []byte) (*cqlPrepared, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.prepared[string(id)]
+	if !ok {
+		return nil, cqlUnpreparedError{id: id}
+	}
+	return p, nil
+}
+
+func (c *cqlConn) prepare(w *cqlWriter, query string) error {
+	p, err := c.server.engine.prepare(c.keyspace, query)
+	if err != nil {
+		return err
+	}
+	id := preparedID(c.keyspace, query)
+	c.server.mu.Lock()
+	c.server.prepared[string(id)] = p
+	c.server.mu.Unlock()
+
+	w.int(cqlResultPrepared)
+	w.shortBytes(id)
+	ks, table := "", ""
+	if p.table != nil {
+		ks, table = p.table.keyspace, p.table.name
+	}
+	// The partition key indexes are left out, so drivers don't route by token
+	w.int(0x0001)
+	w.int(len(p.binds))
+	w.int(0)
+	w.string(ks)
+	w.string(table)
+	for _, b := range p.binds {
+		w.string(b.name)
+		w.option(b.typ)
+	}
+	if _, ok := p.stmt.(*cqlSelect); !ok {
+		w.int(0x0004)
+		w.int(0)
+		return nil
+	}
+	w.metadata(ks, table, p.results)
+	return nil
+}
+
+// run reads the parameters of a QUERY or EXECUTE and runs the statement
+func (c *cqlConn) run(w *cqlWriter, p *cqlPrepared, r *cqlReader) error {
+	r.short() // consistency
+	flags := r.byte()
+	var values []interface{}
+	if flags&0x01 != 0 {
+		if flags&0x40 != 0 {
+			return cqlProtocolError{"Named values aren't supported"}
+		}
+		var err error
+		if values, err = r.values(p); err != nil {
+			return err
+		}
+	}
+	if r.err != nil {
+		return cqlProtocolError{r.err.Error()}
+	}
+
+	result, err := c.server.engine.execute(p, values)
+	if err != nil {
+		return err
+	}
+	switch {
+	case result.keyspace != "":
+		c.keyspace = result.keyspace
+		w.int(cqlResultSetKeyspace)
+		w.string(result.keyspace)
+	case result.columns != nil:
+		return w.rows(p.table, result)
+	default:
+		w.int(cqlResultVoid)
+	}
+	return nil
+}
+
+func (c *cqlConn) batch(w *cqlWriter, r *cqlReader) error {
+	r.byte() // type
+	n := int(r.short())
+	type entry struct {
+		p      *cqlPrepared
+		values []interface{}
+	}
+	entries := make([]entry, 0, n)
+	for i := 0; i < n && r.err == nil; i++ {
+		var (
+			p   *cqlPrepared
+			err error
+		)
+		if r.byte() == 0 {
+			p, err = c.server.engine.prepare(c.keyspace, r.longString())
+		} else {
+			p, err = c.server.lookup(r.shortBytes())
+		}
+		if err != nil {
+			return err
+		}
+		if err := p.checkBatchable(); err != nil {
+			return err
+		}
+		values, err := r.values(p)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, entry{p, values})
+	}
+	if r.err != nil {
+		return cqlProtocolError{r.err.Error()}
+	}
+
+	for _, e := range entries {
+		if _, err := c.server.engine.execute(e.p, e.values); err != nil {
+			return err
+		}
+	}
+	w.int(cqlResultVoid)
+	return nil
+}
+
+func encodeCQLError(err error) []byte {
+	code, message := 0x2200, err.Error()
+	var re gocql.RequestError
+	if errors.As(err, &re) && re.Code() != 0 {
+		code, message = re.Code(), re.Message()
+	}
+	w := &cqlWriter{}
+	w.int(code)
+	w.string(message)
+	switch code {
+	case 0x1000: // unavailable
+		w.short(uint16(gocql.One))
+		w.int(1)
+		w.int(0)
+	case 0x1100: // write timeout
+		w.short(uint16(gocql.One))
+		w.int(0)
+		w.int(1)
+		w.string("SIMPLE")
+	case 0x1200: // read timeout
+		w.short(uint16(gocql.One))
+		w.int(0)
+		w.int(1)
+		w.byte(0)
+	case 0x2400:
+		var e cqlAlreadyExistsError
+		errors.As(err, &e)
+		w.string(e.keyspace)
+		w.string(e.table)
+	case 0x2500:
+		var e cqlUnpreparedError
+		errors.As(err, &e)
+		w.shortBytes(e.id)
+	}
+	return w.Bytes()
+}
+
+// cqlReader reads the notations of the native protocol from a message. Once
+// it runs out of bytes it sets err and returns zero values
+type cqlReader struct {
+	b   []byte
+	err error
+}
+
+func (r *cqlReader) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(r.b) {
+		r.err = errors.New("Not enough bytes to read the message")
+		return nil
+	}
+	b := r.b[:n]
+	r.b = r.b[n:]
+	return b
+}
+
+func (r *cqlReader) byte() byte {
+	if b := r.next(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *cqlReader) short() uint16 {
+	if b := r.next(2); b != nil {
+		return binary.BigEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (r *cqlReader) int() int32 {
+	if b := r.next(4); b != nil {
+		return int32(binary.BigEndian.Uint32(b))
+	}
+	return 0
+}
+
+func (r *cqlReader) string() string {
+	return string(r.next(int(r.short())))
+}
+
+func (r *cqlReader) longString() string {
+	return string(r.next(int(r.int())))
+}
+
+func (r *cqlReader) shortBytes() []byte {
+	return r.next(int(r.short()))
+}
+
+func (r *cqlReader) stringMap() map[string]string {
+	n := int(r.short())
+	m := make(map[string]string, n)
+	for i := 0; i < n && r.err == nil; i++ {
+		k := r.string()
+		m[k] = r.string()
+	}
+	return m
+}
+
+// values reads the values bound to a statement, decoding them into the Go
+// types of its bind columns
+func (r *cqlReader) values(p *cqlPrepared) ([]interface{}, error) {
+	n := int(r.short())
+	if r.err == nil && n != len(p.binds) {
+		return nil, invalidRequest("There were %d markers(?) in CQL but %d bound variables", len(p.binds), n)
+	}
+	values := make([]interface{}, n)
+	for i := 0; i < n && r.err == nil; i++ {
+		length := int(r.int())
+		switch {
+		case length == -1:
+			continue
+		case length == -2:
+			values[i] = gocql.UnsetValue
+			continue
+		}
+		data := r.next(length)
+		if r.err != nil {
+			break
+		}
+		v, err := p.binds[i].decode(data)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+// cqlWriter writes the notations of the native protocol
+type cqlWriter struct {
+	bytes.Buffer
+}
+
+func (w *cqlWriter) byte(b byte) {
+	w.WriteByte(b)
+}
+
+func (w *cqlWriter) short(n uint16) {
+	var b [2]byte
+	binary.BigEndian.PutUint16(b[:], n)
+	w.Write(b[:])
+}
+
+func (w *cqlWriter) int(n int) {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], uint32(int32(n)))
+	w.Write(b[:])
+}
+
+func (w *cqlWriter) string(s string) {
+	w.short(uint16(len(s)))
+	w.WriteString(s)
+}
+
+func (w *cqlWriter) shortBytes(b []byte) {
+	w.short(uint16(len(b)))
+	w.Write(b)
+}
+
+func (w *cqlWriter) bytes(b []byte) {
+	if b == nil {
+		w.int(-1)
+		return
+	}
+	w.int(len(b))
+	w.Write(b)
+}
+
+func (w *cqlWriter) stringMultimap(m map[string][]string) {
+	w.short(uint16(len(m)))
+	for k, vs := range m {
+		w.string(k)
+		w.short(uint16(len(vs)))
+		for _, v := range vs {
+			w.string(v)
+		}
+	}
+}
+
+// option writes a type
+func (w *cqlWriter) option(typ gocql.TypeInfo) {
+	w.short(uint16(typ.Type()))
+	switch typ.Type() {
+	case gocql.TypeList, gocql.TypeSet:
+		w.option(typ.(gocql.CollectionType).Elem)
+	case gocql.TypeMap:
+		c := typ.(gocql.CollectionType)
+		w.option(c.Key)
+		w.option(c.Elem)
+	case gocql.TypeCustom:
+		w.string(typ.Custom())
+	}
+}
+
+// metadata writes the metadata of rows, with a global table spec
+func (w *cqlWriter) metadata(keyspace, table string, columns []cqlColumn) {
+	w.int(0x0001)
+	w.int(len(columns))
+	w.string(keyspace)
+	w.string(table)
+	for _, c := range columns {
+		w.string(c.name)
+		w.option(c.typ)
+	}
+}
+
+func (w *cqlWriter) rows(t *cqlTable, result *cqlResult) error {
+	w.int(cqlResultRows)
+	w.metadata(t.keyspace, t.name, result.columns)
+	w.int(len(result.rows))
+	for _, row := range result.rows {
+		for i, v := range row {
+			b, err := result.columns[i].encode(v)
+			if err != nil {
+				return err
+			}
+			w.bytes(b)
+		}
+	}
+	return nil
+}
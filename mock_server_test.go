@@ -0,0 +1,172 @@
+package gocassa
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/stretchr/testify/assert"
+)
+
+type serverEvent struct {
+	Account string
+	At      time.Time
+	Kind    string
+	Tags    []string
+	Attrs   map[string]string
+	Count   Counter
+}
+
+func mockServerSession(t *testing.T, ks KeySpace) (*MockServer, *gocql.Session) {
+	srv, err := NewMockServer("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { srv.Close() })
+	if err := srv.AddKeySpace("test", ks); err != nil {
+		t.Fatal(err)
+	}
+	sess, err := srv.ClusterConfig("test").CreateSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(sess.Close)
+	return srv, sess
+}
+
+func TestMockServer(t *testing.T) {
+	ks := NewMockKeySpace()
+	_, sess := mockServerSession(t, ks)
+	local := ks.MapTable("customer", "Id", Customer{})
+	remote := NewConnection(GoCQLSessionToQueryExecutor(sess)).KeySpace("test").MapTable("customer", "Id", Customer{})
+
+	assert.NoError(t, local.Set(Customer{Id: "1", Name: "Joe"}).Run())
+	var c Customer
+	assert.NoError(t, remote.Read("1", &c).Run())
+	assert.Equal(t, Customer{Id: "1", Name: "Joe"}, c)
+	assert.IsType(t, RowNotFoundError{}, remote.Read("3", &c).Run())
+
+	assert.NoError(t, remote.Set(Customer{Id: "2", Name: "Jane"}).Add(remote.Update("1", map[string]interface{}{"Name": "Jim"})).RunAtomically())
+	var cs []Customer
+	assert.NoError(t, local.MultiRead([]interface{}{"1", "2"}, &cs).Run())
+	assert.ElementsMatch(t, []Customer{{Id: "1", Name: "Jim"}, {Id: "2", Name: "Jane"}}, cs)
+
+	assert.NoError(t, remote.Delete("1").Run())
+	assert.IsType(t, RowNotFoundError{}, local.Read("1", &c).Run())
+
+	var doc json.RawMessage
+	assert.NoError(t, remote.ReadJSON("2", &doc).Run())
+	assert.JSONEq(t, `{"id": "2", "name": "Jane"}`, string(doc))
+
+	tables, err := NewConnection(GoCQLSessionToQueryExecutor(sess)).KeySpace("test").Tables()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"customer_map_id"}, tables)
+
+	err = sess.Query("SELECT * FROM test.missing").Exec()
+	assert.Error(t, err)
+	assert.True(t, strings.Contains(err.Error(), "unconfigured table missing"))
+}
+
+func TestMockServerCreateTable(t *testing.T) {
+	ks := NewMockKeySpace()
+	_, sess := mockServerSession(t, ks)
+	remote := NewConnection(GoCQLSessionToQueryExecutor(sess)).KeySpace("test").Table("events", serverEvent{}, Keys{
+		PartitionKeys:     []string{"Account"},
+		ClusteringColumns: []string{"At"},
+	}).WithOptions(Options{ClusteringOrder: []ClusteringOrderColumn{{Column: "At", Direction: DESC}}})
+	assert.NoError(t, remote.(TableChanger).Create())
+
+	start := time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		e := serverEvent{Account: "a", At: start.Add(time.Duration(i) * time.Hour), Kind: "login", Tags: []string{"b"}}
+		assert.NoError(t, remote.Set(e).Run())
+	}
+	key := []Relation{Eq("Account", "a"), Eq("At", start)}
+	assert.NoError(t, remote.Where(key...).Update(map[string]interface{}{
+		"Tags":  ListAppend("c"),
+		"Attrs": MapSetFields(map[string]interface{}{"ip": "::1", "ua": "curl"}),
+		"Count": CounterIncrement(2),
+	}).Run())
+	assert.NoError(t, remote.Where(key...).Update(map[string]interface{}{"Tags": ListPrepend("a")}).Run())
+
+	var events []serverEvent
+	assert.NoError(t, remote.Where(Eq("Account", "a")).Read(&events).WithOptions(Options{Limit: 2}).Run())
+	if assert.Len(t, events, 2) {
+		assert.True(t, events[0].At.Equal(start.Add(2*time.Hour)))
+		assert.True(t, events[1].At.Equal(start.Add(time.Hour)))
+	}
+
+	// The table is created in the mock keyspace
+	var dump map[string][]map[string]interface{}
+	var buf strings.Builder
+	assert.NoError(t, ks.(MockStore).Dump(&buf, JSONDump))
+	assert.NoError(t, json.Unmarshal([]byte(buf.String()), &dump))
+	assert.Len(t, dump["events__account__at"], 3)
+
+	var e serverEvent
+	assert.NoError(t, remote.Where(key...).ReadOne(&e).Run())
+	assert.Equal(t, []string{"a", "b", "c"}, e.Tags)
+	assert.Equal(t, map[string]string{"ip": "::1", "ua": "curl"}, e.Attrs)
+	assert.Equal(t, Counter(2), e.Count)
+
+	var release string
+	assert.NoError(t, sess.Query("SELECT release_version FROM system.local WHERE key = 'local'").Scan(&release))
+	assert.Equal(t, cqlReleaseVersion, release)
+}
+
+func TestMockServerCQL(t *testing.T) {
+	_, sess := mockServerSession(t, NewMockKeySpace())
+	exec := func(query string, values ...interface{}) {
+		t.Helper()
+		if err := sess.Query(query, values...).Exec(); err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+	}
+
+	exec("CREATE KEYSPACE other WITH replication = {'class': 'SimpleStrategy', 'replication_factor': 1}")
+	exec(`CREATE TABLE other.docs (
+		id int,
+		version bigint,
+		"Title" text,
+		body map<text, text>,
+		parts list<int>,
+		PRIMARY KEY ((id), version)
+	) WITH CLUSTERING ORDER BY (version DESC)`)
+	err := sess.Query("CREATE TABLE other.docs (id int PRIMARY KEY)").Exec()
+	assert.IsType(t, &gocql.RequestErrAlreadyExists{}, err)
+	exec("CREATE TABLE IF NOT EXISTS other.docs (id int PRIMARY KEY)")
+
+	exec(`INSERT INTO other.docs (id, version, "Title", body, parts) VALUES (1, 1, 'it''s', {'a': 'b'}, [1, 2])`)
+	exec(`INSERT INTO other.docs (id, version, "Title") VALUES (?, ?, ?) USING TTL 60`, 1, int64(2), "second")
+	exec("UPDATE other.docs SET parts[1] = ?, body['c'] = ? WHERE id = 1 AND version = 1", 5, "d")
+	exec("DELETE body['a'] FROM other.docs WHERE id = ? AND version = ?", 1, int64(1))
+
+	var (
+		title string
+		body  map[string]string
+		parts []int
+	)
+	iter := sess.Query(`SELECT "Title", body, parts FROM other.docs WHERE id IN ? AND version >= ?`, []int{1, 2}, int64(1)).Iter()
+	assert.True(t, iter.Scan(&title, &body, &parts))
+	assert.Equal(t, "second", title)
+	assert.True(t, iter.Scan(&title, &body, &parts))
+	assert.Equal(t, "it's", title)
+	assert.Equal(t, map[string]string{"c": "d"}, body)
+	assert.Equal(t, []int{1, 5}, parts)
+	assert.NoError(t, iter.Close())
+
+	err = sess.Query("SELECT * FROM other.docs WHERE version = 1").Exec()
+	assert.Error(t, err)
+	assert.True(t, strings.Contains(err.Error(), "ALLOW FILTERING"))
+	err = sess.Query("SELEC * FROM other.docs").Exec()
+	assert.Error(t, err)
+
+	exec("TRUNCATE other.docs")
+	var n int
+	assert.Equal(t, gocql.ErrNotFound, sess.Query("SELECT id FROM other.docs WHERE id = 1").Scan(&n))
+	exec("DROP TABLE other.docs")
+	assert.Error(t, sess.Query("SELECT id FROM other.docs").Exec())
+	exec("DROP KEYSPACE other")
+}
@@ -0,0 +1,404 @@
+package gocassa
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/btree"
+	"gopkg.in/yaml.v3"
+)
+
+// DumpFormat is the format the contents of a mock keyspace are dumped in
+type DumpFormat int
+
+const (
+	JSONDump DumpFormat = iota
+	YAMLDump
+)
+
+// dumpFormatOf returns the format of a file, by its extension
+func dumpFormatOf(path string) DumpFormat {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return YAMLDump
+	}
+	return JSONDump
+}
+
+// MockStore is implemented by the KeySpace returned by NewMockKeySpace. It
+// saves and loads the contents of the mock tables, so tests can be seeded from
+// fixture files and state can be rolled back between tests.
+//
+// Dumps are objects keyed by table name (as returned by Name), each holding a
+// list of rows keyed by field name. Rows which have expired are left out, and
+// the TTLs of the others aren't kept:
+//
+//	{"users_map_Id": [{"Id": "1", "Name": "Jane"}]}
+type MockStore interface {
+	// Snapshot returns a copy of the contents of every table
+	Snapshot() MockSnapshot
+	// Restore replaces the contents of every table with those of a snapshot
+	Restore(snapshot MockSnapshot)
+	// Dump writes the contents of every table
+	Dump(w io.Writer, format DumpFormat) error
+	// Load adds the rows of a dump to the tables. Rows of tables which haven't
+	// been created yet are added once they are
+	Load(r io.Reader, format DumpFormat) error
+	// LoadTable adds a list of rows to the named table
+	LoadTable(name string, r io.Reader, format DumpFormat) error
+	// SaveFile dumps the contents of every table to a file, in JSON unless
+	// its extension is .yaml or .yml
+	SaveFile(path string) error
+	// LoadFile loads a file written by SaveFile, or a fixture file in the same
+	// format
+	LoadFile(path string) error
+}
+
+// MockSnapshot is a copy of the contents of a mock keyspace, see MockStore
+type MockSnapshot struct {
+	tables  map[string]map[rowKey]*btree.BTree
+	pending map[string][]json.RawMessage
+}
+
+// mockStore keeps track of the tables of a mock keyspace, and of the rows
+// loaded for tables which haven't been created yet
+type mockStore struct {
+	mu      sync.Mutex
+	tables  map[string]*MockTable
+	pending map[string][]json.RawMessage
+	// saving serializes writes to the file of a persistent keyspace
+	saving sync.Mutex
+}
+
+// register makes a new table share the storage of the table with the same
+// name, as tables do in Cassandra, and loads any rows pending for it
+func (ks *mockKeySpace) register(mt *MockTable) {
+	ks.store.mu.Lock()
+	defer ks.store.mu.Unlock()
+	if ks.store.tables == nil {
+		ks.store.tables = map[string]*MockTable{}
+	}
+	if existing, ok := ks.store.tables[mt.tableName]; ok {
+		mt.RWMutex, mt.mtx, mt.rows = existing.RWMutex, existing.mtx, existing.rows
+		mt.replicas, mt.created = existing.replicas, existing.created
+		return
+	}
+	ks.store.tables[mt.tableName] = mt
+
+	rows := ks.store.pending[mt.tableName]
+	delete(ks.store.pending, mt.tableName)
+	if err := mt.loadRows(rows); err != nil {
+		panic(err)
+	}
+}
+
+func (t *MockTable) loadRows(rows []json.RawMessage) error {
+	t.Lock()
+	defer t.Unlock()
+	for _, row := range rows {
+		columns, err := t.columnsFromJSON(row, JSONDefaultNull)
+		if err != nil {
+			return fmt.Errorf("Can't load row into %s: %v", t.tableName, err)
+		}
+		if err := t.setColumns(columns, 0); err != nil {
+			return fmt.Errorf("Can't load row into %s: %v", t.tableName, err)
+		}
+	}
+	t.resetReplicas()
+	return nil
+}
+
+// copyRows returns a deep copy of the rows of the table
+func (t *MockTable) copyRows() map[rowKey]*btree.BTree {
+	t.RLock()
+	defer t.RUnlock()
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+	return copyMockRows(t.rows)
+}
+
+func copyMockRows(rows map[rowKey]*btree.BTree) map[rowKey]*btree.BTree {
+	c := make(map[rowKey]*btree.BTree, len(rows))
+	for k, row := range rows {
+		cp := btree.New(2)
+		row.Ascend(func(item btree.Item) bool {
+			scol := item.(*superColumn)
+			cp.ReplaceOrInsert(&superColumn{
+				Key:          append(key{}, scol.Key...),
+				Columns:      deepCopy(scol.Columns).(map[string]interface{}),
+				Expiries:     deepCopy(scol.Expiries).(map[string]time.Time),
+				Marker:       scol.Marker,
+				MarkerExpiry: scol.MarkerExpiry,
+			})
+			return true
+		})
+		c[k] = cp
+	}
+	return c
+}
+
+// deepCopy copies the maps and slices in v, which modifiers change in place
+func deepCopy(v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Map:
+		if rv.IsNil() {
+			return v
+		}
+		c := reflect.MakeMapWithSize(rv.Type(), rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			c.SetMapIndex(iter.Key(), deepCopyValue(iter.Value(), rv.Type().Elem()))
+		}
+		return c.Interface()
+	case reflect.Slice:
+		if rv.IsNil() {
+			return v
+		}
+		c := reflect.MakeSlice(rv.Type(), rv.Len(), rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			c.Index(i).Set(deepCopyValue(rv.Index(i), rv.Type().Elem()))
+		}
+		return c.Interface()
+	}
+	return v
+}
+
+func deepCopyValue(v reflect.Value, typ reflect.Type) reflect.Value {
+	if v.Kind() == reflect.Interface && v.IsNil() {
+		return reflect.Zero(typ)
+	}
+	c := reflect.ValueOf(deepCopy(v.Interface()))
+	if typ.Kind() == reflect.Interface {
+		ret := reflect.New(typ).Elem()
+		ret.Set(c)
+		return ret
+	}
+	return c
+}
+
+func (ks *mockKeySpace) Snapshot() MockSnapshot {
+	ks.store.mu.Lock()
+	defer ks.store.mu.Unlock()
+	snapshot := MockSnapshot{
+		tables:  make(map[string]map[rowKey]*btree.BTree, len(ks.store.tables)),
+		pending: make(map[string][]json.RawMessage, len(ks.store.pending)),
+	}
+	for name, t := range ks.store.tables {
+		snapshot.tables[name] = t.copyRows()
+	}
+	for name, rows := range ks.store.pending {
+		snapshot.pending[name] = append([]json.RawMessage{}, rows...)
+	}
+	return snapshot
+}
+
+func (ks *mockKeySpace) Restore(snapshot MockSnapshot) {
+	ks.store.mu.Lock()
+	defer ks.store.mu.Unlock()
+	for name, t := range ks.store.tables {
+		rows := copyMockRows(snapshot.tables[name])
+		t.Lock()
+		t.mtx.Lock()
+		// The map is shared with the copies made by WithOptions, so it's
+		// changed in place
+		for k := range t.rows {
+			delete(t.rows, k)
+		}
+		for k, row := range rows {
+			t.rows[k] = row
+		}
+		t.resetReplicas()
+		t.mtx.Unlock()
+		t.Unlock()
+	}
+	ks.store.pending = make(map[string][]json.RawMessage, len(snapshot.pending))
+	for name, rows := range snapshot.pending {
+		ks.store.pending[name] = append([]json.RawMessage{}, rows...)
+	}
+}
+
+// dumpRows returns the rows of the table which haven't expired, in primary
+// key order
+func (t *MockTable) dumpRows() []map[string]interface{} {
+	now := t.now()
+	t.RLock()
+	defer t.RUnlock()
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+
+	keys := make([]string, 0, len(t.rows))
+	for k := range t.rows {
+		keys = append(keys, string(k))
+	}
+	sort.Strings(keys)
+
+	rows := []map[string]interface{}{}
+	for _, k := range keys {
+		t.rows[rowKey(k)].Ascend(func(item btree.Item) bool {
+			if columns, alive := item.(*superColumn).live(t.keys, now); alive {
+				rows = append(rows, deepCopy(columns).(map[string]interface{}))
+			}
+			return true
+		})
+	}
+	return rows
+}
+
+func (ks *mockKeySpace) Dump(w io.Writer, format DumpFormat) error {
+	ks.store.mu.Lock()
+	dump := map[string]interface{}{}
+	for name, t := range ks.store.tables {
+		if rows := t.dumpRows(); len(rows) > 0 {
+			dump[name] = rows
+		}
+	}
+	for name, rows := range ks.store.pending {
+		dump[name] = rows
+	}
+	b, err := json.MarshalIndent(dump, "", "  ")
+	ks.store.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if format == YAMLDump {
+		// Rows are converted to YAML through JSON, so they're encoded the
+		// same way in both formats
+		var v interface{}
+		if err := yaml.Unmarshal(b, &v); err != nil {
+			return err
+		}
+		if b, err = yaml.Marshal(v); err != nil {
+			return err
+		}
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// readJSON reads a JSON or YAML document, returning it as JSON
+func readJSON(r io.Reader, format DumpFormat) ([]byte, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil || format == JSONDump {
+		return b, err
+	}
+	var v interface{}
+	if err := yaml.Unmarshal(b, &v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+func (ks *mockKeySpace) Load(r io.Reader, format DumpFormat) error {
+	b, err := readJSON(r, format)
+	if err != nil {
+		return err
+	}
+	var dump map[string][]json.RawMessage
+	if err := json.Unmarshal(b, &dump); err != nil {
+		return fmt.Errorf("Could not decode dump: %v", err)
+	}
+
+	names := make([]string, 0, len(dump))
+	for name := range dump {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := ks.loadTable(name, dump[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ks *mockKeySpace) LoadTable(name string, r io.Reader, format DumpFormat) error {
+	b, err := readJSON(r, format)
+	if err != nil {
+		return err
+	}
+	var rows []json.RawMessage
+	if err := json.Unmarshal(b, &rows); err != nil {
+		return fmt.Errorf("Could not decode rows of %s: %v", name, err)
+	}
+	return ks.loadTable(name, rows)
+}
+
+func (ks *mockKeySpace) loadTable(name string, rows []json.RawMessage) error {
+	ks.store.mu.Lock()
+	t, ok := ks.store.tables[name]
+	if !ok {
+		if ks.store.pending == nil {
+			ks.store.pending = map[string][]json.RawMessage{}
+		}
+		ks.store.pending[name] = append(ks.store.pending[name], rows...)
+	}
+	ks.store.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	return t.loadRows(rows)
+}
+
+func (ks *mockKeySpace) SaveFile(path string) error {
+	ks.store.saving.Lock()
+	defer ks.store.saving.Unlock()
+
+	buf := &bytes.Buffer{}
+	if err := ks.Dump(buf, dumpFormatOf(path)); err != nil {
+		return err
+	}
+	// Write to a temporary file first, so a crash never leaves half a dump
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (ks *mockKeySpace) LoadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return ks.Load(f, dumpFormatOf(path))
+}
+
+// NewPersistentMockKeySpace returns a mock keyspace which loads the file at
+// path, if it exists, and saves its contents back to it after every write.
+// It's meant for use as a local development store, not for large data sets
+func NewPersistentMockKeySpace(path string) (KeySpace, error) {
+	ks := NewMockKeySpace().(*mockKeySpace)
+	if err := ks.LoadFile(path); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	ks.AddWriteHook(func(ctx context.Context, e WriteEvent) error {
+		return ks.SaveFile(path)
+	})
+	return ks, nil
+}
@@ -1,9 +1,17 @@
 package gocassa
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -50,6 +58,12 @@ func TestRunMockSuite(t *testing.T) {
 	suite.Run(t, new(MockSuite))
 }
 
+// The suite runs again against a strict mock, as the queries of the recipes
+// must all be valid
+func TestRunStrictMockSuite(t *testing.T) {
+	suite.Run(t, &MockSuite{options: []MockOption{StrictMock()}})
+}
+
 type MockSuite struct {
 	suite.Suite
 	*require.Assertions
@@ -64,10 +78,11 @@ type MockSuite struct {
 	embTsTbl             TimeSeriesTable
 	addressByCountyMmTbl MultimapTable
 	mmMkTable            MultimapMkTable
+	options              []MockOption
 }
 
 func (s *MockSuite) SetupTest() {
-	s.ks = NewMockKeySpace()
+	s.ks = NewMockKeySpace(s.options...)
 	s.Assertions = require.New(s.T())
 	s.tbl = s.ks.Table("users", user{}, Keys{
 		PartitionKeys:     []string{"Pk1", "Pk2"},
@@ -257,6 +272,65 @@ func (s *MockSuite) TestMapTableDelete() {
 	s.Equal(RowNotFoundError{}, s.mapTbl.Read(1, &user).Run())
 }
 
+func (s *MockSuite) TestMapTableJSON() {
+	s.insertUsers()
+
+	var doc json.RawMessage
+	s.NoError(s.mapTbl.ReadJSON(1, &doc).Run())
+	s.JSONEq(`{"pk1":1,"pk2":1,"ck1":1,"ck2":2,"name":"Jane"}`, string(doc))
+	s.Equal(RowNotFoundError{}, s.mapTbl.ReadJSON(42, &doc).Run())
+
+	var docs []json.RawMessage
+	s.NoError(s.mapTbl.MultiReadJSON([]interface{}{1, 2}, &docs).Run())
+	s.Len(docs, 2)
+	s.JSONEq(`{"pk1":2,"pk2":1,"ck1":1,"ck2":1,"name":"Jill"}`, string(docs[1]))
+
+	s.NoError(s.mapTbl.WithOptions(Options{Select: []string{"Name"}}).ReadJSON(1, &doc).Run())
+	s.JSONEq(`{"name":"Jane"}`, string(doc))
+
+	// DEFAULT UNSET leaves omitted columns untouched
+	s.NoError(s.mapTbl.SetJSON([]byte(`{"pk1":1,"name":"Janet"}`), JSONDefaultUnset).Run())
+	var u user
+	s.NoError(s.mapTbl.Read(1, &u).Run())
+	s.Equal(user{Pk1: 1, Pk2: 1, Ck1: 1, Ck2: 2, Name: "Janet"}, u)
+
+	// DEFAULT NULL nulls them out
+	s.NoError(s.mapTbl.SetJSON([]byte(`{"pk1":1,"name":"Janet"}`), JSONDefaultNull).Run())
+	s.NoError(s.mapTbl.Read(1, &u).Run())
+	s.Equal(user{Pk1: 1, Name: "Janet"}, u)
+
+	s.Error(s.mapTbl.SetJSON([]byte(`{"pk1":1,"unknown":"x"}`), JSONDefaultNull).Run())
+	s.Error(s.mapTbl.SetJSON([]byte(`{"pk1":"not a number"}`), JSONDefaultNull).Run())
+}
+
+func (s *MockSuite) TestMapTableEmptyFieldModes() {
+	type profile struct {
+		Id       string
+		Name     string
+		Nickname string `cql:",omitempty"`
+		Tags     []string
+	}
+	tbl := s.ks.MapTable("profiles", "Id", profile{})
+	s.NoError(tbl.Set(profile{Id: "1", Name: "Moss", Nickname: "Mossy", Tags: []string{"it"}}).Run())
+
+	// omitempty fields are always left untouched when empty
+	s.NoError(tbl.Set(profile{Id: "1", Name: "Maurice", Tags: []string{"it"}}).Run())
+	var p profile
+	s.NoError(tbl.Read("1", &p).Run())
+	s.Equal(profile{Id: "1", Name: "Maurice", Nickname: "Mossy", Tags: []string{"it"}}, p)
+
+	for _, mode := range []EmptyFieldMode{OmitEmptyFields, UnsetEmptyFields} {
+		s.NoError(tbl.WithOptions(Options{EmptyFields: mode}).Set(profile{Id: "1", Name: "Moss"}).Run())
+		s.NoError(tbl.Read("1", &p).Run())
+		s.Equal(profile{Id: "1", Name: "Moss", Nickname: "Mossy", Tags: []string{"it"}}, p)
+	}
+
+	// by default other empty fields are overwritten
+	s.NoError(tbl.Set(profile{Id: "1", Name: "Moss"}).Run())
+	s.NoError(tbl.Read("1", &p).Run())
+	s.Equal(profile{Id: "1", Name: "Moss", Nickname: "Mossy", Tags: []string{}}, p)
+}
+
 func (s *MockSuite) TestMapModifiers() {
 	tbl := s.ks.MapTable("user342135", "Id", UserWithMap{})
 	createIf(tbl.(TableChanger), s.T())
@@ -317,6 +391,47 @@ func (s *MockSuite) TestMapModifiers() {
 	}
 }
 
+func (s *MockSuite) TestMapTableDeleteColumns() {
+	type profile struct {
+		Id     string
+		Name   string
+		Attrs  map[string]string
+		Tags   []string
+		Scores []int
+	}
+	tbl := s.ks.MapTable("profiles", "Id", profile{})
+	s.NoError(tbl.Set(profile{
+		Id:     "1",
+		Name:   "Moss",
+		Attrs:  map[string]string{"a": "1", "b": "2", "c": "3"},
+		Tags:   []string{"it", "crowd", "it"},
+		Scores: []int{1, 2, 3},
+	}).Run())
+
+	s.NoError(tbl.DeleteColumns("1", Column("name"), MapKey("Attrs", "a"), ListIndex("Scores", 1)).Run())
+	s.NoError(tbl.Update("1", map[string]interface{}{
+		"Attrs": MapRemoveKeys("b"),
+		"Tags":  SetRemove("it"),
+	}).Run())
+
+	var p profile
+	s.NoError(tbl.Read("1", &p).Run())
+	s.Equal(profile{
+		Id:     "1",
+		Attrs:  map[string]string{"c": "3"},
+		Tags:   []string{"crowd"},
+		Scores: []int{1, 3},
+	}, p)
+
+	s.Error(tbl.DeleteColumns("1", Column("Id")).Run())
+	s.Error(tbl.DeleteColumns("1", Column("unknown")).Run())
+	s.Error(tbl.DeleteColumns("1", ListIndex("Scores", 5)).Run())
+	s.Error(tbl.DeleteColumns("1", ListIndex("Attrs", 0)).Run())
+
+	// Column-level deletes require the full primary key
+	s.Error(s.mmapTbl.Table().Where(Eq("Pk1", 1)).DeleteColumns(Column("Name")).Run())
+}
+
 // MultiMapTable tests
 func (s *MockSuite) TestMultiMapTableRead() {
 	s.insertUsers()
@@ -349,6 +464,113 @@ func (s *MockSuite) TestMultiMapTableList() {
 	s.Equal("Joe", users[0].Name)
 }
 
+func (s *MockSuite) TestMultiMapTableJSON() {
+	s.insertUsers()
+
+	var docs []json.RawMessage
+	s.NoError(s.mmapTbl.ListJSON(1, nil, 0, &docs).Run())
+	s.Len(docs, 2)
+	s.JSONEq(`{"pk1":1,"pk2":1,"ck1":1,"ck2":2,"name":"Jane"}`, string(docs[0]))
+
+	s.NoError(s.mmapTbl.SetJSON([]byte(`{"pk1":1,"pk2":3,"name":"Jack"}`), JSONDefaultNull).Run())
+	var doc json.RawMessage
+	s.NoError(s.mmapTbl.ReadJSON(1, 3, &doc).Run())
+	s.JSONEq(`{"pk1":1,"pk2":3,"ck1":0,"ck2":0,"name":"Jack"}`, string(doc))
+}
+
+func (s *MockSuite) TestTaggedFieldsJSON() {
+	type customer struct {
+		CustomerID string    `cql:"customer_id"`
+		FullName   string    `cql:"FullName"`
+		Created    time.Time `cql:"created"`
+		Avatar     []byte    `cql:"avatar"`
+	}
+	tbl := s.ks.MapTable("customers", "customer_id", customer{})
+	created := time.Date(2020, 1, 2, 3, 4, 5, 6e6, time.UTC)
+	s.NoError(tbl.Set(customer{CustomerID: "1", FullName: "Jane", Created: created, Avatar: []byte{0xca, 0xfe}}).Run())
+
+	// Columns are named after the cql tags, and lowercased as Cassandra does
+	var doc json.RawMessage
+	s.NoError(tbl.ReadJSON("1", &doc).Run())
+	s.JSONEq(`{"customer_id":"1","fullname":"Jane","created":"2020-01-02 03:04:05.006Z","avatar":"0xcafe"}`, string(doc))
+	s.NoError(tbl.WithOptions(Options{Select: []string{"customer_id", "FullName"}}).ReadJSON("1", &doc).Run())
+	s.JSONEq(`{"customer_id":"1","fullname":"Jane"}`, string(doc))
+
+	// Documents read back can be written as they are
+	s.NoError(tbl.SetJSON([]byte(`{"customer_id":"2","fullname":"Joe","created":"2020-01-02 03:04:05.006Z","avatar":"0xcafe"}`), JSONDefaultNull).Run())
+	var c customer
+	s.NoError(tbl.Read("2", &c).Run())
+	s.Equal(customer{CustomerID: "2", FullName: "Joe", Created: created, Avatar: []byte{0xca, 0xfe}}, c)
+	s.Error(tbl.SetJSON([]byte(`{"CustomerID":"3"}`), JSONDefaultNull).Run())
+}
+
+func (s *MockSuite) TestTimeSeriesJSON() {
+	s.NoError(s.tsTbl.SetJSON([]byte(`{"id":1,"time":"2015-01-01 00:00:30.000Z","user":"a"}`), JSONDefaultNull).Run())
+	s.NoError(s.tsTbl.SetJSON([]byte(`{"id":2,"time":"2015-01-01T00:01:30Z","user":"b"}`), JSONDefaultNull).Run())
+
+	var doc json.RawMessage
+	s.NoError(s.tsTbl.ReadJSON(s.parseTime("2015-01-01 00:00:30"), 1, &doc).Run())
+	s.JSONEq(`{"bucket":"2015-01-01 00:00:00.000Z","id":1,"time":"2015-01-01 00:00:30.000Z","user":"a","x":0,"y":0}`, string(doc))
+
+	var docs []json.RawMessage
+	s.NoError(s.tsTbl.ListJSON(s.parseTime("2015-01-01 00:00:00"), s.parseTime("2015-01-01 00:02:00"), &docs).Run())
+	s.Len(docs, 2)
+	var points []point
+	s.NoError(s.tsTbl.List(s.parseTime("2015-01-01 00:00:00"), s.parseTime("2015-01-01 00:02:00"), &points).Run())
+	s.Len(points, 2)
+
+	s.NoError(s.mtsTbl.SetJSON([]byte(`{"id":3,"time":"2015-01-01 00:00:30.000Z","user":"c"}`), JSONDefaultNull).Run())
+	s.NoError(s.mtsTbl.ReadJSON("c", s.parseTime("2015-01-01 00:00:30"), 3, &doc).Run())
+	s.NoError(s.mtsTbl.ListJSON("c", s.parseTime("2015-01-01 00:00:00"), s.parseTime("2015-01-01 00:02:00"), &docs).Run())
+	s.Len(docs, 1)
+
+	s.NoError(s.mkTsTbl.SetJSON([]byte(`{"id":4,"time":"2015-01-01 00:00:30.000Z","x":1,"y":2}`), JSONDefaultNull).Run())
+	xy := map[string]interface{}{"X": 1.0, "Y": 2.0}
+	s.NoError(s.mkTsTbl.ReadJSON(xy, s.parseTime("2015-01-01 00:00:30"), map[string]interface{}{"Id": 4}, &doc).Run())
+	s.NoError(s.mkTsTbl.ListJSON(xy, s.parseTime("2015-01-01 00:00:00"), s.parseTime("2015-01-01 00:02:00"), &docs).Run())
+	s.Len(docs, 1)
+
+	s.Error(s.tsTbl.SetJSON([]byte(`{"id":5}`), JSONDefaultNull).Run())
+}
+
+func (s *MockSuite) TestFlakeSeriesJSON() {
+	type event struct {
+		Id   string
+		Body string
+	}
+	tbl := s.ks.ShardedFlakeSeriesTable("events", "Id", 3, FixedBuckets(time.Minute), event{})
+	id := timeToFlake(s.T(), "2006 Jan 2 15:04:00")
+	s.NoError(tbl.SetJSON([]byte(`{"id":"`+id+`","body":"hi"}`), JSONDefaultNull).Run())
+
+	var e event
+	s.NoError(tbl.Read(id, &e).Run())
+	s.Equal(event{Id: id, Body: "hi"}, e)
+	var doc json.RawMessage
+	s.NoError(tbl.ReadJSON(id, &doc).Run())
+	s.Contains(string(doc), `"flake_created":"2006-01-02 15:04:00.000Z"`)
+	var docs []json.RawMessage
+	s.NoError(tbl.ListJSON(s.parseTime("2006-01-02 15:00:00"), s.parseTime("2006-01-02 16:00:00"), &docs).Run())
+	s.Len(docs, 1)
+
+	multi := s.ks.MultiFlakeSeriesTable("user_events", "Body", "Id", time.Minute, event{})
+	s.NoError(multi.SetJSON([]byte(`{"id":"`+id+`","body":"hi"}`), JSONDefaultNull).Run())
+	s.NoError(multi.ReadJSON("hi", id, &doc).Run())
+	s.NoError(multi.ListJSON("hi", s.parseTime("2006-01-02 15:00:00"), s.parseTime("2006-01-02 16:00:00"), &docs).Run())
+	s.Len(docs, 1)
+}
+
+func (s *MockSuite) TestMultimapMkTableJSON() {
+	s.NoError(s.mmMkTable.SetJSON([]byte(`{"id":"1","townid":"2","county":"a","time":"2015-01-01 00:00:00.000Z"}`), JSONDefaultNull).Run())
+	field := map[string]interface{}{"Id": "1", "TownID": "2"}
+
+	var doc json.RawMessage
+	s.NoError(s.mmMkTable.ReadJSON(field, map[string]interface{}{"County": "a"}, &doc).Run())
+	s.Contains(string(doc), `"time":"2015-01-01 00:00:00.000Z"`)
+	var docs []json.RawMessage
+	s.NoError(s.mmMkTable.ListJSON(field, nil, 0, &docs).Run())
+	s.Len(docs, 1)
+}
+
 func (s *MockSuite) TestMultiMapTableUpdate() {
 	s.insertUsers()
 
@@ -374,6 +596,112 @@ func (s *MockSuite) TestMultiMapTableDeleteAll() {
 	s.Empty(users)
 }
 
+func (s *MockSuite) TestMultiMapTableDeleteRange() {
+	s.insertUsers()
+	s.NoError(s.mmapTbl.DeleteRange(1, 1, 2).Run())
+
+	var users []user
+	s.NoError(s.mmapTbl.List(1, nil, 0, &users).Run())
+	s.Len(users, 1)
+	s.Equal("Joe", users[0].Name)
+
+	s.NoError(s.mmapTbl.List(2, nil, 0, &users).Run())
+	s.Len(users, 1)
+}
+
+func (s *MockSuite) TestMultiMapTableDeleteOpenRange() {
+	for i := 1; i <= 5; i++ {
+		s.NoError(s.mmapTbl.Set(user{Pk1: 1, Pk2: i, Name: fmt.Sprintf("User %d", i)}).Run())
+	}
+	s.NoError(s.mmapTbl.DeleteRange(1, nil, 2).Run())
+	s.NoError(s.mmapTbl.DeleteRange(1, 5, nil).Run())
+
+	var users []user
+	s.NoError(s.mmapTbl.List(1, nil, 0, &users).Run())
+	s.Len(users, 3)
+	for i, u := range users {
+		s.Equal(i+2, u.Pk2)
+	}
+}
+
+func (s *MockSuite) TestMultiMapTableListRange() {
+	for i := 1; i <= 5; i++ {
+		s.NoError(s.mmapTbl.Set(user{Pk1: 1, Pk2: i, Name: fmt.Sprintf("User %d", i)}).Run())
+	}
+	pk2s := func(users []user) []int {
+		result := []int{}
+		for _, u := range users {
+			result = append(result, u.Pk2)
+		}
+		return result
+	}
+
+	var users []user
+	s.NoError(s.mmapTbl.ListRange(1, ClusteringRange{From: 2, To: 4}, 0, &users).Run())
+	s.Equal([]int{3}, pk2s(users))
+
+	s.NoError(s.mmapTbl.ListRange(1, ClusteringRange{From: 2, FromInclusive: true, To: 4, ToInclusive: true}, 0, &users).Run())
+	s.Equal([]int{2, 3, 4}, pk2s(users))
+
+	s.NoError(s.mmapTbl.ListRange(1, ClusteringRange{To: 4, Direction: DESC}, 2, &users).Run())
+	s.Equal([]int{3, 2}, pk2s(users))
+
+	// Tables clustered in descending order can still be listed in ascending order
+	desc := s.ks.MultimapTable("users_desc", "Pk1", "Pk2", user{}).
+		WithOptions(Options{ClusteringOrder: []ClusteringOrderColumn{{Column: "Pk2", Direction: DESC}}})
+	for i := 1; i <= 3; i++ {
+		s.NoError(desc.Set(user{Pk1: 1, Pk2: i}).Run())
+	}
+	s.NoError(desc.List(1, nil, 0, &users).Run())
+	s.Equal([]int{3, 2, 1}, pk2s(users))
+	s.NoError(desc.ListRange(1, ClusteringRange{}, 0, &users).Run())
+	s.Equal([]int{1, 2, 3}, pk2s(users))
+}
+
+func (s *MockSuite) TestMultimapMkTableListRange() {
+	tbl := s.ks.MultimapMultiKeyTable("users_by_ck", []string{"Pk1"}, []string{"Ck1", "Ck2"}, user{}).
+		WithOptions(Options{ClusteringOrder: []ClusteringOrderColumn{
+			{Column: "Ck1", Direction: DESC},
+			{Column: "Ck2", Direction: ASC},
+		}})
+	for ck1 := 1; ck1 <= 2; ck1++ {
+		for ck2 := 1; ck2 <= 3; ck2++ {
+			s.NoError(tbl.Set(user{Pk1: 1, Ck1: ck1, Ck2: ck2}).Run())
+		}
+	}
+	cks := func(users []user) [][2]int {
+		result := [][2]int{}
+		for _, u := range users {
+			result = append(result, [2]int{u.Ck1, u.Ck2})
+		}
+		return result
+	}
+	pk := map[string]interface{}{"Pk1": 1}
+
+	var users []user
+	s.NoError(tbl.ListRange(pk, ClusteringRange{
+		From:          map[string]interface{}{"Ck1": 2, "Ck2": 1},
+		FromInclusive: true,
+		To:            map[string]interface{}{"Ck1": 2, "Ck2": 3},
+	}, 0, &users).Run())
+	s.Equal([][2]int{{2, 1}, {2, 2}}, cks(users))
+
+	s.NoError(tbl.ListRange(pk, ClusteringRange{
+		From:      map[string]interface{}{"Ck1": 2, "Ck2": 1},
+		Direction: DESC,
+	}, 0, &users).Run())
+	s.Equal([][2]int{{2, 3}, {2, 2}}, cks(users))
+
+	// Ordering by Ck1 alone reverses the whole clustering order of the table
+	s.NoError(tbl.ListRange(pk, ClusteringRange{To: map[string]interface{}{"Ck1": 2}}, 0, &users).Run())
+	s.Equal([][2]int{{1, 3}, {1, 2}, {1, 1}}, cks(users))
+
+	s.Error(tbl.ListRange(pk, ClusteringRange{
+		From: map[string]interface{}{"Ck1": 1, "Ck2": 2},
+		To:   map[string]interface{}{"Ck1": 2, "Ck2": 1},
+	}, 0, &users).Run())
+}
+
 // TimeSeriesTable tests
 func (s *MockSuite) TestTimeSeriesTableRead() {
 	points := s.insertPoints()
@@ -438,7 +766,811 @@ func (s *MockSuite) TestTimeSeriesTableDelete() {
 	s.Equal(RowNotFoundError{}, s.tsTbl.Read(points[0].Time, points[0].Id, &p).Run())
 }
 
+func (s *MockSuite) TestTimeSeriesTableDeleteRange() {
+	points := s.insertPoints()
+
+	// The range starts in an empty bucket
+	s.NoError(s.tsTbl.DeleteRange(points[0].Time.Add(-2*time.Minute), points[1].Time).Run())
+
+	var ps []point
+	s.NoError(s.tsTbl.List(points[0].Time, points[2].Time, &ps).Run())
+	s.Len(ps, 1)
+	s.Equal(points[2], ps[0])
+}
+
+func (s *MockSuite) TestTimeSeriesTableWalkBuckets() {
+	base := time.Now().Truncate(time.Minute).Add(-10 * time.Minute)
+	points := []point{
+		{Time: base.Add(10 * time.Second), Id: 1},
+		{Time: base.Add(20 * time.Second), Id: 2},
+		{Time: base.Add(3 * time.Minute), Id: 3},
+		{Time: base.Add(5*time.Minute + 30*time.Second), Id: 4},
+	}
+	for _, p := range points {
+		s.NoError(s.tsTbl.Set(p).Run())
+	}
+	ids := func(ps []point) []int {
+		result := []int{}
+		for _, p := range ps {
+			result = append(result, p.Id)
+		}
+		return result
+	}
+
+	var ps []point
+	s.NoError(s.tsTbl.Latest(3, &ps).Run())
+	s.Equal([]int{4, 3, 2}, ids(ps))
+
+	s.NoError(s.tsTbl.ListBefore(points[3].Time, 10, &ps).Run())
+	s.Equal([]int{3, 2, 1}, ids(ps))
+
+	s.NoError(s.tsTbl.ListAfter(points[0].Time, 2, &ps).Run())
+	s.Equal([]int{2, 3}, ids(ps))
+
+	s.NoError(s.tsTbl.ListAfter(base.Add(-time.Minute), 10, &ps).Run())
+	s.Equal([]int{1, 2, 3, 4}, ids(ps))
+
+	// Walking stops after MaxBuckets buckets
+	s.NoError(s.tsTbl.ListBefore(base.Add(6*time.Minute), 10, &ps).
+		WithOptions(Options{MaxBuckets: 3}).Run())
+	s.Equal([]int{4}, ids(ps))
+
+	// Including when set on the table
+	s.NoError(s.tsTbl.WithOptions(Options{MaxBuckets: 3}).ListBefore(base.Add(6*time.Minute), 10, &ps).Run())
+	s.Equal([]int{4}, ids(ps))
+
+	s.Error(s.tsTbl.Latest(1, ps).Run())
+}
+
+func (s *MockSuite) TestTimeSeriesTableListFanOut() {
+	base := s.parseTime("2015-04-01 15:00:00")
+	for i := 1; i <= 6; i++ {
+		p := point{Time: base.Add(time.Duration(i*50) * time.Second), Id: i, User: "John"}
+		s.NoError(s.tsTbl.Set(p).Run())
+		s.NoError(s.mtsTbl.Set(p).Run())
+	}
+	ids := func(ps []point) []int {
+		result := []int{}
+		for _, p := range ps {
+			result = append(result, p.Id)
+		}
+		return result
+	}
+
+	var ps []point
+	s.NoError(s.tsTbl.List(base, base.Add(10*time.Minute), &ps).
+		WithOptions(Options{BucketConcurrency: 2}).Run())
+	s.Equal([]int{1, 2, 3, 4, 5, 6}, ids(ps))
+
+	// Limit applies to the merged results rather than each bucket
+	s.NoError(s.tsTbl.WithOptions(Options{BucketConcurrency: 3, Limit: 4}).
+		List(base, base.Add(10*time.Minute), &ps).Run())
+	s.Equal([]int{1, 2, 3, 4}, ids(ps))
+
+	s.NoError(s.mtsTbl.List("John", base.Add(2*time.Minute), base.Add(10*time.Minute), &ps).
+		WithOptions(Options{BucketConcurrency: 1, Limit: 2}).Run())
+	s.Equal([]int{3, 4}, ids(ps))
+
+	// Tables clustered in descending order are merged latest bucket first
+	desc := s.ks.TimeSeriesTable("points_desc", "Time", "Id", time.Minute, point{}).
+		WithOptions(Options{ClusteringOrder: []ClusteringOrderColumn{{Column: "Time", Direction: DESC}}})
+	for i := 1; i <= 3; i++ {
+		s.NoError(desc.Set(point{Time: base.Add(time.Duration(i*50) * time.Second), Id: i}).Run())
+	}
+	s.NoError(desc.WithOptions(Options{BucketConcurrency: 2}).
+		List(base, base.Add(10*time.Minute), &ps).Run())
+	s.Equal([]int{3, 2, 1}, ids(ps))
+}
+
+func (s *MockSuite) TestTimeSeriesTableListInMultiOp() {
+	base := s.parseTime("2015-04-01 15:00:00")
+	s.NoError(s.tsTbl.Set(point{Time: base.Add(10 * time.Second), Id: 1}).Run())
+	s.NoError(s.tsTbl.Set(point{Time: base.Add(2 * time.Minute), Id: 2}).Run())
+
+	var ps, latest []point
+	op := s.mapTbl.Set(user{Pk1: 1, Name: "Joe"}).
+		Add(s.tsTbl.List(base, base.Add(5*time.Minute), &ps))
+	s.NoError(op.Run())
+	s.Len(ps, 2)
+
+	op = s.tsTbl.List(base, base.Add(time.Minute), &ps).
+		Add(s.tsTbl.ListBefore(base.Add(5*time.Minute), 1, &latest), s.mapTbl.Set(user{Pk1: 2, Name: "Jane"}))
+	s.NoError(op.RunAtomically())
+	s.Len(ps, 1)
+	s.Equal([]point{{Time: base.Add(2 * time.Minute), Id: 2}}, latest)
+
+	var u user
+	s.NoError(s.mapTbl.Read(2, &u).Run())
+	s.Equal("Jane", u.Name)
+}
+
+func (s *MockSuite) TestShardedTimeSeriesTable() {
+	tbl := s.ks.ShardedTimeSeriesTable("points", "Time", "Id", 4, FixedBuckets(time.Minute), point{})
+	base := s.parseTime("2015-04-01 15:00:00")
+	shards := map[int]bool{}
+	for i := 1; i <= 8; i++ {
+		p := point{Time: base.Add(time.Duration(i*20) * time.Second), Id: i, User: "John"}
+		s.NoError(tbl.Set(p).Run())
+		shards[shardOf(i, 4)] = true
+	}
+	s.True(len(shards) > 1)
+	ids := func(ps []point) []int {
+		result := []int{}
+		for _, p := range ps {
+			result = append(result, p.Id)
+		}
+		return result
+	}
+
+	var p point
+	s.NoError(tbl.Read(base.Add(60*time.Second), 3, &p).Run())
+	s.Equal(3, p.Id)
+
+	s.NoError(tbl.Update(base.Add(60*time.Second), 3, map[string]interface{}{"X": 4.2}).Run())
+	s.NoError(tbl.Read(base.Add(60*time.Second), 3, &p).Run())
+	s.Equal(4.2, p.X)
+
+	// Rows are merged in time order across shards, and the limit applies to the merged rows
+	var ps []point
+	s.NoError(tbl.List(base, base.Add(10*time.Minute), &ps).Run())
+	s.Equal([]int{1, 2, 3, 4, 5, 6, 7, 8}, ids(ps))
+
+	s.NoError(tbl.WithOptions(Options{Limit: 3}).List(base, base.Add(10*time.Minute), &ps).Run())
+	s.Equal([]int{1, 2, 3}, ids(ps))
+
+	s.NoError(tbl.ListBefore(base.Add(10*time.Minute), 2, &ps).Run())
+	s.Equal([]int{8, 7}, ids(ps))
+
+	s.NoError(tbl.Buckets(base).Filter().Read(&ps).Run())
+	s.Len(ps, 2)
+
+	s.NoError(tbl.Delete(base.Add(60*time.Second), 3).Run())
+	s.Equal(RowNotFoundError{}, tbl.Read(base.Add(60*time.Second), 3, &p).Run())
+
+	s.NoError(tbl.DeleteRange(base, base.Add(90*time.Second)).Run())
+	s.NoError(tbl.List(base, base.Add(10*time.Minute), &ps).Run())
+	s.Equal([]int{5, 6, 7, 8}, ids(ps))
+}
+
+func (s *MockSuite) TestShardedTimeSeriesTableEqualTimes() {
+	tbl := s.ks.ShardedTimeSeriesTable("points", "Time", "Id", 4, FixedBuckets(time.Minute), point{})
+	base := s.parseTime("2015-04-01 15:00:00")
+	shards := map[int]bool{}
+	for i := 8; i >= 1; i-- {
+		s.NoError(tbl.Set(point{Time: base.Add(time.Second), Id: i, User: "John"}).Run())
+		shards[shardOf(i, 4)] = true
+	}
+	s.True(len(shards) > 1)
+	ids := func(ps []point) []int {
+		result := []int{}
+		for _, p := range ps {
+			result = append(result, p.Id)
+		}
+		return result
+	}
+
+	// Rows with the same time are merged in the order of their ids, reversed
+	// along with the time
+	var ps []point
+	s.NoError(tbl.List(base, base.Add(time.Minute), &ps).Run())
+	s.Equal([]int{1, 2, 3, 4, 5, 6, 7, 8}, ids(ps))
+
+	s.NoError(tbl.WithOptions(Options{Limit: 3}).List(base, base.Add(time.Minute), &ps).Run())
+	s.Equal([]int{1, 2, 3}, ids(ps))
+
+	s.NoError(tbl.ListBefore(base.Add(time.Minute), 3, &ps).Run())
+	s.Equal([]int{8, 7, 6}, ids(ps))
+
+	s.NoError(tbl.ListAfter(base, 3, &ps).Run())
+	s.Equal([]int{1, 2, 3}, ids(ps))
+
+	// With the ids clustered in descending order
+	desc := s.ks.ShardedTimeSeriesTable("points_desc", "Time", "Id", 4, FixedBuckets(time.Minute), point{}).
+		WithOptions(Options{ClusteringOrder: []ClusteringOrderColumn{{Column: "Time", Direction: ASC}, {Column: "Id", Direction: DESC}}})
+	for i := 1; i <= 8; i++ {
+		s.NoError(desc.Set(point{Time: base.Add(time.Second), Id: i, User: "John"}).Run())
+	}
+	s.NoError(desc.List(base, base.Add(time.Minute), &ps).Run())
+	s.Equal([]int{8, 7, 6, 5, 4, 3, 2, 1}, ids(ps))
+
+	s.NoError(desc.ListBefore(base.Add(time.Minute), 3, &ps).Run())
+	s.Equal([]int{1, 2, 3}, ids(ps))
+}
+
+func (s *MockSuite) TestShardedFlakeSeriesListSince() {
+	type event struct {
+		Id   string
+		Body string
+	}
+	tbl := s.ks.ShardedFlakeSeriesTable("events", "Id", 3, FixedBuckets(time.Minute), event{})
+
+	ids := []string{}
+	for _, ts := range []string{"2006 Jan 2 15:03:59", "2006 Jan 2 15:04:00", "2006 Jan 2 15:04:01", "2006 Jan 2 15:05:01"} {
+		id := timeToFlake(s.T(), ts)
+		ids = append(ids, id)
+		s.NoError(tbl.Set(event{Id: id}).Run())
+	}
+
+	var e event
+	s.NoError(tbl.Read(ids[1], &e).Run())
+	s.Equal(ids[1], e.Id)
+
+	var es []event
+	s.NoError(tbl.ListSince(ids[0], 2*time.Minute, &es).Run())
+	s.Len(es, 3)
+	for i, e := range es {
+		s.Equal(ids[i+1], e.Id)
+	}
+}
+
+func (s *MockSuite) TestFlakeSeriesListSinceWithIDs() {
+	type event struct {
+		Id   string
+		Body string
+	}
+	ids := SnowflakeIDs(TwitterSnowflakeEpoch)
+	tbl := s.ks.FlakeSeriesTableWithIDs("events", "Id", FixedBuckets(time.Minute), ids, event{})
+
+	base := s.parseTime("2015-04-01 15:00:00")
+	snowflake := func(t time.Time, seq uint64) string {
+		ms := uint64(t.Sub(TwitterSnowflakeEpoch) / time.Millisecond)
+		return strconv.FormatUint(ms<<22|seq, 10)
+	}
+	// Three ids share a millisecond, so they can only be told apart by their sequence
+	since := snowflake(base, 3)
+	for _, id := range []string{
+		snowflake(base, 1), snowflake(base, 2), since, snowflake(base, 4),
+		snowflake(base.Add(time.Second), 1), snowflake(base.Add(2*time.Minute), 1),
+	} {
+		s.NoError(tbl.Set(event{Id: id}).Run())
+	}
+
+	var e event
+	s.NoError(tbl.Read(since, &e).Run())
+	s.Equal(since, e.Id)
+
+	var es []event
+	s.NoError(tbl.ListSince(since, time.Minute, &es).Run())
+	s.Equal([]event{{Id: snowflake(base, 4)}, {Id: snowflake(base.Add(time.Second), 1)}}, es)
+
+	// The limit counts the rows after the id, not the ones dropped
+	s.NoError(tbl.WithOptions(Options{Limit: 2}).ListSince(snowflake(base, 4), 5*time.Minute, &es).Run())
+	s.Equal([]event{{Id: snowflake(base.Add(time.Second), 1)}, {Id: snowflake(base.Add(2*time.Minute), 1)}}, es)
+
+	s.Error(tbl.ListSince("id_1", time.Minute, &es).Run())
+}
+
+func (s *MockSuite) TestShardedFlakeSeriesWithIDs() {
+	type event struct {
+		Id   string
+		Body string
+	}
+	ids := SnowflakeIDs(TwitterSnowflakeEpoch)
+	tbl := s.ks.ShardedFlakeSeriesTableWithIDs("events", "Id", 3, FixedBuckets(time.Minute), ids, event{})
+
+	base := s.parseTime("2015-04-01 15:00:00")
+	snowflake := func(t time.Time, seq uint64) string {
+		ms := uint64(t.Sub(TwitterSnowflakeEpoch) / time.Millisecond)
+		return strconv.FormatUint(ms<<22|seq, 10)
+	}
+	written := []string{}
+	for i := 0; i < 6; i++ {
+		id := snowflake(base.Add(time.Duration(i*20)*time.Second), 1)
+		written = append(written, id)
+		s.NoError(tbl.Set(event{Id: id}).Run())
+	}
+
+	var e event
+	s.NoError(tbl.Read(written[2], &e).Run())
+	s.Equal(written[2], e.Id)
+
+	var es []event
+	s.NoError(tbl.List(base, base.Add(2*time.Minute), &es).Run())
+	s.Len(es, 6)
+	for i, e := range es {
+		s.Equal(written[i], e.Id)
+	}
+
+	s.NoError(tbl.ListSince(written[3], time.Minute, &es).Run())
+	s.Equal([]event{{Id: written[4]}, {Id: written[5]}}, es)
+}
+
+func (s *MockSuite) TestTimeSeriesTableFollow() {
+	base := s.parseTime("2015-04-01 15:00:00")
+	clock := NewManualClock(base.Add(5 * time.Minute))
+	store := &MemoryCheckpoints{}
+	// A page size of one makes rows sharing a time span pages
+	tbl := s.tsTbl.WithOptions(Options{
+		Clock:           clock,
+		Checkpoints:     store,
+		Limit:           1,
+		PollInterval:    time.Hour,
+		MaxPollInterval: 2 * time.Hour,
+	})
+	for i, d := range []time.Duration{10 * time.Second, 20 * time.Second, 20 * time.Second, 70 * time.Second, 200 * time.Second} {
+		s.NoError(tbl.Set(point{Time: base.Add(d), Id: i + 1}).Run())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	seen := make(chan int, 10)
+	done := make(chan error)
+	go func() {
+		done <- tbl.Follow(ctx, base, func(ctx context.Context, row interface{}) error {
+			seen <- row.(point).Id
+			return nil
+		})
+	}()
+	next := func() int {
+		select {
+		case id := <-seen:
+			return id
+		case <-time.After(time.Second):
+			s.FailNow("timed out following")
+			return 0
+		}
+	}
+	for i := 1; i <= 5; i++ {
+		s.Equal(i, next())
+	}
+
+	// New rows are picked up, in the current bucket and once time moves on.
+	// Polls wait for the clock, so it's moved on by up to MaxPollInterval once
+	// Follow is waiting
+	poll := func(d time.Duration) {
+		clock.BlockUntilTimers(1)
+		clock.Add(d)
+	}
+	s.NoError(tbl.Set(point{Time: clock.Now(), Id: 6}).Run())
+	poll(2 * time.Hour)
+	s.Equal(6, next())
+	s.NoError(tbl.Set(point{Time: clock.Now().Add(2 * time.Hour), Id: 7}).Run())
+	poll(2 * time.Hour)
+	s.Equal(7, next())
+	cancel()
+	s.Equal(context.Canceled, <-done)
+
+	// Following again carries on from the checkpoint
+	cp, ok, err := store.Load(context.Background())
+	s.NoError(err)
+	s.True(ok)
+	s.Equal([]string{"7"}, cp.IDs)
+	s.NoError(tbl.Set(point{Time: clock.Now(), Id: 8}).Run())
+	stop := fmt.Errorf("stop")
+	s.Equal(stop, tbl.Follow(context.Background(), base, func(ctx context.Context, row interface{}) error {
+		s.Equal(8, row.(point).Id)
+		return stop
+	}))
+}
+
+func (s *MockSuite) TestFlakeSeriesTableFollow() {
+	type event struct {
+		Id   string
+		Body string
+	}
+	base := s.parseTime("2015-04-01 15:00:00")
+	clock := NewManualClock(base)
+	gen, err := NewIDGenerator("event", 1, clock)
+	s.NoError(err)
+	checkpoints := CheckpointTable(s.ks, "checkpoints")
+	tbl := s.ks.FlakeSeriesTable("events", "Id", time.Minute, event{}).WithOptions(Options{
+		Clock:        clock,
+		Checkpoints:  MapTableCheckpoints(checkpoints, "test"),
+		PollInterval: time.Millisecond,
+	})
+
+	ids := []string{}
+	for _, d := range []time.Duration{0, 0, time.Second, 3 * time.Minute} {
+		clock.Add(d)
+		id, err := gen.NewID()
+		s.NoError(err)
+		ids = append(ids, id)
+		s.NoError(tbl.Set(event{Id: id}).Run())
+	}
+
+	handled := []string{}
+	follow := func(from string, n int) error {
+		handled = []string{}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		return tbl.Follow(ctx, from, func(ctx context.Context, row interface{}) error {
+			handled = append(handled, row.(event).Id)
+			if len(handled) == n {
+				cancel()
+			}
+			return nil
+		})
+	}
+
+	s.Equal(context.Canceled, follow(ids[0], 2))
+	s.Equal(ids[1:3], handled)
+	// The checkpoint takes precedence over the id to follow from
+	s.Equal(context.Canceled, follow(ids[0], 1))
+	s.Equal(ids[3:], handled)
+
+	s.Error(follow("id", 1))
+}
+
+func (s *MockSuite) TestEntityTable() {
+	type order struct {
+		Id      string
+		Owner   string
+		Created time.Time
+		Total   int
+		Attrs   map[string]string
+	}
+	tbl := s.ks.EntityTable("orders", "Id", order{},
+		MultimapView("Owner"),
+		TimeSeriesView("Created", FixedBuckets(time.Hour)))
+	s.NoError(tbl.CreateIfNotExist())
+	byOwner, byTime := tbl.Multimap("Owner"), tbl.TimeSeries("Created")
+	s.Nil(tbl.Multimap("Total"))
+
+	created := s.parseTime("2015-04-01 15:41:00")
+	o := order{Id: "o1", Owner: "alice", Created: created, Total: 10, Attrs: map[string]string{}}
+	s.NoError(tbl.Set(o).RunLoggedBatchWithContext(context.Background()))
+
+	var read order
+	var orders []order
+	s.NoError(tbl.Read("o1", &read).Run())
+	s.Equal(o, read)
+	s.NoError(byOwner.List("alice", nil, 0, &orders).Run())
+	s.Equal([]order{o}, orders)
+	s.NoError(byTime.List(created, created, &orders).Run())
+	s.Equal([]order{o}, orders)
+
+	// Changing an indexed field moves the row in that view only
+	s.NoError(tbl.Update("o1", map[string]interface{}{"Owner": "bob", "Total": 20}).Run())
+	o.Owner, o.Total = "bob", 20
+	s.NoError(byOwner.List("alice", nil, 0, &orders).Run())
+	s.Empty(orders)
+	s.NoError(byOwner.List("bob", nil, 0, &orders).Run())
+	s.Equal([]order{o}, orders)
+	s.NoError(byTime.List(created, created, &orders).Run())
+	s.Equal([]order{o}, orders)
+
+	o.Created = created.Add(2 * time.Hour)
+	s.NoError(tbl.Set(o).Run())
+	s.NoError(byTime.List(created, created, &orders).Run())
+	s.Empty(orders)
+	s.NoError(byTime.List(o.Created, o.Created, &orders).Run())
+	s.Equal([]order{o}, orders)
+
+	s.NoError(tbl.Update("o1", map[string]interface{}{"Attrs": MapSetField("gift", "yes")}).Run())
+	s.NoError(byOwner.Read("bob", "o1", &read).Run())
+	s.Equal(map[string]string{"gift": "yes"}, read.Attrs)
+	s.Error(tbl.Update("o1", map[string]interface{}{"Owner": "carol", "Attrs": MapSetField("gift", "no")}).Run())
+	s.Error(tbl.Update("o1", map[string]interface{}{"Id": "o2"}).Run())
+	s.Equal(RowNotFoundError{}, tbl.Update("o2", map[string]interface{}{"Total": 1}).Run())
+
+	s.NoError(tbl.Delete("o1").Run())
+	s.Equal(RowNotFoundError{}, tbl.Read("o1", &read).Run())
+	s.NoError(byOwner.List("bob", nil, 0, &orders).Run())
+	s.Empty(orders)
+	s.NoError(byTime.List(o.Created, o.Created, &orders).Run())
+	s.Empty(orders)
+
+	// Writes can be batched with the ops of other tables
+	o = order{Id: "o3", Owner: "carol", Created: created, Attrs: map[string]string{}}
+	s.NoError(s.mapTbl.Set(user{Pk1: 1, Name: "Carol"}).Add(tbl.Set(o)).RunAtomically())
+	s.NoError(byOwner.List("carol", nil, 0, &orders).Run())
+	s.Equal([]order{o}, orders)
+	var u user
+	s.NoError(s.mapTbl.Read(1, &u).Run())
+	s.Equal("Carol", u.Name)
+}
+
+func (s *MockSuite) TestEntityTableEmptyKeyFields() {
+	type order struct {
+		Id      string
+		Owner   string
+		Created time.Time `cql:",omitempty"`
+		Total   int
+	}
+	tbl := s.ks.EntityTable("orders_omit", "Id", order{},
+		MultimapView("Owner"),
+		TimeSeriesView("Created", FixedBuckets(time.Hour)))
+	byOwner, byTime := tbl.Multimap("Owner"), tbl.TimeSeries("Created")
+
+	// Views are written with the fields left out of the row when empty,
+	o := order{Id: "o1", Owner: "alice", Total: 10}
+	s.NoError(tbl.Set(o).Run())
+	var orders []order
+	s.NoError(byOwner.List("alice", nil, 0, &orders).Run())
+	s.Equal([]order{o}, orders)
+
+	// and by the values stored when a row is set again without them
+	created := s.parseTime("2015-04-01 15:41:00")
+	s.NoError(tbl.Update("o1", map[string]interface{}{"Created": created}).Run())
+	s.NoError(tbl.Set(order{Id: "o1", Owner: "alice", Total: 20}).Run())
+	o = order{Id: "o1", Owner: "alice", Created: created, Total: 20}
+	var read order
+	s.NoError(tbl.Read("o1", &read).Run())
+	s.Equal(o, read)
+	s.NoError(byOwner.List("alice", nil, 0, &orders).Run())
+	s.Equal([]order{o}, orders)
+	s.NoError(byTime.List(created, created, &orders).Run())
+	s.Equal([]order{o}, orders)
+
+	// Rows whose view key fields are missing or of the wrong type are rejected
+	s.Error(tbl.Set(map[string]interface{}{"Id": "o2", "Owner": "bob", "Total": 1}).Run())
+	s.Error(tbl.Set(map[string]interface{}{"Id": "o2", "Owner": "bob", "Created": "today"}).Run())
+	s.Error(tbl.Update("o1", map[string]interface{}{"Created": "today"}).Run())
+	s.NoError(tbl.Read("o1", &read).Run())
+	s.Equal(o, read)
+}
+
+func (s *MockSuite) TestWriteHooks() {
+	var events []WriteEvent
+	s.ks.AddWriteHook(func(ctx context.Context, e WriteEvent) error {
+		events = append(events, e)
+		return nil
+	})
+
+	s.NoError(s.mapTbl.Set(user{Pk1: 1, Name: "Jane"}).Run())
+	s.NoError(s.mapTbl.Update(1, map[string]interface{}{"Name": "Joan"}).Run())
+	s.NoError(s.mapTbl.Delete(1).Run())
+
+	s.Len(events, 3)
+	s.Equal(SetWrite, events[0].Type)
+	s.Equal("users_map_Pk1", events[0].Table)
+	s.Equal(map[string]interface{}{"Pk1": 1}, events[0].Keys)
+	s.Equal("Jane", events[0].Values["Name"])
+	s.Equal(UpdateWrite, events[1].Type)
+	s.Equal(map[string]interface{}{"Pk1": 1}, events[1].Keys)
+	s.Equal(map[string]interface{}{"Name": "Joan"}, events[1].Values)
+	s.Equal(DeleteWrite, events[2].Type)
+	s.Equal(map[string]interface{}{"Pk1": 1}, events[2].Keys)
+	s.Nil(events[2].Values)
+
+	// Hooks of a table are called on top of the ones of the keyspace, and
+	// their errors are returned
+	hookErr := errors.New("hook failed")
+	tbl := s.mapTbl.WithOptions(Options{WriteHooks: []WriteHook{
+		func(ctx context.Context, e WriteEvent) error { return hookErr },
+	}})
+	s.Equal(hookErr, tbl.Set(user{Pk1: 2, Name: "Jim"}).Run())
+	s.Len(events, 4)
+	var u user
+	s.NoError(s.mapTbl.Read(2, &u).Run())
+	s.Equal("Jim", u.Name)
+}
+
+func (s *MockSuite) TestWriteOutbox() {
+	clock := NewManualClock(s.parseTime("2015-04-01 15:41:00"))
+	outbox := OutboxTable(s.ks, "outbox", FixedBuckets(time.Hour))
+	s.NoError(outbox.CreateIfNotExist())
+	s.ks.SetOutbox(outbox)
+
+	tbl := s.mapTbl.WithOptions(Options{Clock: clock})
+	s.NoError(tbl.Set(user{Pk1: 1, Name: "Jane"}).Run())
+	clock.Add(time.Second)
+	s.NoError(tbl.Update(1, map[string]interface{}{"Name": "Joan"}).Add(tbl.Delete(2)).RunAtomically())
+
+	var events []OutboxEvent
+	start := s.parseTime("2015-04-01 15:00:00")
+	s.NoError(outbox.List(start, start.Add(time.Hour), &events).Run())
+	s.Len(events, 3)
+	s.Equal("set", events[0].Type)
+	s.Equal("users_map_Pk1", events[0].Table)
+	s.Equal(`{"Pk1":1}`, events[0].Keys)
+	s.Equal(`{"Ck1":0,"Ck2":0,"Name":"Jane","Pk1":1,"Pk2":0}`, events[0].Values)
+	s.Equal(s.parseTime("2015-04-01 15:41:00"), events[0].Created.UTC())
+	s.Equal("update", events[1].Type)
+	s.Equal(`{"Name":"Joan"}`, events[1].Values)
+	s.Equal("delete", events[2].Type)
+	s.Equal(`{"Pk1":2}`, events[2].Keys)
+	s.Equal("null", events[2].Values)
+
+	// A table can have an outbox of its own
+	other := OutboxTable(s.ks, "other_outbox", FixedBuckets(time.Hour))
+	s.NoError(tbl.WithOptions(Options{Outbox: other}).Delete(1).Run())
+	events = nil
+	s.NoError(other.List(start, start.Add(time.Hour), &events).Run())
+	s.Len(events, 1)
+}
+
 // MultiTimeSeriesTable tests
+func (s *MockSuite) TestMockTTL() {
+	clock := NewManualClock(s.parseTime("2015-04-01 15:41:00"))
+	ks := NewMockKeySpace(MockClock(clock))
+	tbl := ks.MapTable("users", "Pk1", user{})
+
+	s.NoError(tbl.Set(user{Pk1: 1, Name: "Jane"}).WithOptions(Options{TTL: time.Minute}).Run())
+	s.NoError(tbl.Set(user{Pk1: 2, Name: "Jim"}).Run())
+	s.NoError(tbl.Update(2, map[string]interface{}{"Name": "Jimmy"}).WithOptions(Options{TTL: time.Minute}).Run())
+	s.NoError(tbl.Update(3, map[string]interface{}{"Name": "Joan"}).WithOptions(Options{TTL: time.Hour}).Run())
+
+	var u user
+	s.NoError(tbl.Read(1, &u).Run())
+	s.Equal("Jane", u.Name)
+
+	clock.Add(time.Minute)
+	// The row set with a TTL has expired
+	s.IsType(RowNotFoundError{}, tbl.Read(1, &u).Run())
+	// The cell updated with a TTL has expired, but the row set without one
+	// hasn't
+	u = user{}
+	s.NoError(tbl.Read(2, &u).Run())
+	s.Equal(user{Pk1: 2}, u)
+	// A row only written by updates lives as long as its cells
+	s.NoError(tbl.Read(3, &u).Run())
+	s.Equal("Joan", u.Name)
+	clock.Add(time.Hour)
+	s.IsType(RowNotFoundError{}, tbl.Read(3, &u).Run())
+
+	// A newer write without a TTL replaces the TTL of a cell
+	s.NoError(tbl.Set(user{Pk1: 4, Name: "Jack"}).WithOptions(Options{TTL: time.Minute}).Run())
+	s.NoError(tbl.Update(4, map[string]interface{}{"Name": "Jake"}).Run())
+	clock.Add(time.Minute)
+	s.NoError(tbl.Read(4, &u).Run())
+	s.Equal("Jake", u.Name)
+}
+
+func (s *MockSuite) TestMockClock() {
+	clock := NewManualClock(s.parseTime("2015-04-01 15:41:00"))
+	ks := NewMockKeySpace(MockClock(clock))
+	tbl := ks.TimeSeriesTable("points", "Time", "Id", time.Minute, point{})
+	s.NoError(tbl.Set(point{Time: s.parseTime("2015-04-01 15:40:30"), Id: 1}).Run())
+	s.NoError(tbl.Set(point{Time: s.parseTime("2015-04-01 15:41:30"), Id: 2}).Run())
+
+	// Latest lists the rows before the time on the clock
+	var points []point
+	s.NoError(tbl.Latest(10, &points).Run())
+	s.Len(points, 1)
+	s.Equal(1, points[0].Id)
+
+	clock.Add(time.Minute)
+	s.NoError(tbl.Latest(10, &points).Run())
+	s.Len(points, 2)
+}
+
+func (s *MockSuite) TestStrictMock() {
+	keys := Keys{PartitionKeys: []string{"Pk1", "Pk2"}, ClusteringColumns: []string{"Ck1", "Ck2"}}
+	tbl := NewMockKeySpace(StrictMock()).Table("users", user{}, keys)
+	lenient := NewMockKeySpace().Table("users", user{}, keys)
+	s.NoError(tbl.(TableChanger).CreateIfNotExist())
+	s.NoError(tbl.Set(user{Pk1: 1, Pk2: 1, Ck1: 1, Ck2: 1, Name: "Jane"}).Run())
+
+	var users []user
+	invalid := []struct {
+		op      func(tbl Table) Op
+		message string
+	}{
+		{func(tbl Table) Op {
+			return tbl.Where(Eq("Pk1", 1), Eq("Pk2", 1), GT("Ck1", 0), Eq("Ck2", 1)).Read(&users)
+		}, `Clustering column "Ck2" cannot be restricted (preceding column "Ck1" is restricted by a non-EQ relation)`},
+		{func(tbl Table) Op {
+			return tbl.Where(Eq("Pk1", 1), Eq("Pk2", 1), GT("Ck1", 0), In("Ck2", 1, 2)).Read(&users)
+		}, `Clustering column "Ck2" cannot be restricted (preceding column "Ck1" is restricted by a non-EQ relation)`},
+		{func(tbl Table) Op {
+			return tbl.Where(Eq("Pk1", 1), Eq("Pk2", 1), Eq("Ck2", 1)).Read(&users)
+		}, `PRIMARY KEY column "Ck2" cannot be restricted as preceding column "Ck1" is not restricted`},
+		{func(tbl Table) Op {
+			return tbl.Where(Eq("Ck1", 1)).Read(&users)
+		}, filteringMessage},
+		{func(tbl Table) Op {
+			return tbl.Where(Eq("Pk1", 1)).Read(&users)
+		}, "Partition key parts: Pk2 must be restricted as other parts are"},
+		{func(tbl Table) Op {
+			return tbl.Where(Eq("Pk1", 1), Eq("Pk2", 1)).Read(&users).WithOptions(Options{
+				ClusteringOrder: []ClusteringOrderColumn{{Column: "Ck1", Direction: ASC}, {Column: "Ck2", Direction: DESC}},
+			})
+		}, "Unsupported order by relation"},
+		{func(tbl Table) Op {
+			return tbl.Where(Eq("Pk1", 1), Eq("Pk2", 1), Eq("Ck1", 1)).Update(map[string]interface{}{"Name": "Joan"})
+		}, "Some clustering keys are missing: Ck2"},
+		{func(tbl Table) Op {
+			return tbl.Where(Eq("Pk1", 1), Eq("Ck1", 1)).Delete()
+		}, "Some partition key parts are missing: Pk2"},
+	}
+	for _, c := range invalid {
+		err := c.op(tbl).Run()
+		s.IsType(InvalidRequestError{}, err)
+		s.EqualError(err, c.message)
+	}
+	// A mock which isn't strict answers them regardless
+	s.NoError(invalid[0].op(lenient).Run())
+	s.NoError(invalid[1].op(lenient).Run())
+
+	s.NoError(tbl.Where(Eq("Pk1", 1), Eq("Pk2", 1), LT("Ck1", 2)).Read(&users).WithOptions(Options{
+		ClusteringOrder: []ClusteringOrderColumn{{Column: "Ck1", Direction: DESC}, {Column: "Ck2", Direction: DESC}},
+	}).Run())
+	s.Len(users, 1)
+	// IN doesn't stop the columns after it being restricted, and columns
+	// which aren't part of the key may have secondary indexes
+	s.NoError(tbl.Where(Eq("Pk1", 1), Eq("Pk2", 1), In("Ck1", 1, 2), Eq("Ck2", 1)).Read(&users).Run())
+	s.Len(users, 1)
+	s.NoError(tbl.Where(Eq("Pk1", 1), Eq("Pk2", 1), Eq("Name", "Jane")).Read(&users).Run())
+	s.Len(users, 1)
+
+	// The order is checked against the clustering order the table was
+	// created with, once it's known, rather than the one of its options
+	ascending := Options{ClusteringOrder: []ClusteringOrderColumn{{Column: "Ck1", Direction: ASC}, {Column: "Ck2", Direction: ASC}}}
+	mixed := Options{ClusteringOrder: []ClusteringOrderColumn{{Column: "Ck1", Direction: DESC}, {Column: "Ck2", Direction: ASC}}}
+	desc := NewMockKeySpace(StrictMock()).Table("users_desc", user{}, keys).
+		WithOptions(Options{ClusteringOrder: []ClusteringOrderColumn{{Column: "Ck1", Direction: DESC}}})
+	s.NoError(desc.Where(Eq("Pk1", 1), Eq("Pk2", 1)).Read(&users).WithOptions(ascending).Run())
+	s.NoError(desc.(TableChanger).CreateIfNotExist())
+	s.NoError(desc.Where(Eq("Pk1", 1), Eq("Pk2", 1)).Read(&users).WithOptions(mixed).Run())
+	s.EqualError(desc.Where(Eq("Pk1", 1), Eq("Pk2", 1)).Read(&users).WithOptions(ascending).Run(), "Unsupported order by relation")
+	s.EqualError(desc.WithOptions(ascending).Where(Eq("Pk1", 1), Eq("Pk2", 1)).Read(&users).Run(), "Unsupported order by relation")
+}
+
+func (s *MockSuite) TestMockSnapshotRestore() {
+	store := s.ks.(MockStore)
+	s.NoError(s.mapTbl.Set(user{Pk1: 1, Name: "Jane"}).Run())
+	snapshot := store.Snapshot()
+
+	s.NoError(s.mapTbl.Update(1, map[string]interface{}{"Name": "Joan"}).Run())
+	s.NoError(s.mapTbl.Set(user{Pk1: 2, Name: "Jim"}).Run())
+	store.Restore(snapshot)
+
+	var users []user
+	s.NoError(s.mapTbl.MultiRead([]interface{}{1, 2}, &users).Run())
+	s.Equal([]user{{Pk1: 1, Name: "Jane"}}, users)
+
+	// Snapshots can be restored more than once
+	s.NoError(s.mapTbl.Delete(1).Run())
+	store.Restore(snapshot)
+	var u user
+	s.NoError(s.mapTbl.Read(1, &u).Run())
+	s.Equal("Jane", u.Name)
+}
+
+func (s *MockSuite) TestMockDumpLoad() {
+	s.NoError(s.mapTbl.Set(user{Pk1: 1, Name: "Jane"}).Run())
+	s.NoError(s.tsTbl.Set(point{Time: s.parseTime("2015-04-01 15:41:00"), Id: 1, User: "jane", X: 1.5}).Run())
+
+	for _, format := range []DumpFormat{JSONDump, YAMLDump} {
+		buf := &bytes.Buffer{}
+		s.NoError(s.ks.(MockStore).Dump(buf, format))
+
+		ks := NewMockKeySpace()
+		s.NoError(ks.(MockStore).Load(bytes.NewReader(buf.Bytes()), format))
+		var u user
+		s.NoError(ks.MapTable("users", "Pk1", user{}).Read(1, &u).Run())
+		s.Equal(user{Pk1: 1, Name: "Jane"}, u)
+		var points []point
+		tbl := ks.TimeSeriesTable("points", "Time", "Id", 1*time.Minute, point{})
+		s.NoError(tbl.List(s.parseTime("2015-04-01 15:00:00"), s.parseTime("2015-04-01 16:00:00"), &points).Run())
+		s.Equal([]point{{Time: s.parseTime("2015-04-01 15:41:00"), Id: 1, User: "jane", X: 1.5}}, points)
+	}
+}
+
+func (s *MockSuite) TestMockLoadTable() {
+	fixture := `
+- Pk1: 1
+  Name: Jane
+- Pk1: 2
+  Name: Jim
+`
+	store := s.ks.(MockStore)
+	s.NoError(store.LoadTable(s.mapTbl.Name(), strings.NewReader(fixture), YAMLDump))
+	var users []user
+	s.NoError(s.mapTbl.MultiRead([]interface{}{1, 2}, &users).Run())
+	s.Equal([]user{{Pk1: 1, Name: "Jane"}, {Pk1: 2, Name: "Jim"}}, users)
+
+	s.Error(store.LoadTable(s.mapTbl.Name(), strings.NewReader(`[{"Nope": 1}]`), JSONDump))
+}
+
+func (s *MockSuite) TestPersistentMockKeySpace() {
+	dir, err := ioutil.TempDir("", "gocassa")
+	s.NoError(err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "store.json")
+
+	ks, err := NewPersistentMockKeySpace(path)
+	s.NoError(err)
+	s.NoError(ks.MapTable("users", "Pk1", user{}).Set(user{Pk1: 1, Name: "Jane"}).Run())
+
+	ks, err = NewPersistentMockKeySpace(path)
+	s.NoError(err)
+	var u user
+	s.NoError(ks.MapTable("users", "Pk1", user{}).Read(1, &u).Run())
+	s.Equal("Jane", u.Name)
+}
+
 func (s *MockSuite) TestMultiTimeSeriesTableRead() {
 	points := s.insertPoints()
 
@@ -482,6 +1614,18 @@ func (s *MockSuite) TestMultiTimeSeriesTableDelete() {
 	s.Equal(RowNotFoundError{}, s.mtsTbl.Read("John", points[0].Time, points[0].Id, &p).Run())
 }
 
+func (s *MockSuite) TestMultiTimeSeriesTableDeleteRange() {
+	points := s.insertPoints()
+
+	s.NoError(s.mtsTbl.DeleteRange("John", points[0].Time, points[2].Time).Run())
+
+	var ps []point
+	s.NoError(s.mtsTbl.List("John", points[0].Time, points[2].Time, &ps).Run())
+	s.Empty(ps)
+	s.NoError(s.mtsTbl.List("Jane", points[0].Time, points[2].Time, &ps).Run())
+	s.Len(ps, 1)
+}
+
 func (s *MockSuite) TestMultiKeyTimeSeriesTableRead() {
 	points := s.insertPoints()
 
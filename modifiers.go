@@ -21,6 +21,8 @@ const (
 	ModifierMapSetFields                       // set values from the provided map
 	ModifierMapSetField                        // update a value for a specific key
 	ModifierCounterIncrement                   // increment a counter
+	ModifierMapRemoveKeys                      // remove the entries with the given keys from a map
+	ModifierSetRemove                          // remove elements from a set
 )
 
 type Modifier struct {
@@ -49,6 +51,10 @@ func (m Modifier) Operation() ModifierOp {
 //     to be set in the underlying map
 //   - ModifierCounterIncrement returns 1 element (int) with how much the value
 //     should be incremented by (or decremented if the value is negative)
+//   - ModifierMapRemoveKeys returns the keys (interface{}) of the entries
+//     to be removed from the map
+//   - ModifierSetRemove returns the values (interface{}) to be removed from
+//     the set
 func (m Modifier) Args() []interface{} {
 	return m.args
 }
@@ -110,6 +116,22 @@ func CounterIncrement(value int) Modifier {
 	}
 }
 
+// MapRemoveKeys removes the entries with the given keys from the map
+func MapRemoveKeys(keys ...interface{}) Modifier {
+	return Modifier{
+		op:   ModifierMapRemoveKeys,
+		args: keys,
+	}
+}
+
+// SetRemove removes the given values from the set
+func SetRemove(values ...interface{}) Modifier {
+	return Modifier{
+		op:   ModifierSetRemove,
+		args: values,
+	}
+}
+
 func (m Modifier) cql(name string) (string, []interface{}) {
 	str := ""
 	vals := []interface{}{}
@@ -149,6 +171,9 @@ func (m Modifier) cql(name string) (string, []interface{}) {
 	case ModifierMapSetField:
 		str = fmt.Sprintf("%s[?] = ?", name)
 		vals = append(vals, m.args[0], m.args[1])
+	case ModifierMapRemoveKeys, ModifierSetRemove:
+		str = fmt.Sprintf("%s = %s - ?", name, name)
+		vals = append(vals, m.args)
 	case ModifierCounterIncrement:
 		val := m.args[0].(int)
 		if val > 0 {
@@ -1,6 +1,7 @@
 package gocassa
 
 import (
+	"encoding/json"
 	"fmt"
 	"time"
 )
@@ -9,7 +10,8 @@ type multiFlakeSeriesT struct {
 	t          Table
 	indexField string
 	idField    string
-	bucketSize time.Duration
+	bucketer   Bucketer
+	ids        IDTimeExtractor
 }
 
 func (o *multiFlakeSeriesT) Table() Table                        { return o.t }
@@ -23,7 +25,7 @@ func (o *multiFlakeSeriesT) CreateIfNotExistStatement() (Statement, error) {
 }
 
 func (o *multiFlakeSeriesT) Set(v interface{}) Op {
-	m, ok := toMap(v)
+	m, ok := toSetMap(v)
 	if !ok {
 		panic("Can't set: not able to convert")
 	}
@@ -32,63 +34,102 @@ func (o *multiFlakeSeriesT) Set(v interface{}) Op {
 		panic(fmt.Sprintf("Id field (%s) is not present or is not a string", o.idField))
 	}
 
-	timestamp, err := flakeToTime(id)
+	timestamp, err := o.ids.Time(id)
 	if err != nil {
 		return errOp{err: err}
 	}
 
 	m[flakeTimestampFieldName] = timestamp
-	m[bucketFieldName] = bucket(timestamp, o.bucketSize)
+	m[bucketFieldName] = o.bucketer.Bucket(timestamp)
 
 	return o.Table().
 		Set(m)
 }
 
 func (o *multiFlakeSeriesT) Update(v interface{}, id string, m map[string]interface{}) Op {
-	timestamp, err := flakeToTime(id)
+	f, err := o.rowFilter(v, id)
 	if err != nil {
 		return errOp{err: err}
 	}
-	bucket := bucket(timestamp, o.bucketSize)
-
-	return o.Table().
-		Where(Eq(o.indexField, v),
-			Eq(bucketFieldName, bucket),
-			Eq(flakeTimestampFieldName, timestamp),
-			Eq(o.idField, id)).
-		Update(m)
+	return f.Update(m)
 }
 
 func (o *multiFlakeSeriesT) Delete(v interface{}, id string) Op {
-	timestamp, err := flakeToTime(id)
+	f, err := o.rowFilter(v, id)
 	if err != nil {
 		return errOp{err: err}
 	}
-	bucket := bucket(timestamp, o.bucketSize)
-
-	return o.Table().
-		Where(Eq(o.indexField, v),
-			Eq(bucketFieldName, bucket),
-			Eq(flakeTimestampFieldName, timestamp),
-			Eq(o.idField, id)).
-		Delete()
+	return f.Delete()
 }
 
 func (o *multiFlakeSeriesT) Read(v interface{}, id string, pointer interface{}) Op {
-	timestamp, err := flakeToTime(id)
+	f, err := o.rowFilter(v, id)
+	if err != nil {
+		return errOp{err: err}
+	}
+	return f.ReadOne(pointer)
+}
+
+func (o *multiFlakeSeriesT) SetJSON(document []byte, def JSONDefault) Op {
+	d, err := decodeJSONDocument(document)
+	if err != nil {
+		return errOp{err: err}
+	}
+	id, err := d.string(o.idField)
+	if err != nil {
+		return errOp{err: err}
+	}
+	timestamp, err := o.ids.Time(id)
+	if err != nil {
+		return errOp{err: err}
+	}
+	if err := d.set(flakeTimestampFieldName, timestamp); err != nil {
+		return errOp{err: err}
+	}
+	if err := d.set(bucketFieldName, o.bucketer.Bucket(timestamp)); err != nil {
+		return errOp{err: err}
+	}
+	if document, err = d.encode(); err != nil {
+		return errOp{err: err}
+	}
+	return o.Table().SetJSON(document, def)
+}
+
+func (o *multiFlakeSeriesT) ReadJSON(v interface{}, id string, pointer *json.RawMessage) Op {
+	f, err := o.rowFilter(v, id)
 	if err != nil {
 		return errOp{err: err}
 	}
-	bucket := bucket(timestamp, o.bucketSize)
+	return f.ReadOneJSON(pointer)
+}
+
+func (o *multiFlakeSeriesT) rowFilter(v interface{}, id string) (Filter, error) {
+	timestamp, err := o.ids.Time(id)
+	if err != nil {
+		return nil, err
+	}
 	return o.Table().
 		Where(Eq(o.indexField, v),
-			Eq(bucketFieldName, bucket),
+			Eq(bucketFieldName, o.bucketer.Bucket(timestamp)),
 			Eq(flakeTimestampFieldName, timestamp),
-			Eq(o.idField, id)).
-		ReadOne(pointer)
+			Eq(o.idField, id)), nil
 }
 
 func (o *multiFlakeSeriesT) List(v interface{}, startTime, endTime time.Time, pointerToASlice interface{}) Op {
+	return newBucketListOp(o.listFilter(v, startTime, endTime), o.Buckets(v, startTime), endTime, flakeTimestampFieldName, bucketReader{}, pointerToASlice)
+}
+
+func (o *multiFlakeSeriesT) ListJSON(v interface{}, startTime, endTime time.Time, pointerToASlice *[]json.RawMessage) Op {
+	return o.listFilter(v, startTime, endTime).
+		ReadJSON(pointerToASlice)
+}
+
+func (o *multiFlakeSeriesT) DeleteRange(v interface{}, startTime, endTime time.Time) Op {
+	return o.listFilter(v, startTime, endTime).
+		Delete()
+}
+
+func (o *multiFlakeSeriesT) listFilter(v interface{}, startTime, endTime time.Time) Filter {
 	buckets := []interface{}{}
 	for bucket := o.Buckets(v, startTime); bucket.Bucket().Before(endTime); bucket = bucket.Next() {
 		buckets = append(buckets, bucket.Bucket())
@@ -97,20 +138,31 @@ func (o *multiFlakeSeriesT) List(v interface{}, startTime, endTime time.Time, po
 		Where(Eq(o.indexField, v),
 			In(bucketFieldName, buckets...),
 			GTE(flakeTimestampFieldName, startTime),
-			LT(flakeTimestampFieldName, endTime)).
-		Read(pointerToASlice)
+			LT(flakeTimestampFieldName, endTime))
+}
+
+func (o *multiFlakeSeriesT) Latest(v interface{}, n int, pointerToASlice interface{}) Op {
+	return o.ListBefore(v, tableClock(o.Table()).Now(), n, pointerToASlice)
+}
+
+func (o *multiFlakeSeriesT) ListBefore(v interface{}, t time.Time, n int, pointerToASlice interface{}) Op {
+	return newBucketWalkOp(o.Buckets(v, t), flakeTimestampFieldName, bucketReader{relations: []Relation{LT(flakeTimestampFieldName, t)}}, DESC, n, pointerToASlice)
+}
+
+func (o *multiFlakeSeriesT) ListAfter(v interface{}, t time.Time, n int, pointerToASlice interface{}) Op {
+	return newBucketWalkOp(o.Buckets(v, t), flakeTimestampFieldName, bucketReader{relations: []Relation{GT(flakeTimestampFieldName, t)}}, ASC, n, pointerToASlice)
 }
 
 func (o *multiFlakeSeriesT) Buckets(v interface{}, start time.Time) Buckets {
 	return bucketIter{
 		v:         start,
-		step:      o.bucketSize,
+		bucketer:  o.bucketer,
 		field:     bucketFieldName,
 		invariant: o.Table().Where(Eq(o.indexField, v))}
 }
 
 func (o *multiFlakeSeriesT) ListSince(v interface{}, id string, window time.Duration, pointerToASlice interface{}) Op {
-	startTime, err := flakeToTime(id)
+	startTime, err := o.ids.Time(id)
 	if err != nil {
 		return errOp{err: err}
 	}
@@ -118,7 +170,7 @@ func (o *multiFlakeSeriesT) ListSince(v interface{}, id string, window time.Dura
 	var endTime time.Time
 	if window == 0 {
 		// no window set - so go up until 5 mins in the future
-		endTime = time.Now().Add(5 * time.Minute)
+		endTime = tableClock(o.Table()).Now().Add(5 * time.Minute)
 	} else {
 		endTime = startTime.Add(window)
 	}
@@ -128,13 +180,13 @@ func (o *multiFlakeSeriesT) ListSince(v interface{}, id string, window time.Dura
 		buckets = append(buckets, bucket.Bucket())
 	}
 
-	return o.Table().
+	f := o.Table().
 		Where(Eq(o.indexField, v),
-			In(bucketFieldName, buckets),
+			In(bucketFieldName, buckets...),
 			GTE(flakeTimestampFieldName, startTime),
-			LT(flakeTimestampFieldName, endTime),
-			GT(o.idField, id)).
-		Read(pointerToASlice)
+			LT(flakeTimestampFieldName, endTime))
+	list := newBucketListOp(f, o.Buckets(v, startTime), endTime, flakeTimestampFieldName, bucketReader{}, pointerToASlice)
+	return newSinceOp(list, o.Table(), o.ids, o.idField, id, pointerToASlice)
 }
 
 func (o *multiFlakeSeriesT) WithOptions(opt Options) MultiFlakeSeriesTable {
@@ -142,6 +194,7 @@ func (o *multiFlakeSeriesT) WithOptions(opt Options) MultiFlakeSeriesTable {
 		t:          o.Table().WithOptions(opt),
 		indexField: o.indexField,
 		idField:    o.idField,
-		bucketSize: o.bucketSize,
+		bucketer:   o.bucketer,
+		ids:        o.ids,
 	}
 }
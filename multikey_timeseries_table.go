@@ -1,6 +1,7 @@
 package gocassa
 
 import (
+	"encoding/json"
 	"time"
 )
 
@@ -9,7 +10,7 @@ type multiKeyTimeSeriesT struct {
 	indexFields []string
 	timeField   string
 	idFields    []string
-	bucketSize  time.Duration
+	bucketer    Bucketer
 }
 
 func (o *multiKeyTimeSeriesT) Table() Table                        { return o.t }
@@ -23,57 +24,81 @@ func (o *multiKeyTimeSeriesT) CreateIfNotExistStatement() (Statement, error) {
 }
 
 func (o *multiKeyTimeSeriesT) Set(v interface{}) Op {
-	m, ok := toMap(v)
+	m, ok := toSetMap(v)
 	if !ok {
 		panic("Can't set: not able to convert")
 	}
 	if tim, ok := m[o.timeField].(time.Time); !ok {
 		panic("timeField is not actually a time.Time")
 	} else {
-		m[bucketFieldName] = bucket(tim, o.bucketSize)
+		m[bucketFieldName] = o.bucketer.Bucket(tim)
 	}
 	return o.Table().
 		Set(m)
 }
 
 func (o *multiKeyTimeSeriesT) Update(v map[string]interface{}, timeStamp time.Time, id map[string]interface{}, m map[string]interface{}) Op {
-	bucket := bucket(timeStamp, o.bucketSize)
-	relations := make([]Relation, 0)
-	relations = append(relations, o.ListOfEqualRelations(v, id)...)
-	relations = append(relations, Eq(bucketFieldName, bucket))
-	relations = append(relations, Eq(o.timeField, timeStamp))
-
-	return o.Table().
-		Where(relations...).
+	return o.rowFilter(v, timeStamp, id).
 		Update(m)
 }
 
 func (o *multiKeyTimeSeriesT) Delete(v map[string]interface{}, timeStamp time.Time, id map[string]interface{}) Op {
-	bucket := bucket(timeStamp, o.bucketSize)
-	relations := make([]Relation, 0)
-	relations = append(relations, o.ListOfEqualRelations(v, id)...)
-	relations = append(relations, Eq(bucketFieldName, bucket))
-	relations = append(relations, Eq(o.timeField, timeStamp))
-
-	return o.Table().
-		Where(relations...).
+	return o.rowFilter(v, timeStamp, id).
 		Delete()
 }
 
 func (o *multiKeyTimeSeriesT) Read(v map[string]interface{}, timeStamp time.Time, id map[string]interface{}, pointer interface{}) Op {
-	bucket := bucket(timeStamp, o.bucketSize)
+	return o.rowFilter(v, timeStamp, id).
+		ReadOne(pointer)
+}
+
+func (o *multiKeyTimeSeriesT) SetJSON(document []byte, def JSONDefault) Op {
+	d, err := decodeJSONDocument(document)
+	if err != nil {
+		return errOp{err: err}
+	}
+	tim, err := d.time(o.timeField)
+	if err != nil {
+		return errOp{err: err}
+	}
+	if err := d.set(bucketFieldName, o.bucketer.Bucket(tim)); err != nil {
+		return errOp{err: err}
+	}
+	if document, err = d.encode(); err != nil {
+		return errOp{err: err}
+	}
+	return o.Table().SetJSON(document, def)
+}
+
+func (o *multiKeyTimeSeriesT) ReadJSON(v map[string]interface{}, timeStamp time.Time, id map[string]interface{}, pointer *json.RawMessage) Op {
+	return o.rowFilter(v, timeStamp, id).
+		ReadOneJSON(pointer)
+}
+
+func (o *multiKeyTimeSeriesT) rowFilter(v map[string]interface{}, timeStamp time.Time, id map[string]interface{}) Filter {
 	relations := make([]Relation, 0)
 	relations = append(relations, o.ListOfEqualRelations(v, id)...)
-	relations = append(relations, Eq(bucketFieldName, bucket))
+	relations = append(relations, Eq(bucketFieldName, o.bucketer.Bucket(timeStamp)))
 	relations = append(relations, Eq(o.timeField, timeStamp))
-
 	return o.Table().
-		Where(relations...).
-		ReadOne(pointer)
-
+		Where(relations...)
 }
 
 func (o *multiKeyTimeSeriesT) List(v map[string]interface{}, startTime time.Time, endTime time.Time, pointerToASlice interface{}) Op {
+	return newBucketListOp(o.listFilter(v, startTime, endTime), o.Buckets(v, startTime), endTime, o.timeField, bucketReader{}, pointerToASlice)
+}
+
+func (o *multiKeyTimeSeriesT) ListJSON(v map[string]interface{}, startTime, endTime time.Time, pointerToASlice *[]json.RawMessage) Op {
+	return o.listFilter(v, startTime, endTime).
+		ReadJSON(pointerToASlice)
+}
+
+func (o *multiKeyTimeSeriesT) DeleteRange(v map[string]interface{}, startTime time.Time, endTime time.Time) Op {
+	return o.listFilter(v, startTime, endTime).
+		Delete()
+}
+
+func (o *multiKeyTimeSeriesT) listFilter(v map[string]interface{}, startTime time.Time, endTime time.Time) Filter {
 	buckets := []interface{}{}
 	for bucket := o.Buckets(v, startTime); bucket.Bucket().Before(endTime); bucket = bucket.Next() {
 		buckets = append(buckets, bucket.Bucket())
@@ -86,14 +111,25 @@ func (o *multiKeyTimeSeriesT) List(v map[string]interface{}, startTime time.Time
 	relations = append(relations, LTE(o.timeField, endTime))
 
 	return o.Table().
-		Where(relations...).
-		Read(pointerToASlice)
+		Where(relations...)
+}
+
+func (o *multiKeyTimeSeriesT) Latest(v map[string]interface{}, n int, pointerToASlice interface{}) Op {
+	return o.ListBefore(v, tableClock(o.Table()).Now(), n, pointerToASlice)
+}
+
+func (o *multiKeyTimeSeriesT) ListBefore(v map[string]interface{}, t time.Time, n int, pointerToASlice interface{}) Op {
+	return newBucketWalkOp(o.Buckets(v, t), o.timeField, bucketReader{relations: []Relation{LT(o.timeField, t)}}, DESC, n, pointerToASlice)
+}
+
+func (o *multiKeyTimeSeriesT) ListAfter(v map[string]interface{}, t time.Time, n int, pointerToASlice interface{}) Op {
+	return newBucketWalkOp(o.Buckets(v, t), o.timeField, bucketReader{relations: []Relation{GT(o.timeField, t)}}, ASC, n, pointerToASlice)
 }
 
 func (o *multiKeyTimeSeriesT) Buckets(v map[string]interface{}, start time.Time) Buckets {
 	return bucketIter{
 		v:         start,
-		step:      o.bucketSize,
+		bucketer:  o.bucketer,
 		field:     bucketFieldName,
 		invariant: o.Table().Where(o.ListOfEqualRelations(v, nil)...)}
 }
@@ -104,7 +140,7 @@ func (o *multiKeyTimeSeriesT) WithOptions(opt Options) MultiKeyTimeSeriesTable {
 		indexFields: o.indexFields,
 		timeField:   o.timeField,
 		idFields:    o.idFields,
-		bucketSize:  o.bucketSize,
+		bucketer:    o.bucketer,
 	}
 }
 
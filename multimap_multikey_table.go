@@ -1,5 +1,13 @@
 package gocassa
 
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
 type multimapMkT struct {
 	t               Table
 	fieldsToIndexBy []string
@@ -33,6 +41,12 @@ func (mm *multimapMkT) Delete(field, id map[string]interface{}) Op {
 		Delete()
 }
 
+func (mm *multimapMkT) DeleteColumns(field, id map[string]interface{}, columns ...Selection) Op {
+	return mm.Table().
+		Where(mm.ListOfEqualRelations(field, id)...).
+		DeleteColumns(columns...)
+}
+
 func (mm *multimapMkT) DeleteAll(field map[string]interface{}) Op {
 	return mm.Table().
 		Where(mm.ListOfEqualRelations(field, nil)...).
@@ -52,6 +66,27 @@ func (mm *multimapMkT) MultiRead(field, id map[string]interface{}, pointerToASli
 }
 
 func (mm *multimapMkT) List(field, startId map[string]interface{}, limit int, pointerToASlice interface{}) Op {
+	return mm.listFilter(field, startId, limit).
+		Read(pointerToASlice)
+}
+
+func (mm *multimapMkT) SetJSON(document []byte, def JSONDefault) Op {
+	return mm.Table().
+		SetJSON(document, def)
+}
+
+func (mm *multimapMkT) ReadJSON(field, id map[string]interface{}, pointer *json.RawMessage) Op {
+	return mm.Table().
+		Where(mm.ListOfEqualRelations(field, id)...).
+		ReadOneJSON(pointer)
+}
+
+func (mm *multimapMkT) ListJSON(field, startId map[string]interface{}, limit int, pointerToASlice *[]json.RawMessage) Op {
+	return mm.listFilter(field, startId, limit).
+		ReadJSON(pointerToASlice)
+}
+
+func (mm *multimapMkT) listFilter(field, startId map[string]interface{}, limit int) Filter {
 	rels := mm.ListOfEqualRelations(field, nil)
 	if startId != nil {
 		for _, field := range mm.idField {
@@ -65,10 +100,81 @@ func (mm *multimapMkT) List(field, startId map[string]interface{}, limit int, po
 			Limit: limit,
 		}).
 		Table().
+		Where(rels...)
+}
+
+func (mm *multimapMkT) ListRange(field map[string]interface{}, r ClusteringRange, limit int, pointerToASlice interface{}) Op {
+	from, _ := r.From.(map[string]interface{})
+	to, _ := r.To.(map[string]interface{})
+	if (r.From != nil && from == nil) || (r.To != nil && to == nil) {
+		return errOp{err: errors.New("ClusteringRange bounds must be maps of id fields to values")}
+	}
+
+	// Cassandra only allows a range on a clustering column following the ones
+	// restricted by equality, so the range applies to the last id field the
+	// bounds hold, and the ones before it have to hold the same value in both
+	last := 0
+	for i, field := range mm.idField {
+		if boundValue(from, field) != nil || boundValue(to, field) != nil {
+			last = i
+		}
+	}
+	rels := mm.ListOfEqualRelations(field, nil)
+	for _, field := range mm.idField[:last] {
+		value := boundValue(from, field)
+		if value == nil {
+			value = boundValue(to, field)
+		}
+		if (r.From != nil && !reflect.DeepEqual(boundValue(from, field), value)) ||
+			(r.To != nil && !reflect.DeepEqual(boundValue(to, field), value)) {
+			return errOp{err: fmt.Errorf("ClusteringRange bounds must hold the same value of %s, as they can only differ in the last id field they hold", field)}
+		}
+		rels = append(rels, Eq(field, value))
+	}
+	rangeField := mm.idField[last]
+	rels = append(rels, r.relations(rangeField, boundValue(from, rangeField), boundValue(to, rangeField))...)
+
+	return mm.Table().
+		WithOptions(Options{
+			Limit:           limit,
+			ClusteringOrder: mm.rangeOrder(r.Direction, last),
+		}).
 		Where(rels...).
 		Read(pointerToASlice)
 }
 
+// rangeOrder returns the order of the id fields up to the last one, which
+// returns rows in the direction. Cassandra only allows reading rows in the
+// clustering order of the table or its reverse, so the order of the other id
+// fields follows the one of the last
+func (mm *multimapMkT) rangeOrder(direction ColumnDirection, last int) []ClusteringOrderColumn {
+	tableOrder := map[string]ColumnDirection{}
+	if t, ok := mm.Table().(optionsTable); ok {
+		for _, col := range t.tableOptions().ClusteringOrder {
+			tableOrder[strings.ToLower(col.Column)] = col.Direction
+		}
+	}
+	reverse := tableOrder[strings.ToLower(mm.idField[last])] != direction
+	order := make([]ClusteringOrderColumn, 0, last+1)
+	for _, field := range mm.idField[:last+1] {
+		direction := tableOrder[strings.ToLower(field)]
+		if reverse {
+			direction = !direction
+		}
+		order = append(order, ClusteringOrderColumn{Column: field, Direction: direction})
+	}
+	return order
+}
+
+// boundValue returns the value of field in a range bound, or nil if the
+// bound doesn't restrict it
+func boundValue(bound map[string]interface{}, field string) interface{} {
+	if value := bound[field]; value != nil && value != "" {
+		return value
+	}
+	return nil
+}
+
 func (mm *multimapMkT) WithOptions(o Options) MultimapMkTable {
 	return &multimapMkT{
 		t:               mm.Table().WithOptions(o),
@@ -1,5 +1,7 @@
 package gocassa
 
+import "encoding/json"
+
 type multimapT struct {
 	t              Table
 	fieldToIndexBy string
@@ -34,6 +36,25 @@ func (mm *multimapT) Delete(field, id interface{}) Op {
 		Delete()
 }
 
+func (mm *multimapT) DeleteColumns(field, id interface{}, columns ...Selection) Op {
+	return mm.Table().
+		Where(Eq(mm.fieldToIndexBy, field), Eq(mm.idField, id)).
+		DeleteColumns(columns...)
+}
+
+func (mm *multimapT) DeleteRange(field, startId, endId interface{}) Op {
+	rels := []Relation{Eq(mm.fieldToIndexBy, field)}
+	if startId != nil {
+		rels = append(rels, GTE(mm.idField, startId))
+	}
+	if endId != nil {
+		rels = append(rels, LT(mm.idField, endId))
+	}
+	return mm.Table().
+		Where(rels...).
+		Delete()
+}
+
 func (mm *multimapT) DeleteAll(field interface{}) Op {
 	return mm.Table().
 		Where(Eq(mm.fieldToIndexBy, field)).
@@ -48,6 +69,28 @@ func (mm *multimapT) Read(field, id, pointer interface{}) Op {
 }
 
 func (mm *multimapT) List(field, startId interface{}, limit int, pointerToASlice interface{}) Op {
+	return mm.listFilter(field, startId, limit).
+		Read(pointerToASlice)
+}
+
+func (mm *multimapT) SetJSON(document []byte, def JSONDefault) Op {
+	return mm.Table().
+		SetJSON(document, def)
+}
+
+func (mm *multimapT) ReadJSON(field, id interface{}, pointer *json.RawMessage) Op {
+	return mm.Table().
+		Where(Eq(mm.fieldToIndexBy, field),
+			Eq(mm.idField, id)).
+		ReadOneJSON(pointer)
+}
+
+func (mm *multimapT) ListJSON(field, startId interface{}, limit int, pointerToASlice *[]json.RawMessage) Op {
+	return mm.listFilter(field, startId, limit).
+		ReadJSON(pointerToASlice)
+}
+
+func (mm *multimapT) listFilter(field, startId interface{}, limit int) Filter {
 	rels := []Relation{Eq(mm.fieldToIndexBy, field)}
 	if startId != nil {
 		rels = append(rels, GTE(mm.idField, startId))
@@ -56,6 +99,17 @@ func (mm *multimapT) List(field, startId interface{}, limit int, pointerToASlice
 		WithOptions(Options{
 			Limit: limit,
 		}).
+		Where(rels...)
+}
+
+func (mm *multimapT) ListRange(field interface{}, r ClusteringRange, limit int, pointerToASlice interface{}) Op {
+	rels := []Relation{Eq(mm.fieldToIndexBy, field)}
+	rels = append(rels, r.relations(mm.idField, r.From, r.To)...)
+	return mm.Table().
+		WithOptions(Options{
+			Limit:           limit,
+			ClusteringOrder: []ClusteringOrderColumn{{Column: mm.idField, Direction: r.Direction}},
+		}).
 		Where(rels...).
 		Read(pointerToASlice)
 }
@@ -9,11 +9,16 @@ func Noop() Op {
 }
 
 func (mo multiOp) Run() error {
-	if err := mo.Preflight(); err != nil {
+	if err := mo.preflightOnRun(); err != nil {
 		return err
 	}
 	for _, op := range mo {
-		if err := op.Run(); err != nil {
+		// The ops have all passed (or skipped) Preflight already
+		run := op.Run
+		if o, ok := op.(*singleOp); ok {
+			run = o.run
+		}
+		if err := run(); err != nil {
 			return err
 		}
 	}
@@ -24,22 +29,88 @@ func (mo multiOp) RunWithContext(ctx context.Context) error {
 	return mo.WithOptions(Options{Context: ctx}).Run()
 }
 
+// opBuilder is implemented by ops which build the ops they're made up of when
+// they're run, such as the writes of an EntityTable
+type opBuilder interface {
+	buildOp() (Op, error)
+}
+
+// flattenOps returns the ops an op is made up of
+func flattenOps(op Op) multiOp {
+	if mo, ok := op.(multiOp); ok {
+		return mo
+	}
+	return multiOp{op}
+}
+
+// build replaces the ops which are opBuilders with the ops they build
+func (mo multiOp) build() (multiOp, error) {
+	ops := make(multiOp, 0, len(mo))
+	for _, op := range mo {
+		if b, ok := op.(opBuilder); ok {
+			built, err := b.buildOp()
+			if err != nil {
+				return nil, err
+			}
+			nested, err := flattenOps(built).build()
+			if err != nil {
+				return nil, err
+			}
+			ops = append(ops, nested...)
+			continue
+		}
+		ops = append(ops, op)
+	}
+	return ops, nil
+}
+
+// statements returns the statements of the ops, including the writes of their
+// events to outboxes
+func (mo multiOp) statements() ([]Statement, error) {
+	stmts := make([]Statement, 0, len(mo))
+	for _, op := range mo {
+		stmts = append(stmts, op.GenerateStatement())
+		if w, ok := op.(outboxWriter); ok {
+			s, ok, err := w.outboxStatement()
+			if err != nil {
+				return nil, err
+			} else if ok {
+				stmts = append(stmts, s)
+			}
+		}
+	}
+	return stmts, nil
+}
+
 func (mo multiOp) runLoggedBatch() error {
+	mo, err := mo.build()
+	if err != nil {
+		return err
+	}
 	if len(mo) == 0 {
 		return nil
 	}
 
-	if err := mo.Preflight(); err != nil {
+	if err := mo.preflightOnRun(); err != nil {
 		return err
 	}
-	stmts := make([]Statement, len(mo))
-	for i, op := range mo {
-		s := op.GenerateStatement()
-		stmts[i] = s
+	stmts, err := mo.statements()
+	if err != nil {
+		return err
 	}
 
 	qe := mo.QueryExecutor()
-	return qe.ExecuteAtomicallyWithOptions(mo.Options(), stmts)
+	if err := qe.ExecuteAtomicallyWithOptions(mo.Options(), stmts); err != nil {
+		return err
+	}
+	for _, op := range mo {
+		if w, ok := op.(outboxWriter); ok {
+			if err := w.runWriteHooks(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
 }
 
 func (mo multiOp) RunLoggedBatchWithContext(ctx context.Context) error {
@@ -105,6 +176,17 @@ func (mo multiOp) WithOptions(opts Options) Op {
 	return result
 }
 
+// preflightOnRun calls the Preflight of the ops, other than the ones which
+// skip it with the SkipPreflight option
+func (mo multiOp) preflightOnRun() error {
+	for _, op := range mo {
+		if err := preflightOnRun(op); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (mo multiOp) Preflight() error {
 	for _, op := range mo {
 		if err := op.Preflight(); err != nil {
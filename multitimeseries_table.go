@@ -1,6 +1,7 @@
 package gocassa
 
 import (
+	"encoding/json"
 	"time"
 )
 
@@ -9,7 +10,7 @@ type multiTimeSeriesT struct {
 	indexField string
 	timeField  string
 	idField    string
-	bucketSize time.Duration
+	bucketer   Bucketer
 }
 
 func (o *multiTimeSeriesT) Table() Table                        { return o.t }
@@ -23,51 +24,80 @@ func (o *multiTimeSeriesT) CreateIfNotExistStatement() (Statement, error) {
 }
 
 func (o *multiTimeSeriesT) Set(v interface{}) Op {
-	m, ok := toMap(v)
+	m, ok := toSetMap(v)
 	if !ok {
 		panic("Can't set: not able to convert")
 	}
 	if tim, ok := m[o.timeField].(time.Time); !ok {
 		panic("timeField is not actually a time.Time")
 	} else {
-		m[bucketFieldName] = bucket(tim, o.bucketSize)
+		m[bucketFieldName] = o.bucketer.Bucket(tim)
 	}
 	return o.Table().
 		Set(m)
 }
 
 func (o *multiTimeSeriesT) Update(v interface{}, timeStamp time.Time, id interface{}, m map[string]interface{}) Op {
-	bucket := bucket(timeStamp, o.bucketSize)
-	return o.Table().
-		Where(Eq(o.indexField, v),
-			Eq(bucketFieldName, bucket),
-			Eq(o.timeField, timeStamp),
-			Eq(o.idField, id)).
+	return o.rowFilter(v, timeStamp, id).
 		Update(m)
 }
 
 func (o *multiTimeSeriesT) Delete(v interface{}, timeStamp time.Time, id interface{}) Op {
-	bucket := bucket(timeStamp, o.bucketSize)
-	return o.Table().
-		Where(Eq(o.indexField, v),
-			Eq(bucketFieldName, bucket),
-			Eq(o.timeField, timeStamp),
-			Eq(o.idField, id)).
+	return o.rowFilter(v, timeStamp, id).
 		Delete()
 }
 
 func (o *multiTimeSeriesT) Read(v interface{}, timeStamp time.Time, id, pointer interface{}) Op {
-	bucket := bucket(timeStamp, o.bucketSize)
+	return o.rowFilter(v, timeStamp, id).
+		ReadOne(pointer)
+}
+
+func (o *multiTimeSeriesT) SetJSON(document []byte, def JSONDefault) Op {
+	d, err := decodeJSONDocument(document)
+	if err != nil {
+		return errOp{err: err}
+	}
+	tim, err := d.time(o.timeField)
+	if err != nil {
+		return errOp{err: err}
+	}
+	if err := d.set(bucketFieldName, o.bucketer.Bucket(tim)); err != nil {
+		return errOp{err: err}
+	}
+	if document, err = d.encode(); err != nil {
+		return errOp{err: err}
+	}
+	return o.Table().SetJSON(document, def)
+}
+
+func (o *multiTimeSeriesT) ReadJSON(v interface{}, timeStamp time.Time, id interface{}, pointer *json.RawMessage) Op {
+	return o.rowFilter(v, timeStamp, id).
+		ReadOneJSON(pointer)
+}
+
+func (o *multiTimeSeriesT) rowFilter(v interface{}, timeStamp time.Time, id interface{}) Filter {
 	return o.Table().
 		Where(Eq(o.indexField, v),
-			Eq(bucketFieldName, bucket),
+			Eq(bucketFieldName, o.bucketer.Bucket(timeStamp)),
 			Eq(o.timeField, timeStamp),
-			Eq(o.idField, id)).
-		ReadOne(pointer)
-
+			Eq(o.idField, id))
 }
 
 func (o *multiTimeSeriesT) List(v interface{}, startTime time.Time, endTime time.Time, pointerToASlice interface{}) Op {
+	return newBucketListOp(o.listFilter(v, startTime, endTime), o.Buckets(v, startTime), endTime, o.timeField, bucketReader{}, pointerToASlice)
+}
+
+func (o *multiTimeSeriesT) ListJSON(v interface{}, startTime, endTime time.Time, pointerToASlice *[]json.RawMessage) Op {
+	return o.listFilter(v, startTime, endTime).
+		ReadJSON(pointerToASlice)
+}
+
+func (o *multiTimeSeriesT) DeleteRange(v interface{}, startTime time.Time, endTime time.Time) Op {
+	return o.listFilter(v, startTime, endTime).
+		Delete()
+}
+
+func (o *multiTimeSeriesT) listFilter(v interface{}, startTime time.Time, endTime time.Time) Filter {
 	buckets := []interface{}{}
 	for bucket := o.Buckets(v, startTime); bucket.Bucket().Before(endTime); bucket = bucket.Next() {
 		buckets = append(buckets, bucket.Bucket())
@@ -76,14 +106,25 @@ func (o *multiTimeSeriesT) List(v interface{}, startTime time.Time, endTime time
 		Where(Eq(o.indexField, v),
 			In(bucketFieldName, buckets...),
 			GTE(o.timeField, startTime),
-			LTE(o.timeField, endTime)).
-		Read(pointerToASlice)
+			LTE(o.timeField, endTime))
+}
+
+func (o *multiTimeSeriesT) Latest(v interface{}, n int, pointerToASlice interface{}) Op {
+	return o.ListBefore(v, tableClock(o.Table()).Now(), n, pointerToASlice)
+}
+
+func (o *multiTimeSeriesT) ListBefore(v interface{}, t time.Time, n int, pointerToASlice interface{}) Op {
+	return newBucketWalkOp(o.Buckets(v, t), o.timeField, bucketReader{relations: []Relation{LT(o.timeField, t)}}, DESC, n, pointerToASlice)
+}
+
+func (o *multiTimeSeriesT) ListAfter(v interface{}, t time.Time, n int, pointerToASlice interface{}) Op {
+	return newBucketWalkOp(o.Buckets(v, t), o.timeField, bucketReader{relations: []Relation{GT(o.timeField, t)}}, ASC, n, pointerToASlice)
 }
 
 func (o *multiTimeSeriesT) Buckets(v interface{}, start time.Time) Buckets {
 	return bucketIter{
 		v:         start,
-		step:      o.bucketSize,
+		bucketer:  o.bucketer,
 		field:     bucketFieldName,
 		invariant: o.Table().Where(Eq(o.indexField, v))}
 }
@@ -94,6 +135,6 @@ func (o *multiTimeSeriesT) WithOptions(opt Options) MultiTimeSeriesTable {
 		indexField: o.indexField,
 		timeField:  o.timeField,
 		idField:    o.idField,
-		bucketSize: o.bucketSize,
+		bucketer:   o.bucketer,
 	}
 }
@@ -1,6 +1,7 @@
 package gocassa
 
 import (
+	"encoding/json"
 	"sort"
 
 	"context"
@@ -12,15 +13,21 @@ const (
 	deleteOpType
 	updateOpType
 	insertOpType
+	insertJSONOpType
 )
 
 type singleOp struct {
-	options Options
-	f       filter
-	opType  uint8
-	result  interface{}
-	m       map[string]interface{} // map for updates, sets etc
-	qe      QueryExecutor
+	options     Options
+	f           filter
+	opType      uint8
+	result      interface{}
+	m           map[string]interface{} // map for updates, sets etc
+	json        bool                   // whether reads select JSON documents
+	document    []byte                 // JSON document for inserts
+	jsonDefault JSONDefault            // how JSON inserts treat omitted columns
+	columns     []Selection            // columns for column-level deletes
+	set         bool                   // whether an update sets a whole row
+	qe          QueryExecutor
 }
 
 func (o *singleOp) Options() Options {
@@ -29,19 +36,38 @@ func (o *singleOp) Options() Options {
 
 func (o *singleOp) WithOptions(opts Options) Op {
 	return &singleOp{
-		options: o.options.Merge(opts),
-		f:       o.f,
-		opType:  o.opType,
-		result:  o.result,
-		m:       o.m,
-		qe:      o.qe}
+		options:     o.options.Merge(opts),
+		f:           o.f,
+		opType:      o.opType,
+		result:      o.result,
+		m:           o.m,
+		json:        o.json,
+		document:    o.document,
+		jsonDefault: o.jsonDefault,
+		columns:     o.columns,
+		set:         o.set,
+		qe:          o.qe}
 }
 
 func (o *singleOp) Add(additions ...Op) Op {
 	return multiOp{o}.Add(additions...)
 }
 
+// Preflight checks the relations, fields and modifiers of the op against the
+// keys and fields of its table, returning the errors Cassandra would otherwise
+// return once the op is run
 func (o *singleOp) Preflight() error {
+	schema := o.f.t.schema()
+	switch o.opType {
+	case readOpType, singleReadOpType:
+		return schema.validateSelect(o.f.rs, o.f.t.options.Merge(o.options))
+	case insertOpType:
+		return schema.validateInsert(o.m)
+	case updateOpType:
+		return schema.validateUpdate(o.f.rs, o.m)
+	case deleteOpType:
+		return schema.validateDelete(o.f.rs, o.columns)
+	}
 	return nil
 }
 
@@ -54,22 +80,60 @@ func newWriteOp(qe QueryExecutor, f filter, opType uint8, m map[string]interface
 }
 
 func (o *singleOp) Run() error {
+	if err := preflightOnRun(o); err != nil {
+		return err
+	}
+	return o.run()
+}
+
+// preflightOnRun calls the Preflight of an op about to be run, unless it's
+// skipped with the SkipPreflight option
+func preflightOnRun(op Op) error {
+	opts := op.Options()
+	switch o := op.(type) {
+	case *singleOp:
+		opts = o.f.t.options.Merge(o.options)
+	case multiOp:
+		return o.preflightOnRun()
+	}
+	if opts.SkipPreflight {
+		return nil
+	}
+	return op.Preflight()
+}
+
+// run runs the op once it has passed Preflight
+func (o *singleOp) run() error {
 	switch o.opType {
 	case readOpType, singleReadOpType:
 		stmt := o.generateSelect(o.options)
 		scanner := NewScanner(stmt, o.result)
 		return o.qe.QueryWithOptions(o.options, stmt, scanner)
-	case insertOpType:
-		stmt := o.generateInsert(o.options)
-		return o.qe.ExecuteWithOptions(o.options, stmt)
-	case updateOpType:
-		stmt := o.generateUpdate(o.options)
-		return o.qe.ExecuteWithOptions(o.options, stmt)
-	case deleteOpType:
-		stmt := o.generateDelete(o.options)
+	}
+
+	stmt := o.GenerateStatement()
+	hooks, outbox := o.writeTargets()
+	if len(hooks) == 0 && outbox == nil {
 		return o.qe.ExecuteWithOptions(o.options, stmt)
 	}
-	return nil
+	event, _ := o.writeEvent()
+	if outbox == nil {
+		if err := o.qe.ExecuteWithOptions(o.options, stmt); err != nil {
+			return err
+		}
+		return runWriteHooks(hooks, event)
+	}
+
+	// The event is written to the outbox in the same logged batch
+	row, err := newOutboxEvent(event)
+	if err != nil {
+		return err
+	}
+	stmts := []Statement{stmt, outbox.Set(row).GenerateStatement()}
+	if err := o.qe.ExecuteAtomicallyWithOptions(o.options, stmts); err != nil {
+		return err
+	}
+	return runWriteHooks(hooks, event)
 }
 
 func (o *singleOp) RunWithContext(ctx context.Context) error {
@@ -94,6 +158,8 @@ func (o *singleOp) GenerateStatement() Statement {
 		return o.generateSelect(o.options)
 	case insertOpType:
 		return o.generateInsert(o.options)
+	case insertJSONOpType:
+		return o.generateInsertJSON(o.options)
 	case updateOpType:
 		return o.generateUpdate(o.options)
 	case deleteOpType:
@@ -106,6 +172,76 @@ func (o *singleOp) QueryExecutor() QueryExecutor {
 	return o.qe
 }
 
+// writeTargets returns the hooks to call after the write, and the outbox to
+// write its event to
+func (o *singleOp) writeTargets() ([]WriteHook, TimeSeriesTable) {
+	return o.f.t.keySpace.hooks.forWrite(o.f.t.Name(), o.f.t.options.Merge(o.options))
+}
+
+// writeEvent returns the event describing the write, and false for reads
+func (o *singleOp) writeEvent() (WriteEvent, bool) {
+	e := WriteEvent{
+		Keyspace:  o.f.t.keySpace.name,
+		Table:     o.f.t.Name(),
+		Relations: o.f.rs,
+		Keys:      keysFromRelations(o.f.t.info.keys, o.f.rs),
+		Options:   o.f.t.options.Merge(o.options),
+	}
+	switch o.opType {
+	case insertOpType:
+		e.Type, e.Values = SetWrite, o.m
+		e.Keys = keysFromValues(o.f.t.info.keys, o.m)
+	case insertJSONOpType:
+		e.Type = SetWrite
+		if err := json.Unmarshal(o.document, &e.Values); err == nil {
+			e.Keys = keysFromValues(o.f.t.info.keys, e.Values)
+		}
+	case updateOpType:
+		e.Type, e.Values = UpdateWrite, o.m
+		if o.set {
+			// Sets turned into updates leave the keys out of the fields
+			e.Type, e.Relations = SetWrite, nil
+			e.Values = copyMap(e.Keys)
+			for k, v := range o.m {
+				e.Values[k] = v
+			}
+		}
+	case deleteOpType:
+		e.Type, e.Columns = DeleteWrite, o.columns
+	default:
+		return WriteEvent{}, false
+	}
+	return e, true
+}
+
+func (o *singleOp) outboxStatement() (Statement, bool, error) {
+	_, outbox := o.writeTargets()
+	if outbox == nil {
+		return nil, false, nil
+	}
+	event, ok := o.writeEvent()
+	if !ok {
+		return nil, false, nil
+	}
+	row, err := newOutboxEvent(event)
+	if err != nil {
+		return nil, false, err
+	}
+	return outbox.Set(row).GenerateStatement(), true, nil
+}
+
+func (o *singleOp) runWriteHooks() error {
+	hooks, _ := o.writeTargets()
+	if len(hooks) == 0 {
+		return nil
+	}
+	event, ok := o.writeEvent()
+	if !ok {
+		return nil
+	}
+	return runWriteHooks(hooks, event)
+}
+
 func (o *singleOp) generateSelect(opt Options) SelectStatement {
 	mopt := o.f.t.options.Merge(opt)
 	return SelectStatement{
@@ -117,6 +253,7 @@ func (o *singleOp) generateSelect(opt Options) SelectStatement {
 		limit:          mopt.Limit,
 		allowFiltering: mopt.AllowFiltering,
 		keys:           o.f.t.info.keys,
+		json:           o.json,
 	}
 }
 
@@ -131,6 +268,18 @@ func (o *singleOp) generateInsert(opt Options) InsertStatement {
 	}
 }
 
+func (o *singleOp) generateInsertJSON(opt Options) InsertJSONStatement {
+	mopt := o.f.t.options.Merge(opt)
+	return InsertJSONStatement{
+		keyspace:    o.f.t.keySpace.name,
+		table:       o.f.t.Name(),
+		document:    o.document,
+		jsonDefault: o.jsonDefault,
+		ttl:         mopt.TTL,
+		keys:        o.f.t.info.keys,
+	}
+}
+
 func (o *singleOp) generateUpdate(opt Options) UpdateStatement {
 	mopt := o.f.t.options.Merge(opt)
 	return UpdateStatement{
@@ -147,6 +296,7 @@ func (o *singleOp) generateDelete(opt Options) DeleteStatement {
 	return DeleteStatement{
 		keyspace: o.f.t.keySpace.name,
 		table:    o.f.t.Name(),
+		columns:  o.columns,
 		where:    o.f.rs,
 		keys:     o.f.t.info.keys,
 	}
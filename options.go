@@ -25,6 +25,24 @@ func (d ColumnDirection) String() string {
 	}
 }
 
+// EmptyFieldMode determines how Set writes fields which hold an empty value,
+// ie. nil, their zero value or an empty map, slice or string. Fields tagged
+// with the "omitempty" option are always left out when empty.
+type EmptyFieldMode int
+
+const (
+	// NullEmptyFields writes empty fields as nulls, which creates tombstones.
+	// This is the default.
+	NullEmptyFields EmptyFieldMode = iota
+	// OmitEmptyFields leaves empty fields out of the statement, so any
+	// existing values for those columns are kept.
+	OmitEmptyFields
+	// UnsetEmptyFields binds empty fields as gocql.UnsetValue, so any existing
+	// values for those columns are kept while the shape of the statement
+	// stays the same. This requires version 4 of the native protocol.
+	UnsetEmptyFields
+)
+
 // ClusteringOrderColumn specifies a clustering column and whether its
 // clustering order is ASC or DESC.
 type ClusteringOrderColumn struct {
@@ -61,19 +79,56 @@ type Options struct {
 	Compressor string
 	// Context allows a request context to passed, which is propagated to the QueryExecutor
 	Context context.Context
+	// EmptyFields specifies how Set writes fields holding an empty value. Primary key fields are always written.
+	EmptyFields EmptyFieldMode
+	// MaxBuckets limits the number of buckets Latest, ListBefore and ListAfter query before giving up on
+	// finding more rows. If zero, 100 buckets are queried at most
+	MaxBuckets int
+	// BucketConcurrency makes List on the time series and flake series recipes query every bucket separately,
+	// with up to this many queries running at once, instead of querying all buckets with a single IN query.
+	// The results are merged in clustering order and Limit applies to the merged results
+	BucketConcurrency int
+	// PollInterval is how long Follow waits before polling again once it has caught up. It doubles after each
+	// poll which finds nothing, up to MaxPollInterval. If zero, Follow waits a second, and at most 30 seconds
+	PollInterval    time.Duration
+	MaxPollInterval time.Duration
+	// Checkpoints makes Follow save a checkpoint after each row it handles, and carry on from the last one saved
+	Checkpoints CheckpointStore
+	// Clock tells the current time. If nil, the system clock is used
+	Clock Clock
+	// WriteHooks are called after each successful write, on top of the ones added to the keyspace
+	WriteHooks []WriteHook
+	// Outbox makes writes also write an OutboxEvent to it, in the same logged batch. It overrides the outbox
+	// set on the keyspace
+	Outbox TimeSeriesTable
+	// SkipPreflight makes ops run without calling Preflight first, sending them to Cassandra even if it would
+	// return an error
+	SkipPreflight bool
 }
 
 // Merge returns a new Options which is a right biased merge of the two initial Options.
 func (o Options) Merge(neu Options) Options {
 	ret := Options{
-		TTL:             o.TTL,
-		Limit:           o.Limit,
-		TableName:       o.TableName,
-		ClusteringOrder: o.ClusteringOrder,
-		Select:          o.Select,
-		CompactStorage:  o.CompactStorage,
-		Compressor:      o.Compressor,
-		Context:         o.Context,
+		TTL:               o.TTL,
+		Limit:             o.Limit,
+		TableName:         o.TableName,
+		ClusteringOrder:   o.ClusteringOrder,
+		AllowFiltering:    o.AllowFiltering,
+		Select:            o.Select,
+		Consistency:       o.Consistency,
+		CompactStorage:    o.CompactStorage,
+		Compressor:        o.Compressor,
+		Context:           o.Context,
+		EmptyFields:       o.EmptyFields,
+		MaxBuckets:        o.MaxBuckets,
+		BucketConcurrency: o.BucketConcurrency,
+		PollInterval:      o.PollInterval,
+		MaxPollInterval:   o.MaxPollInterval,
+		Checkpoints:       o.Checkpoints,
+		Clock:             o.Clock,
+		WriteHooks:        o.WriteHooks,
+		Outbox:            o.Outbox,
+		SkipPreflight:     o.SkipPreflight,
 	}
 	if neu.TTL != time.Duration(0) {
 		ret.TTL = neu.TTL
@@ -106,6 +161,36 @@ func (o Options) Merge(neu Options) Options {
 	if neu.Context != nil {
 		ret.Context = neu.Context
 	}
+	if neu.EmptyFields != NullEmptyFields {
+		ret.EmptyFields = neu.EmptyFields
+	}
+	if neu.MaxBuckets != 0 {
+		ret.MaxBuckets = neu.MaxBuckets
+	}
+	if neu.BucketConcurrency != 0 {
+		ret.BucketConcurrency = neu.BucketConcurrency
+	}
+	if neu.PollInterval != 0 {
+		ret.PollInterval = neu.PollInterval
+	}
+	if neu.MaxPollInterval != 0 {
+		ret.MaxPollInterval = neu.MaxPollInterval
+	}
+	if neu.Checkpoints != nil {
+		ret.Checkpoints = neu.Checkpoints
+	}
+	if neu.Clock != nil {
+		ret.Clock = neu.Clock
+	}
+	if len(neu.WriteHooks) > 0 {
+		ret.WriteHooks = neu.WriteHooks
+	}
+	if neu.Outbox != nil {
+		ret.Outbox = neu.Outbox
+	}
+	if neu.SkipPreflight {
+		ret.SkipPreflight = neu.SkipPreflight
+	}
 
 	return ret
 }
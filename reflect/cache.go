@@ -30,6 +30,11 @@ func (f Field) Index() []int {
 	return f.index
 }
 
+// OmitEmpty returns whether the field's tag specifies the "omitempty" option
+func (f Field) OmitEmpty() bool {
+	return f.omitEmpty
+}
+
 func fillField(f Field) Field {
 	f.nameBytes = []byte(f.name)
 
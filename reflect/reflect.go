@@ -35,6 +35,47 @@ func StructToMap(val interface{}) (map[string]interface{}, bool) {
 	return mapVal, true
 }
 
+// StructToMapOmitEmpty is like StructToMap, but fields tagged with the
+// "omitempty" option are left out of the map when they hold an empty value
+// (see IsEmptyValue).
+func StructToMapOmitEmpty(val interface{}) (map[string]interface{}, bool) {
+	structVal := r.Indirect(r.ValueOf(val))
+	kind := structVal.Kind()
+	if kind != r.Struct {
+		return nil, false
+	}
+	structFields := cachedTypeFields(structVal.Type())
+	mapVal := make(map[string]interface{}, len(structFields))
+	for _, info := range structFields {
+		field := fieldByIndex(structVal, info.index)
+		if info.omitEmpty && isEmptyValue(field) {
+			continue
+		}
+		mapVal[info.name] = field.Interface()
+	}
+	return mapVal, true
+}
+
+// IsEmptyValue returns whether the value is nil, holds the zero value for its
+// type or is an empty map, slice or string.
+func IsEmptyValue(val interface{}) bool {
+	if val == nil {
+		return true
+	}
+	return isEmptyValue(r.ValueOf(val))
+}
+
+func isEmptyValue(v r.Value) bool {
+	if !v.IsValid() {
+		return true
+	}
+	switch v.Kind() {
+	case r.Map, r.Slice, r.String:
+		return v.Len() == 0
+	}
+	return v.IsZero()
+}
+
 // StructFieldMap takes a struct type and extracts the Field info into a map by
 // field name. The "cql" key in the struct field's tag value is the key
 // name. Examples:
@@ -65,6 +65,47 @@ func TestStructToMap(t *testing.T) {
 	}
 }
 
+func TestStructToMapOmitEmpty(t *testing.T) {
+	type profile struct {
+		Id   string
+		Name string   `cql:"name,omitempty"`
+		Tags []string `cql:",omitempty"`
+		Age  int
+	}
+
+	m, ok := StructToMapOmitEmpty(profile{Id: "1"})
+	if !ok {
+		t.Fatal("ok is false for a profile")
+	}
+	if !reflect.DeepEqual(m, map[string]interface{}{"Id": "1", "Age": 0}) {
+		t.Errorf("Unexpected map %v", m)
+	}
+
+	m, _ = StructToMapOmitEmpty(profile{Id: "1", Name: "Moss", Tags: []string{"it"}})
+	if !reflect.DeepEqual(m, map[string]interface{}{"Id": "1", "name": "Moss", "Tags": []string{"it"}, "Age": 0}) {
+		t.Errorf("Unexpected map %v", m)
+	}
+
+	if _, ok := StructToMapOmitEmpty("str"); ok {
+		t.Error("ok result from StructToMapOmitEmpty when the val is a string")
+	}
+}
+
+func TestIsEmptyValue(t *testing.T) {
+	var nilPtr *string
+	for _, v := range []interface{}{nil, "", 0, 0.0, false, nilPtr, []string{}, map[string]int{}, gocql.UUID{}} {
+		if !IsEmptyValue(v) {
+			t.Errorf("Expected %#v to be empty", v)
+		}
+	}
+	str := ""
+	for _, v := range []interface{}{"a", 1, true, &str, []string{""}, map[string]int{"a": 0}, gocql.TimeUUID()} {
+		if IsEmptyValue(v) {
+			t.Errorf("Expected %#v not to be empty", v)
+		}
+	}
+}
+
 func TestMapToStruct(t *testing.T) {
 	m := make(map[string]interface{})
 	assert := func() {
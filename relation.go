@@ -164,3 +164,40 @@ func LTE(field string, term interface{}) Relation {
 		terms: toI(term),
 	}
 }
+
+// ClusteringRange specifies a range of clustering keys to list, and the
+// order in which the rows are returned. A nil bound leaves that end of the
+// range open.
+type ClusteringRange struct {
+	// From is the lower bound of the range
+	From interface{}
+	// FromInclusive includes rows equal to the lower bound
+	FromInclusive bool
+	// To is the upper bound of the range
+	To interface{}
+	// ToInclusive includes rows equal to the upper bound
+	ToInclusive bool
+	// Direction is the order the rows are returned in, regardless of the
+	// clustering order of the table
+	Direction ColumnDirection
+}
+
+// relations returns the relations restricting field to the range
+func (r ClusteringRange) relations(field string, from, to interface{}) []Relation {
+	rels := []Relation{}
+	if from != nil {
+		if r.FromInclusive {
+			rels = append(rels, GTE(field, from))
+		} else {
+			rels = append(rels, GT(field, from))
+		}
+	}
+	if to != nil {
+		if r.ToInclusive {
+			rels = append(rels, LTE(field, to))
+		} else {
+			rels = append(rels, LT(field, to))
+		}
+	}
+	return rels
+}
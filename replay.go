@@ -0,0 +1,389 @@
+package gocassa
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/gocql/gocql"
+)
+
+// recordedCall is a call made to a QueryExecutor, as it's written to a
+// recording, one per line
+type recordedCall struct {
+	Type        string              `json:"type"`
+	Statements  []recordedStatement `json:"statements"`
+	Consistency string              `json:"consistency,omitempty"`
+	Rows        [][]recordedColumn  `json:"rows,omitempty"`
+	Error       string              `json:"error,omitempty"`
+}
+
+type recordedStatement struct {
+	Query  string          `json:"query"`
+	Values json.RawMessage `json:"values"`
+}
+
+// recordedColumn is a column of a recorded row: its CQL type and its value as
+// Cassandra returned it, which is null for null columns. Columns which weren't
+// scanned have no type
+type recordedColumn struct {
+	Type  string `json:"type,omitempty"`
+	Value []byte `json:"value"`
+}
+
+func newRecordedCall(typ expectationType, opts Options, stmts []Statement) (*recordedCall, error) {
+	call := &recordedCall{Type: typ.String()}
+	if opts.Consistency != nil {
+		call.Consistency = opts.Consistency.String()
+	}
+	for _, stmt := range stmts {
+		values, err := json.Marshal(stmt.Values())
+		if err != nil {
+			return nil, fmt.Errorf("could not record the values of %q: %v", stmt.Query(), err)
+		}
+		call.Statements = append(call.Statements, recordedStatement{Query: stmt.Query(), Values: values})
+	}
+	return call, nil
+}
+
+// key identifies the calls a recorded call is replayed for
+func (c *recordedCall) key() string {
+	key, _ := json.Marshal([]interface{}{c.Type, c.Statements, c.Consistency})
+	return string(key)
+}
+
+// recordingIterator records the rows scanned from a Scannable, with the type
+// and value of each column, so that they're decoded with gocql.Unmarshal when
+// replayed as they were when recorded
+type recordingIterator struct {
+	Scannable
+	rows [][]recordedColumn
+	err  error
+}
+
+// columnIterator is implemented by iterators which tell the types of their
+// columns and decode them with gocql.Unmarshal, such as gocql.Iter
+type columnIterator interface {
+	Columns() []gocql.ColumnInfo
+}
+
+func (iter *recordingIterator) Scan(dest ...interface{}) error {
+	if _, ok := iter.Scannable.(columnIterator); ok {
+		return iter.scanRaw(dest)
+	}
+	// Other iterators set the values of the columns, which are encoded with
+	// the type they're scanned into
+	if err := iter.Scannable.Scan(dest...); err != nil {
+		return err
+	}
+	row := make([]recordedColumn, len(dest))
+	for i, d := range dest {
+		if _, ok := d.(*IgnoreFieldType); ok {
+			continue
+		}
+		col, err := encodeColumn(d)
+		if err != nil && iter.err == nil {
+			iter.err = fmt.Errorf("could not record %T: %v", d, err)
+		}
+		row[i] = col
+	}
+	iter.rows = append(iter.rows, row)
+	return nil
+}
+
+// scanRaw scans a row, recording the columns as they're decoded
+func (iter *recordingIterator) scanRaw(dest []interface{}) error {
+	cols := make([]*rawColumn, len(dest))
+	wrapped := make([]interface{}, len(dest))
+	for i, d := range dest {
+		wrapped[i] = d
+		if _, ok := d.(*IgnoreFieldType); !ok {
+			cols[i] = &rawColumn{dest: d}
+			wrapped[i] = cols[i]
+		}
+	}
+	if err := iter.Scannable.Scan(wrapped...); err != nil {
+		return err
+	}
+	row := make([]recordedColumn, len(dest))
+	for i, c := range cols {
+		if c == nil {
+			continue
+		}
+		if c.err != nil && iter.err == nil {
+			iter.err = fmt.Errorf("could not record %T: %v", c.dest, c.err)
+		}
+		row[i] = c.recorded
+	}
+	iter.rows = append(iter.rows, row)
+	return nil
+}
+
+// rawColumn records the type and value of a column as it's decoded into dest
+type rawColumn struct {
+	dest     interface{}
+	recorded recordedColumn
+	err      error
+}
+
+func (c *rawColumn) UnmarshalCQL(info gocql.TypeInfo, data []byte) error {
+	c.recorded.Type = cqlTypeString(info)
+	if _, err := parseCQLType(c.recorded.Type); err != nil {
+		c.err = err
+	}
+	if data != nil {
+		c.recorded.Value = append([]byte{}, data...)
+	}
+	return gocql.Unmarshal(info, data, c.dest)
+}
+
+// encodeColumn encodes the value scanned into dest, with the CQL type of the
+// type of dest
+func encodeColumn(dest interface{}) (recordedColumn, error) {
+	t := reflect.TypeOf(dest)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	s, err := stringTypeOf(reflect.Zero(t).Interface())
+	if err != nil {
+		return recordedColumn{}, err
+	}
+	typ, err := parseCQLType(s)
+	if err != nil {
+		return recordedColumn{}, err
+	}
+	value, err := gocql.Marshal(typ, dest)
+	return recordedColumn{Type: cqlTypeString(typ), Value: value}, err
+}
+
+// recordingScanner records the rows its Scanner scans, and the error it
+// returns so it can be told apart from errors returned by Cassandra
+type recordingScanner struct {
+	Scanner
+	iter *recordingIterator
+	err  error
+}
+
+func (s *recordingScanner) ScanIter(iter Scannable) (int, error) {
+	s.iter = &recordingIterator{Scannable: iter}
+	n, err := s.Scanner.ScanIter(s.iter)
+	s.err = err
+	return n, err
+}
+
+type recordingQueryExecutor struct {
+	qe QueryExecutor
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewRecordingQueryExecutor returns a QueryExecutor which runs every call on
+// qe, writing the statements, their consistency and the rows scanned from
+// their results to w, to be replayed by NewReplayQueryExecutor
+func NewRecordingQueryExecutor(qe QueryExecutor, w io.Writer) QueryExecutor {
+	return &recordingQueryExecutor{qe: qe, w: w}
+}
+
+func (qe *recordingQueryExecutor) record(call *recordedCall, err error) error {
+	if err != nil {
+		call.Error = err.Error()
+	}
+	b, merr := json.Marshal(call)
+	if merr != nil {
+		return merr
+	}
+	qe.mu.Lock()
+	defer qe.mu.Unlock()
+	if _, werr := qe.w.Write(append(b, '\n')); werr != nil {
+		return werr
+	}
+	return err
+}
+
+func (qe *recordingQueryExecutor) QueryWithOptions(opts Options, stmt Statement, scanner Scanner) error {
+	call, err := newRecordedCall(queryExpectation, opts, []Statement{stmt})
+	if err != nil {
+		return err
+	}
+	rs := &recordingScanner{Scanner: scanner}
+	err = qe.qe.QueryWithOptions(opts, stmt, rs)
+	if rs.iter != nil {
+		if rs.iter.err != nil {
+			return rs.iter.err
+		}
+		call.Rows = rs.iter.rows
+	}
+	if err != nil && err == rs.err {
+		// The error came from the scanner, which will return it again when
+		// the rows are replayed
+		if rerr := qe.record(call, nil); rerr != nil {
+			return rerr
+		}
+		return err
+	}
+	return qe.record(call, err)
+}
+
+func (qe *recordingQueryExecutor) Query(stmt Statement, scanner Scanner) error {
+	return qe.QueryWithOptions(Options{}, stmt, scanner)
+}
+
+func (qe *recordingQueryExecutor) ExecuteWithOptions(opts Options, stmt Statement) error {
+	call, err := newRecordedCall(executeExpectation, opts, []Statement{stmt})
+	if err != nil {
+		return err
+	}
+	return qe.record(call, qe.qe.ExecuteWithOptions(opts, stmt))
+}
+
+func (qe *recordingQueryExecutor) Execute(stmt Statement) error {
+	return qe.ExecuteWithOptions(Options{}, stmt)
+}
+
+func (qe *recordingQueryExecutor) ExecuteAtomically(stmts []Statement) error {
+	return qe.ExecuteAtomicallyWithOptions(Options{}, stmts)
+}
+
+func (qe *recordingQueryExecutor) ExecuteAtomicallyWithOptions(opts Options, stmts []Statement) error {
+	call, err := newRecordedCall(batchExpectation, opts, stmts)
+	if err != nil {
+		return err
+	}
+	return qe.record(call, qe.qe.ExecuteAtomicallyWithOptions(opts, stmts))
+}
+
+// replayIterator serves recorded rows, decoding each column into the value
+// it's scanned into
+type replayIterator struct {
+	rows         [][]recordedColumn
+	currRowIndex int
+	err          error
+}
+
+func (iter *replayIterator) Next() bool {
+	if iter.err != nil || iter.currRowIndex+1 >= len(iter.rows) {
+		return false
+	}
+	iter.currRowIndex++
+	return true
+}
+
+func (iter *replayIterator) Scan(dest ...interface{}) error {
+	if iter.currRowIndex < 0 {
+		return fmt.Errorf("called Scan without calling Next")
+	}
+	row := iter.rows[iter.currRowIndex]
+	if len(dest) != len(row) {
+		iter.err = fmt.Errorf("got %d pointers for unmarshalling %d recorded columns", len(dest), len(row))
+		return iter.err
+	}
+	for i, d := range dest {
+		if _, ok := d.(*IgnoreFieldType); ok {
+			continue
+		}
+		typ, err := parseCQLType(row[i].Type)
+		if err != nil {
+			iter.err = fmt.Errorf("could not replay a column of type %q: %v", row[i].Type, err)
+			return iter.err
+		}
+		if err := gocql.Unmarshal(typ, row[i].Value, d); err != nil {
+			iter.err = fmt.Errorf("could not replay a %s column into %T: %v", row[i].Type, d, err)
+			return iter.err
+		}
+	}
+	return nil
+}
+
+func (iter *replayIterator) Err() error {
+	return iter.err
+}
+
+type replayQueryExecutor struct {
+	mu    sync.Mutex
+	calls map[string][]*recordedCall
+}
+
+// NewReplayQueryExecutor returns a QueryExecutor which replays a recording
+// written by NewRecordingQueryExecutor. Each call is answered by the next
+// recorded call with the same statements, values and consistency, with the
+// recorded rows scanned into its result. Recorded errors are returned with
+// their message only. Calls which weren't recorded return an error
+func NewReplayQueryExecutor(r io.Reader) (QueryExecutor, error) {
+	qe := &replayQueryExecutor{calls: map[string][]*recordedCall{}}
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 64*1024*1024)
+	for line := 1; sc.Scan(); line++ {
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		call := &recordedCall{}
+		if err := json.Unmarshal(sc.Bytes(), call); err != nil {
+			return nil, fmt.Errorf("could not read recorded call on line %d: %v", line, err)
+		}
+		key := call.key()
+		qe.calls[key] = append(qe.calls[key], call)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return qe, nil
+}
+
+func (qe *replayQueryExecutor) replay(typ expectationType, opts Options, stmts []Statement) (*recordedCall, error) {
+	call, err := newRecordedCall(typ, opts, stmts)
+	if err != nil {
+		return nil, err
+	}
+	key := call.key()
+	qe.mu.Lock()
+	defer qe.mu.Unlock()
+	calls := qe.calls[key]
+	if len(calls) == 0 {
+		queries := make([]string, len(call.Statements))
+		for i, stmt := range call.Statements {
+			queries[i] = fmt.Sprintf("%q with values %s", stmt.Query, stmt.Values)
+		}
+		return nil, fmt.Errorf("gocassa: no recording left of %s %s", typ, strings.Join(queries, ", "))
+	}
+	qe.calls[key] = calls[1:]
+	if calls[0].Error != "" {
+		return nil, errors.New(calls[0].Error)
+	}
+	return calls[0], nil
+}
+
+func (qe *replayQueryExecutor) QueryWithOptions(opts Options, stmt Statement, scanner Scanner) error {
+	call, err := qe.replay(queryExpectation, opts, []Statement{stmt})
+	if err != nil {
+		return err
+	}
+	_, err = scanner.ScanIter(&replayIterator{rows: call.Rows, currRowIndex: -1})
+	return err
+}
+
+func (qe *replayQueryExecutor) Query(stmt Statement, scanner Scanner) error {
+	return qe.QueryWithOptions(Options{}, stmt, scanner)
+}
+
+func (qe *replayQueryExecutor) ExecuteWithOptions(opts Options, stmt Statement) error {
+	_, err := qe.replay(executeExpectation, opts, []Statement{stmt})
+	return err
+}
+
+func (qe *replayQueryExecutor) Execute(stmt Statement) error {
+	return qe.ExecuteWithOptions(Options{}, stmt)
+}
+
+func (qe *replayQueryExecutor) ExecuteAtomically(stmts []Statement) error {
+	return qe.ExecuteAtomicallyWithOptions(Options{}, stmts)
+}
+
+func (qe *replayQueryExecutor) ExecuteAtomicallyWithOptions(opts Options, stmts []Statement) error {
+	_, err := qe.replay(batchExpectation, opts, stmts)
+	return err
+}
@@ -0,0 +1,112 @@
+package gocassa
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/stretchr/testify/assert"
+)
+
+type recordedUser struct {
+	Id      gocql.UUID
+	Name    string
+	Created time.Time
+	Scores  map[string]int
+	Avatar  []byte
+	Manager *string
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	id, missing := gocql.TimeUUID(), gocql.TimeUUID()
+	manager := "Jane"
+	user := recordedUser{
+		Id:      id,
+		Name:    "Joe",
+		Created: time.Date(2020, 5, 1, 12, 30, 0, 123000000, time.UTC),
+		Scores:  map[string]int{"a": 1, "b": 2},
+		Avatar:  []byte{0, 1, 2},
+		Manager: &manager,
+	}
+
+	mock := NewMockQueryExecutor(t)
+	mock.ExpectQueryRegexp(`^SELECT .* FROM test.user_map_Id WHERE id = \?$`).WillReturnRows(user)
+	mock.ExpectQueryRegexp(`^SELECT .* FROM test.user_map_Id WHERE id = \?$`)
+	mock.ExpectExecuteRegexp(`^UPDATE test.user_map_Id`)
+	mock.ExpectExecuteRegexp(`^DELETE FROM test.user_map_Id`).WillReturnError(errors.New("timed out"))
+
+	var recording bytes.Buffer
+	tbl := NewConnection(NewRecordingQueryExecutor(mock, &recording)).KeySpace("test").MapTable("user", "Id", recordedUser{})
+	var got recordedUser
+	assert.NoError(t, tbl.Read(id, &got).Run())
+	assert.Equal(t, user, got)
+	assert.Equal(t, RowNotFoundError{}, tbl.Read(missing, &got).Run())
+	assert.NoError(t, tbl.Update(id, map[string]interface{}{"Name": "Jim"}).Run())
+	assert.EqualError(t, tbl.Delete(id).Run(), "timed out")
+	assert.Equal(t, 4, strings.Count(recording.String(), "\n"))
+
+	replay, err := NewReplayQueryExecutor(bytes.NewReader(recording.Bytes()))
+	assert.NoError(t, err)
+	tbl = NewConnection(replay).KeySpace("test").MapTable("user", "Id", recordedUser{})
+	got = recordedUser{}
+	assert.NoError(t, tbl.Read(id, &got).Run())
+	assert.True(t, user.Created.Equal(got.Created))
+	got.Created = user.Created
+	assert.Equal(t, user, got)
+	assert.Equal(t, RowNotFoundError{}, tbl.Read(missing, &got).Run())
+	assert.NoError(t, tbl.Update(id, map[string]interface{}{"Name": "Jim"}).Run())
+	assert.EqualError(t, tbl.Delete(id).Run(), "timed out")
+
+	err = tbl.Read(id, &got).Run()
+	assert.Error(t, err)
+	assert.True(t, strings.Contains(err.Error(), "no recording left of query"))
+}
+
+func TestRecordRawColumns(t *testing.T) {
+	type post struct {
+		Id      string
+		Created time.Time
+		Votes   map[string]int
+		Tags    []string
+	}
+	created := time.Date(2020, 5, 1, 12, 30, 0, 123000000, time.UTC)
+
+	// The columns are recorded with their CQL types, as they're returned by
+	// the executor
+	var recording bytes.Buffer
+	ks := NewConnection(NewRecordingQueryExecutor(NewInMemoryQueryExecutor(), &recording)).KeySpace("test")
+	tbl := ks.MapTable("post", "Id", post{})
+	assert.NoError(t, tbl.(TableChanger).CreateIfNotExist())
+	assert.NoError(t, tbl.Set(post{Id: "1", Created: created, Votes: map[string]int{"up": 2}}).Run())
+	var got post
+	assert.NoError(t, tbl.Read("1", &got).Run())
+	lines := strings.Split(strings.TrimSpace(recording.String()), "\n")
+	var call recordedCall
+	assert.NoError(t, json.Unmarshal([]byte(lines[len(lines)-1]), &call))
+	assert.Equal(t, [][]recordedColumn{{
+		{Type: "timestamp", Value: []byte{0, 0, 1, 0x71, 0xd0, 0x37, 0x09, 0xbb}},
+		{Type: "text", Value: []byte("1")},
+		{Type: "list<text>"},
+		{Type: "map<text, int>", Value: []byte{0, 0, 0, 1, 0, 0, 0, 2, 'u', 'p', 0, 0, 0, 4, 0, 0, 0, 2}},
+	}}, call.Rows)
+
+	// and decoded by gocql when replayed, into any type it can decode them to
+	type votes struct {
+		Id      string
+		Created int64
+		Votes   map[string]int64
+		Tags    []string
+	}
+	replay, err := NewReplayQueryExecutor(bytes.NewReader(recording.Bytes()))
+	assert.NoError(t, err)
+	ks = NewConnection(replay).KeySpace("test")
+	assert.NoError(t, ks.MapTable("post", "Id", post{}).(TableChanger).CreateIfNotExist())
+	assert.NoError(t, ks.MapTable("post", "Id", post{}).Set(post{Id: "1", Created: created, Votes: map[string]int{"up": 2}}).Run())
+	var v votes
+	assert.NoError(t, ks.MapTable("post", "Id", votes{}).Read("1", &v).Run())
+	assert.Equal(t, votes{Id: "1", Created: created.UnixNano() / int64(time.Millisecond), Votes: map[string]int64{"up": 2}, Tags: []string{}}, v)
+}
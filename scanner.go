@@ -29,6 +29,10 @@ func NewScanner(stmt SelectStatement, result interface{}) Scanner {
 }
 
 func (s *scanner) ScanIter(iter Scannable) (int, error) {
+	if s.stmt.JSON() {
+		return s.iterJSON(iter)
+	}
+
 	switch getNonPtrType(reflect.TypeOf(s.result)).Kind() {
 	case reflect.Slice:
 		return s.iterSlice(iter)
@@ -136,6 +140,65 @@ func (s *scanner) iterSingle(iter Scannable) (int, error) {
 	return 1, nil
 }
 
+// iterJSON decodes the rows of a SELECT JSON query. The result should either
+// be a pointer to a byte slice type (such as json.RawMessage) for a single
+// row, or a pointer to a slice of them
+func (s *scanner) iterJSON(iter Scannable) (int, error) {
+	resultType := getNonPtrType(reflect.TypeOf(s.result))
+	if resultType.Kind() != reflect.Slice {
+		return 0, fmt.Errorf("can only decode JSON into a byte slice or slice of byte slices, not %T", s.result)
+	}
+
+	err := allocateNilReference(s.result)
+	if err != nil {
+		return 0, err
+	}
+	resultVal := reflect.ValueOf(s.result)
+	for resultVal.Kind() == reflect.Ptr {
+		resultVal = resultVal.Elem()
+	}
+
+	switch {
+	case resultType.Elem().Kind() == reflect.Uint8:
+		var doc []byte
+		if !iter.Next() {
+			err := iter.Err()
+			if err == nil || err == gocql.ErrNotFound {
+				return 0, RowNotFoundError{}
+			}
+			return 0, err
+		}
+		if err := iter.Scan(&doc); err != nil {
+			return 0, err
+		}
+		resultVal.Set(reflect.ValueOf(doc).Convert(resultType))
+
+		s.rowsScanned++
+		return 1, nil
+	case resultType.Elem().Kind() == reflect.Slice && resultType.Elem().Elem().Kind() == reflect.Uint8:
+		if resultVal.Len() != 0 {
+			resultVal.Set(reflect.Zero(resultType))
+		}
+
+		rowsScanned := 0
+		for iter.Next() {
+			var doc []byte
+			if err := iter.Scan(&doc); err != nil {
+				return rowsScanned, err
+			}
+			resultVal.Set(reflect.Append(resultVal, reflect.ValueOf(doc).Convert(resultType.Elem())))
+			rowsScanned++
+		}
+		s.rowsScanned += rowsScanned
+
+		if err := iter.Err(); err != nil {
+			return rowsScanned, err
+		}
+		return rowsScanned, nil
+	}
+	return 0, fmt.Errorf("can only decode JSON into a byte slice or slice of byte slices, not %T", s.result)
+}
+
 // generatePtrs takes in a list of fields, the field map giving the type info
 // per field and the target struct value and generates a list of interface
 // pointers
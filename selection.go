@@ -0,0 +1,47 @@
+package gocassa
+
+// Selection identifies a column, or a single element of a collection column,
+// which is deleted by a column-level delete
+type Selection struct {
+	column string
+	// terms holds the map key or list index of the element being selected.
+	// It is empty when the whole column is selected
+	terms []interface{}
+}
+
+// Column provides the name of the selected column
+func (s Selection) Column() string {
+	return s.column
+}
+
+// Element provides the map key or list index of the selected element, and
+// whether an element (rather than the whole column) is selected at all
+func (s Selection) Element() (interface{}, bool) {
+	if len(s.terms) == 0 {
+		return nil, false
+	}
+	return s.terms[0], true
+}
+
+// Column selects a whole column
+func Column(column string) Selection {
+	return Selection{
+		column: column,
+	}
+}
+
+// MapKey selects the entry with the given key of a map column
+func MapKey(column string, key interface{}) Selection {
+	return Selection{
+		column: column,
+		terms:  toI(key),
+	}
+}
+
+// ListIndex selects the element at the given index of a list column
+func ListIndex(column string, index int) Selection {
+	return Selection{
+		column: column,
+		terms:  toI(index),
+	}
+}
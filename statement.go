@@ -36,6 +36,7 @@ type SelectStatement struct {
 	allowFiltering             bool                    // whether we should allow filtering
 	keys                       Keys                    // partition / clustering keys for table
 	clusteringSentinelsEnabled bool                    // whether we should enable our clustering sentinel
+	json                       bool                    // whether rows are returned as JSON documents
 }
 
 // NewSelectStatement adds the ability to craft a new SelectStatement
@@ -77,11 +78,14 @@ func (s SelectStatement) Values() []interface{} {
 // QueryAndValues returns the CQL query and any bind values
 func (s SelectStatement) QueryAndValues() (string, []interface{}) {
 	values := make([]interface{}, 0)
-	query := []string{
-		"SELECT",
+	query := []string{"SELECT"}
+	if s.JSON() {
+		query = append(query, "JSON")
+	}
+	query = append(query,
 		strings.Join(s.fields, ", "),
 		fmt.Sprintf("FROM %s.%s", s.Keyspace(), s.Table()),
-	}
+	)
 
 	whereCQL, whereValues := generateWhereCQL(s.Relations(), s.Keys(), s.clusteringSentinelsEnabled)
 	if whereCQL != "" {
@@ -187,6 +191,19 @@ func (s SelectStatement) WithClusteringSentinel(enabled bool) SelectStatement {
 	return s
 }
 
+// JSON returns whether each row is selected as a single JSON document
+// (SELECT JSON)
+func (s SelectStatement) JSON() bool {
+	return s.json
+}
+
+// WithJSON allows toggling of selecting each row as a single JSON document.
+// When enabled, the only column returned is JSONColumnName
+func (s SelectStatement) WithJSON(enabled bool) SelectStatement {
+	s.json = enabled
+	return s
+}
+
 // InsertStatement represents an INSERT query to write some data in C*
 // It satisfies the Statement interface
 type InsertStatement struct {
@@ -310,6 +327,139 @@ func (s InsertStatement) WithClusteringSentinel(enabled bool) InsertStatement {
 	return s
 }
 
+// JSONColumnName is the name of the single column returned by Cassandra for
+// a SELECT JSON query
+const JSONColumnName = "[json]"
+
+// JSONDefault determines how columns omitted from a JSON document are
+// treated when it is inserted
+type JSONDefault int
+
+const (
+	JSONDefaultNull  JSONDefault = iota // omitted columns are set to null (DEFAULT NULL)
+	JSONDefaultUnset                    // omitted columns are left untouched (DEFAULT UNSET)
+)
+
+func (d JSONDefault) String() string {
+	switch d {
+	case JSONDefaultNull:
+		return "NULL"
+	case JSONDefaultUnset:
+		return "UNSET"
+	default:
+		return ""
+	}
+}
+
+// InsertJSONStatement represents an INSERT ... JSON query to write a JSON
+// document as a row in C*. It satisfies the Statement interface
+type InsertJSONStatement struct {
+	keyspace    string        // name of the keyspace
+	table       string        // name of the table
+	document    []byte        // JSON document to be inserted
+	jsonDefault JSONDefault   // how omitted columns are treated
+	ttl         time.Duration // ttl of the row
+	keys        Keys          // partition / clustering keys for table
+}
+
+// NewInsertJSONStatement adds the ability to craft a new InsertJSONStatement
+// This function will error if the parameters passed in are invalid
+func NewInsertJSONStatement(keyspace, table string, document []byte, jsonDefault JSONDefault, keys Keys) (InsertJSONStatement, error) {
+	stmt := InsertJSONStatement{}
+	if keyspace == "" || table == "" {
+		return stmt, fmt.Errorf("keyspace and table can't be empty")
+	}
+
+	if len(document) < 1 {
+		return stmt, fmt.Errorf("document must be a JSON object to insert")
+	}
+
+	if len(keys.PartitionKeys) == 0 {
+		return stmt, fmt.Errorf("partition key should be supplied")
+	}
+
+	stmt.keyspace = keyspace
+	stmt.table = table
+	stmt.document = document
+	stmt.jsonDefault = jsonDefault
+	stmt.keys = keys
+	return stmt, nil
+}
+
+// Query provides the CQL query string for an INSERT INTO ... JSON query
+func (s InsertJSONStatement) Query() string {
+	query, _ := s.QueryAndValues()
+	return query
+}
+
+// Values provide the binding values for an INSERT INTO ... JSON query
+func (s InsertJSONStatement) Values() []interface{} {
+	_, values := s.QueryAndValues()
+	return values
+}
+
+// QueryAndValues returns the CQL query and any bind values
+func (s InsertJSONStatement) QueryAndValues() (string, []interface{}) {
+	query := []string{"INSERT INTO", fmt.Sprintf("%s.%s", s.Keyspace(), s.Table()), "JSON ?"}
+	values := []interface{}{string(s.document)}
+
+	if s.Default() == JSONDefaultUnset {
+		query = append(query, "DEFAULT UNSET")
+	}
+
+	// Determine if we need to set a TTL
+	if s.TTL() > time.Duration(0) {
+		query = append(query, "USING TTL ?")
+		values = append(values, int(s.TTL().Seconds()))
+	}
+
+	return strings.Join(query, " "), values
+}
+
+// Keyspace returns the name of the Keyspace for the statement
+func (s InsertJSONStatement) Keyspace() string {
+	return s.keyspace
+}
+
+// Table returns the name of the table for this statement
+func (s InsertJSONStatement) Table() string {
+	return s.table
+}
+
+// Document returns the JSON document to be inserted
+func (s InsertJSONStatement) Document() []byte {
+	return s.document
+}
+
+// Default returns how columns omitted from the document are treated
+func (s InsertJSONStatement) Default() JSONDefault {
+	return s.jsonDefault
+}
+
+// TTL returns the Time-To-Live for this row statement. A duration of 0
+// means there is no TTL
+func (s InsertJSONStatement) TTL() time.Duration {
+	if s.ttl < time.Duration(1) {
+		return time.Duration(0)
+	}
+	return s.ttl
+}
+
+// WithTTL allows setting of the time-to-live for this insert statement.
+// A duration of 0 means there is no TTL
+func (s InsertJSONStatement) WithTTL(ttl time.Duration) InsertJSONStatement {
+	if ttl < time.Duration(1) {
+		ttl = time.Duration(0)
+	}
+	s.ttl = ttl
+	return s
+}
+
+// Keys provides the Partition / Clustering keys defined by the table recipe
+func (s InsertJSONStatement) Keys() Keys {
+	return s.keys
+}
+
 // UpdateStatement represents an UPDATE query to update some data in C*
 // It satisfies the Statement interface
 type UpdateStatement struct {
@@ -441,11 +591,12 @@ func (s UpdateStatement) WithClusteringSentinel(enabled bool) UpdateStatement {
 // DeleteStatement represents a DELETE query to delete some data in C*
 // It satisfies the Statement interface
 type DeleteStatement struct {
-	keyspace             string     // name of the keyspace
-	table                string     // name of the table
-	where                []Relation // where filter clauses
-	keys                 Keys       // partition / clustering keys for table
-	allowClusterSentinel bool       // whether we should enable our clustering sentinel
+	keyspace             string      // name of the keyspace
+	table                string      // name of the table
+	columns              []Selection // columns to delete, empty means whole rows
+	where                []Relation  // where filter clauses
+	keys                 Keys        // partition / clustering keys for table
+	allowClusterSentinel bool        // whether we should enable our clustering sentinel
 }
 
 // NewDeleteStatement adds the ability to craft a new DeleteStatement
@@ -485,12 +636,21 @@ func (s DeleteStatement) Values() []interface{} {
 
 // QueryAndValues returns the CQL query and any bind values
 func (s DeleteStatement) QueryAndValues() (string, []interface{}) {
-	query := fmt.Sprintf("DELETE FROM %s.%s", s.Keyspace(), s.Table())
+	query := "DELETE"
+	values := make([]interface{}, 0)
+	if len(s.Columns()) > 0 {
+		selectionCQL, selectionValues := generateSelectionCQL(s.Columns())
+		query += " " + selectionCQL
+		values = append(values, selectionValues...)
+	}
+	query += fmt.Sprintf(" FROM %s.%s", s.Keyspace(), s.Table())
+
 	whereCQL, whereValues := generateWhereCQL(s.Relations(), s.Keys(), s.allowClusterSentinel)
 	if whereCQL != "" {
 		query += " WHERE " + whereCQL
+		values = append(values, whereValues...)
 	}
-	return query, whereValues
+	return query, values
 }
 
 // Keyspace returns the name of the Keyspace for the statement
@@ -509,6 +669,19 @@ func (s DeleteStatement) Relations() []Relation {
 	return s.where
 }
 
+// Columns returns the columns (or collection elements) to be deleted. If
+// empty, whole rows are deleted
+func (s DeleteStatement) Columns() []Selection {
+	return s.columns
+}
+
+// WithColumns allows the setting of the columns (or collection elements)
+// to be deleted. Passing no columns deletes whole rows
+func (s DeleteStatement) WithColumns(columns []Selection) DeleteStatement {
+	s.columns = columns
+	return s
+}
+
 // Keys provides the Partition / Clustering keys defined by the table recipe
 func (s DeleteStatement) Keys() Keys {
 	return s.keys
@@ -531,6 +704,19 @@ func (s cqlStatement) Query() string { return s.query }
 
 func (s cqlStatement) Values() []interface{} { return s.values }
 
+// batchStatement returns a statement running stmts in a logged batch
+func batchStatement(stmts []Statement) Statement {
+	queries := make([]string, 0, len(stmts)+2)
+	values := []interface{}{}
+	queries = append(queries, "BEGIN BATCH")
+	for _, stmt := range stmts {
+		queries = append(queries, stmt.Query()+";")
+		values = append(values, stmt.Values()...)
+	}
+	queries = append(queries, "APPLY BATCH")
+	return cqlStatement{query: strings.Join(queries, " "), values: values}
+}
+
 // noOpStatement represents a statement that doesn't perform any specific
 // query. It's used internally for testing, satisfies the Statement interface
 type noOpStatement struct{}
@@ -598,6 +784,24 @@ func generateRelationCQL(rel Relation, keys Keys, clusteringSentinelsEnabled boo
 	}
 }
 
+// generateSelectionCQL generates the CQL for the columns of a DELETE
+// statement. An expected output might look like:
+//	- "foo", {}
+//	- "foo, bar[?]", {"baz"}
+func generateSelectionCQL(selections []Selection) (string, []interface{}) {
+	clauses, values := make([]string, 0, len(selections)), make([]interface{}, 0)
+	for _, selection := range selections {
+		column := strings.ToLower(selection.Column())
+		if element, ok := selection.Element(); ok {
+			clauses = append(clauses, column+"[?]")
+			values = append(values, element)
+			continue
+		}
+		clauses = append(clauses, column)
+	}
+	return strings.Join(clauses, ", "), values
+}
+
 // generateOrderByCQL generates the CQL for the ORDER BY clause. An expected
 // output might look like:
 //	- foo ASC
@@ -36,6 +36,10 @@ func TestSelectStatement(t *testing.T) {
 	stmt = stmt.WithAllowFiltering(true)
 	assert.Equal(t, "SELECT a, b, c FROM ks1.tbl1 WHERE foo = ? AND baz IN ? ORDER BY a ASC LIMIT ? ALLOW FILTERING", stmt.Query())
 	assert.Equal(t, []interface{}{"bar", []interface{}{"bing"}, 10}, stmt.Values())
+
+	stmt = stmt.WithJSON(true)
+	assert.Equal(t, "SELECT JSON a, b, c FROM ks1.tbl1 WHERE foo = ? AND baz IN ? ORDER BY a ASC LIMIT ? ALLOW FILTERING", stmt.Query())
+	assert.Equal(t, []interface{}{"bar", []interface{}{"bing"}, 10}, stmt.Values())
 }
 
 func TestInsertStatement(t *testing.T) {
@@ -56,6 +60,26 @@ func TestInsertStatement(t *testing.T) {
 	assert.Equal(t, []interface{}{"b", "d", 3600}, stmt.Values())
 }
 
+func TestInsertJSONStatement(t *testing.T) {
+	keys := Keys{PartitionKeys: []string{"a"}}
+	_, err := NewInsertJSONStatement("ks1", "tbl1", nil, JSONDefaultNull, keys)
+	assert.Error(t, err)
+
+	stmt, err := NewInsertJSONStatement("ks1", "tbl1", []byte(`{"a":"b"}`), JSONDefaultNull, keys)
+	assert.NoError(t, err)
+	assert.Equal(t, "INSERT INTO ks1.tbl1 JSON ?", stmt.Query())
+	assert.Equal(t, []interface{}{`{"a":"b"}`}, stmt.Values())
+
+	stmt, err = NewInsertJSONStatement("ks1", "tbl1", []byte(`{"a":"b"}`), JSONDefaultUnset, keys)
+	assert.NoError(t, err)
+	assert.Equal(t, "INSERT INTO ks1.tbl1 JSON ? DEFAULT UNSET", stmt.Query())
+	assert.Equal(t, []interface{}{`{"a":"b"}`}, stmt.Values())
+
+	stmt = stmt.WithTTL(1 * time.Hour)
+	assert.Equal(t, "INSERT INTO ks1.tbl1 JSON ? DEFAULT UNSET USING TTL ?", stmt.Query())
+	assert.Equal(t, []interface{}{`{"a":"b"}`, 3600}, stmt.Values())
+}
+
 func TestUpdateStatement(t *testing.T) {
 	fieldMap := map[string]interface{}{"a": "b"}
 	relations := []Relation{Eq("foo", "bar")}
@@ -71,6 +95,12 @@ func TestUpdateStatement(t *testing.T) {
 	assert.Equal(t, "UPDATE ks1.tbl1 SET a = ?, c = c + ? WHERE foo = ?", stmt.Query())
 	assert.Equal(t, []interface{}{"b", []interface{}{"d"}, "bar"}, stmt.Values())
 
+	fieldMap = map[string]interface{}{"a": MapRemoveKeys("x", "y"), "c": SetRemove("d")}
+	stmt, err = NewUpdateStatement("ks1", "tbl1", fieldMap, relations, keys)
+	assert.NoError(t, err)
+	assert.Equal(t, "UPDATE ks1.tbl1 SET a = a - ?, c = c - ? WHERE foo = ?", stmt.Query())
+	assert.Equal(t, []interface{}{[]interface{}{"x", "y"}, []interface{}{"d"}, "bar"}, stmt.Values())
+
 	fieldMap = map[string]interface{}{"a": "b", "c": "d"}
 	stmt, err = NewUpdateStatement("ks1", "tbl1", fieldMap, relations, keys)
 	assert.NoError(t, err)
@@ -107,6 +137,10 @@ func TestDeleteStatement(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, "DELETE FROM ks1.tbl1 WHERE foo = ? AND baz IN ?", stmt.Query())
 	assert.Equal(t, []interface{}{"bar", []interface{}{"a", "b", "c"}}, stmt.Values())
+
+	stmt = stmt.WithColumns([]Selection{Column("Name"), MapKey("tags", "k"), ListIndex("items", 3)})
+	assert.Equal(t, "DELETE name, tags[?], items[?] FROM ks1.tbl1 WHERE foo = ? AND baz IN ?", stmt.Query())
+	assert.Equal(t, []interface{}{"k", 3, "bar", []interface{}{"a", "b", "c"}}, stmt.Values())
 }
 
 func TestStatementsWithSentinel(t *testing.T) {
@@ -4,6 +4,8 @@ import (
 	"reflect"
 	"strings"
 
+	"github.com/gocql/gocql"
+
 	r "github.com/stut/gocassa/reflect"
 )
 
@@ -22,6 +24,7 @@ type tableInfo struct {
 	fieldNames     map[string]struct{} // This is here only to check containment
 	fields         []string
 	fieldValues    []interface{}
+	created        *createdOrders
 }
 
 func newTableInfo(keyspace, name string, keys Keys, entity interface{}, fieldSource map[string]interface{}) *tableInfo {
@@ -31,6 +34,7 @@ func newTableInfo(keyspace, name string, keys Keys, entity interface{}, fieldSou
 		marshalSource: entity,
 		keys:          keys,
 		fieldSource:   fieldSource,
+		created:       newCreatedOrders(),
 	}
 	fields := make([]string, 0, len(fieldSource))
 	values := make([]interface{}, 0, len(fieldSource))
@@ -58,6 +62,43 @@ func toMap(i interface{}) (m map[string]interface{}, ok bool) {
 	return
 }
 
+// toSetMap is like toMap, but leaves out empty struct fields which are tagged
+// with the "omitempty" option. It should be used when converting rows to be
+// written, rather than row definitions
+func toSetMap(i interface{}) (m map[string]interface{}, ok bool) {
+	switch v := i.(type) {
+	case map[string]interface{}:
+		m, ok = v, true
+	default:
+		m, ok = r.StructToMapOmitEmpty(i)
+	}
+
+	return
+}
+
+// applyEmptyFieldMode returns a copy of the field map with any empty non-key
+// fields omitted or unset, depending on the mode
+func applyEmptyFieldMode(m map[string]interface{}, keys Keys, mode EmptyFieldMode) map[string]interface{} {
+	if mode == NullEmptyFields {
+		return m
+	}
+	keyFields := map[string]bool{}
+	for _, k := range append(append([]string{}, keys.PartitionKeys...), keys.ClusteringColumns...) {
+		keyFields[strings.ToLower(k)] = true
+	}
+	ret := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		if keyFields[strings.ToLower(k)] || !r.IsEmptyValue(v) {
+			ret[k] = v
+			continue
+		}
+		if mode == UnsetEmptyFields {
+			ret[k] = gocql.UnsetValue
+		}
+	}
+	return ret
+}
+
 func (t t) Where(rs ...Relation) Filter {
 	return filter{
 		t:  t,
@@ -130,10 +171,11 @@ func allFieldValuesAreNullable(fields map[string]interface{}) bool {
 }
 
 func (t t) Set(i interface{}) Op {
-	m, ok := toMap(i)
+	m, ok := toSetMap(i)
 	if !ok {
 		panic("SetWithOptions: Incompatible type")
 	}
+	m = applyEmptyFieldMode(m, t.info.keys, t.options.EmptyFields)
 	ks := append(t.info.keys.PartitionKeys, t.info.keys.ClusteringColumns...)
 	updFields := removeFields(m, ks)
 	if len(updFields) == 0 || allFieldValuesAreNullable(updFields) {
@@ -141,10 +183,21 @@ func (t t) Set(i interface{}) Op {
 	}
 	transformFields(updFields)
 	rels := relations(t.info.keys, m)
-	return newWriteOp(t.keySpace.qe, filter{
+	op := newWriteOp(t.keySpace.qe, filter{
 		t:  t,
 		rs: rels,
 	}, updateOpType, updFields)
+	op.set = true
+	return op
+}
+
+func (t t) SetJSON(document []byte, def JSONDefault) Op {
+	return &singleOp{
+		qe:          t.keySpace.qe,
+		f:           filter{t: t},
+		opType:      insertJSONOpType,
+		document:    document,
+		jsonDefault: def}
 }
 
 func (t t) Create() error {
@@ -175,6 +228,7 @@ func (t t) Recreate() error {
 }
 
 func (t t) CreateStatement() (Statement, error) {
+	t.info.created.record(t.Name(), t.options.ClusteringOrder)
 	return createTable(t.keySpace.name,
 		t.Name(),
 		t.info.keys.PartitionKeys,
@@ -189,6 +243,7 @@ func (t t) CreateStatement() (Statement, error) {
 }
 
 func (t t) CreateIfNotExistStatement() (Statement, error) {
+	t.info.created.record(t.Name(), t.options.ClusteringOrder)
 	return createTableIfNotExist(t.keySpace.name,
 		t.Name(),
 		t.info.keys.PartitionKeys,
@@ -202,6 +257,20 @@ func (t t) CreateIfNotExistStatement() (Statement, error) {
 	)
 }
 
+func (t t) tableOptions() Options {
+	return t.options
+}
+
+func (t t) rowDefinition() interface{} {
+	return t.info.marshalSource
+}
+
+// schema returns what queries on the table are validated against
+func (t t) schema() tableSchema {
+	order, known := t.info.created.get(t.Name())
+	return tableSchema{table: t.Name(), keys: t.info.keys, fieldSource: t.info.fieldSource, order: order, orderKnown: known}
+}
+
 func (t t) Name() string {
 	if len(t.options.TableName) > 0 {
 		return t.options.TableName
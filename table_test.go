@@ -1,6 +1,8 @@
 package gocassa
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"math/rand"
 	"strings"
@@ -356,6 +358,22 @@ func TestExecuteWithConsistency(t *testing.T) {
 	}
 }
 
+func TestMergeKeepsTableOptions(t *testing.T) {
+	one, quorum := gocql.One, gocql.Quorum
+	merged := Options{Consistency: &quorum, AllowFiltering: true}.Merge(Options{TTL: time.Hour})
+	assert.Equal(t, &quorum, merged.Consistency)
+	assert.True(t, merged.AllowFiltering)
+	assert.Equal(t, time.Hour, merged.TTL)
+	merged = merged.Merge(Options{Consistency: &one})
+	assert.Equal(t, &one, merged.Consistency)
+
+	conn := &connection{q: &OptionCheckingQE{opts: &Options{}}}
+	cs := conn.KeySpace("some ks").Table("customerWithFiltering", Customer{}, Keys{PartitionKeys: []string{"Id"}}).
+		WithOptions(Options{AllowFiltering: true})
+	stmt := cs.Where(Eq("Name", "Joe")).Read(&[]Customer{}).WithOptions(Options{Limit: 10}).GenerateStatement()
+	assert.Contains(t, stmt.Query(), "ALLOW FILTERING")
+}
+
 func TestExecuteWithNullableFields(t *testing.T) {
 	type UserBasic struct {
 		Id   	 string
@@ -416,6 +434,42 @@ func TestExecuteWithNullableFields(t *testing.T) {
 	assert.Equal(t, "UPDATE user.user_by_id SET metadata = ?, status = ? WHERE id = ? AND name = ?", qe.stmt.Query())
 }
 
+func TestExecuteWithEmptyFieldModes(t *testing.T) {
+	type UserProfile struct {
+		Id       string
+		Name     string
+		Nickname string `cql:",omitempty"`
+		Age      int
+	}
+
+	qe := &OptionCheckingQE{opts: &Options{}}
+	conn := &connection{q: qe}
+	ks := conn.KeySpace("user")
+	cs := ks.Table("user", UserProfile{}, Keys{PartitionKeys: []string{"Id"}}).
+		WithOptions(Options{TableName: "user_by_id"})
+
+	// omitempty fields are left out when empty, other fields are nulled
+	err := cs.Set(UserProfile{Id: "100", Name: "Moss"}).Run()
+	assert.NoError(t, err)
+	assert.Equal(t, "UPDATE user.user_by_id SET age = ?, name = ? WHERE id = ?", qe.stmt.Query())
+	assert.Equal(t, []interface{}{0, "Moss", "100"}, qe.stmt.Values())
+
+	err = cs.WithOptions(Options{EmptyFields: OmitEmptyFields}).Set(UserProfile{Id: "100", Name: "Moss"}).Run()
+	assert.NoError(t, err)
+	assert.Equal(t, "UPDATE user.user_by_id SET name = ? WHERE id = ?", qe.stmt.Query())
+	assert.Equal(t, []interface{}{"Moss", "100"}, qe.stmt.Values())
+
+	// with nothing but the key left the row is inserted
+	err = cs.WithOptions(Options{EmptyFields: OmitEmptyFields}).Set(UserProfile{Id: "100"}).Run()
+	assert.NoError(t, err)
+	assert.Equal(t, "INSERT INTO user.user_by_id (id) VALUES (?)", qe.stmt.Query())
+
+	err = cs.WithOptions(Options{EmptyFields: UnsetEmptyFields}).Set(UserProfile{Id: "100", Name: "Moss"}).Run()
+	assert.NoError(t, err)
+	assert.Equal(t, "UPDATE user.user_by_id SET age = ?, name = ? WHERE id = ?", qe.stmt.Query())
+	assert.Equal(t, []interface{}{gocql.UnsetValue, "Moss", "100"}, qe.stmt.Values())
+}
+
 func TestAllFieldValuesAreNullable(t *testing.T) {
 	// all collection types defined are nullable
 	assert.True(t, allFieldValuesAreNullable(map[string]interface{}{
@@ -446,3 +500,311 @@ func TestAllFieldValuesAreNullable(t *testing.T) {
 	// the empty field list is nullable
 	assert.True(t, allFieldValuesAreNullable(map[string]interface{}{}))
 }
+
+func TestMultimapListRange(t *testing.T) {
+	type Post struct {
+		UserId string
+		Id     int
+		Body   string
+	}
+
+	qe := &OptionCheckingQE{opts: &Options{}}
+	conn := &connection{q: qe}
+	ks := conn.KeySpace("blog")
+	mm := ks.MultimapTable("post", "UserId", "Id", Post{}).
+		WithOptions(Options{TableName: "post_by_user"})
+
+	var posts []Post
+	err := mm.ListRange("moss", ClusteringRange{From: 10, FromInclusive: true, To: 20}, 5, &posts).Run()
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT body, id, userid FROM blog.post_by_user WHERE userid = ? AND id >= ? AND id < ? ORDER BY Id ASC LIMIT ?", qe.stmt.Query())
+	assert.Equal(t, []interface{}{"moss", 10, 20, 5}, qe.stmt.Values())
+
+	err = mm.ListRange("moss", ClusteringRange{To: 20, ToInclusive: true, Direction: DESC}, 0, &posts).Run()
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT body, id, userid FROM blog.post_by_user WHERE userid = ? AND id <= ? ORDER BY Id DESC", qe.stmt.Query())
+	assert.Equal(t, []interface{}{"moss", 20}, qe.stmt.Values())
+
+	mk := ks.MultimapMultiKeyTable("post", []string{"UserId"}, []string{"Id"}, Post{}).
+		WithOptions(Options{TableName: "post_by_user_mk"})
+	err = mk.ListRange(map[string]interface{}{"UserId": "moss"}, ClusteringRange{
+		From:      map[string]interface{}{"Id": 10},
+		Direction: DESC,
+	}, 0, &posts).Run()
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT body, id, userid FROM blog.post_by_user_mk WHERE userid = ? AND id > ? ORDER BY Id DESC", qe.stmt.Query())
+
+	err = mk.ListRange(map[string]interface{}{"UserId": "moss"}, ClusteringRange{From: 10}, 0, &posts).Run()
+	assert.Error(t, err)
+
+	// With two id fields the range applies to the last one the bounds hold,
+	// and keeps the clustering order of the table or reverses all of it
+	type DailyPost struct {
+		UserId string
+		Day    int
+		Id     int
+		Body   string
+	}
+	daily := ks.MultimapMultiKeyTable("post", []string{"UserId"}, []string{"Day", "Id"}, DailyPost{}).
+		WithOptions(Options{TableName: "post_by_day", ClusteringOrder: []ClusteringOrderColumn{
+			{Column: "Day", Direction: DESC},
+			{Column: "Id", Direction: ASC},
+		}})
+	var dailyPosts []DailyPost
+	err = daily.ListRange(map[string]interface{}{"UserId": "moss"}, ClusteringRange{
+		From:          map[string]interface{}{"Day": 3, "Id": 10},
+		FromInclusive: true,
+		To:            map[string]interface{}{"Day": 3, "Id": 20},
+		Direction:     DESC,
+	}, 0, &dailyPosts).Run()
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT body, day, id, userid FROM blog.post_by_day WHERE userid = ? AND day = ? AND id >= ? AND id < ? ORDER BY Day ASC, Id DESC", qe.stmt.Query())
+	assert.Equal(t, []interface{}{"moss", 3, 10, 20}, qe.stmt.Values())
+
+	err = daily.ListRange(map[string]interface{}{"UserId": "moss"}, ClusteringRange{
+		To: map[string]interface{}{"Day": 3},
+	}, 0, &dailyPosts).Run()
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT body, day, id, userid FROM blog.post_by_day WHERE userid = ? AND day < ? ORDER BY Day ASC", qe.stmt.Query())
+
+	err = daily.ListRange(map[string]interface{}{"UserId": "moss"}, ClusteringRange{
+		From: map[string]interface{}{"Day": 3, "Id": 10},
+		To:   map[string]interface{}{"Day": 4, "Id": 20},
+	}, 0, &dailyPosts).Run()
+	assert.Error(t, err)
+}
+
+func TestMultimapDeleteRange(t *testing.T) {
+	type Post struct {
+		UserId string
+		Id     int
+		Body   string
+	}
+
+	qe := &OptionCheckingQE{opts: &Options{}}
+	conn := &connection{q: qe}
+	mm := conn.KeySpace("blog").MultimapTable("post", "UserId", "Id", Post{}).
+		WithOptions(Options{TableName: "post_by_user"})
+
+	assert.NoError(t, mm.DeleteRange("moss", 10, 20).Run())
+	assert.Equal(t, "DELETE FROM blog.post_by_user WHERE userid = ? AND id >= ? AND id < ?", qe.stmt.Query())
+	assert.Equal(t, []interface{}{"moss", 10, 20}, qe.stmt.Values())
+
+	assert.NoError(t, mm.DeleteRange("moss", nil, 20).Run())
+	assert.Equal(t, "DELETE FROM blog.post_by_user WHERE userid = ? AND id < ?", qe.stmt.Query())
+	assert.Equal(t, []interface{}{"moss", 20}, qe.stmt.Values())
+
+	assert.NoError(t, mm.DeleteRange("moss", 10, nil).Run())
+	assert.Equal(t, "DELETE FROM blog.post_by_user WHERE userid = ? AND id >= ?", qe.stmt.Query())
+	assert.Equal(t, []interface{}{"moss", 10}, qe.stmt.Values())
+}
+
+func TestEntityOpStatement(t *testing.T) {
+	type Order struct {
+		Id    string
+		Owner string
+	}
+
+	qe := &OptionCheckingQE{opts: &Options{}}
+	conn := &connection{q: qe}
+	orders := conn.KeySpace("shop").EntityTable("orders", "Id", Order{}, MultimapView("Owner"))
+
+	// The writes depend on the row stored, which isn't read until the op runs
+	op := orders.Set(Order{Id: "o1", Owner: "moss"})
+	assert.Equal(t, qe, op.QueryExecutor())
+	assert.Equal(t, noOpStatement{}, op.GenerateStatement())
+	assert.Nil(t, qe.stmt)
+
+	assert.Equal(t, qe, orders.Delete("o1").QueryExecutor())
+	assert.Equal(t, qe, orders.Update("o1", map[string]interface{}{"Owner": "jen"}).QueryExecutor())
+	assert.Nil(t, qe.stmt)
+}
+
+func TestFlakeSeriesListBefore(t *testing.T) {
+	type Event struct {
+		Id   string
+		Body string
+	}
+
+	qe := &OptionCheckingQE{opts: &Options{}}
+	conn := &connection{q: qe}
+	ks := conn.KeySpace("events")
+	fs := ks.FlakeSeriesTable("event", "Id", time.Hour, Event{}).
+		WithOptions(Options{TableName: "event_by_hour"})
+
+	var events []Event
+	now := time.Now()
+	stmt := fs.ListBefore(now, 20, &events).GenerateStatement()
+	assert.Equal(t, "SELECT body, id, bucket, flake_created FROM events.event_by_hour WHERE bucket = ? AND flake_created < ? ORDER BY flake_created DESC LIMIT ?", stmt.Query())
+	assert.Equal(t, []interface{}{bucket(now, time.Hour), now, 20}, stmt.Values())
+
+	stmt = fs.ListAfter(now, 20, &events).GenerateStatement()
+	assert.Equal(t, "SELECT body, id, bucket, flake_created FROM events.event_by_hour WHERE bucket = ? AND flake_created > ? ORDER BY flake_created ASC LIMIT ?", stmt.Query())
+}
+
+// Mock QueryExecutor that keeps track of the logged batches executed
+type batchRecordingQE struct {
+	OptionCheckingQE
+	batches [][]Statement
+}
+
+func (qe *batchRecordingQE) ExecuteAtomicallyWithOptions(opts Options, stmts []Statement) error {
+	qe.batches = append(qe.batches, stmts)
+	return nil
+}
+
+func TestWriteOutboxBatch(t *testing.T) {
+	qe := &batchRecordingQE{OptionCheckingQE: OptionCheckingQE{opts: &Options{}}}
+	conn := &connection{q: qe}
+	ks := conn.KeySpace("some ks")
+	ks.SetOutbox(OutboxTable(ks, "outbox", FixedBuckets(time.Hour)))
+	var events []WriteEvent
+	ks.AddWriteHook(func(ctx context.Context, e WriteEvent) error {
+		events = append(events, e)
+		return nil
+	})
+	cs := ks.MapTable("customerWithOutbox", "Id", Customer{})
+
+	if err := cs.Set(Customer{Id: "100", Name: "Joe"}).Run(); err != nil {
+		t.Fatal(err)
+	}
+	if err := cs.Delete("100").Add(cs.Delete("101")).RunAtomically(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(qe.batches) != 2 || len(qe.batches[0]) != 2 || len(qe.batches[1]) != 4 {
+		t.Fatalf("Expected the outbox writes in the batches, got %v", qe.batches)
+	}
+	if !strings.Contains(qe.batches[0][1].Query(), "outbox") {
+		t.Fatalf("Expected a write to the outbox, got %s", qe.batches[0][1].Query())
+	}
+	if len(events) != 3 || events[0].Type != SetWrite || events[2].Keys["Id"] != "101" {
+		t.Fatalf("Unexpected events %v", events)
+	}
+}
+
+func TestWriteEventOnlyBuiltForHooks(t *testing.T) {
+	qe := &OptionCheckingQE{opts: &Options{}}
+	conn := &connection{q: qe}
+	cs := conn.KeySpace("some ks").MapTable("customerJSON", "Id", Customer{})
+	tags := make([]string, 100)
+	for i := range tags {
+		tags[i] = fmt.Sprintf("tag%d", i)
+	}
+	small := []byte(`{"id": "100", "name": "Joe"}`)
+	large := []byte(fmt.Sprintf(`{"id": "100", "name": "Joe", "tags": ["%s"]}`, strings.Join(tags, `", "`)))
+
+	// Without hooks or an outbox, documents aren't decoded into events
+	allocs := func(tbl MapTable, doc []byte) float64 {
+		return testing.AllocsPerRun(10, func() { tbl.SetJSON(doc, JSONDefaultNull).Run() })
+	}
+	if allocs(cs, small) != allocs(cs, large) {
+		t.Fatalf("Expected the allocations of SetJSON not to depend on the document without hooks")
+	}
+
+	var events []WriteEvent
+	hooked := cs.WithOptions(Options{WriteHooks: []WriteHook{func(ctx context.Context, e WriteEvent) error {
+		events = append(events, e)
+		return nil
+	}}})
+	if err := hooked.SetJSON(small, JSONDefaultNull).Run(); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Values["name"] != "Joe" {
+		t.Fatalf("Unexpected events %v", events)
+	}
+}
+
+type post struct {
+	Id    string
+	Title string
+	Tags  []string
+}
+
+func TestPreflight(t *testing.T) {
+	qe := &OptionCheckingQE{opts: &Options{}}
+	conn := &connection{q: qe}
+	ks := conn.KeySpace("some ks")
+	cs := ks.Table("customerPreflight", Customer{}, Keys{
+		PartitionKeys:     []string{"Id"},
+		ClusteringColumns: []string{"Name"},
+	})
+
+	var keyErr KeyFieldError
+	err := cs.Where(Eq("Id", "100"), Eq("Name", "Joe")).Update(map[string]interface{}{"Name": "Jim"}).Run()
+	assert.True(t, errors.As(err, &keyErr))
+	assert.Equal(t, "Name", keyErr.Field)
+
+	var unknownErr UnknownFieldError
+	err = cs.Where(Eq("Id", "100")).Read(&[]Customer{}).WithOptions(Options{Select: []string{"Age"}}).Run()
+	assert.True(t, errors.As(err, &unknownErr))
+	assert.Equal(t, "Age", unknownErr.Field)
+
+	err = cs.Where(Eq("Id", "100"), Eq("Name", "Joe")).Update(map[string]interface{}{"Age": 1}).Run()
+	assert.True(t, errors.As(err, &unknownErr))
+
+	var modifierErr ModifierError
+	ps := ks.MapTable("postPreflight", "Id", post{})
+	err = ps.Update("100", map[string]interface{}{"Title": ListAppend("x")}).Run()
+	assert.True(t, errors.As(err, &modifierErr))
+	assert.Equal(t, ModifierListAppend, modifierErr.Modifier)
+	assert.NoError(t, ps.Update("100", map[string]interface{}{"Tags": ListAppend("x")}).Run())
+
+	var invalidErr InvalidRequestError
+	qe.stmt = nil
+	err = cs.Where(Eq("Name", "Joe")).Read(&[]Customer{}).Run()
+	assert.True(t, errors.As(err, &invalidErr))
+	assert.Nil(t, qe.stmt)
+
+	err = cs.Where(Eq("Id", "100"), Eq("Name", "Joe")).Read(&[]Customer{}).Run()
+	assert.NoError(t, err)
+	assert.NotNil(t, qe.stmt)
+
+	// SkipPreflight sends ops regardless, whether it's set on the op, the
+	// table or the ops of a batch
+	skip := Options{SkipPreflight: true}
+	qe.stmt = nil
+	assert.NoError(t, cs.Where(Eq("Name", "Joe")).Read(&[]Customer{}).WithOptions(skip).Run())
+	assert.Equal(t, "SELECT id, name FROM some ks.customerPreflight__Id__Name WHERE name = ?", qe.stmt.Query())
+	qe.stmt = nil
+	assert.NoError(t, cs.WithOptions(skip).Where(Eq("Id", "100"), Eq("Name", "Joe")).Update(map[string]interface{}{"Name": "Jim"}).Run())
+	assert.NotNil(t, qe.stmt)
+	invalidWrite := cs.Where(Eq("Id", "100"), Eq("Name", "Joe")).Update(map[string]interface{}{"Age": 1})
+	assert.Error(t, ps.Delete("100").Add(invalidWrite).RunAtomically())
+	assert.NoError(t, ps.Delete("100").Add(invalidWrite).WithOptions(skip).RunAtomically())
+
+	// Cassandra accepts IN on a clustering column followed by EQ on the next,
+	// and relations on columns with secondary indexes
+	type dailyPost struct {
+		Author string
+		Day    int
+		Id     string
+		Title  string
+	}
+	dps := ks.Table("dailyPostPreflight", dailyPost{}, Keys{
+		PartitionKeys:     []string{"Author"},
+		ClusteringColumns: []string{"Day", "Id"},
+	})
+	err = dps.Where(Eq("Author", "joe"), In("Day", 1, 2), Eq("Id", "100")).Read(&[]dailyPost{}).Run()
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT author, day, id, title FROM some ks.dailyPostPreflight__Author__Day_Id WHERE author = ? AND day IN ? AND id = ?", qe.stmt.Query())
+	err = dps.Where(Eq("Title", "Hello")).Read(&[]dailyPost{}).Run()
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT author, day, id, title FROM some ks.dailyPostPreflight__Author__Day_Id WHERE title = ?", qe.stmt.Query())
+
+	// ORDER BY is checked against the clustering order the table was created
+	// with, which options set on the table afterwards don't change
+	ascending := Options{ClusteringOrder: []ClusteringOrderColumn{{Column: "Day", Direction: ASC}, {Column: "Id", Direction: ASC}}}
+	dps = dps.WithOptions(Options{ClusteringOrder: []ClusteringOrderColumn{{Column: "Day", Direction: DESC}}})
+	assert.NoError(t, dps.Where(Eq("Author", "joe")).Read(&[]dailyPost{}).WithOptions(ascending).Run())
+	_, err = dps.CreateStatement()
+	assert.NoError(t, err)
+	err = dps.Where(Eq("Author", "joe")).Read(&[]dailyPost{}).WithOptions(ascending).Run()
+	assert.True(t, errors.As(err, &invalidErr))
+	err = dps.WithOptions(ascending).Where(Eq("Author", "joe")).Read(&[]dailyPost{}).Run()
+	assert.True(t, errors.As(err, &invalidErr))
+	err = dps.Where(Eq("Author", "joe")).Read(&[]dailyPost{}).WithOptions(Options{
+		ClusteringOrder: []ClusteringOrderColumn{{Column: "Day", Direction: ASC}, {Column: "Id", Direction: DESC}},
+	}).Run()
+	assert.NoError(t, err)
+}
@@ -1,16 +1,20 @@
 package gocassa
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
 	"time"
 )
 
 const bucketFieldName = "bucket"
 
 type timeSeriesT struct {
-	t          Table
-	timeField  string
-	idField    string
-	bucketSize time.Duration
+	t         Table
+	timeField string
+	idField   string
+	bucketer  Bucketer
+	shards    int // number of shards each bucket is split into, if sharded
 }
 
 func (o *timeSeriesT) Table() Table                        { return o.t }
@@ -24,70 +28,174 @@ func (o *timeSeriesT) CreateIfNotExistStatement() (Statement, error) {
 }
 
 func (o *timeSeriesT) Set(v interface{}) Op {
-	m, ok := toMap(v)
+	m, ok := toSetMap(v)
 	if !ok {
 		panic("Can't set: not able to convert")
 	}
 	if tim, ok := m[o.timeField].(time.Time); !ok {
 		panic("timeField is not actually a time.Time")
 	} else {
-		m[bucketFieldName] = bucket(tim, o.bucketSize)
+		m[bucketFieldName] = o.bucketer.Bucket(tim)
+	}
+	if o.shards > 0 {
+		m[shardFieldName] = shardOf(m[o.idField], o.shards)
 	}
 	return o.Table().Set(m)
 }
 
 func (o *timeSeriesT) Update(timeStamp time.Time, id interface{}, m map[string]interface{}) Op {
-	bucket := bucket(timeStamp, o.bucketSize)
-	return o.Table().
-		Where(Eq(bucketFieldName, bucket),
-			Eq(o.timeField, timeStamp),
-			Eq(o.idField, id)).
+	return o.rowFilter(timeStamp, id).
 		Update(m)
 }
 
 func (o *timeSeriesT) Delete(timeStamp time.Time, id interface{}) Op {
-	bucket := bucket(timeStamp, o.bucketSize)
-	return o.Table().
-		Where(Eq(bucketFieldName, bucket),
-			Eq(o.timeField, timeStamp),
-			Eq(o.idField, id)).
+	return o.rowFilter(timeStamp, id).
 		Delete()
 }
 
 func (o *timeSeriesT) Read(timeStamp time.Time, id, pointer interface{}) Op {
-	bucket := bucket(timeStamp, o.bucketSize)
-	return o.Table().
-		Where(Eq(bucketFieldName, bucket),
-			Eq(o.timeField, timeStamp),
-			Eq(o.idField, id)).
+	return o.rowFilter(timeStamp, id).
 		ReadOne(pointer)
 }
 
+func (o *timeSeriesT) SetJSON(document []byte, def JSONDefault) Op {
+	d, err := decodeJSONDocument(document)
+	if err != nil {
+		return errOp{err: err}
+	}
+	tim, err := d.time(o.timeField)
+	if err != nil {
+		return errOp{err: err}
+	}
+	if err := d.set(bucketFieldName, o.bucketer.Bucket(tim)); err != nil {
+		return errOp{err: err}
+	}
+	if o.shards > 0 {
+		id, err := d.value(o.idField)
+		if err != nil {
+			return errOp{err: err}
+		}
+		if err := d.set(shardFieldName, shardOf(id, o.shards)); err != nil {
+			return errOp{err: err}
+		}
+	}
+	if document, err = d.encode(); err != nil {
+		return errOp{err: err}
+	}
+	return o.Table().SetJSON(document, def)
+}
+
+func (o *timeSeriesT) ReadJSON(timeStamp time.Time, id interface{}, pointer *json.RawMessage) Op {
+	return o.rowFilter(timeStamp, id).
+		ReadOneJSON(pointer)
+}
+
+func (o *timeSeriesT) rowFilter(timeStamp time.Time, id interface{}) Filter {
+	rels := []Relation{
+		Eq(bucketFieldName, o.bucketer.Bucket(timeStamp)),
+		Eq(o.timeField, timeStamp),
+		Eq(o.idField, id),
+	}
+	if o.shards > 0 {
+		rels = append(rels, Eq(shardFieldName, shardOf(id, o.shards)))
+	}
+	return o.Table().Where(rels...)
+}
+
 func (o *timeSeriesT) List(startTime time.Time, endTime time.Time, pointerToASlice interface{}) Op {
+	return newBucketListOp(o.listFilter(startTime, endTime), o.Buckets(startTime), endTime, o.timeField, o.reader(), pointerToASlice)
+}
+
+func (o *timeSeriesT) ListJSON(startTime, endTime time.Time, pointerToASlice *[]json.RawMessage) Op {
+	return o.listFilter(startTime, endTime).
+		ReadJSON(pointerToASlice)
+}
+
+func (o *timeSeriesT) DeleteRange(startTime time.Time, endTime time.Time) Op {
+	return o.listFilter(startTime, endTime).
+		Delete()
+}
+
+func (o *timeSeriesT) listFilter(startTime time.Time, endTime time.Time) Filter {
 	buckets := []interface{}{}
 	for bucket := o.Buckets(startTime); bucket.Bucket().Before(endTime); bucket = bucket.Next() {
 		buckets = append(buckets, bucket.Bucket())
 	}
 	return o.Table().
-		Where(In(bucketFieldName, buckets...),
+		Where(o.withShards(
+			In(bucketFieldName, buckets...),
 			GTE(o.timeField, startTime),
-			LTE(o.timeField, endTime)).
-		Read(pointerToASlice)
+			LTE(o.timeField, endTime))...)
+}
+
+// withShards adds a relation covering all shards when the table is sharded
+func (o *timeSeriesT) withShards(rels ...Relation) []Relation {
+	if o.shards > 0 {
+		rels = append(rels, In(shardFieldName, allShards(o.shards)...))
+	}
+	return rels
+}
+
+func (o *timeSeriesT) reader(rels ...Relation) bucketReader {
+	return bucketReader{
+		relations: rels,
+		shards:    o.shards,
+		timeField: o.timeField,
+		idField:   o.idField,
+		rowTime: func(row map[string]interface{}) time.Time {
+			t, _ := row[o.timeField].(time.Time)
+			return t
+		},
+	}
+}
+
+func (o *timeSeriesT) Latest(n int, pointerToASlice interface{}) Op {
+	return o.ListBefore(tableClock(o.Table()).Now(), n, pointerToASlice)
+}
+
+func (o *timeSeriesT) ListBefore(t time.Time, n int, pointerToASlice interface{}) Op {
+	return newBucketWalkOp(o.Buckets(t), o.timeField, o.reader(LT(o.timeField, t)), DESC, n, pointerToASlice)
+}
+
+func (o *timeSeriesT) ListAfter(t time.Time, n int, pointerToASlice interface{}) Op {
+	return newBucketWalkOp(o.Buckets(t), o.timeField, o.reader(GT(o.timeField, t)), ASC, n, pointerToASlice)
 }
 
 func (o *timeSeriesT) Buckets(start time.Time) Buckets {
 	return bucketIter{
 		v:         start,
-		step:      o.bucketSize,
+		bucketer:  o.bucketer,
+		shards:    o.shards,
 		field:     bucketFieldName,
 		invariant: o.Table().Where()}
 }
 
+func (o *timeSeriesT) Follow(ctx context.Context, from time.Time, handler FollowHandler) error {
+	return follower{
+		table:     o.Table(),
+		buckets:   o.Buckets,
+		timeField: o.timeField,
+		reader:    o.reader(),
+		rowID: func(row map[string]interface{}) string {
+			return fmt.Sprint(row[o.idField])
+		},
+		after: func(id string, cp Checkpoint) (bool, error) {
+			for _, seen := range cp.IDs {
+				if seen == id {
+					return false, nil
+				}
+			}
+			return true, nil
+		},
+	}.follow(ctx, Checkpoint{Time: from}, handler)
+}
+
 func (o *timeSeriesT) WithOptions(opt Options) TimeSeriesTable {
 	return &timeSeriesT{
-		t:          o.Table().WithOptions(opt),
-		timeField:  o.timeField,
-		idField:    o.idField,
-		bucketSize: o.bucketSize,
+		t:         o.Table().WithOptions(opt),
+		timeField: o.timeField,
+		idField:   o.idField,
+		bucketer:  o.bucketer,
+		shards:    o.shards,
 	}
 }
@@ -0,0 +1,428 @@
+package gocassa
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// errCodeInvalid is the error code of Cassandra's Invalid errors
+const errCodeInvalid = 0x2200
+
+// InvalidRequestError is returned for queries Cassandra would reject as
+// invalid, with the message Cassandra gives. It implements gocql.RequestError
+type InvalidRequestError struct {
+	message string
+}
+
+func invalidRequest(format string, args ...interface{}) InvalidRequestError {
+	return InvalidRequestError{message: fmt.Sprintf(format, args...)}
+}
+
+func (e InvalidRequestError) Code() int {
+	return errCodeInvalid
+}
+
+func (e InvalidRequestError) Message() string {
+	return e.message
+}
+
+func (e InvalidRequestError) Error() string {
+	return e.message
+}
+
+// UnknownFieldError is returned for queries naming a field their table
+// doesn't have
+type UnknownFieldError struct {
+	Table, Field string
+}
+
+func (e UnknownFieldError) Error() string {
+	return fmt.Sprintf("Undefined column name %s", e.Field)
+}
+
+// KeyFieldError is returned for writes which would change or delete a field
+// of the primary key of a row
+type KeyFieldError struct {
+	Table, Field string
+	// Deletion is whether the field was to be deleted rather than updated
+	Deletion bool
+}
+
+func (e KeyFieldError) Error() string {
+	if e.Deletion {
+		return fmt.Sprintf("Invalid identifier %s for deletion (should not be a PRIMARY KEY part)", e.Field)
+	}
+	return fmt.Sprintf("PRIMARY KEY part %s found in SET part", e.Field)
+}
+
+var modifierNames = map[ModifierOp]string{
+	ModifierListPrepend:      "ListPrepend",
+	ModifierListAppend:       "ListAppend",
+	ModifierListSetAtIndex:   "ListSetAtIndex",
+	ModifierListRemove:       "ListRemove",
+	ModifierMapSetFields:     "MapSetFields",
+	ModifierMapSetField:      "MapSetField",
+	ModifierCounterIncrement: "CounterIncrement",
+	ModifierMapRemoveKeys:    "MapRemoveKeys",
+	ModifierSetRemove:        "SetRemove",
+}
+
+// ModifierError is returned for updates applying a modifier to a field of a
+// type it doesn't apply to
+type ModifierError struct {
+	Table, Field string
+	Modifier     ModifierOp
+	Type         reflect.Type
+}
+
+func (e ModifierError) Error() string {
+	return fmt.Sprintf("Modifier %s can't be applied to %s, of type %v", modifierNames[e.Modifier], e.Field, e.Type)
+}
+
+const filteringMessage = "Cannot execute this query as it might involve data filtering and thus may have unpredictable performance. If you want to execute this query despite the performance unpredictability, use ALLOW FILTERING"
+
+// restrictions groups relations by the lowercased name of their field, as
+// CQL identifiers aren't case sensitive
+func restrictions(rs []Relation) map[string][]Relation {
+	m := map[string][]Relation{}
+	for _, r := range rs {
+		f := strings.ToLower(r.Field())
+		m[f] = append(m[f], r)
+	}
+	return m
+}
+
+func isKeyField(keys Keys, field string) bool {
+	for _, k := range append(keys.PartitionKeys, keys.ClusteringColumns...) {
+		if strings.EqualFold(k, field) {
+			return true
+		}
+	}
+	return false
+}
+
+func onlyEqOrIn(rs []Relation) bool {
+	for _, r := range rs {
+		if r.Comparator() != CmpEquality && r.Comparator() != CmpIn {
+			return false
+		}
+	}
+	return true
+}
+
+// checkPartitionKey checks the restrictions on the partition key, which has
+// to be restricted entirely with EQ or IN relations if it is at all. It
+// returns whether it is restricted
+func checkPartitionKey(keys Keys, r map[string][]Relation) (bool, error) {
+	var missing []string
+	for _, k := range keys.PartitionKeys {
+		rels := r[strings.ToLower(k)]
+		if len(rels) == 0 {
+			missing = append(missing, k)
+		} else if !onlyEqOrIn(rels) {
+			return false, invalidRequest("Only EQ and IN relation are supported on the partition key (unless you use the token() function)")
+		}
+	}
+	if len(missing) == len(keys.PartitionKeys) {
+		return false, nil
+	} else if len(missing) > 0 {
+		return false, invalidRequest("Partition key parts: %s must be restricted as other parts are", strings.Join(missing, ", "))
+	}
+	return true, nil
+}
+
+// checkClusteringColumns checks that only a prefix of the clustering columns
+// is restricted, and that only the last column of it is restricted by
+// anything but EQ or IN
+func checkClusteringColumns(keys Keys, r map[string][]Relation) error {
+	unrestricted, nonEq := "", ""
+	for _, c := range keys.ClusteringColumns {
+		rels := r[strings.ToLower(c)]
+		switch {
+		case len(rels) == 0:
+			if unrestricted == "" {
+				unrestricted = c
+			}
+			continue
+		case unrestricted != "":
+			return invalidRequest("PRIMARY KEY column \"%s\" cannot be restricted as preceding column \"%s\" is not restricted", c, unrestricted)
+		case nonEq != "":
+			return invalidRequest("Clustering column \"%s\" cannot be restricted (preceding column \"%s\" is restricted by a non-EQ relation)", c, nonEq)
+		}
+		if !onlyEqOrIn(rels) {
+			nonEq = c
+		}
+	}
+	return nil
+}
+
+// checkOrder checks that an ordering follows the clustering columns, in the
+// order they're declared in, either in the declared directions or all of them
+// reversed. The directions aren't checked when the declared ones are unknown
+func checkOrder(keys Keys, order, declared []ClusteringOrderColumn, known bool) error {
+	declaredDirection := func(column string) ColumnDirection {
+		for _, o := range declared {
+			if strings.EqualFold(o.Column, column) {
+				return o.Direction
+			}
+		}
+		return ASC
+	}
+
+	var reversed bool
+	for i, o := range order {
+		if i >= len(keys.ClusteringColumns) || !strings.EqualFold(o.Column, keys.ClusteringColumns[i]) {
+			for _, c := range keys.ClusteringColumns {
+				if strings.EqualFold(o.Column, c) {
+					return invalidRequest("Order by currently only supports the ordering of columns following their declared order in the PRIMARY KEY")
+				}
+			}
+			return invalidRequest("Order by is currently only supported on the clustered columns of the PRIMARY KEY, got %s", o.Column)
+		}
+		r := o.Direction != declaredDirection(o.Column)
+		if known && i > 0 && r != reversed {
+			return invalidRequest("Unsupported order by relation")
+		}
+		reversed = r
+	}
+	return nil
+}
+
+// validateRead checks that Cassandra would serve a read of a table with the
+// given keys and clustering order (as declared when the table was created, if
+// that's known)
+func validateRead(keys Keys, rs []Relation, opts Options, declared []ClusteringOrderColumn, known bool) error {
+	r := restrictions(rs)
+	partitionKey, err := checkPartitionKey(keys, r)
+	if err != nil && !opts.AllowFiltering {
+		return err
+	}
+	if !opts.AllowFiltering {
+		// Relations on other columns may be served by secondary indexes,
+		// which tables don't know about, so they're left to Cassandra
+		for _, rel := range rs {
+			if !partitionKey && isKeyField(keys, rel.Field()) {
+				return invalidRequest(filteringMessage)
+			}
+		}
+		if err := checkClusteringColumns(keys, r); err != nil {
+			return err
+		}
+	}
+
+	if len(opts.ClusteringOrder) > 0 {
+		if !partitionKey {
+			return invalidRequest("ORDER BY is only supported when the partition key is restricted by an EQ or an IN.")
+		}
+		return checkOrder(keys, opts.ClusteringOrder, declared, known)
+	}
+	return nil
+}
+
+// validateWrite checks that Cassandra would accept an update or a delete of
+// the rows of a table with the given keys. Updates, and deletes of single
+// columns, have to name whole rows
+func validateWrite(keys Keys, rs []Relation, wholeRows bool) error {
+	for _, rel := range rs {
+		if !isKeyField(keys, rel.Field()) {
+			return invalidRequest("Non PRIMARY KEY columns found in where clause: %s", rel.Field())
+		}
+	}
+
+	r := restrictions(rs)
+	var missing []string
+	for _, k := range keys.PartitionKeys {
+		rels := r[strings.ToLower(k)]
+		if len(rels) == 0 {
+			missing = append(missing, k)
+		} else if !onlyEqOrIn(rels) {
+			return invalidRequest("Only EQ and IN relation are supported on the partition key (unless you use the token() function)")
+		}
+	}
+	if len(missing) > 0 {
+		return invalidRequest("Some partition key parts are missing: %s", strings.Join(missing, ", "))
+	}
+
+	if !wholeRows {
+		return checkClusteringColumns(keys, r)
+	}
+	for _, c := range keys.ClusteringColumns {
+		rels := r[strings.ToLower(c)]
+		if len(rels) == 0 {
+			missing = append(missing, c)
+		} else if !onlyEqOrIn(rels) {
+			return invalidRequest("Slice restrictions are not supported on the clustering columns in UPDATE statements")
+		}
+	}
+	if len(missing) > 0 {
+		return invalidRequest("Some clustering keys are missing: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// tableSchema is what queries on a table are validated against: its keys, and
+// the fields (with values of their types) of the rows it was created with
+type tableSchema struct {
+	table       string
+	keys        Keys
+	fieldSource map[string]interface{}
+	// order is the clustering order the table was created with, if orderKnown
+	// is set
+	order      []ClusteringOrderColumn
+	orderKnown bool
+}
+
+// createdOrders records the clustering order tables were created with, by
+// table name. The order of a table is only known once it's created, as it can
+// be set with WithOptions
+type createdOrders struct {
+	mu     sync.Mutex
+	orders map[string][]ClusteringOrderColumn
+}
+
+func newCreatedOrders() *createdOrders {
+	return &createdOrders{orders: map[string][]ClusteringOrderColumn{}}
+}
+
+func (c *createdOrders) record(table string, order []ClusteringOrderColumn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.orders[table] = order
+}
+
+func (c *createdOrders) get(table string) ([]ClusteringOrderColumn, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	order, ok := c.orders[table]
+	return order, ok
+}
+
+// field returns the field a column refers to, as CQL identifiers aren't case
+// sensitive
+func (s tableSchema) field(column string) (string, error) {
+	if _, ok := s.fieldSource[column]; ok {
+		return column, nil
+	}
+	for f := range s.fieldSource {
+		if strings.EqualFold(f, column) {
+			return f, nil
+		}
+	}
+	return "", UnknownFieldError{Table: s.table, Field: column}
+}
+
+func (s tableSchema) checkRelationFields(rs []Relation) error {
+	for _, r := range rs {
+		if _, err := s.field(r.Field()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s tableSchema) validateSelect(rs []Relation, opts Options) error {
+	if err := s.checkRelationFields(rs); err != nil {
+		return err
+	}
+	for _, f := range opts.Select {
+		if _, err := s.field(f); err != nil {
+			return err
+		}
+	}
+	return validateRead(s.keys, rs, opts, s.order, s.orderKnown)
+}
+
+func (s tableSchema) validateInsert(m map[string]interface{}) error {
+	rs := []Relation{}
+	for f, v := range m {
+		field, err := s.field(f)
+		if err != nil {
+			return err
+		}
+		if isKeyField(s.keys, field) {
+			rs = append(rs, Eq(field, v))
+		}
+	}
+	return validateWrite(s.keys, rs, true)
+}
+
+func (s tableSchema) validateUpdate(rs []Relation, m map[string]interface{}) error {
+	if err := s.checkRelationFields(rs); err != nil {
+		return err
+	}
+	if err := validateWrite(s.keys, rs, true); err != nil {
+		return err
+	}
+	for f, v := range m {
+		field, err := s.field(f)
+		if err != nil {
+			return err
+		}
+		if isKeyField(s.keys, field) {
+			return KeyFieldError{Table: s.table, Field: field}
+		}
+		if mod, ok := v.(Modifier); ok {
+			if err := s.checkModifier(field, mod); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s tableSchema) validateDelete(rs []Relation, columns []Selection) error {
+	if err := s.checkRelationFields(rs); err != nil {
+		return err
+	}
+	if _, err := s.deletableFields(columns); err != nil {
+		return err
+	}
+	return validateWrite(s.keys, rs, len(columns) > 0)
+}
+
+// deletableFields resolves the fields of the selected columns, making sure
+// none of them are part of the primary key
+func (s tableSchema) deletableFields(columns []Selection) ([]string, error) {
+	fields := make([]string, len(columns))
+	for i, column := range columns {
+		field, err := s.field(column.Column())
+		if err != nil {
+			return nil, err
+		}
+		if isKeyField(s.keys, field) {
+			return nil, KeyFieldError{Table: s.table, Field: column.Column(), Deletion: true}
+		}
+		fields[i] = field
+	}
+	return fields, nil
+}
+
+// checkModifier checks that a modifier applies to the type of a field. Lists
+// and sets are slices, and maps are maps
+func (s tableSchema) checkModifier(field string, m Modifier) error {
+	source := s.fieldSource[field]
+	if source == nil {
+		return nil
+	}
+	t := reflect.TypeOf(source)
+	isList := t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8
+	var ok bool
+	switch m.Operation() {
+	case ModifierListPrepend, ModifierListAppend, ModifierListSetAtIndex, ModifierListRemove:
+		ok = isList
+	case ModifierMapSetFields, ModifierMapSetField, ModifierMapRemoveKeys:
+		ok = t.Kind() == reflect.Map
+	case ModifierSetRemove:
+		ok = isList || t.Kind() == reflect.Map
+	case ModifierCounterIncrement:
+		ok = t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64
+	default:
+		ok = true
+	}
+	if !ok {
+		return ModifierError{Table: s.table, Field: field, Modifier: m.Operation(), Type: t}
+	}
+	return nil
+}